@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/IBM/sarama"
+)
+
+// apiKeyIncrementalAlterConfigs is the protocol API added in Kafka 2.3 to
+// change single configs of a resource.
+const apiKeyIncrementalAlterConfigs = 44
+
+var configOperationFlag string
+
+var configOperations = map[string]sarama.IncrementalAlterConfigsOperation{
+	"set":      sarama.IncrementalAlterConfigsOperationSet,
+	"delete":   sarama.IncrementalAlterConfigsOperationDelete,
+	"append":   sarama.IncrementalAlterConfigsOperationAppend,
+	"subtract": sarama.IncrementalAlterConfigsOperationSubtract,
+}
+
+func parseConfigOperation(op string) (sarama.IncrementalAlterConfigsOperation, error) {
+	operation, ok := configOperations[op]
+	if !ok {
+		return 0, fmt.Errorf("invalid --operation %q. Possible values: set, delete, append, subtract", op)
+	}
+	return operation, nil
+}
+
+// parseConfigArgs parses KEY=VALUE arguments into config changes. Values may
+// contain "=" and ",". For the delete operation the arguments are keys.
+func parseConfigArgs(args []string, op sarama.IncrementalAlterConfigsOperation) (map[string]sarama.IncrementalAlterConfigsEntry, error) {
+	entries := make(map[string]sarama.IncrementalAlterConfigsEntry, len(args))
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		key := kv[0]
+		if key == "" {
+			return nil, fmt.Errorf("invalid config %q, expected KEY=VALUE", arg)
+		}
+		if _, ok := entries[key]; ok {
+			return nil, fmt.Errorf("config %v is set more than once", key)
+		}
+
+		entry := sarama.IncrementalAlterConfigsEntry{Operation: op}
+		if op != sarama.IncrementalAlterConfigsOperationDelete {
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid config %q, expected KEY=VALUE", arg)
+			}
+			value := kv[1]
+			entry.Value = &value
+		}
+		entries[key] = entry
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no configs given")
+	}
+	return entries, nil
+}
+
+// splitConfigList splits a comma separated list of configs. A part without
+// "=" continues the list value of the previous config, so
+// "cleanup.policy=compact,delete" is a single config. For the delete
+// operation every part is a key.
+func splitConfigList(list string, op sarama.IncrementalAlterConfigsOperation) []string {
+	var configs []string
+	for _, part := range strings.Split(list, ",") {
+		if op != sarama.IncrementalAlterConfigsOperationDelete && !strings.Contains(part, "=") && len(configs) > 0 {
+			configs[len(configs)-1] += "," + part
+			continue
+		}
+		configs = append(configs, part)
+	}
+	return configs
+}
+
+// supportsIncrementalAlterConfigs asks a broker whether the cluster supports
+// IncrementalAlterConfigs.
+func supportsIncrementalAlterConfigs() (bool, error) {
+	cfg := getConfig()
+	client := getClientFromConfig(cfg)
+	defer client.Close()
+
+	brokers := client.Brokers()
+	if len(brokers) == 0 {
+		return false, fmt.Errorf("no brokers available")
+	}
+	broker := brokers[0]
+	if err := broker.Open(cfg); err != nil && err != sarama.ErrAlreadyConnected {
+		return false, err
+	}
+	resp, err := broker.ApiVersions(&sarama.ApiVersionsRequest{})
+	if err != nil {
+		return false, err
+	}
+	for _, key := range resp.ApiKeys {
+		if key.ApiKey == apiKeyIncrementalAlterConfigs {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getIncrementalAlterConfigs returns whether the cluster supports
+// IncrementalAlterConfigs, for alterConfigs.
+func getIncrementalAlterConfigs() bool {
+	incremental, err := supportsIncrementalAlterConfigs()
+	if err != nil {
+		errorExit("Unable to get supported API versions: %v", err)
+	}
+	return incremental
+}
+
+// alterConfigs changes configs of a topic or broker. IncrementalAlterConfigs
+// is used if the cluster supports it, as returned by
+// getIncrementalAlterConfigs, it changes only the given configs. Older
+// clusters only support AlterConfigs, which resets all other dynamic configs
+// of the resource, so a warning is printed.
+func alterConfigs(admin sarama.ClusterAdmin, incremental bool, resourceType sarama.ConfigResourceType, name string, entries map[string]sarama.IncrementalAlterConfigsEntry, validateOnly bool) error {
+	if incremental {
+		return admin.IncrementalAlterConfig(resourceType, name, entries, validateOnly)
+	}
+
+	values := make(map[string]*string, len(entries))
+	for key, entry := range entries {
+		if entry.Operation != sarama.IncrementalAlterConfigsOperationSet {
+			return fmt.Errorf("operations other than set require Kafka >=2.3.0")
+		}
+		values[key] = entry.Value
+	}
+	fmt.Fprintf(errWriter, "Warning: the cluster does not support incremental config changes (Kafka <2.3.0), all other dynamic configs of %v are reset to their defaults.\n", name)
+	return admin.AlterConfig(resourceType, name, values, validateOnly)
+}
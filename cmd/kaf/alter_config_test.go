@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfigArgs(t *testing.T) {
+	entries, err := parseConfigArgs([]string{"log.cleaner.threads=2", "ssl.cipher.suites=a,b", "sasl.jaas.config=x=y"}, sarama.IncrementalAlterConfigsOperationSet)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, "2", *entries["log.cleaner.threads"].Value)
+	require.Equal(t, "a,b", *entries["ssl.cipher.suites"].Value)
+	require.Equal(t, "x=y", *entries["sasl.jaas.config"].Value)
+	require.Equal(t, sarama.IncrementalAlterConfigsOperationSet, entries["sasl.jaas.config"].Operation)
+
+	entries, err = parseConfigArgs([]string{"retention.ms"}, sarama.IncrementalAlterConfigsOperationDelete)
+	require.NoError(t, err)
+	require.Nil(t, entries["retention.ms"].Value)
+	require.Equal(t, sarama.IncrementalAlterConfigsOperationDelete, entries["retention.ms"].Operation)
+
+	for _, args := range [][]string{{"retention.ms"}, {"=2"}, {"a=1", "a=2"}, {}} {
+		_, err := parseConfigArgs(args, sarama.IncrementalAlterConfigsOperationSet)
+		require.Error(t, err, args)
+	}
+
+	_, err = parseConfigOperation("replace")
+	require.Error(t, err)
+}
+
+func TestSplitConfigList(t *testing.T) {
+	require.Equal(t, []string{"retention.ms=1000", "cleanup.policy=compact,delete"}, splitConfigList("retention.ms=1000,cleanup.policy=compact,delete", sarama.IncrementalAlterConfigsOperationSet))
+	require.Equal(t, []string{"retention.ms", "cleanup.policy"}, splitConfigList("retention.ms,cleanup.policy", sarama.IncrementalAlterConfigsOperationDelete))
+}
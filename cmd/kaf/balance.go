@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/IBM/sarama"
+)
+
+// leaderSkewThreshold is the ratio of the most leaders on one broker to the
+// average above which leadership is reported as imbalanced.
+const leaderSkewThreshold = 1.2
+
+// balanceBarWidth is the width of the longest histogram bar.
+const balanceBarWidth = 30
+
+// brokerLoad is the number of partition leaders and replicas on a broker.
+type brokerLoad struct {
+	Broker   int32
+	Leaders  int
+	Replicas int
+	// Preferred is the number of partitions that prefer the broker as
+	// leader, because it is their first replica.
+	Preferred int
+}
+
+// clusterBalance summarizes how leadership and replicas are distributed.
+type clusterBalance struct {
+	Brokers []brokerLoad
+	// NotPreferred is the number of partitions not led by their preferred
+	// leader.
+	NotPreferred int
+	// Leaderless is the number of partitions without a leader.
+	Leaderless int
+}
+
+// computeBalance counts leaders and replicas of the partitions of topics per
+// broker. Brokers without partitions are included with zero counts.
+func computeBalance(brokerIDs []int32, topics []*sarama.TopicMetadata) clusterBalance {
+	loads := make(map[int32]*brokerLoad, len(brokerIDs))
+	load := func(id int32) *brokerLoad {
+		l, ok := loads[id]
+		if !ok {
+			l = &brokerLoad{Broker: id}
+			loads[id] = l
+		}
+		return l
+	}
+	for _, id := range brokerIDs {
+		load(id)
+	}
+
+	var balance clusterBalance
+	for _, topic := range topics {
+		for _, p := range topic.Partitions {
+			if p.Leader < 0 {
+				balance.Leaderless++
+			} else {
+				load(p.Leader).Leaders++
+			}
+			for _, replica := range p.Replicas {
+				load(replica).Replicas++
+			}
+			if len(p.Replicas) > 0 {
+				load(p.Replicas[0]).Preferred++
+				if p.Leader >= 0 && p.Leader != p.Replicas[0] {
+					balance.NotPreferred++
+				}
+			}
+		}
+	}
+
+	for _, l := range loads {
+		balance.Brokers = append(balance.Brokers, *l)
+	}
+	sort.Slice(balance.Brokers, func(i, j int) bool { return balance.Brokers[i].Broker < balance.Brokers[j].Broker })
+	return balance
+}
+
+// leaderSkew returns the ratio of the most leaders on one broker to the
+// average number of leaders per broker, 1 is perfectly balanced.
+func (b clusterBalance) leaderSkew() float64 {
+	if len(b.Brokers) == 0 {
+		return 1
+	}
+	var total, max int
+	for _, l := range b.Brokers {
+		total += l.Leaders
+		if l.Leaders > max {
+			max = l.Leaders
+		}
+	}
+	if total == 0 {
+		return 1
+	}
+	return float64(max) / (float64(total) / float64(len(b.Brokers)))
+}
+
+// imbalanced reports whether the broker leads noticeably more partitions than
+// the average.
+func (b clusterBalance) imbalanced(l brokerLoad) bool {
+	var total int
+	for _, other := range b.Brokers {
+		total += other.Leaders
+	}
+	mean := float64(total) / float64(len(b.Brokers))
+	// A single partition more than the average is unavoidable if the
+	// partitions do not divide evenly.
+	return float64(l.Leaders) > mean*leaderSkewThreshold && float64(l.Leaders)-mean > 1
+}
+
+func writeBalance(w io.Writer, b clusterBalance) {
+	var maxLeaders int
+	for _, l := range b.Brokers {
+		if l.Leaders > maxLeaders {
+			maxLeaders = l.Leaders
+		}
+	}
+
+	var anyImbalanced bool
+	tw := tabwriter.NewWriter(w, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	if !noHeaderFlag {
+		fmt.Fprintf(tw, "BROKER\tLEADERS\tPREFERRED\tREPLICAS\tLEADER DISTRIBUTION\t\n")
+	}
+	for _, l := range b.Brokers {
+		bar := ""
+		if maxLeaders > 0 {
+			bar = strings.Repeat("#", l.Leaders*balanceBarWidth/maxLeaders)
+		}
+		if b.imbalanced(l) {
+			anyImbalanced = true
+			bar += " (imbalanced)"
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t\n", l.Broker, l.Leaders, l.Preferred, l.Replicas, bar)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nLeader skew: %.2f (most leaders on one broker / average)\n", b.leaderSkew())
+	if b.Leaderless > 0 {
+		fmt.Fprintf(w, "%v partitions have no leader.\n", b.Leaderless)
+	}
+	if b.NotPreferred > 0 {
+		fmt.Fprintf(w, "%v partitions are not led by their preferred leader. Run a preferred leader election to move leadership back, e.g. kafka-leader-election.sh --election-type preferred --all-topic-partitions\n", b.NotPreferred)
+	} else if anyImbalanced {
+		fmt.Fprintf(w, "All partitions are led by their preferred leader, the skew comes from the replica assignment. Reassign partitions to spread preferred leaders across brokers.\n")
+	}
+}
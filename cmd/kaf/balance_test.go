@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeBalance(t *testing.T) {
+	topics := []*sarama.TopicMetadata{{
+		Name: "orders",
+		Partitions: []*sarama.PartitionMetadata{
+			{ID: 0, Leader: 1, Replicas: []int32{1, 2}},
+			{ID: 1, Leader: 1, Replicas: []int32{2, 1}},
+			{ID: 2, Leader: 1, Replicas: []int32{1, 3}},
+			{ID: 3, Leader: 1, Replicas: []int32{3, 1}},
+			{ID: 4, Leader: -1, Replicas: []int32{2, 3}},
+		},
+	}}
+
+	balance := computeBalance([]int32{3, 1, 2, 4}, topics)
+	require.Equal(t, []brokerLoad{
+		{Broker: 1, Leaders: 4, Replicas: 4, Preferred: 2},
+		{Broker: 2, Leaders: 0, Replicas: 3, Preferred: 2},
+		{Broker: 3, Leaders: 0, Replicas: 3, Preferred: 1},
+		{Broker: 4},
+	}, balance.Brokers)
+	require.Equal(t, 2, balance.NotPreferred)
+	require.Equal(t, 1, balance.Leaderless)
+	require.Equal(t, 4.0, balance.leaderSkew())
+	require.True(t, balance.imbalanced(balance.Brokers[0]))
+	require.False(t, balance.imbalanced(balance.Brokers[1]))
+
+	var out bytes.Buffer
+	writeBalance(&out, balance)
+	require.Contains(t, out.String(), "(imbalanced)")
+	require.Contains(t, out.String(), "2 partitions are not led by their preferred leader")
+	require.Contains(t, out.String(), "1 partitions have no leader")
+
+	even := computeBalance([]int32{1, 2}, []*sarama.TopicMetadata{{
+		Partitions: []*sarama.PartitionMetadata{
+			{Leader: 1, Replicas: []int32{1, 2}},
+			{Leader: 2, Replicas: []int32{2, 1}},
+			{Leader: 1, Replicas: []int32{1, 2}},
+		},
+	}})
+	require.False(t, even.imbalanced(even.Brokers[0]))
+}
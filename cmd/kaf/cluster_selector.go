@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/birdayz/kaf/pkg/config"
+)
+
+var clusterSelectorFlag string
+
+// selectorCommands are the read-only commands --cluster-selector runs.
+var selectorCommands = map[string]bool{
+	"kaf ping":           true,
+	"kaf version":        true,
+	"kaf topics":         true,
+	"kaf topic ls":       true,
+	"kaf topic describe": true,
+	"kaf topic lag":      true,
+	"kaf groups":         true,
+	"kaf group ls":       true,
+	"kaf group describe": true,
+	"kaf group members":  true,
+	"kaf group offsets":  true,
+	"kaf nodes":          true,
+	"kaf node ls":        true,
+	"kaf node balance":   true,
+	"kaf node logdirs":   true,
+}
+
+// clusterSelectorParallelism is the number of clusters a command runs
+// against at the same time.
+const clusterSelectorParallelism = 8
+
+// clusterRun is the output of a command run against one cluster.
+type clusterRun struct {
+	cluster string
+	stdout  []byte
+	stderr  []byte
+	// err is set if the command failed.
+	err error
+}
+
+// runAcrossClusters runs the command against every cluster matching
+// --cluster-selector and exits. Every cluster is a separate kaf process with
+// --cluster, so a failing cluster does not stop the others.
+func runAcrossClusters(cmd *cobra.Command, args []string) {
+	// Errors are not about the current cluster.
+	currentCluster = nil
+
+	if !selectorCommands[cmd.CommandPath()] {
+		commands := make([]string, 0, len(selectorCommands))
+		for command := range selectorCommands {
+			commands = append(commands, strings.TrimPrefix(command, "kaf "))
+		}
+		sort.Strings(commands)
+		errorExit("--cluster-selector only runs read-only commands: %v", strings.Join(commands, ", "))
+	}
+	if clusterOverride != "" || brokersFlag != nil || connectionFlagsSet() {
+		errorExit("--cluster-selector cannot be combined with --cluster, --brokers, --sasl-* or --tls")
+	}
+	for _, name := range []string{"watch", "follow"} {
+		// Each cluster's output is printed once its command exited.
+		if flag := cmd.Flags().Lookup(name); flag != nil && flag.Changed {
+			errorExit("--cluster-selector cannot be combined with --%v, the commands would never exit", name)
+		}
+	}
+	if cmd.CommandPath() == "kaf ping" && len(args) > 0 {
+		errorExit("--cluster-selector cannot be combined with the CLUSTER argument of ping")
+	}
+	if cfgFile == config.StdinPath {
+		errorExit("--cluster-selector cannot read the config from stdin, every cluster reads it again")
+	}
+	selector, err := config.ParseSelector(clusterSelectorFlag)
+	if err != nil {
+		errorExit("Invalid --cluster-selector: %v", err)
+	}
+	clusters := cfg.SelectClusters(selector)
+	if len(clusters) == 0 {
+		errorExit("No cluster in %v has labels matching --cluster-selector %v", cfg.Path(), clusterSelectorFlag)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		errorExit("Unable to run kaf for every cluster: %v", err)
+	}
+
+	runs := make([]clusterRun, len(clusters))
+	limit := make(chan struct{}, clusterSelectorParallelism)
+	var wg sync.WaitGroup
+	for i, cluster := range clusters {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			var stdout, stderr bytes.Buffer
+			child := exec.Command(executable, clusterArgs(os.Args[1:], name)...)
+			child.Stdout, child.Stderr = &stdout, &stderr
+			err := child.Run()
+			runs[i] = clusterRun{cluster: name, stdout: stdout.Bytes(), stderr: stderr.Bytes(), err: err}
+		}(i, cluster.Name)
+	}
+	wg.Wait()
+
+	if err := writeClusterRuns(outWriter, errWriter, runs); err != nil {
+		errorExit("Failed to write results: %v", err)
+	}
+	var failed []string
+	for _, run := range runs {
+		if run.err != nil {
+			failed = append(failed, run.cluster)
+		}
+	}
+	if len(failed) > 0 {
+		errorExit("Failed on %v of %v clusters: %v", len(failed), len(runs), strings.Join(failed, ", "))
+	}
+	os.Exit(0)
+}
+
+// clusterArgs returns the arguments of the kaf run against cluster: those of
+// kaf without --cluster-selector, with --cluster before a -- separator.
+func clusterArgs(args []string, cluster string) []string {
+	var filtered []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--":
+			filtered = append(filtered, "--cluster", cluster)
+			return append(filtered, args[i:]...)
+		case args[i] == "--cluster-selector":
+			i++
+		case strings.HasPrefix(args[i], "--cluster-selector="):
+		default:
+			filtered = append(filtered, args[i])
+		}
+	}
+	return append(filtered, "--cluster", cluster)
+}
+
+// clusterResult is the JSON object printed per cluster with --output json.
+// Result is the JSON output of the command, Error and Code those of its JSON
+// error object if it failed.
+type clusterResult struct {
+	Cluster string          `json:"cluster"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Code    string          `json:"code,omitempty"`
+}
+
+// writeClusterRuns prints the output of every cluster under a heading with
+// its name, or as a JSON object per cluster with --output json. Messages on
+// stderr are prefixed with the cluster name.
+func writeClusterRuns(stdout, stderr io.Writer, runs []clusterRun) error {
+	for i, run := range runs {
+		if outputFormat == OutputFormatJSON {
+			result := clusterResult{Cluster: run.cluster, Result: jsonOutput(run.stdout)}
+			diagnostics := run.stderr
+			if run.err != nil {
+				result.Error, result.Code = runError(run)
+				diagnostics = nil
+			}
+			b, err := json.Marshal(result)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(stdout, string(b)); err != nil {
+				return err
+			}
+			writePrefixed(stderr, run.cluster, diagnostics)
+			continue
+		}
+
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		fmt.Fprintf(stdout, "=== %v\n", run.cluster)
+		if _, err := stdout.Write(run.stdout); err != nil {
+			return err
+		}
+		writePrefixed(stderr, run.cluster, run.stderr)
+	}
+	return nil
+}
+
+// jsonOutput returns the JSON printed by a command, as an array if it printed
+// JSON lines, and as a JSON string if it printed something else.
+func jsonOutput(out []byte) json.RawMessage {
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return nil
+	}
+	if json.Valid(out) {
+		return out
+	}
+	lines := bytes.Split(out, []byte("\n"))
+	for _, line := range lines {
+		if !json.Valid(line) {
+			b, _ := json.Marshal(string(out))
+			return b
+		}
+	}
+	return append(append([]byte("["), bytes.Join(lines, []byte(","))...), ']')
+}
+
+// runError returns the message and code of the JSON error object a failed
+// command printed, or its stderr.
+func runError(run clusterRun) (message string, code string) {
+	stderr := bytes.TrimSpace(run.stderr)
+	if i := bytes.LastIndexByte(stderr, '\n'); i >= 0 {
+		stderr = stderr[i+1:]
+	}
+	var obj jsonError
+	if err := json.Unmarshal(stderr, &obj); err == nil && obj.Error != "" {
+		return obj.Error, obj.Code
+	}
+	if len(stderr) > 0 {
+		return string(stderr), errorCodeGeneric
+	}
+	return run.err.Error(), errorCodeGeneric
+}
+
+func writePrefixed(w io.Writer, prefix string, text []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	for scanner.Scan() {
+		fmt.Fprintf(w, "%v: %v\n", prefix, scanner.Text())
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterArgs(t *testing.T) {
+	require.Equal(t, []string{"group", "ls", "--output", "json", "--cluster", "prod"}, clusterArgs([]string{"--cluster-selector", "env=prod", "group", "ls", "--output", "json"}, "prod"))
+	require.Equal(t, []string{"ping", "--cluster", "prod"}, clusterArgs([]string{"ping", "--cluster-selector=env=prod"}, "prod"))
+	require.Equal(t, []string{"topic", "describe", "--cluster", "prod", "--", "--cluster-selector"}, clusterArgs([]string{"topic", "describe", "--", "--cluster-selector"}, "prod"))
+}
+
+func TestJSONOutput(t *testing.T) {
+	require.Nil(t, jsonOutput([]byte("\n")))
+	require.JSONEq(t, `{"brokers":3}`, string(jsonOutput([]byte(`{"brokers":3}`+"\n"))))
+	require.JSONEq(t, `[{"offset":1},{"offset":2}]`, string(jsonOutput([]byte("{\"offset\":1}\n{\"offset\":2}\n"))))
+	require.JSONEq(t, `"NAME\tSTATE\nbilling\tStable"`, string(jsonOutput([]byte("NAME\tSTATE\nbilling\tStable\n"))))
+}
+
+func TestWriteClusterRuns(t *testing.T) {
+	defer func(format OutputFormat) { outputFormat = format }(outputFormat)
+	failure := errors.New("exit status 1")
+	runs := []clusterRun{
+		{cluster: "prod-eu", stdout: []byte("billing\n"), stderr: []byte("Warning: slow\n")},
+		{cluster: "prod-us", stderr: []byte("Unable to get cluster admin: connection refused\n"), err: failure},
+	}
+
+	var stdout, stderr bytes.Buffer
+	outputFormat = OutputFormatDefault
+	require.NoError(t, writeClusterRuns(&stdout, &stderr, runs))
+	require.Equal(t, "=== prod-eu\nbilling\n\n=== prod-us\n", stdout.String())
+	require.Equal(t, "prod-eu: Warning: slow\nprod-us: Unable to get cluster admin: connection refused\n", stderr.String())
+
+	stdout.Reset()
+	stderr.Reset()
+	outputFormat = OutputFormatJSON
+	runs[0].stdout = []byte(`{"latencyMs":3}` + "\n")
+	runs[1].stderr = []byte(`{"error":"Ping of cluster prod-us failed","code":"connection","cluster":"prod-us"}` + "\n")
+	require.NoError(t, writeClusterRuns(&stdout, &stderr, runs))
+	require.Equal(t, `{"cluster":"prod-eu","result":{"latencyMs":3}}
+{"cluster":"prod-us","error":"Ping of cluster prod-us failed","code":"connection"}
+`, stdout.String())
+	require.Equal(t, "prod-eu: Warning: slow\n", stderr.String())
+
+	message, code := runError(clusterRun{err: failure})
+	require.Equal(t, "exit status 1", message)
+	require.Equal(t, errorCodeGeneric, code)
+}
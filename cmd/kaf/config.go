@@ -16,14 +16,18 @@ import (
 var (
 	flagEhConnString  string
 	flagBrokerVersion string
+	flagShowSecrets   bool
 )
 
 func init() {
 	configCmd.AddCommand(configImportCmd)
+	configCmd.AddCommand(configImportJavaCmd)
+	configCmd.AddCommand(configExportJavaCmd)
 	configCmd.AddCommand(configUseCmd)
 	configCmd.AddCommand(configLsCmd)
 	configCmd.AddCommand(configAddClusterCmd)
 	configCmd.AddCommand(configRemoveClusterCmd)
+	configCmd.AddCommand(configRenameClusterCmd)
 	configCmd.AddCommand(configSelectCluster)
 	configCmd.AddCommand(configCurrentContext)
 	configCmd.AddCommand(configAddEventhub)
@@ -31,6 +35,7 @@ func init() {
 
 	configLsCmd.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers")
 	configAddEventhub.Flags().StringVar(&flagEhConnString, "eh-connstring", "", "EventHub ConnectionString")
+	configExportJavaCmd.Flags().BoolVar(&flagShowSecrets, "show-secrets", false, "Include passwords and client secrets instead of masking them")
 	configAddClusterCmd.Flags().StringVar(&flagBrokerVersion, "broker-version", "", fmt.Sprintf("Broker Version. Available Versions: %v", sarama.SupportedVersions))
 }
 
@@ -205,17 +210,60 @@ var configRemoveClusterCmd = &cobra.Command{
 
 		cfg.Clusters = append(cfg.Clusters[:pos], cfg.Clusters[pos+1:]...)
 
+		removedCurrent := cfg.CurrentCluster == name
+		if removedCurrent {
+			cfg.CurrentCluster = ""
+		}
+
 		err := cfg.Write()
 		if err != nil {
 			errorExit("Unable to write config: %v\n", err)
 		}
 		fmt.Println("Removed cluster.")
+		if removedCurrent {
+			fmt.Fprintf(errWriter, "Warning: removed the current cluster, no cluster is selected now. Use 'kaf config use-cluster' to select one.\n")
+		}
+	},
+}
+
+var configRenameClusterCmd = &cobra.Command{
+	Use:               "rename-cluster OLD NEW",
+	Short:             "Rename cluster",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: validConfigArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		oldName, newName := args[0], args[1]
+
+		var found *config.Cluster
+		for _, cluster := range cfg.Clusters {
+			if cluster.Name == newName {
+				errorExit("Could not rename cluster: cluster with name '%v' exists already.", newName)
+			}
+			if cluster.Name == oldName {
+				found = cluster
+			}
+		}
+
+		if found == nil {
+			errorExit("Could not rename cluster: cluster with name '%v' not exists.", oldName)
+		}
+
+		found.Name = newName
+		if cfg.CurrentCluster == oldName {
+			cfg.CurrentCluster = newName
+		}
+
+		err := cfg.Write()
+		if err != nil {
+			errorExit("Unable to write config: %v\n", err)
+		}
+		fmt.Printf("Renamed cluster \"%v\" to \"%v\".\n", oldName, newName)
 	},
 }
 
 var configImportCmd = &cobra.Command{
 	Use:   "import [ccloud]",
-	Short: "Import configurations into the $HOME/.kaf/config file",
+	Short: "Import configurations into the kaf config file",
 	Run: func(cmd *cobra.Command, args []string) {
 		if path, err := config.TryFindCcloudConfigFile(); err == nil {
 			fmt.Printf("Detected Confluent Cloud config in file %v\n", path)
@@ -269,3 +317,82 @@ var configImportCmd = &cobra.Command{
 		return nil
 	},
 }
+
+var configImportJavaCmd = &cobra.Command{
+	Use:     "import-java FILE [NAME]",
+	Short:   "Import a cluster from a Java client.properties file",
+	Example: "kaf config import-java client.properties my-cluster",
+	Args:    cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster, warnings, err := config.ParseJavaClientProperties(args[0])
+		if err != nil {
+			errorExit("Unable to parse %v: %v\n", args[0], err)
+		}
+		for _, warning := range warnings {
+			fmt.Fprintf(errWriter, "Warning: %v\n", warning)
+		}
+
+		var name string
+		if len(args) == 2 {
+			name = args[1]
+		} else {
+			prompt := promptui.Prompt{
+				Label: "Cluster name",
+			}
+			name, err = prompt.Run()
+			if err != nil {
+				errorExit("Aborted, exiting.\n")
+			}
+		}
+		if name == "" {
+			errorExit("Cluster name must not be empty")
+		}
+
+		for _, existing := range cfg.Clusters {
+			if existing.Name == name {
+				errorExit("Could not add cluster: cluster with name '%v' exists already.", name)
+			}
+		}
+
+		cluster.Name = name
+		cfg.Clusters = append(cfg.Clusters, cluster)
+		if err := cfg.Write(); err != nil {
+			errorExit("Unable to write config: %v\n", err)
+		}
+		fmt.Printf("Imported cluster \"%v\".\n", name)
+	},
+}
+
+var configExportJavaCmd = &cobra.Command{
+	Use:               "export-java [CLUSTER]",
+	Short:             "Export a cluster as a Java client.properties file",
+	Long:              "Export a cluster as a Java client.properties file, printed to stdout. Defaults to the current cluster.",
+	Example:           "kaf config export-java my-cluster > client.properties",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: validConfigArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var cluster *config.Cluster
+		if len(args) == 1 {
+			for _, c := range cfg.Clusters {
+				if c.Name == args[0] {
+					cluster = c
+					break
+				}
+			}
+			if cluster == nil {
+				errorExit("Cluster with name %v not found\n", args[0])
+			}
+		} else {
+			cluster = cfg.ActiveCluster()
+			if cluster == nil {
+				errorExit("No cluster selected. Pass a cluster name or use 'kaf config use-cluster'.")
+			}
+		}
+
+		properties, warnings := config.FormatJavaClientProperties(cluster, flagShowSecrets)
+		for _, warning := range warnings {
+			fmt.Fprintf(errWriter, "Warning: %v\n", warning)
+		}
+		fmt.Fprint(outWriter, properties)
+	},
+}
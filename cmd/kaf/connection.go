@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/birdayz/kaf/pkg/config"
+)
+
+var (
+	saslMechanismFlag string
+	saslUsernameFlag  string
+	saslPasswordFlag  string
+	tlsFlag           bool
+)
+
+var saslMechanisms = []string{"PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512", "OAUTHBEARER", "AWS_MSK_IAM", "GSSAPI"}
+
+// connectionFlagsSet reports whether any of the --sasl-* or --tls flags is
+// given.
+func connectionFlagsSet() bool {
+	return saslMechanismFlag != "" || saslUsernameFlag != "" || saslPasswordFlag != "" || tlsFlag
+}
+
+// parseBrokers validates the --brokers addresses, which must be host:port
+// pairs. Spaces around addresses are removed.
+func parseBrokers(brokers []string) ([]string, error) {
+	parsed := make([]string, 0, len(brokers))
+	for _, broker := range brokers {
+		broker = strings.TrimSpace(broker)
+		host, port, err := net.SplitHostPort(broker)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --brokers address %q, expected host:port", broker)
+		}
+		if host == "" {
+			return nil, fmt.Errorf("invalid --brokers address %q, the host is missing", broker)
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			return nil, fmt.Errorf("invalid --brokers address %q, the port must be a number from 1 to 65535", broker)
+		}
+		parsed = append(parsed, broker)
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("--brokers needs at least one address")
+	}
+	return parsed, nil
+}
+
+// copyCluster returns a copy of cluster that flags can override without
+// changing the loaded config, which config commands write back.
+func copyCluster(cluster *config.Cluster) *config.Cluster {
+	c := *cluster
+	if cluster.SASL != nil {
+		sasl := *cluster.SASL
+		c.SASL = &sasl
+	}
+	if cluster.TLS != nil {
+		tls := *cluster.TLS
+		c.TLS = &tls
+	}
+	return &c
+}
+
+// applyConnectionFlags sets SASL and TLS of cluster from the --sasl-* and
+// --tls flags. --sasl-mechanism replaces any SASL config of the cluster,
+// --sasl-username and --sasl-password alone only replace the credentials. With
+// OAUTHBEARER, --sasl-password is a static token.
+func applyConnectionFlags(cluster *config.Cluster) error {
+	if saslMechanismFlag != "" {
+		mechanism := strings.ToUpper(saslMechanismFlag)
+		if !containsString(saslMechanisms, mechanism) {
+			return fmt.Errorf("invalid --sasl-mechanism %q. Possible values: %v", saslMechanismFlag, strings.Join(saslMechanisms, ", "))
+		}
+		cluster.SASL = &config.SASL{Mechanism: mechanism}
+		if cluster.SecurityProtocol != "SASL_SSL" {
+			cluster.SecurityProtocol = "SASL_PLAINTEXT"
+		}
+	} else if (saslUsernameFlag != "" || saslPasswordFlag != "") && cluster.SASL == nil {
+		return fmt.Errorf("--sasl-username and --sasl-password require --sasl-mechanism")
+	}
+
+	if sasl := cluster.SASL; sasl != nil {
+		if saslUsernameFlag != "" {
+			sasl.Username = saslUsernameFlag
+		}
+		if saslPasswordFlag != "" {
+			if sasl.Mechanism == "OAUTHBEARER" {
+				sasl.Token = saslPasswordFlag
+			} else {
+				sasl.Password = saslPasswordFlag
+			}
+		}
+	}
+
+	if tlsFlag {
+		if cluster.SASL != nil {
+			cluster.SecurityProtocol = "SASL_SSL"
+		} else if cluster.TLS == nil {
+			cluster.TLS = &config.TLS{}
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/birdayz/kaf/pkg/config"
+)
+
+func withConnectionFlags(t *testing.T, mechanism, username, password string, tls bool) {
+	saslMechanismFlag, saslUsernameFlag, saslPasswordFlag, tlsFlag = mechanism, username, password, tls
+	t.Cleanup(func() {
+		saslMechanismFlag, saslUsernameFlag, saslPasswordFlag, tlsFlag = "", "", "", false
+	})
+}
+
+func TestApplyConnectionFlags(t *testing.T) {
+	withConnectionFlags(t, "scram-sha-512", "alice", "secret", true)
+	cluster := &config.Cluster{Brokers: []string{"kafka:9093"}}
+	require.NoError(t, applyConnectionFlags(cluster))
+	require.Equal(t, "SASL_SSL", cluster.SecurityProtocol)
+	require.Equal(t, &config.SASL{Mechanism: "SCRAM-SHA-512", Username: "alice", Password: "secret"}, cluster.SASL)
+
+	withConnectionFlags(t, "PLAIN", "", "", false)
+	cluster = &config.Cluster{}
+	require.NoError(t, applyConnectionFlags(cluster))
+	require.Equal(t, "SASL_PLAINTEXT", cluster.SecurityProtocol)
+
+	withConnectionFlags(t, "OAUTHBEARER", "", "token", false)
+	cluster = &config.Cluster{}
+	require.NoError(t, applyConnectionFlags(cluster))
+	require.Equal(t, "token", cluster.SASL.Token)
+	require.Empty(t, cluster.SASL.Password)
+
+	// Credentials alone replace those of the configured mechanism.
+	withConnectionFlags(t, "", "bob", "", false)
+	cluster = &config.Cluster{SecurityProtocol: "SASL_SSL", SASL: &config.SASL{Mechanism: "SCRAM-SHA-256", Username: "alice", Password: "secret"}}
+	require.NoError(t, applyConnectionFlags(cluster))
+	require.Equal(t, &config.SASL{Mechanism: "SCRAM-SHA-256", Username: "bob", Password: "secret"}, cluster.SASL)
+
+	withConnectionFlags(t, "", "", "", true)
+	cluster = &config.Cluster{}
+	require.NoError(t, applyConnectionFlags(cluster))
+	require.Equal(t, &config.TLS{}, cluster.TLS)
+	require.Nil(t, cluster.SASL)
+
+	withConnectionFlags(t, "", "bob", "", false)
+	require.Error(t, applyConnectionFlags(&config.Cluster{}))
+
+	withConnectionFlags(t, "DIGEST-MD5", "", "", false)
+	require.Error(t, applyConnectionFlags(&config.Cluster{}))
+}
+
+func TestCopyCluster(t *testing.T) {
+	loaded := &config.Cluster{Brokers: []string{"kafka:9092"}, SASL: &config.SASL{Mechanism: "PLAIN", Username: "alice"}, TLS: &config.TLS{}}
+	withConnectionFlags(t, "", "bob", "", false)
+	cluster := copyCluster(loaded)
+	require.NoError(t, applyConnectionFlags(cluster))
+	cluster.SASL.InsecurePlaintext = true
+	cluster.TLS.Insecure = true
+
+	require.Equal(t, "bob", cluster.SASL.Username)
+	require.Equal(t, &config.SASL{Mechanism: "PLAIN", Username: "alice"}, loaded.SASL)
+	require.Equal(t, &config.TLS{}, loaded.TLS)
+}
+
+func TestParseBrokers(t *testing.T) {
+	brokers, err := parseBrokers([]string{"kafka-1:9092", " 10.0.0.2:9093", "[::1]:9094"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"kafka-1:9092", "10.0.0.2:9093", "[::1]:9094"}, brokers)
+
+	for _, invalid := range [][]string{{"kafka-1"}, {":9092"}, {"kafka-1:http"}, {"kafka-1:0"}, {"kafka-1:9092", ""}, {}} {
+		_, err := parseBrokers(invalid)
+		require.Error(t, err, "%v", invalid)
+	}
+}
@@ -5,13 +5,22 @@ import (
 	"context"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"text/tabwriter"
+	"time"
 
 	"github.com/IBM/sarama"
 	"github.com/birdayz/kaf/pkg/avro"
+	"github.com/birdayz/kaf/pkg/kaf"
 	"github.com/birdayz/kaf/pkg/proto"
 	"github.com/golang/protobuf/jsonpb"
 	prettyjson "github.com/hokaccha/go-prettyjson"
@@ -29,34 +38,150 @@ var (
 	follow      bool
 	tail        int32
 	schemaCache *avro.SchemaCache
-	keyfmt      *prettyjson.Formatter
+	// keySchemaCache decodes Avro keys, the registry may differ from the
+	// value registry if subject prefixes are configured.
+	keySchemaCache *avro.SchemaCache
+	// protoRegistry and keyProtoRegistry decode Protobuf messages with
+	// schemas of the registry.
+	protoRegistry, keyProtoRegistry *proto.RegistryDecoder
+	keyfmt                          *prettyjson.Formatter
 
 	protoType    string
 	keyProtoType string
 
-	flagPartitions []int32
+	flagPartitions partitionList
 
 	limitMessagesFlag int64
+	exitOnEOFFlag     bool
+	valueDecompress   string
+	zstdDictFlag      string
+	countFlag         bool
+	decodeFlag        []string
+	keyDecodeFlag     string
+	valueDecodeFlag   string
+	// keyDecoders and valueDecoders are the decoders tried for keys and
+	// values, none for the automatic detection.
+	keyDecoders   []string
+	valueDecoders []string
+	rateFlag      float64
+	rateModeFlag  string
+
+	sampleFlag     float64
+	sampleSeedFlag int64
+
+	deadLetterFileFlag string
+	deadLetters        *deadLetterWriter
+
+	toAvroFlag    string
+	avroWrapFlag  bool
+	avroExport    *avroExporter
+	fromTimeFlag  string
+	fromTime      time.Time
+	toOffsetFlag  int64
+	isolationFlag string
+	// commitIntervalFlag is how often offsets marked with --commit are
+	// committed.
+	commitIntervalFlag time.Duration
+	keysOnlyFlag       bool
+	commitOnOutputFlag bool
+	// avroNativeJSONFlag makes Avro values print as plain JSON instead of
+	// the Avro JSON encoding.
+	avroNativeJSONFlag bool
+	fetchMinBytesFlag  int32
+	fetchMaxBytesFlag  int32
+	fetchMaxWaitFlag   time.Duration
+	// noCommitFlag makes sure a group consume never commits offsets.
+	noCommitFlag   bool
+	timeFormatFlag string
+	// startOffset is --offset relative to the watermarks, if given so.
+	startOffset *relativeOffset
+	// offsetFromGroupFlag is a group whose committed offsets are used as
+	// start offsets, without joining the group.
+	offsetFromGroupFlag string
+	findKeyFlag         string
+	allMatchesFlag      bool
+	// findKeyMatches counts the messages matching --find-key, stopFinding
+	// stops consuming once the first one is printed. Only
+	// withoutConsumerGroup sets it, --find-key is rejected with --group.
+	findKeyMatches int64
+	stopFinding    context.CancelFunc
+
+	dedupByFlag      string
+	dedupModeFlag    string
+	dedupMaxKeysFlag int
+	dedup            *deduplicator
+
+	outputLimiter *rateLimiter
+	// rateSkipped counts messages not printed with --rate-mode skip.
+	rateSkipped int64
+	// sampleSeen and samplePrinted count messages with --sample.
+	sampleSeen, samplePrinted int64
 
 	reg *proto.DescriptorRegistry
 )
 
 func init() {
 	rootCmd.AddCommand(consumeCmd)
-	consumeCmd.Flags().StringVar(&offsetFlag, "offset", "oldest", "Offset to start consuming. Possible values: oldest, newest, or integer.")
+	consumeCmd.Flags().StringVar(&offsetFlag, "offset", "oldest", "Offset to start consuming. Possible values: oldest, newest, an integer, or -N and +N for N messages before the newest or after the oldest offset of each partition. Use -v to print the resolved offsets")
 	consumeCmd.Flags().BoolVar(&raw, "raw", false, "Print raw output of messages, without key or prettified JSON")
 	consumeCmd.Flags().Var(&outputFormat, "output", "Set output format messages: default, raw (without key or prettified JSON), json")
 	consumeCmd.Flags().BoolVarP(&follow, "follow", "f", false, "Continue to consume messages until program execution is interrupted/terminated")
-	consumeCmd.Flags().Int32VarP(&tail, "tail", "n", 0, "Print last n messages per partition")
+	consumeCmd.Flags().DurationVar(&idleTimeoutFlag, "idle-timeout", 0, "Stop once no new message arrived for this duration, with --follow or --group. Exits with a non-zero code if no message arrived at all")
+	consumeCmd.Flags().Int32VarP(&tail, "tail", "n", 0, "Print last n messages per partition. Stops at the high watermark unless --follow is given")
 	consumeCmd.Flags().StringSliceVar(&protoFiles, "proto-include", []string{}, "Path to proto files")
 	consumeCmd.Flags().StringSliceVar(&protoExclude, "proto-exclude", []string{}, "Proto exclusions (path prefixes)")
 	consumeCmd.Flags().BoolVar(&decodeMsgPack, "decode-msgpack", false, "Enable deserializing msgpack")
-	consumeCmd.Flags().StringVar(&protoType, "proto-type", "", "Fully qualified name of the proto message type. Example: com.test.SampleMessage")
+	consumeCmd.Flags().StringVar(&valueDecompress, "value-decompress", "", "Decompress record values compressed by the producing application before decoding: [gzip|zstd|lz4|snappy]. lz4 values use the lz4 frame format")
+	consumeCmd.Flags().StringSliceVar(&decodeFlag, "decode", nil, "Decoders to try in order until one succeeds: auto, or a comma separated list of avro, proto, json, raw, hex. auto is avro,proto,json,raw,hex. Use -v to print the decoder used")
+	consumeCmd.Flags().StringVar(&zstdDictFlag, "zstd-dict", "", "Path to a zstd dictionary for values compressed with a shared dictionary. Implies --value-decompress zstd")
+	consumeCmd.Flags().StringVar(&protoType, "proto-type", "", "Fully qualified name of the proto message type. Example: com.test.SampleMessage. Not needed for messages encoded with a Protobuf schema of the schema registry")
+	consumeCmd.Flags().IntVar(&maxValueSizeFlag, "max-value-size", 0, "Skip or truncate printed values larger than this many bytes before decoding them, with a warning on stderr. 0 for no limit")
+	consumeCmd.Flags().StringVar(&oversizeModeFlag, "oversize-mode", "skip", "What to do with values larger than --max-value-size: skip or truncate")
+	consumeCmd.Flags().StringVar(&keyDecodeFlag, "key-decode", "auto", "Decoder of keys: auto, string, json, avro, proto, hex, base64 or raw. raw prints the bytes unchanged. Overrides --decode for keys, avro and proto use the registry of the -key subject")
+	consumeCmd.Flags().StringVar(&valueDecodeFlag, "value-decode", "auto", "Decoder of values: auto, string, json, avro, proto, hex, base64 or raw. raw prints the bytes unchanged. Overrides --decode for values, avro and proto use the registry of the -value subject")
 	consumeCmd.Flags().StringVar(&keyProtoType, "key-proto-type", "", "Fully qualified name of the proto key type. Example: com.test.SampleMessage")
-	consumeCmd.Flags().Int32SliceVarP(&flagPartitions, "partitions", "p", []int32{}, "Partitions to consume from")
+	consumeCmd.Flags().VarP(&flagPartitions, "partitions", "p", "Partitions to consume from. Comma separated list of partitions or ranges, e.g. 0,2,5 or 0-3")
 	consumeCmd.Flags().Int64VarP(&limitMessagesFlag, "limit-messages", "l", 0, "Limit messages per partition")
+	consumeCmd.Flags().BoolVar(&exitOnEOFFlag, "exit-on-eof", false, "Exit once all partitions reached their high watermark. Defaults to true for --offset oldest without --follow")
+	consumeCmd.Flags().BoolVar(&countFlag, "count", false, "Count messages per partition up to the high watermark instead of printing them. Respects --offset, --tail, --partitions and --limit-messages")
+	consumeCmd.Flags().BoolVar(&pageFlag, "page", false, "Show a page of messages at a time and pause consuming until Enter is pressed, q quits. Pages are $LINES high, 24 if unset. Ignored if stdout is not a terminal")
+	consumeCmd.Flags().BoolVar(&digestFlag, "digest", false, "Print a SHA-256 digest of offset, key and value of the messages per partition and a combined digest instead of the messages, to compare topics or runs. Stops at the high watermark")
+	consumeCmd.Flags().Float64Var(&rateFlag, "rate", 0, "Print at most N messages per second. Consuming continues at full speed, see --rate-mode")
+	consumeCmd.Flags().StringVar(&rateModeFlag, "rate-mode", "block", "What to do with messages exceeding --rate: block (wait, nothing is dropped) or skip (do not print them)")
+	consumeCmd.Flags().Float64Var(&sampleFlag, "sample", 0, "Print only a random fraction of messages, e.g. 0.01 for about 1%")
+	consumeCmd.Flags().Int64Var(&sampleSeedFlag, "sample-seed", 0, "Seed for --sample, to print the same messages again. Random by default")
+	consumeCmd.Flags().StringVar(&deadLetterFileFlag, "dead-letter-file", "", "Write messages whose value fails to decode to this file as JSON lines with base64 key and value, instead of printing them")
+	consumeCmd.Flags().StringVar(&toAvroFlag, "to-avro", "", "Write Avro encoded values to this Avro object container file instead of printing them, using the registry schema of the first message. Stops at the high watermark")
+	consumeCmd.Flags().BoolVar(&avroWrapFlag, "avro-wrap-metadata", false, "With --to-avro, store each value in a record with topic, partition, offset, timestamp and key")
+	consumeCmd.Flags().BoolVar(&keysOnlyFlag, "keys-only", false, "Print only the decoded keys, one per line. Messages without key are skipped. Combine with --dedup-by key for the distinct keys")
+	consumeCmd.Flags().StringVar(&isolationFlag, "isolation", "read_uncommitted", "Isolation level: read_uncommitted (all records) or read_committed (skip records of aborted transactions). read_committed reads only up to the last stable offset, records of open transactions are not shown")
+	consumeCmd.Flags().StringVar(&transformFlag, "transform", "", "Lua script defining a function transform(record) applied to every message after decoding. It returns the changed record, or nil to skip the message")
+	consumeCmd.Flags().DurationVar(&transformTimeoutFlag, "transform-timeout", 100*time.Millisecond, "Maximum time --transform may run per message")
+	consumeCmd.Flags().StringVar(&timeFormatFlag, "time-format", "rfc3339", "Format of message timestamps in default and JSON output: rfc3339 (UTC), unix (milliseconds since the epoch), local (RFC3339 in the local time zone) or a Go time layout such as '2006-01-02 15:04:05', rendered in UTC")
+	consumeCmd.Flags().StringVar(&fromTimeFlag, "from-time", "", "Start at the first message at or after this time (RFC3339) instead of --offset")
+	consumeCmd.Flags().StringVar(&findKeyFlag, "find-key", "", "Print the first message whose key or decoded key equals this value and exit. Exits with an error if no message up to the high watermark matches")
+	consumeCmd.Flags().BoolVar(&allMatchesFlag, "all-matches", false, "With --find-key, print every matching message instead of the first")
+	consumeCmd.Flags().StringVar(&offsetFromGroupFlag, "offset-from-group", "", "Start each partition at the offset committed by this consumer group, or at the oldest offset if the group has not committed one. The group is not joined and its offsets are not changed")
+	consumeCmd.Flags().StringVar(&stateFileFlag, "state-file", "", "Resume each partition after the last offset consumed by the previous run with this file, and record the offsets in it on exit. Like a consumer group without joining one or writing offsets to the brokers. The first run starts at --offset")
+	consumeCmd.Flags().Int64Var(&toOffsetFlag, "to-offset", -1, "Stop consuming a partition after this offset")
+	consumeCmd.Flags().StringVar(&dedupByFlag, "dedup-by", "", "Print only one message per key: key")
+	consumeCmd.Flags().StringVar(&dedupModeFlag, "dedup-mode", "first", "Which message per key --dedup-by prints: first (printed as consumed) or last (printed once all partitions are consumed)")
+	consumeCmd.Flags().IntVar(&dedupMaxKeysFlag, "dedup-max-keys", 1000000, "Maximum number of keys tracked by --dedup-by. Memory usage grows with the number of keys")
+	consumeCmd.Flags().BoolVar(&avroNativeJSONFlag, "avro-native-json", false, "Print Avro values as plain JSON: unions without type wrapper, timestamps and dates as RFC 3339, decimals as strings. The default Avro JSON encoding can be produced again with --avro-schema-id")
+	consumeCmd.Flags().BoolVar(&decryptFlag, "decrypt", false, "Decrypt values encrypted by kaf produce --encrypt with the AES key of --encryption-key-file before decoding. Values that are not encrypted are printed as they are")
+	consumeCmd.Flags().StringVar(&encryptionKeyFileFlag, "encryption-key-file", "", "File with the AES-128, AES-192 or AES-256 key for --decrypt, raw, hex or base64 encoded")
+	consumeCmd.Flags().BoolVar(&compactSnapshotFlag, "compact-snapshot", false, "Read all partitions up to the high watermark and print only the last message per key as JSON lines, the state log compaction eventually leaves. Tombstones remove their key, messages without key are skipped")
+	consumeCmd.Flags().IntVar(&snapshotMaxKeysFlag, "snapshot-max-keys", 1000000, "Keys --compact-snapshot holds in memory before spilling messages to disk. Memory usage grows with the number of keys and the message sizes")
+	consumeCmd.Flags().StringVar(&snapshotToFlag, "to", "", "Write the --compact-snapshot to this file instead of stdout. The file is only replaced once the snapshot is complete")
+	consumeCmd.Flags().StringVar(&snapshotSpillDirFlag, "snapshot-spill-dir", "", "Directory for the spill files of --compact-snapshot, defaults to the temporary directory. It needs space for all messages read")
+	consumeCmd.Flags().Int32Var(&fetchMinBytesFlag, "fetch-min-bytes", 0, "Minimum bytes a broker collects before answering a fetch, up to --fetch-max-wait. Overrides fetch-min-bytes of the cluster config, defaults to 1")
+	consumeCmd.Flags().Int32Var(&fetchMaxBytesFlag, "fetch-max-bytes", 0, "Bytes fetched per partition and request. Larger values improve throughput on high latency links. Overrides fetch-max-bytes of the cluster config, defaults to 1MiB")
+	consumeCmd.Flags().DurationVar(&fetchMaxWaitFlag, "fetch-max-wait", 0, "Maximum time a broker waits for --fetch-min-bytes. Overrides fetch-max-wait of the cluster config, defaults to 500ms")
 	consumeCmd.Flags().StringVarP(&groupFlag, "group", "g", "", "Consumer Group to use for consume")
-	consumeCmd.Flags().BoolVar(&groupCommitFlag, "commit", false, "Commit Group offset after receiving messages. Works only if consuming as Consumer Group")
+	consumeCmd.Flags().BoolVar(&groupCommitFlag, "commit", false, "Commit Group offset after receiving messages. Works only if consuming as Consumer Group. Offsets are committed every --commit-interval and on exit, messages printed after the last commit are printed again after a crash (at-least-once)")
+	consumeCmd.Flags().BoolVar(&commitOnOutputFlag, "commit-on-output", false, "Like --commit, but check that each message was written to stdout before committing its offset. Consuming stops at the first failed write")
+	consumeCmd.Flags().BoolVar(&noCommitFlag, "no-commit", false, "Join the consumer group without ever committing offsets, also disabling auto-commit. Use to attach a debug consumer to a group without moving its offsets")
+	consumeCmd.Flags().DurationVar(&commitIntervalFlag, "commit-interval", time.Second, "How often offsets are committed with --commit")
 
 	if err := consumeCmd.RegisterFlagCompletionFunc("output", completeOutputFormat); err != nil {
 		errorExit("Failed to register flag completion: %v", err)
@@ -71,28 +196,109 @@ func init() {
 	keyfmt.Indent = 0
 }
 
+// getCommittedOffsets returns the offsets committed by group for partitions of
+// topic. Partitions without committed offset are missing. The offsets are only
+// read, the group is not joined.
+func getCommittedOffsets(group string, topic string, partitions []int32) map[int32]int64 {
+	admin := getClusterAdmin()
+	defer admin.Close()
+
+	resp, err := admin.ListConsumerGroupOffsets(group, map[string][]int32{topic: partitions})
+	if err != nil {
+		errorExit("Unable to get offsets of group %v: %v\n", group, err)
+	}
+	if resp.Err != sarama.ErrNoError {
+		errorExit("Unable to get offsets of group %v: %v\n", group, resp.Err)
+	}
+
+	committed := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		block := resp.GetBlock(topic, partition)
+		if block == nil {
+			continue
+		}
+		// Failed blocks carry offset -1, like partitions without a commit.
+		if block.Err != sarama.ErrNoError {
+			errorExit("Unable to get offset of group %v for partition %v: %v\n", group, partition, block.Err)
+		}
+		if block.Offset < 0 {
+			continue
+		}
+		committed[partition] = block.Offset
+	}
+	if len(committed) == 0 {
+		fmt.Fprintf(errWriter, "Group %v has no committed offsets for %v, starting at the oldest offsets.\n", group, topic)
+	}
+	return committed
+}
+
 type offsets struct {
 	newest int64
 	oldest int64
 }
 
-func getOffsets(client sarama.Client, topic string, partition int32) (*offsets, error) {
-	newest, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
-	if err != nil {
-		return nil, err
-	}
+// consumeEndIdleTimeout is the kaf.ConsumeOptions.EndIdleTimeout of consumes
+// without --group, replaced in tests.
+var consumeEndIdleTimeout time.Duration
 
-	oldest, err := client.GetOffset(topic, partition, sarama.OffsetOldest)
+// getOffsets returns the offsets bounding what a consumer with the isolation
+// level of client can read, see kaf.PartitionOffsets.
+func getOffsets(client sarama.Client, topic string, partition int32) (*offsets, error) {
+	oldest, newest, err := kaf.PartitionOffsets(client, topic, partition)
 	if err != nil {
 		return nil, err
 	}
-
 	return &offsets{
 		newest: newest,
 		oldest: oldest,
 	}, nil
 }
 
+// applyFetchFlags sets the fetch flags given on the command line.
+func applyFetchFlags(cmd *cobra.Command, cfg *sarama.Config) {
+	if cmd.Flags().Changed("fetch-min-bytes") {
+		if fetchMinBytesFlag < 1 {
+			errorExit("--fetch-min-bytes must be at least 1")
+		}
+		cfg.Consumer.Fetch.Min = fetchMinBytesFlag
+	}
+	if cmd.Flags().Changed("fetch-max-bytes") {
+		if fetchMaxBytesFlag < 1 {
+			errorExit("--fetch-max-bytes must be at least 1")
+		}
+		cfg.Consumer.Fetch.Default = fetchMaxBytesFlag
+	}
+	if cmd.Flags().Changed("fetch-max-wait") {
+		if fetchMaxWaitFlag < time.Millisecond {
+			errorExit("--fetch-max-wait must be at least 1ms")
+		}
+		cfg.Consumer.MaxWaitTime = fetchMaxWaitFlag
+	}
+}
+
+// warnFetchBelowMaxMessageBytes warns if messages of the topic may be larger
+// than the fetch size. Such messages are still consumed, but the consumer
+// first has to retry with a growing fetch size.
+func warnFetchBelowMaxMessageBytes(topic string, fetchBytes int32) {
+	entries, err := getClusterAdmin().DescribeConfig(sarama.ConfigResource{
+		Type:        sarama.TopicResource,
+		Name:        topic,
+		ConfigNames: []string{"max.message.bytes"},
+	})
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.Name != "max.message.bytes" {
+			continue
+		}
+		maxMessageBytes, err := strconv.ParseInt(entry.Value, 10, 32)
+		if err == nil && maxMessageBytes > int64(fetchBytes) {
+			fmt.Fprintf(errWriter, "Warning: fetch size of %v bytes is below max.message.bytes %v of topic %v, larger messages need additional fetches\n", fetchBytes, maxMessageBytes, topic)
+		}
+	}
+}
+
 var consumeCmd = &cobra.Command{
 	Use:               "consume TOPIC",
 	Short:             "Consume messages",
@@ -103,7 +309,25 @@ var consumeCmd = &cobra.Command{
 		var offset int64
 		cfg := getConfig()
 		topic := args[0]
-		client := getClientFromConfig(cfg)
+
+		switch isolationFlag {
+		case "read_uncommitted":
+			cfg.Consumer.IsolationLevel = sarama.ReadUncommitted
+		case "read_committed":
+			if !cfg.Version.IsAtLeast(sarama.V0_11_0_0) {
+				errorExit("--isolation read_committed requires Kafka 0.11 or later")
+			}
+			cfg.Consumer.IsolationLevel = sarama.ReadCommitted
+		default:
+			errorExit("Invalid --isolation %q. Possible values: read_uncommitted, read_committed", isolationFlag)
+		}
+
+		applyFetchFlags(cmd, cfg)
+
+		client := getKafClientFromConfig(cfg)
+		if cmd.Flags().Changed("fetch-max-bytes") || currentCluster.FetchMaxBytes > 0 {
+			warnFetchBelowMaxMessageBytes(topic, cfg.Consumer.Fetch.Default)
+		}
 
 		// Allow deprecated flag to override when outputFormat is not specified, or default.
 		if outputFormat == OutputFormatDefault && raw {
@@ -118,6 +342,17 @@ var consumeCmd = &cobra.Command{
 			offset = sarama.OffsetNewest
 			cfg.Consumer.Offsets.Initial = sarama.OffsetNewest
 		default:
+			relative, err := parseRelativeOffset(offsetFlag)
+			if err != nil {
+				errorExit("Invalid --offset: %v", err)
+			}
+			if relative != nil {
+				if groupFlag != "" {
+					errorExit("Relative --offset %v cannot be combined with --group", offsetFlag)
+				}
+				startOffset = relative
+				break
+			}
 			o, err := strconv.ParseInt(offsetFlag, 10, 64)
 			if err != nil {
 				errorExit("Could not parse '%s' to int64: %w", offsetFlag, err)
@@ -125,8 +360,273 @@ var consumeCmd = &cobra.Command{
 			offset = o
 		}
 
+		switch valueDecompress {
+		case "", "gzip", "zstd", "lz4", "snappy":
+		default:
+			errorExit("Invalid --value-decompress %q. Possible values: gzip, zstd, lz4, snappy", valueDecompress)
+		}
+		if zstdDictFlag != "" {
+			if valueDecompress != "" && valueDecompress != "zstd" {
+				errorExit("--zstd-dict requires --value-decompress zstd")
+			}
+			valueDecompress = "zstd"
+			if err := loadZstdDict(zstdDictFlag); err != nil {
+				errorExit("Unable to load zstd dictionary: %v", err)
+			}
+		}
+
+		if len(decodeFlag) > 0 {
+			decoders, err := parseDecoders(decodeFlag)
+			if err != nil {
+				errorExit("Invalid --decode: %v", err)
+			}
+			keyDecoders, valueDecoders = decoders, decoders
+		}
+		if decoders, err := parseFieldDecoder(keyDecodeFlag); err != nil {
+			errorExit("Invalid --key-decode: %v", err)
+		} else if decoders != nil {
+			keyDecoders = decoders
+		}
+		if decoders, err := parseFieldDecoder(valueDecodeFlag); err != nil {
+			errorExit("Invalid --value-decode: %v", err)
+		} else if decoders != nil {
+			valueDecoders = decoders
+		}
+		if err := checkFieldDecoder(keyDecodeFlag, topic+"-key", keyProtoType); err != nil {
+			errorExit("Invalid --key-decode: %v", err)
+		}
+		if err := checkFieldDecoder(valueDecodeFlag, topic+"-value", protoType); err != nil {
+			errorExit("Invalid --value-decode: %v", err)
+		}
+
+		if maxValueSizeFlag < 0 {
+			errorExit("--max-value-size must not be negative")
+		}
+		switch oversizeModeFlag {
+		case "skip", "truncate":
+		default:
+			errorExit("Invalid --oversize-mode %q. Possible values: skip, truncate", oversizeModeFlag)
+		}
+		if cmd.Flags().Changed("oversize-mode") && maxValueSizeFlag == 0 {
+			errorExit("--oversize-mode requires --max-value-size")
+		}
+
+		if rateFlag < 0 {
+			errorExit("--rate must not be negative")
+		}
+		switch rateModeFlag {
+		case "block", "skip":
+		default:
+			errorExit("Invalid --rate-mode %q. Possible values: block, skip", rateModeFlag)
+		}
+		if rateFlag > 0 {
+			outputLimiter = newRateLimiter(rateFlag)
+		}
+
+		if sampleFlag < 0 || sampleFlag > 1 {
+			errorExit("--sample must be between 0 and 1")
+		}
+		if !cmd.Flags().Changed("sample-seed") {
+			sampleSeedFlag = time.Now().UnixNano()
+		}
+
+		switch dedupByFlag {
+		case "":
+		case "key":
+			switch dedupModeFlag {
+			case "first":
+			case "last":
+				if follow || groupFlag != "" {
+					errorExit("--dedup-mode last cannot be combined with --follow or --group")
+				}
+			default:
+				errorExit("Invalid --dedup-mode %q. Possible values: first, last", dedupModeFlag)
+			}
+			if dedupMaxKeysFlag < 1 {
+				errorExit("--dedup-max-keys must be at least 1")
+			}
+			dedup = newDeduplicator(dedupModeFlag, dedupMaxKeysFlag)
+		default:
+			errorExit("Invalid --dedup-by %q. Possible values: key", dedupByFlag)
+		}
+
+		if tail < 0 {
+			errorExit("--tail must not be negative")
+		}
+		if tail > 0 && groupFlag != "" {
+			errorExit("--tail cannot be combined with --group")
+		}
+		if countFlag && groupFlag != "" {
+			errorExit("--count cannot be combined with --group")
+		}
+		if allMatchesFlag && findKeyFlag == "" {
+			errorExit("--all-matches requires --find-key")
+		}
+		if findKeyFlag != "" && (groupFlag != "" || follow || countFlag || toAvroFlag != "" || dedupByFlag != "") {
+			errorExit("--find-key cannot be combined with --group, --follow, --count, --to-avro or --dedup-by")
+		}
+
+		if compactSnapshotFlag {
+			if follow || groupFlag != "" || tail > 0 || cmd.Flags().Changed("offset") || fromTimeFlag != "" || offsetFromGroupFlag != "" {
+				errorExit("--compact-snapshot reads from the oldest offset, it cannot be combined with --follow, --group, --tail, --offset, --from-time or --offset-from-group")
+			}
+			if countFlag || dedupByFlag != "" || findKeyFlag != "" || toAvroFlag != "" || keysOnlyFlag || limitMessagesFlag > 0 {
+				errorExit("--compact-snapshot cannot be combined with --count, --dedup-by, --find-key, --to-avro, --keys-only or --limit-messages")
+			}
+			if cmd.Flags().Changed("output") && outputFormat != OutputFormatJSON {
+				errorExit("--compact-snapshot prints JSON lines, --output %v is not supported", outputFormat)
+			}
+			if cmd.Flags().Changed("exit-on-eof") && !exitOnEOFFlag {
+				errorExit("--compact-snapshot requires reading up to the high watermark, --exit-on-eof=false is not supported")
+			}
+			if snapshotMaxKeysFlag < 1 {
+				errorExit("--snapshot-max-keys must be at least 1")
+			}
+			outputFormat = OutputFormatJSON
+			snapshot = newCompactSnapshot(snapshotMaxKeysFlag, snapshotSpillDirFlag)
+
+			if snapshotToFlag != "" {
+				var err error
+				snapshotOut, err = createSnapshotFile(snapshotToFlag)
+				if err != nil {
+					errorExit("Unable to create snapshot file: %v", err)
+				}
+				outWriter, colorableOut = snapshotOut, snapshotOut
+			}
+		} else if snapshotToFlag != "" {
+			errorExit("--to requires --compact-snapshot")
+		}
+
+		if err := validateTimeFormat(timeFormatFlag); err != nil {
+			errorExit("%v", err)
+		}
+
+		if fromTimeFlag != "" {
+			if cmd.Flags().Changed("offset") || tail > 0 || groupFlag != "" {
+				errorExit("--from-time cannot be combined with --offset, --tail or --group")
+			}
+			t, err := time.Parse(time.RFC3339, fromTimeFlag)
+			if err != nil {
+				errorExit("Invalid --from-time %q, expected RFC3339 like 2024-01-02T15:04:05Z: %v", fromTimeFlag, err)
+			}
+			fromTime = t
+		}
+
+		if offsetFromGroupFlag != "" && (cmd.Flags().Changed("offset") || tail > 0 || groupFlag != "" || fromTimeFlag != "") {
+			errorExit("--offset-from-group cannot be combined with --offset, --tail, --group or --from-time")
+		}
+
+		if stateFileFlag != "" {
+			if groupFlag != "" || tail > 0 || fromTimeFlag != "" || offsetFromGroupFlag != "" || compactSnapshotFlag || findKeyFlag != "" {
+				errorExit("--state-file cannot be combined with --group, --tail, --from-time, --offset-from-group, --compact-snapshot or --find-key")
+			}
+			var err error
+			positions, err = loadConsumeState(stateFileFlag, topic)
+			if err != nil {
+				errorExit("Unable to read state file: %v", err)
+			}
+		}
+
+		if keysOnlyFlag && (toAvroFlag != "" || deadLetterFileFlag != "") {
+			errorExit("--keys-only cannot be combined with --to-avro or --dead-letter-file")
+		}
+
+		if transformFlag != "" && (keysOnlyFlag || toAvroFlag != "") {
+			errorExit("--transform cannot be combined with --keys-only or --to-avro")
+		}
+		setupTransformer()
+		setupEncryption(decryptFlag, "--decrypt")
+
+		if deadLetterFileFlag != "" {
+			var err error
+			deadLetters, err = newDeadLetterWriter(deadLetterFileFlag)
+			if err != nil {
+				errorExit("Unable to create dead letter file: %v", err)
+			}
+		}
+
+		if toAvroFlag != "" {
+			if follow || groupFlag != "" || countFlag {
+				errorExit("--to-avro cannot be combined with --follow, --group or --count")
+			}
+			var err error
+			avroExport, err = newAvroExporter(toAvroFlag, avroWrapFlag)
+			if err != nil {
+				errorExit("Unable to create Avro file: %v", err)
+			}
+			exitOnEOFFlag = true
+		}
+
+		if digestFlag {
+			if follow || groupFlag != "" || countFlag || compactSnapshotFlag || toAvroFlag != "" || findKeyFlag != "" || dedupByFlag != "" || keysOnlyFlag || transformFlag != "" || sampleFlag > 0 {
+				errorExit("--digest cannot be combined with --follow, --group, --count, --compact-snapshot, --to-avro, --find-key, --dedup-by, --keys-only, --transform or --sample")
+			}
+			if cmd.Flags().Changed("exit-on-eof") && !exitOnEOFFlag {
+				errorExit("--digest requires reading up to the high watermark, --exit-on-eof=false is not supported")
+			}
+			if cmd.Flags().Changed("output") && outputFormat != OutputFormatJSON && outputFormat != OutputFormatDefault {
+				errorExit("--digest prints a table or JSON, --output %v is not supported", outputFormat)
+			}
+			digest = newDigester()
+			exitOnEOFFlag = true
+		}
+
+		if cmd.Flags().Changed("exit-on-eof") {
+			if exitOnEOFFlag && follow {
+				errorExit("--exit-on-eof cannot be combined with --follow")
+			}
+		} else if avroExport == nil && digest == nil {
+			exitOnEOFFlag = (offsetFlag == "oldest" || startOffset != nil || tail > 0 || fromTimeFlag != "" || offsetFromGroupFlag != "" || findKeyFlag != "" || (positions != nil && positions.resumed)) && !follow
+		}
+		if findKeyFlag != "" && !exitOnEOFFlag {
+			errorExit("--find-key requires reading up to the high watermark, --exit-on-eof=false is not supported")
+		}
+		if countFlag {
+			// Counting ends at the high watermark, also if there is nothing
+			// to count after the start offset.
+			if follow {
+				errorExit("--count cannot be combined with --follow")
+			}
+			if cmd.Flags().Changed("exit-on-eof") && !exitOnEOFFlag {
+				errorExit("--count requires reading up to the high watermark, --exit-on-eof=false is not supported")
+			}
+			exitOnEOFFlag = true
+		}
+
+		if commitOnOutputFlag {
+			if groupFlag == "" {
+				errorExit("--commit-on-output requires --group")
+			}
+			groupCommitFlag = true
+			// Report a closed stdout pipe as a failed write instead of
+			// being killed by SIGPIPE.
+			signal.Notify(make(chan os.Signal, 1), syscall.SIGPIPE)
+		}
+		if noCommitFlag {
+			if groupFlag == "" {
+				errorExit("--no-commit requires --group")
+			}
+			if groupCommitFlag {
+				errorExit("--no-commit cannot be combined with --commit or --commit-on-output")
+			}
+			cfg.Consumer.Offsets.AutoCommit.Enable = false
+		}
+		if commitIntervalFlag <= 0 {
+			errorExit("--commit-interval must be positive")
+		}
+		cfg.Consumer.Offsets.AutoCommit.Interval = commitIntervalFlag
+
+		if idleTimeoutFlag < 0 {
+			errorExit("--idle-timeout must be positive")
+		}
+		if idleTimeoutFlag > 0 && !follow && groupFlag == "" {
+			errorExit("--idle-timeout requires --follow or --group")
+		}
+
+		setupPager()
+
 		if groupFlag != "" {
-			withConsumerGroup(cmd.Context(), client, topic, groupFlag)
+			withConsumerGroup(cmd.Context(), client.Sarama(), topic, groupFlag)
 		} else {
 			withoutConsumerGroup(cmd.Context(), client, topic, offset)
 		}
@@ -134,13 +634,37 @@ var consumeCmd = &cobra.Command{
 	},
 }
 
-type g struct{}
+// groupShutdownTimeout is how long a group consume may take to commit
+// offsets after SIGINT or SIGTERM.
+const groupShutdownTimeout = 10 * time.Second
+
+type g struct {
+	// cancel stops the consume after an output error with
+	// --commit-on-output, err is the first such error.
+	cancel context.CancelFunc
+	once   sync.Once
+	err    error
+	group  string
+}
+
+func (g *g) fail(err error) {
+	g.once.Do(func() {
+		g.err = err
+		g.cancel()
+	})
+}
 
 func (g *g) Setup(s sarama.ConsumerGroupSession) error {
 	return nil
 }
 
 func (g *g) Cleanup(s sarama.ConsumerGroupSession) error {
+	if groupCommitFlag {
+		// Commit synchronously so a rerun resumes after the last message
+		// printed, auto-commit would only run after the next interval.
+		s.Commit()
+		fmt.Fprintf(errWriter, "Committed offsets of group %v.\n", g.group)
+	}
 	return nil
 }
 
@@ -148,7 +672,18 @@ func (g *g) ConsumeClaim(s sarama.ConsumerGroupSession, claim sarama.ConsumerGro
 
 	mu := sync.Mutex{} // Synchronizes stderr and stdout.
 	for msg := range claim.Messages() {
-		handleMessage(msg, &mu)
+		idle.seen()
+		err := handleMessage(msg, &mu)
+		if errors.Is(err, errPagerQuit) {
+			// Not marked, the group resumes at the first message not shown.
+			return nil
+		}
+		if err != nil && commitOnOutputFlag {
+			// Neither this nor later messages of the claim are marked, so
+			// the group resumes at this message.
+			g.fail(fmt.Errorf("writing message at partition %v offset %v failed: %w", msg.Partition, msg.Offset, err))
+			return nil
+		}
 		if groupCommitFlag {
 			s.MarkMessage(msg, "")
 		}
@@ -162,119 +697,472 @@ func withConsumerGroup(ctx context.Context, client sarama.Client, topic, group s
 		errorExit("Failed to create consumer group: %v", err)
 	}
 
-	schemaCache = getSchemaCache()
+	schemaCache = getSchemaCache(topic)
+	keySchemaCache = getKeySchemaCache(topic)
+	protoRegistry = getProtoRegistryDecoder(topic + "-value")
+	keyProtoRegistry = getProtoRegistryDecoder(topic + "-key")
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	handler := &g{cancel: cancel, group: group}
+	if pager != nil {
+		pager.start(ctx, cancel)
+	}
+	if idleTimeoutFlag > 0 {
+		idle = startIdleWatcher(idleTimeoutFlag, cancel)
+		defer idle.stop()
+	}
+
+	switch {
+	case groupCommitFlag:
+		fmt.Fprintf(errWriter, "Committing offsets of group %v every %v and on exit.\n", group, commitIntervalFlag)
+	case noCommitFlag:
+		fmt.Fprintf(errWriter, "Not committing offsets of group %v (--no-commit).\n", group)
+	default:
+		fmt.Fprintf(errWriter, "Not committing offsets of group %v, use --commit to commit them.\n", group)
+	}
 
-	err = cg.Consume(ctx, []string{topic}, &g{})
-	if err != nil {
-		errorExit("Error on consume: %v", err)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+			return
+		}
+		// Give the session time to finish the current messages and commit.
+		select {
+		case <-time.After(groupShutdownTimeout):
+			errorExit("Timed out after %v committing offsets of group %v", groupShutdownTimeout, group)
+		case <-done:
+		}
+	}()
+
+	// Consume returns on rebalances, a new session is joined until
+	// interrupted.
+	for ctx.Err() == nil {
+		err = cg.Consume(ctx, []string{topic}, handler)
+		if err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				break
+			}
+			errorExit("Error on consume: %v", err)
+		}
+	}
+	if err := cg.Close(); err != nil {
+		errorExit("Failed to close consumer group: %v", err)
 	}
+	close(done)
+	deadLetters.close()
+
+	if handler.err != nil {
+		errorExit("Stopped consuming, offsets were committed up to the last message written: %v", handler.err)
+	}
+	idle.report()
+	reportOversized()
 }
 
-func withoutConsumerGroup(ctx context.Context, client sarama.Client, topic string, offset int64) {
-	consumer, err := sarama.NewConsumerFromClient(client)
-	if err != nil {
-		errorExit("Unable to create consumer from client: %v\n", err)
+func withoutConsumerGroup(ctx context.Context, client *kaf.Client, topic string, offset int64) {
+	if findKeyFlag != "" && !allMatchesFlag {
+		ctx, stopFinding = context.WithCancel(ctx)
+		defer stopFinding()
+	}
+
+	if idleTimeoutFlag > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		idle = startIdleWatcher(idleTimeoutFlag, cancel)
+		defer idle.stop()
+	}
+
+	if pager != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		pager.start(ctx, cancel)
+	}
+
+	if positions != nil {
+		// Stop consuming on SIGINT or SIGTERM to save the state file.
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
 	}
 
 	var partitions []int32
+	var err error
 	if len(flagPartitions) == 0 {
-		partitions, err = consumer.Partitions(topic)
+		partitions, err = client.Sarama().Partitions(topic)
 		if err != nil {
 			errorExit("Unable to get partitions: %v\n", err)
 		}
 	} else {
+		available, err := client.Sarama().Partitions(topic)
+		if err != nil {
+			errorExit("Unable to get partitions: %v\n", err)
+		}
+		for _, partition := range flagPartitions {
+			if !containsPartition(available, partition) {
+				errorExit("Partition %v does not exist on topic %v", partition, topic)
+			}
+		}
 		partitions = flagPartitions
 	}
 
-	schemaCache = getSchemaCache()
+	schemaCache = getSchemaCache(topic)
+	keySchemaCache = getKeySchemaCache(topic)
+	protoRegistry = getProtoRegistryDecoder(topic + "-value")
+	keyProtoRegistry = getProtoRegistryDecoder(topic + "-key")
 
-	wg := sync.WaitGroup{}
-	mu := sync.Mutex{} // Synchronizes stderr and stdout.
-	for _, partition := range partitions {
-		wg.Add(1)
+	var groupOffsets map[int32]int64
+	if offsetFromGroupFlag != "" {
+		groupOffsets = getCommittedOffsets(offsetFromGroupFlag, topic, partitions)
+	}
 
-		go func(partition int32, offset int64) {
-			defer wg.Done()
+	var consumed int64
+	counts := make(map[int32]int64, len(partitions))
 
-			offsets, err := getOffsets(client, topic, partition)
-			if err != nil {
-				errorExit("Failed to get %s offsets for partition %d: %w", topic, partition, err)
-			}
+	// Per partition state, each used only while consuming its partition.
+	partitionCounts := make(map[int32]*int64, len(partitions))
+	partitionDigests := make(map[int32]*partitionDigest, len(partitions))
+	for _, partition := range partitions {
+		partitionCounts[partition] = new(int64)
+		if digest != nil {
+			partitionDigests[partition] = digest.partition(partition)
+		}
+	}
 
+	mu := sync.Mutex{} // Synchronizes stderr and stdout.
+	err = client.Consume(ctx, kaf.ConsumeOptions{
+		Topic:      topic,
+		Partitions: partitions,
+		StartOffset: func(partition int32, oldest, newest int64) (int64, error) {
+			offset := offset
 			if tail != 0 {
-				offset = offsets.newest - int64(tail)
-				if offset < offsets.oldest {
-					offset = offsets.oldest
+				offset = newest - int64(tail)
+				if offset < oldest {
+					offset = oldest
+				}
+			} else if startOffset != nil {
+				offset = startOffset.resolve(oldest, newest)
+				if verbose {
+					mu.Lock()
+					fmt.Fprintf(errWriter, "Starting partition %v at offset %v (--offset %v, oldest %v, newest %v)\n", partition, offset, offsetFlag, oldest, newest)
+					mu.Unlock()
 				}
 			}
 
-			// Already at end of partition, return early
-			if !follow && offsets.newest == offsets.oldest {
-				return
+			if fromTimeFlag != "" {
+				var err error
+				offset, err = client.Sarama().GetOffset(topic, partition, fromTime.UnixNano()/int64(time.Millisecond))
+				if err != nil {
+					return 0, fmt.Errorf("unable to get offset at %v: %w", fromTime, err)
+				}
+				if offset == -1 {
+					// No message at or after the time.
+					offset = newest
+				}
 			}
 
-			pc, err := consumer.ConsumePartition(topic, partition, offset)
-			if err != nil {
-				errorExit("Unable to consume partition: %v %v %v %v\n", topic, partition, offset, err)
-			}
-
-			var count int64 = 0
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case msg := <-pc.Messages():
-					handleMessage(msg, &mu)
-					count++
-					if limitMessagesFlag > 0 && count >= limitMessagesFlag {
-						return
-					}
-					if !follow && msg.Offset+1 >= pc.HighWaterMarkOffset() {
-						return
+			if offsetFromGroupFlag != "" {
+				offset = oldest
+				if committed, ok := groupOffsets[partition]; ok && committed > oldest {
+					offset = committed
+					if offset > newest {
+						offset = newest
 					}
 				}
 			}
-		}(partition, offset)
+
+			if positions != nil {
+				if resumed, ok := positions.start(partition, oldest, newest, errWriter); ok {
+					offset = resumed
+				}
+			}
+			return offset, nil
+		},
+		Follow:         follow,
+		WaitAtEnd:      !exitOnEOFFlag,
+		Limit:          limitMessagesFlag,
+		EndIdleTimeout: consumeEndIdleTimeout,
+		Handle: func(msg *sarama.ConsumerMessage) error {
+			if toOffsetFlag >= 0 && msg.Offset > toOffsetFlag {
+				return kaf.ErrStopPartition
+			}
+			idle.seen()
+			if partitionDigest := partitionDigests[msg.Partition]; partitionDigest != nil {
+				partitionDigest.add(msg)
+			} else if !countFlag {
+				if err := handleMessage(msg, &mu); err != nil && positions != nil {
+					// Not recorded, the next run resumes at msg.
+					return kaf.ErrStopPartition
+				}
+			}
+			if positions != nil {
+				positions.consumed(msg.Partition, msg.Offset)
+			}
+			atomic.AddInt64(&consumed, 1)
+			*partitionCounts[msg.Partition]++
+			if toOffsetFlag >= 0 && msg.Offset >= toOffsetFlag {
+				return kaf.ErrStopPartition
+			}
+			return nil
+		},
+	})
+	if err != nil && ctx.Err() == nil {
+		errorExit("Unable to consume topic %v: %v", topic, err)
+	}
+	for partition, count := range partitionCounts {
+		counts[partition] = *count
+	}
+	deadLetters.close()
+	idle.report()
+
+	if positions != nil {
+		if err := positions.save(); err != nil {
+			errorExit("Failed to write state file: %v", err)
+		}
+	}
+
+	if snapshot != nil {
+		keys, err := snapshot.flush(func(msg *sarama.ConsumerMessage) error { return outputMessage(msg, &mu) })
+		if err == nil && snapshotOut != nil {
+			err = snapshotOut.commit()
+		}
+		if err != nil {
+			if snapshotOut != nil {
+				snapshotOut.abort()
+			}
+			errorExit("Failed to write snapshot: %v", err)
+		}
+		snapshot.printSummary(keys)
+		if snapshotOut != nil {
+			fmt.Fprintf(errWriter, "Wrote snapshot to %v. Restore it with kaf produce --from-dump %v\n", snapshotToFlag, snapshotToFlag)
+		}
+	}
+
+	if dedup != nil {
+		for _, msg := range dedup.flush() {
+			outputMessage(msg, &mu)
+		}
+		dedup.printSummary()
+	}
+
+	if countFlag {
+		printCounts(partitions, counts)
+		return
+	}
+
+	if digest != nil {
+		printDigests(outWriter, partitions, digest.summary(partitions))
+		return
+	}
+
+	if avroExport != nil {
+		avroExport.close()
+		return
+	}
+
+	if findKeyFlag != "" {
+		if atomic.LoadInt64(&findKeyMatches) == 0 {
+			errorExit("No message with key %q found", findKeyFlag)
+		}
+		return
+	}
+
+	if exitOnEOFFlag && consumed == 0 {
+		fmt.Fprintln(errWriter, "0 messages")
+	}
+	if sampleFlag > 0 {
+		fmt.Fprintf(errWriter, "Printed %v of %v messages (--sample %v --sample-seed %v)\n", atomic.LoadInt64(&samplePrinted), atomic.LoadInt64(&sampleSeen), sampleFlag, sampleSeedFlag)
+	}
+	if skipped := atomic.LoadInt64(&rateSkipped); skipped > 0 {
+		fmt.Fprintf(errWriter, "Skipped printing %v of %v messages due to --rate\n", skipped, consumed)
+	}
+	reportOversized()
+}
+
+func printCounts(partitions []int32, counts map[int32]int64) {
+	sorted := append([]int32(nil), partitions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total int64
+	for _, partition := range sorted {
+		total += counts[partition]
+	}
+
+	if outputFormat == OutputFormatJSON {
+		perPartition := make(map[string]int64, len(sorted))
+		for _, partition := range sorted {
+			perPartition[strconv.Itoa(int(partition))] = counts[partition]
+		}
+		b, err := json.Marshal(map[string]interface{}{
+			"partitions": perPartition,
+			"total":      total,
+		})
+		if err != nil {
+			errorExit("Failed to encode counts: %v", err)
+		}
+		fmt.Fprintln(outWriter, string(b))
+		return
+	}
+
+	w := tabwriter.NewWriter(outWriter, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	if !noHeaderFlag {
+		fmt.Fprintf(w, "PARTITION\tCOUNT\t\n")
+	}
+	for _, partition := range sorted {
+		fmt.Fprintf(w, "%v\t%v\t\n", partition, counts[partition])
+	}
+	fmt.Fprintf(w, "Total\t%v\t\n", total)
+	w.Flush()
+}
+
+// handleMessage outputs msg unless it is filtered. The error is the error
+// writing to stdout, if any.
+func handleMessage(msg *sarama.ConsumerMessage, mu *sync.Mutex) error {
+	if valueCipher != nil {
+		msg = decryptMessage(msg)
+	}
+
+	if sampleFlag > 0 {
+		atomic.AddInt64(&sampleSeen, 1)
+		if !sampled(sampleFlag, sampleSeedFlag, msg.Partition, msg.Offset) {
+			return nil
+		}
+		atomic.AddInt64(&samplePrinted, 1)
+	}
+
+	if snapshot != nil {
+		if err := snapshot.offer(msg); err != nil {
+			errorExit("Failed to build snapshot: %v", err)
+		}
+		return nil
+	}
+
+	if dedup != nil && !dedup.offer(msg) {
+		return nil
+	}
+
+	if findKeyFlag != "" {
+		if !matchesKey(msg, findKeyFlag) {
+			return nil
+		}
+		// Partitions are consumed concurrently, only the first match is
+		// printed without --all-matches.
+		if atomic.AddInt64(&findKeyMatches, 1) > 1 && !allMatchesFlag {
+			return nil
+		}
+		err := outputMessage(msg, mu)
+		if !allMatchesFlag {
+			stopFinding()
+		}
+		return err
+	}
+
+	return outputMessage(msg, mu)
+}
+
+// matchesKey reports whether the raw or decoded key of msg equals key.
+func matchesKey(msg *sarama.ConsumerMessage, key string) bool {
+	if msg.Key == nil {
+		return false
+	}
+	if string(msg.Key) == key {
+		return true
+	}
+	var stderr bytes.Buffer
+	return string(decodeKey(msg, &stderr)) == key
+}
+
+// outputMessage prints msg, or writes it to the --to-avro file.
+func outputMessage(msg *sarama.ConsumerMessage, mu *sync.Mutex) error {
+	if avroExport != nil {
+		avroExport.write(msg)
+		return nil
 	}
-	wg.Wait()
+	return printMessage(msg, mu)
 }
 
-func handleMessage(msg *sarama.ConsumerMessage, mu *sync.Mutex) {
+func printMessage(msg *sarama.ConsumerMessage, mu *sync.Mutex) error {
+	if outputLimiter != nil {
+		if rateModeFlag == "skip" {
+			if !outputLimiter.Allow() {
+				atomic.AddInt64(&rateSkipped, 1)
+				return nil
+			}
+		} else {
+			outputLimiter.Wait()
+		}
+	}
+
 	var stderr bytes.Buffer
 
+	if keysOnlyFlag {
+		return printKey(msg, decodeKey(msg, &stderr), &stderr, mu)
+	}
+
+	msg, ok := limitValueSize(msg, &stderr)
+	if !ok {
+		mu.Lock()
+		stderr.WriteTo(errWriter)
+		mu.Unlock()
+		return nil
+	}
+
 	var dataToDisplay []byte
 	var keyToDisplay []byte
 	var err error
+	// valueErr is the error decoding the value, if any.
+	var valueErr error
 
-	if protoType != "" {
-		dataToDisplay, err = protoDecode(reg, msg.Value, protoType)
-		if err != nil {
-			fmt.Fprintf(&stderr, "failed to decode proto. falling back to binary outputla. Error: %v\n", err)
-		}
-	} else {
-		dataToDisplay, err = avroDecode(msg.Value)
+	value := msg.Value
+	if valueDecompress != "" && len(value) > 0 {
+		decompressed, err := decompressValue(valueDecompress, value)
 		if err != nil {
-			fmt.Fprintf(&stderr, "could not decode Avro data: %v\n", err)
+			fmt.Fprintf(&stderr, "could not decompress value at partition %v offset %v, using it as is: %v\n", msg.Partition, msg.Offset, err)
+		} else {
+			value = decompressed
 		}
 	}
 
-	if keyProtoType != "" {
-		keyToDisplay, err = protoDecode(reg, msg.Key, keyProtoType)
+	if len(valueDecoders) > 0 {
+		var decoder string
+		dataToDisplay, decoder = decodeWithFallback(valueDecoders, value, protoType, schemaCache, protoRegistry)
+		if decoder == "" {
+			valueErr = fmt.Errorf("none of the decoders %v succeeded", strings.Join(valueDecoders, ","))
+		}
+		if verbose {
+			fmt.Fprintf(&stderr, "decoded value at partition %v offset %v as %v\n", msg.Partition, msg.Offset, decoderName(decoder))
+		}
+	} else if protoType != "" {
+		dataToDisplay, err = protoDecode(reg, value, protoType)
 		if err != nil {
-			fmt.Fprintf(&stderr, "failed to decode proto key. falling back to binary outputla. Error: %v\n", err)
+			valueErr = err
+			fmt.Fprintf(&stderr, "failed to decode proto. falling back to binary outputla. Error: %v\n", err)
 		}
 	} else {
-		keyToDisplay, err = avroDecode(msg.Key)
+		dataToDisplay, err = registryDecode(msg, value, &stderr)
 		if err != nil {
-			fmt.Fprintf(&stderr, "could not decode Avro data: %v\n", err)
+			valueErr = err
+			fmt.Fprintf(&stderr, "could not decode registry data: %v\n", err)
 		}
 	}
 
+	keyToDisplay = decodeKey(msg, &stderr)
+
 	if decodeMsgPack {
 		var obj interface{}
-		err = msgpack.Unmarshal(msg.Value, &obj)
+		err = msgpack.Unmarshal(value, &obj)
 		if err != nil {
+			valueErr = err
 			fmt.Fprintf(&stderr, "could not decode msgpack data: %v\n", err)
+		} else {
+			// The value is shown as msgpack, earlier decoders failing does
+			// not matter.
+			valueErr = nil
 		}
 
 		dataToDisplay, err = json.Marshal(obj)
@@ -283,13 +1171,92 @@ func handleMessage(msg *sarama.ConsumerMessage, mu *sync.Mutex) {
 		}
 	}
 
+	if valueErr != nil && deadLetters != nil {
+		deadLetters.write(msg, valueErr)
+		return nil
+	}
+
+	if transformer != nil {
+		var keep bool
+		msg, keyToDisplay, dataToDisplay, keep = transformMessage(msg, keyToDisplay, dataToDisplay)
+		if !keep {
+			return nil
+		}
+	}
+
 	dataToDisplay = formatMessage(msg, dataToDisplay, keyToDisplay, &stderr)
 
+	return writeOutput(dataToDisplay, &stderr, mu)
+}
+
+// writeOutput writes the diagnostics of a message to stderr and data as a
+// line to stdout.
+func writeOutput(data []byte, stderr *bytes.Buffer, mu *sync.Mutex) error {
 	mu.Lock()
+	defer mu.Unlock()
+	if pager != nil {
+		if err := pager.wait(data, stderr.Bytes()); err != nil {
+			return err
+		}
+	}
 	stderr.WriteTo(errWriter)
-	_, _ = colorableOut.Write(dataToDisplay)
-	fmt.Fprintln(outWriter)
-	mu.Unlock()
+	if _, err := colorableOut.Write(data); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(outWriter)
+	return err
+}
+
+// decodeKey decodes the key of msg with --key-decode or like the value, using
+// the registry of the -key subject.
+func decodeKey(msg *sarama.ConsumerMessage, stderr *bytes.Buffer) []byte {
+	var keyToDisplay []byte
+	var err error
+	if len(keyDecoders) > 0 {
+		var decoder string
+		keyToDisplay, decoder = decodeWithFallback(keyDecoders, msg.Key, keyProtoType, keySchemaCache, keyProtoRegistry)
+		if verbose && len(msg.Key) > 0 {
+			fmt.Fprintf(stderr, "decoded key at partition %v offset %v as %v\n", msg.Partition, msg.Offset, decoderName(decoder))
+		}
+	} else if keyProtoType != "" {
+		keyToDisplay, err = protoDecode(reg, msg.Key, keyProtoType)
+		if err != nil {
+			fmt.Fprintf(stderr, "failed to decode proto key. falling back to binary outputla. Error: %v\n", err)
+		}
+	} else {
+		keyToDisplay, err = registryDecodeKey(msg, stderr)
+		if err != nil {
+			fmt.Fprintf(stderr, "could not decode registry data: %v\n", err)
+		}
+	}
+	return keyToDisplay
+}
+
+// printKey prints only the key of msg for --keys-only, messages without key
+// are skipped.
+func printKey(msg *sarama.ConsumerMessage, keyToDisplay []byte, stderr *bytes.Buffer, mu *sync.Mutex) error {
+	if len(msg.Key) == 0 {
+		mu.Lock()
+		stderr.WriteTo(errWriter)
+		mu.Unlock()
+		return nil
+	}
+
+	switch outputFormat {
+	case OutputFormatJSON:
+		jsonKey, err := json.Marshal(map[string]interface{}{"key": formatJSON(keyToDisplay)})
+		if err != nil {
+			fmt.Fprintf(stderr, "could not encode key as JSON: %v\n", err)
+		}
+		keyToDisplay = jsonKey
+	case OutputFormatRaw:
+	default:
+		if isJSON(keyToDisplay) {
+			keyToDisplay = formatKey(keyToDisplay)
+		}
+	}
+
+	return writeOutput(keyToDisplay, stderr, mu)
 }
 
 func formatMessage(msg *sarama.ConsumerMessage, rawMessage []byte, keyToDisplay []byte, stderr *bytes.Buffer) []byte {
@@ -301,7 +1268,7 @@ func formatMessage(msg *sarama.ConsumerMessage, rawMessage []byte, keyToDisplay
 
 		jsonMessage["partition"] = msg.Partition
 		jsonMessage["offset"] = msg.Offset
-		jsonMessage["timestamp"] = msg.Timestamp
+		jsonMessage["timestamp"] = formatTimestamp(msg.Timestamp)
 
 		if len(msg.Headers) > 0 {
 			jsonMessage["headers"] = msg.Headers
@@ -354,13 +1321,42 @@ func formatMessage(msg *sarama.ConsumerMessage, rawMessage []byte, keyToDisplay
 		if msg.Key != nil && len(msg.Key) > 0 {
 			fmt.Fprintf(w, "Key:\t%v\n", string(keyToDisplay))
 		}
-		fmt.Fprintf(w, "Partition:\t%v\nOffset:\t%v\nTimestamp:\t%v\n", msg.Partition, msg.Offset, msg.Timestamp)
+		fmt.Fprintf(w, "Partition:\t%v\nOffset:\t%v\nTimestamp:\t%v\n", msg.Partition, msg.Offset, formatTimestamp(msg.Timestamp))
 		w.Flush()
 
 		return rawMessage
 	}
 }
 
+func validateTimeFormat(format string) error {
+	switch format {
+	case "", "rfc3339", "unix", "local":
+		return nil
+	}
+	// A layout without any time element formats to itself, e.g. a misspelled
+	// keyword.
+	if time.Unix(0, 0).Format(format) == format {
+		return fmt.Errorf("invalid --time-format %q. Possible values: rfc3339, unix, local or a Go time layout such as '2006-01-02 15:04:05'", format)
+	}
+	return nil
+}
+
+// formatTimestamp formats a message timestamp as selected with --time-format.
+// For unix it returns the milliseconds since the epoch as a number, so JSON
+// output contains a number instead of a string.
+func formatTimestamp(t time.Time) interface{} {
+	switch timeFormatFlag {
+	case "", "rfc3339":
+		return t.UTC().Format(time.RFC3339Nano)
+	case "unix":
+		return t.UnixNano() / int64(time.Millisecond)
+	case "local":
+		return t.Local().Format(time.RFC3339Nano)
+	default:
+		return t.UTC().Format(timeFormatFlag)
+	}
+}
+
 // proto to JSON
 func protoDecode(reg *proto.DescriptorRegistry, b []byte, _type string) ([]byte, error) {
 	dynamicMessage := reg.MessageForType(_type)
@@ -384,11 +1380,61 @@ func protoDecode(reg *proto.DescriptorRegistry, b []byte, _type string) ([]byte,
 
 }
 
-func avroDecode(b []byte) ([]byte, error) {
-	if schemaCache != nil {
-		return schemaCache.DecodeMessage(b)
+// registryDecode decodes values in the Confluent wire format with the
+// Protobuf or Avro schema of the registry. Other values, and values with a
+// schema ID unknown to the registry, are returned as is.
+func registryDecode(msg *sarama.ConsumerMessage, b []byte, stderr *bytes.Buffer) ([]byte, error) {
+	return decodeWithRegistry(schemaCache, protoRegistry, b, "value", msg, stderr)
+}
+
+func registryDecodeKey(msg *sarama.ConsumerMessage, stderr *bytes.Buffer) ([]byte, error) {
+	return decodeWithRegistry(keySchemaCache, keyProtoRegistry, msg.Key, "key", msg, stderr)
+}
+
+// decodeWithRegistry decodes b, the field of msg, if it has a valid schema ID
+// in the Confluent wire format. With --verbose, the detected schema is
+// logged to stderr.
+func decodeWithRegistry(cache *avro.SchemaCache, protoDecoder *proto.RegistryDecoder, b []byte, field string, msg *sarama.ConsumerMessage, stderr *bytes.Buffer) ([]byte, error) {
+	schemaID, ok := confluentSchemaID(b)
+	if !ok || (cache == nil && protoDecoder == nil) {
+		return b, nil
+	}
+	decoded, format, err := decodeRegistered(cache, protoDecoder, b)
+	if errors.Is(err, avro.ErrSchemaNotFound) || errors.Is(err, proto.ErrSchemaNotFound) {
+		if verbose {
+			fmt.Fprintf(stderr, "%v at partition %v offset %v starts like the Confluent wire format, but schema %v is not in the registry. Using it as is\n", field, msg.Partition, msg.Offset, schemaID)
+		}
+		return b, nil
+	}
+	if err != nil {
+		return b, err
+	}
+	if verbose && format != "" {
+		fmt.Fprintf(stderr, "decoded %v at partition %v offset %v as %v with schema %v\n", field, msg.Partition, msg.Offset, format, schemaID)
+	}
+	return decoded, nil
+}
+
+// decodeRegistered decodes a value in the Confluent wire format and returns
+// the name of its format, or an empty name if it was left as is.
+func decodeRegistered(cache *avro.SchemaCache, protoDecoder *proto.RegistryDecoder, b []byte) ([]byte, string, error) {
+	if protoDecoder != nil {
+		decoded, err := protoDecoder.Decode(b)
+		if err == nil {
+			return decoded, "Protobuf", nil
+		}
+		if err != proto.ErrNotProtobuf {
+			return b, "", err
+		}
+	}
+	if cache != nil {
+		decoded, err := cache.DecodeMessage(b)
+		if err != nil {
+			return b, "", err
+		}
+		return decoded, "Avro", nil
 	}
-	return b, nil
+	return b, "", nil
 }
 
 func formatKey(key []byte) []byte {
@@ -423,6 +1469,64 @@ func isJSON(data []byte) bool {
 	return false
 }
 
+// partitionList is a flag value holding partitions given as a comma separated
+// list of single partitions and inclusive ranges, e.g. "0,2,5-7".
+type partitionList []int32
+
+func (p *partitionList) String() string {
+	parts := make([]string, 0, len(*p))
+	for _, partition := range *p {
+		parts = append(parts, strconv.Itoa(int(partition)))
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+func (p *partitionList) Set(v string) error {
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to := part, part
+		if i := strings.Index(part, "-"); i > 0 {
+			from, to = part[:i], part[i+1:]
+		}
+
+		start, err := strconv.ParseInt(from, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid partition %q", part)
+		}
+		end, err := strconv.ParseInt(to, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid partition %q", part)
+		}
+		if start < 0 || end < start {
+			return fmt.Errorf("invalid partition range %q", part)
+		}
+
+		for partition := start; partition <= end; partition++ {
+			if !containsPartition(*p, int32(partition)) {
+				*p = append(*p, int32(partition))
+			}
+		}
+	}
+	return nil
+}
+
+func (p *partitionList) Type() string {
+	return "partitions"
+}
+
+func containsPartition(partitions []int32, partition int32) bool {
+	for _, p := range partitions {
+		if p == partition {
+			return true
+		}
+	}
+	return false
+}
+
 type OutputFormat string
 
 const (
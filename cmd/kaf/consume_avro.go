@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/IBM/sarama"
+	"github.com/linkedin/goavro/v2"
+)
+
+// avroBlockSize is the number of records written per OCF block.
+const avroBlockSize = 1000
+
+// avroExporter writes Confluent Avro encoded messages to an Avro object
+// container file. The file schema is the schema of the first message, messages
+// encoded with other schemas are skipped.
+type avroExporter struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	// wrap stores every message in a record with its metadata.
+	wrap bool
+
+	schemaID int
+	codec    *goavro.Codec
+	writer   *goavro.OCFWriter
+	pending  []interface{}
+
+	written, skipped int64
+	warned           map[int]bool
+}
+
+func newAvroExporter(path string, wrap bool) (*avroExporter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &avroExporter{
+		path:   path,
+		file:   file,
+		wrap:   wrap,
+		warned: make(map[int]bool),
+	}, nil
+}
+
+func (e *avroExporter) write(msg *sarama.ConsumerMessage) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(msg.Value) < 5 || msg.Value[0] != 0x00 {
+		e.skip(msg, -1)
+		return
+	}
+	schemaID := int(binary.BigEndian.Uint32(msg.Value[1:5]))
+
+	if e.writer == nil {
+		if err := e.init(schemaID); err != nil {
+			errorExit("Unable to write Avro file: %v", err)
+		}
+	}
+	if schemaID != e.schemaID {
+		e.skip(msg, schemaID)
+		return
+	}
+
+	native, _, err := e.codec.NativeFromBinary(msg.Value[5:])
+	if err != nil {
+		errorExit("Unable to decode Avro value at partition %v offset %v: %v", msg.Partition, msg.Offset, err)
+	}
+	if e.wrap {
+		var key interface{}
+		if msg.Key != nil {
+			key = goavro.Union("bytes", msg.Key)
+		}
+		native = map[string]interface{}{
+			"topic":     msg.Topic,
+			"partition": msg.Partition,
+			"offset":    msg.Offset,
+			"timestamp": msg.Timestamp,
+			"key":       key,
+			"value":     native,
+		}
+	}
+
+	e.pending = append(e.pending, native)
+	e.written++
+	if len(e.pending) >= avroBlockSize {
+		e.flush()
+	}
+}
+
+func (e *avroExporter) init(schemaID int) error {
+	if schemaCache == nil {
+		return fmt.Errorf("a schema registry is required")
+	}
+	codec, err := schemaCache.CodecForSchemaID(schemaID)
+	if err != nil {
+		return err
+	}
+
+	schema := codec.Schema()
+	if e.wrap {
+		schema = `{"type":"record","name":"KafkaRecord","namespace":"kaf","fields":[` +
+			`{"name":"topic","type":"string"},` +
+			`{"name":"partition","type":"int"},` +
+			`{"name":"offset","type":"long"},` +
+			`{"name":"timestamp","type":{"type":"long","logicalType":"timestamp-millis"}},` +
+			`{"name":"key","type":["null","bytes"]},` +
+			`{"name":"value","type":` + schema + `}]}`
+	}
+
+	writer, err := goavro.NewOCFWriter(goavro.OCFConfig{
+		W:               e.file,
+		Schema:          schema,
+		CompressionName: goavro.CompressionDeflateLabel,
+	})
+	if err != nil {
+		return err
+	}
+	e.schemaID = schemaID
+	e.codec = codec
+	e.writer = writer
+	return nil
+}
+
+func (e *avroExporter) skip(msg *sarama.ConsumerMessage, schemaID int) {
+	e.skipped++
+	if e.warned[schemaID] {
+		return
+	}
+	e.warned[schemaID] = true
+	if schemaID == -1 {
+		fmt.Fprintf(errWriter, "Skipping messages not encoded with a registry schema, first at partition %v offset %v\n", msg.Partition, msg.Offset)
+	} else {
+		fmt.Fprintf(errWriter, "Skipping messages encoded with schema ID %v, the file uses schema ID %v\n", schemaID, e.schemaID)
+	}
+}
+
+func (e *avroExporter) flush() {
+	if len(e.pending) == 0 {
+		return
+	}
+	if err := e.writer.Append(e.pending); err != nil {
+		errorExit("Unable to write Avro file: %v", err)
+	}
+	e.pending = e.pending[:0]
+}
+
+func (e *avroExporter) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.writer != nil {
+		e.flush()
+	}
+	if err := e.file.Close(); err != nil {
+		errorExit("Unable to write Avro file: %v", err)
+	}
+	if e.writer == nil {
+		// Without a schema there is no valid file to write.
+		_ = os.Remove(e.path)
+		fmt.Fprintf(errWriter, "No Avro records found, %v was not written. Skipped %v.\n", e.path, e.skipped)
+		return
+	}
+	fmt.Fprintf(errWriter, "Wrote %v records to %v, skipped %v.\n", e.written, e.path, e.skipped)
+}
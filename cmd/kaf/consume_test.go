@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"github.com/birdayz/kaf/pkg/config"
+	"github.com/birdayz/kaf/pkg/proto"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestPartitionList(t *testing.T) {
+	var p partitionList
+	require.NoError(t, p.Set("0,2,5-7"))
+	require.NoError(t, p.Set("2,9"))
+	require.Equal(t, partitionList{0, 2, 5, 6, 7, 9}, p)
+
+	require.Error(t, p.Set("a"))
+	require.Error(t, p.Set("3-1"))
+}
+
+func TestDecodeWithFallback(t *testing.T) {
+	decoders, err := parseDecoders([]string{"auto"})
+	require.NoError(t, err)
+
+	out, decoder := decodeWithFallback(decoders, []byte(`{"a":1}`), "", nil, nil)
+	require.Equal(t, "json", decoder)
+	require.Equal(t, `{"a":1}`, string(out))
+
+	_, decoder = decodeWithFallback(decoders, []byte("plain text"), "", nil, nil)
+	require.Equal(t, "raw", decoder)
+
+	out, decoder = decodeWithFallback(decoders, []byte{0xff, 0xfe}, "", nil, nil)
+	require.Equal(t, "hex", decoder)
+	require.Equal(t, "fffe", string(out))
+
+	decoders, err = parseDecoders([]string{"json"})
+	require.NoError(t, err)
+	out, decoder = decodeWithFallback(decoders, []byte("plain text"), "", nil, nil)
+	require.Equal(t, "", decoder)
+	require.Equal(t, "plain text", string(out))
+
+	_, err = parseDecoders([]string{"xml"})
+	require.Error(t, err)
+}
+
+func TestFieldDecoder(t *testing.T) {
+	for value, want := range map[string][]string{
+		"auto":   nil,
+		"raw":    {"as-is"},
+		"string": {"raw"},
+		"base64": {"base64"},
+		"avro":   {"avro"},
+	} {
+		decoders, err := parseFieldDecoder(value)
+		require.NoError(t, err, value)
+		require.Equal(t, want, decoders, value)
+	}
+	_, err := parseFieldDecoder("xml")
+	require.Error(t, err)
+
+	out, decoder := decodeWithFallback([]string{"as-is"}, []byte{0xff, 'a'}, "", nil, nil)
+	require.Equal(t, "as-is", decoder)
+	require.Equal(t, []byte{0xff, 'a'}, out)
+	out, _ = decodeWithFallback([]string{"base64"}, []byte{0xff, 'a'}, "", nil, nil)
+	require.Equal(t, "/2E=", string(out))
+	_, decoder = decodeWithFallback([]string{"raw"}, []byte{0xff}, "", nil, nil)
+	require.Equal(t, "", decoder, "string requires valid UTF-8")
+}
+
+func TestCheckFieldDecoder(t *testing.T) {
+	defer func(c *config.Cluster) { currentCluster = c }(currentCluster)
+	currentCluster = &config.Cluster{}
+
+	require.NoError(t, checkFieldDecoder("string", "orders-key", ""))
+	require.Error(t, checkFieldDecoder("avro", "orders-value", ""))
+	require.Error(t, checkFieldDecoder("proto", "orders-value", ""))
+	require.NoError(t, checkFieldDecoder("proto", "orders-value", "com.example.Order"))
+
+	currentCluster.SchemaRegistryURL = "http://registry:8081"
+	require.NoError(t, checkFieldDecoder("avro", "orders-value", ""))
+	require.NoError(t, checkFieldDecoder("proto", "orders-key", ""))
+}
+
+func TestConfluentSchemaID(t *testing.T) {
+	id, ok := confluentSchemaID([]byte{0, 0, 0, 1, 2, 'x'})
+	require.True(t, ok)
+	require.Equal(t, 258, id)
+
+	for _, b := range [][]byte{
+		nil,
+		[]byte("plain"),
+		{0, 0, 0, 1},
+		{0, 0, 0, 0, 0, 'x'},
+		{0, 0x80, 0, 0, 1},
+	} {
+		_, ok := confluentSchemaID(b)
+		require.False(t, ok, "%x", b)
+	}
+}
+
+func TestDecodeWithRegistryUnknownSchema(t *testing.T) {
+	registry := httptest.NewServer(http.NotFoundHandler())
+	defer registry.Close()
+	verbose = true
+	defer func() { verbose = false }()
+
+	decoder := proto.NewRegistryDecoder(registry.URL, "", "")
+	msg := &sarama.ConsumerMessage{Partition: 1, Offset: 5}
+	value := []byte{0, 0, 0, 0, 9, 0, 'x'}
+	var stderr bytes.Buffer
+	out, err := decodeWithRegistry(nil, decoder, value, "value", msg, &stderr)
+	require.NoError(t, err)
+	require.Equal(t, value, out)
+	require.Equal(t, "value at partition 1 offset 5 starts like the Confluent wire format, but schema 9 is not in the registry. Using it as is\n", stderr.String())
+
+	// Values without a valid schema ID are not looked up.
+	stderr.Reset()
+	out, err = decodeWithRegistry(nil, decoder, []byte("plain"), "value", msg, &stderr)
+	require.NoError(t, err)
+	require.Equal(t, "plain", string(out))
+	require.Empty(t, stderr.String())
+}
+
+func TestSampled(t *testing.T) {
+	var selected int
+	for offset := int64(0); offset < 100000; offset++ {
+		if sampled(0.01, 42, 0, offset) {
+			selected++
+		}
+	}
+	require.InDelta(t, 1000, selected, 200)
+
+	for offset := int64(0); offset < 100; offset++ {
+		require.Equal(t, sampled(0.5, 7, 3, offset), sampled(0.5, 7, 3, offset))
+	}
+	require.True(t, sampled(1, 0, 0, 0))
+}
+
+func TestPrintKey(t *testing.T) {
+	var out bytes.Buffer
+	origOut, origColorable, origFormat := outWriter, colorableOut, outputFormat
+	outWriter, colorableOut = &out, &out
+	defer func() { outWriter, colorableOut, outputFormat = origOut, origColorable, origFormat }()
+
+	var mu sync.Mutex
+	print := func(key string) {
+		msg := &sarama.ConsumerMessage{Key: []byte(key), Value: []byte("ignored")}
+		var stderr bytes.Buffer
+		printKey(msg, decodeKey(msg, &stderr), &stderr, &mu)
+	}
+
+	outputFormat = OutputFormatDefault
+	print("user-1")
+	print("")
+	print(`{"id": 2}`)
+	require.Equal(t, "user-1\n{ \"id\": 2 }\n", out.String())
+
+	out.Reset()
+	outputFormat = OutputFormatJSON
+	print("user-1")
+	print(`{"id":2}`)
+	require.Equal(t, "{\"key\":\"user-1\"}\n{\"key\":{\"id\":2}}\n", out.String())
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("broken pipe") }
+
+func TestHandleMessageWriteError(t *testing.T) {
+	origOut, origColorable, origErr := outWriter, colorableOut, errWriter
+	defer func() { outWriter, colorableOut, errWriter = origOut, origColorable, origErr }()
+	var stderr bytes.Buffer
+	errWriter = &stderr
+
+	var mu sync.Mutex
+	msg := &sarama.ConsumerMessage{Value: []byte("value")}
+
+	var out bytes.Buffer
+	outWriter, colorableOut = &out, &out
+	require.NoError(t, handleMessage(msg, &mu))
+	require.Contains(t, out.String(), "value")
+
+	outWriter, colorableOut = failingWriter{}, failingWriter{}
+	require.Error(t, handleMessage(msg, &mu))
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	orig := timeFormatFlag
+	defer func() { timeFormatFlag = orig }()
+
+	ts := time.Date(2024, 3, 1, 12, 30, 15, 250*int(time.Millisecond), time.FixedZone("CET", 3600))
+	for format, expected := range map[string]interface{}{
+		"":                    "2024-03-01T11:30:15.25Z",
+		"rfc3339":             "2024-03-01T11:30:15.25Z",
+		"unix":                int64(1709292615250),
+		"2006-01-02 15:04:05": "2024-03-01 11:30:15",
+	} {
+		timeFormatFlag = format
+		require.NoError(t, validateTimeFormat(format))
+		require.Equal(t, expected, formatTimestamp(ts), format)
+	}
+
+	timeFormatFlag = "local"
+	require.Equal(t, ts.Local().Format(time.RFC3339Nano), formatTimestamp(ts))
+
+	require.Error(t, validateTimeFormat("iso"))
+}
+
+func TestHandleMessageFindKey(t *testing.T) {
+	origOut, origColorable, origFind, origAll, origStop := outWriter, colorableOut, findKeyFlag, allMatchesFlag, stopFinding
+	defer func() {
+		outWriter, colorableOut, findKeyFlag, allMatchesFlag, stopFinding = origOut, origColorable, origFind, origAll, origStop
+		findKeyMatches = 0
+	}()
+	var out bytes.Buffer
+	outWriter, colorableOut = &out, &out
+
+	var stopped int
+	stopFinding = func() { stopped++ }
+	findKeyFlag = "abc123"
+
+	var mu sync.Mutex
+	messages := []*sarama.ConsumerMessage{
+		{Key: []byte("other"), Value: []byte("value-1")},
+		{Value: []byte("value-2")},
+		{Key: []byte("abc123"), Value: []byte("value-3")},
+		{Key: []byte("abc123"), Value: []byte("value-4")},
+	}
+	for _, msg := range messages {
+		require.NoError(t, handleMessage(msg, &mu))
+	}
+	require.Contains(t, out.String(), "value-3")
+	require.NotContains(t, out.String(), "value-1")
+	require.NotContains(t, out.String(), "value-4")
+	require.Equal(t, 1, stopped)
+
+	out.Reset()
+	findKeyMatches = 0
+	allMatchesFlag = true
+	for _, msg := range messages {
+		require.NoError(t, handleMessage(msg, &mu))
+	}
+	require.Contains(t, out.String(), "value-3")
+	require.Contains(t, out.String(), "value-4")
+	require.NotContains(t, out.String(), "value-2")
+	require.Equal(t, int64(2), findKeyMatches)
+}
+
+func TestHandleMessageDeadLetters(t *testing.T) {
+	origOut, origColorable, origErr := outWriter, colorableOut, errWriter
+	origDecoders, origMsgPack, origDeadLetters := valueDecoders, decodeMsgPack, deadLetters
+	defer func() {
+		outWriter, colorableOut, errWriter = origOut, origColorable, origErr
+		valueDecoders, decodeMsgPack, deadLetters = origDecoders, origMsgPack, origDeadLetters
+	}()
+	var out, stderr bytes.Buffer
+	outWriter, colorableOut, errWriter = &out, &out, &stderr
+
+	path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+	var err error
+	deadLetters, err = newDeadLetterWriter(path)
+	require.NoError(t, err)
+
+	packed, err := msgpack.Marshal(map[string]string{"id": "o-1"})
+	require.NoError(t, err)
+	var mu sync.Mutex
+	valueDecoders = []string{"json"}
+
+	// Not JSON, dead lettered.
+	require.NoError(t, handleMessage(&sarama.ConsumerMessage{Offset: 1, Value: packed}, &mu))
+	// Not JSON, but decoded as msgpack afterwards.
+	decodeMsgPack = true
+	require.NoError(t, handleMessage(&sarama.ConsumerMessage{Offset: 2, Value: packed}, &mu))
+	require.Contains(t, out.String(), `"o-1"`)
+
+	deadLetters.close()
+	require.Contains(t, stderr.String(), "Wrote 1 messages failing to decode to "+path)
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"offset":1`)
+	require.NotContains(t, string(b), `"offset":2`)
+}
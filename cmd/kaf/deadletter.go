@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// deadLetter is a message that failed to decode, as written to the dead
+// letter file. Key and value are base64 encoded by encoding/json.
+type deadLetter struct {
+	Topic     string    `json:"topic"`
+	Partition int32     `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Timestamp time.Time `json:"timestamp"`
+	Key       []byte    `json:"key"`
+	Value     []byte    `json:"value"`
+	Error     string    `json:"error"`
+}
+
+// deadLetterWriter writes messages failing to decode to a JSON lines file.
+type deadLetterWriter struct {
+	mu    sync.Mutex
+	path  string
+	file  *os.File
+	count int64
+}
+
+func newDeadLetterWriter(path string) (*deadLetterWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &deadLetterWriter{path: path, file: file}, nil
+}
+
+func (d *deadLetterWriter) write(msg *sarama.ConsumerMessage, decodeErr error) {
+	b, err := json.Marshal(deadLetter{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Timestamp: msg.Timestamp,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Error:     decodeErr.Error(),
+	})
+	if err != nil {
+		errorExit("Failed to encode dead letter: %v", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// Unbuffered, so the file is complete when consume is interrupted.
+	if _, err := d.file.Write(append(b, '\n')); err != nil {
+		errorExit("Failed to write dead letter file: %v", err)
+	}
+	d.count++
+}
+
+// close closes the file and reports the number of messages written to it. It
+// can be called on a nil writer.
+func (d *deadLetterWriter) close() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.file.Close(); err != nil {
+		errorExit("Failed to write dead letter file: %v", err)
+	}
+	fmt.Fprintf(errWriter, "Wrote %v messages failing to decode to %v\n", d.count, d.path)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/birdayz/kaf/pkg/avro"
+	"github.com/birdayz/kaf/pkg/proto"
+)
+
+// autoDecoders is the fallback chain used by --decode auto.
+var autoDecoders = []string{"avro", "proto", "json", "raw", "hex"}
+
+var errDecoderNotApplicable = errors.New("not applicable")
+
+// confluentSchemaID returns the schema ID of a value in the Confluent wire
+// format: a zero magic byte followed by the big endian schema ID. Registries
+// assign positive IDs, so values starting with four or more zero bytes or
+// with the sign bit set are not taken for the wire format.
+func confluentSchemaID(b []byte) (int, bool) {
+	if len(b) < 5 || b[0] != 0x00 {
+		return 0, false
+	}
+	id := int32(binary.BigEndian.Uint32(b[1:5]))
+	if id <= 0 {
+		return 0, false
+	}
+	return int(id), true
+}
+
+// parseDecoders resolves the values of --decode into an ordered list of
+// decoder names.
+func parseDecoders(values []string) ([]string, error) {
+	var decoders []string
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		switch v {
+		case "auto":
+			decoders = append(decoders, autoDecoders...)
+		case "avro", "proto", "json", "raw", "hex", "base64":
+			decoders = append(decoders, v)
+		case "string":
+			decoders = append(decoders, "raw")
+		default:
+			return nil, fmt.Errorf("unknown decoder %q. Possible values: auto, avro, proto, json, raw, string, hex, base64", v)
+		}
+	}
+	return decoders, nil
+}
+
+// parseFieldDecoder resolves the value of --key-decode or --value-decode into
+// the decoders of the key or value. auto returns nil, which keeps the
+// decoding of --decode or the automatic detection. Unlike in --decode, raw
+// passes bytes through unchanged and string requires valid UTF-8.
+func parseFieldDecoder(value string) ([]string, error) {
+	switch value {
+	case "auto":
+		return nil, nil
+	case "raw":
+		return []string{"as-is"}, nil
+	case "string":
+		return []string{"raw"}, nil
+	case "json", "avro", "proto", "hex", "base64":
+		return []string{value}, nil
+	}
+	return nil, fmt.Errorf("unknown decoder %q. Possible values: auto, string, json, avro, proto, hex, base64, raw", value)
+}
+
+// checkFieldDecoder returns an error if decoder, the value of --key-decode or
+// --value-decode, can never decode the field of subject: avro without a
+// registry, and proto without a registry or message type.
+func checkFieldDecoder(decoder, subject, messageType string) error {
+	switch {
+	case decoder == "avro" && currentCluster.SchemaRegistryForSubject(subject) == nil:
+		return fmt.Errorf("avro requires a schema registry for subject %v", subject)
+	case decoder == "proto" && messageType == "" && currentCluster.SchemaRegistryForSubject(subject) == nil:
+		return fmt.Errorf("proto requires a schema registry for subject %v, or the message type with --proto-type or --key-proto-type", subject)
+	}
+	return nil
+}
+
+// decodeWithFallback tries the decoders in order and returns the output of the
+// first one that succeeds, together with its name. Avro and registry encoded
+// Protobuf are decoded with cache and protoDecoder, those of the -key or
+// -value subject. The hex and base64 decoders always succeed; if no decoder
+// succeeds the value is returned as is.
+func decodeWithFallback(decoders []string, b []byte, messageType string, cache *avro.SchemaCache, protoDecoder *proto.RegistryDecoder) ([]byte, string) {
+	for _, decoder := range decoders {
+		var decoded []byte
+		var err error
+		switch decoder {
+		case "avro":
+			if _, ok := confluentSchemaID(b); cache == nil || !ok {
+				continue
+			}
+			decoded, err = cache.DecodeMessage(b)
+		case "proto":
+			if messageType == "" && protoDecoder != nil {
+				// Without a type, only registry encoded messages can be decoded.
+				decoded, err = protoDecoder.Decode(b)
+				break
+			}
+			if reg == nil || messageType == "" || reg.MessageForType(messageType) == nil {
+				continue
+			}
+			decoded, err = protoDecode(reg, b, messageType)
+		case "json":
+			if !isJSON(b) {
+				err = errDecoderNotApplicable
+			}
+			decoded = b
+		case "raw":
+			if !utf8.Valid(b) {
+				err = errDecoderNotApplicable
+			}
+			decoded = b
+		case "hex":
+			decoded = []byte(hex.EncodeToString(b))
+		case "base64":
+			decoded = []byte(base64.StdEncoding.EncodeToString(b))
+		case "as-is":
+			decoded = b
+		}
+		if err == nil {
+			return decoded, decoder
+		}
+	}
+	return b, ""
+}
+
+func decoderName(decoder string) string {
+	if decoder == "" {
+		return "none"
+	}
+	return decoder
+}
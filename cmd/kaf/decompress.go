@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	lz4Magic  = []byte{0x04, 0x22, 0x4d, 0x18}
+
+	zstdDictMagic = []byte{0x37, 0xa4, 0x30, 0xec}
+
+	zstdDecoderOnce sync.Once
+	zstdDecoder     *zstd.Decoder
+	zstdDecoderErr  error
+	// zstdDict is the dictionary used by the zstd decoder, see loadZstdDict.
+	zstdDict []byte
+)
+
+// loadZstdDict reads a zstd dictionary, as created by zstd --train, to be used
+// when decompressing zstd values. It must be called before the first value is
+// decompressed.
+func loadZstdDict(path string) error {
+	dict, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(dict, zstdDictMagic) {
+		return fmt.Errorf("%v is not a zstd dictionary", path)
+	}
+	zstdDict = dict
+	_, err = getZstdDecoder()
+	return err
+}
+
+// getZstdDecoder returns the shared zstd decoder, which is safe for concurrent
+// use by DecodeAll.
+func getZstdDecoder() (*zstd.Decoder, error) {
+	zstdDecoderOnce.Do(func() {
+		var opts []zstd.DOption
+		if zstdDict != nil {
+			opts = append(opts, zstd.WithDecoderDicts(zstdDict))
+		}
+		zstdDecoder, zstdDecoderErr = zstd.NewReader(nil, opts...)
+	})
+	return zstdDecoder, zstdDecoderErr
+}
+
+// decompressValue decompresses a record value that was compressed by the
+// producing application, independent of Kafka's batch compression. An error
+// is returned if the value is not compressed with the given codec.
+func decompressValue(codec string, b []byte) ([]byte, error) {
+	switch codec {
+	case "gzip":
+		if !bytes.HasPrefix(b, gzipMagic) {
+			return nil, fmt.Errorf("value is not gzip compressed")
+		}
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	case "zstd":
+		if !bytes.HasPrefix(b, zstdMagic) {
+			return nil, fmt.Errorf("value is not zstd compressed")
+		}
+		decoder, err := getZstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+		return decoder.DecodeAll(b, nil)
+	case "lz4":
+		if !bytes.HasPrefix(b, lz4Magic) {
+			return nil, fmt.Errorf("value is not lz4 compressed")
+		}
+		return ioutil.ReadAll(lz4.NewReader(bytes.NewReader(b)))
+	case "snappy":
+		return snappy.Decode(nil, b)
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", codec)
+	}
+}
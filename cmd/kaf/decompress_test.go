@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecompressValue(t *testing.T) {
+	value := bytes.Repeat([]byte(`{"id":1,"name":"order"}`), 50)
+
+	for _, tc := range []struct {
+		codec    string
+		compress func(t *testing.T, b []byte) []byte
+		// corrupt is a value with the magic bytes of the codec, if it has
+		// any, and an invalid body.
+		corrupt []byte
+	}{
+		{
+			codec: "gzip",
+			compress: func(t *testing.T, b []byte) []byte {
+				var buf bytes.Buffer
+				w := gzip.NewWriter(&buf)
+				_, err := w.Write(b)
+				require.NoError(t, err)
+				require.NoError(t, w.Close())
+				return buf.Bytes()
+			},
+			corrupt: append(append([]byte(nil), gzipMagic...), 0x08, 0, 0xff, 0xff, 0xff),
+		},
+		{
+			codec: "zstd",
+			compress: func(t *testing.T, b []byte) []byte {
+				w, err := zstd.NewWriter(nil)
+				require.NoError(t, err)
+				defer w.Close()
+				return w.EncodeAll(b, nil)
+			},
+			corrupt: append(append([]byte(nil), zstdMagic...), 0xff, 0xff, 0xff, 0xff),
+		},
+		{
+			codec: "lz4",
+			compress: func(t *testing.T, b []byte) []byte {
+				var buf bytes.Buffer
+				w := lz4.NewWriter(&buf)
+				_, err := w.Write(b)
+				require.NoError(t, err)
+				require.NoError(t, w.Close())
+				return buf.Bytes()
+			},
+			corrupt: append(append([]byte(nil), lz4Magic...), 0xff, 0xff, 0xff, 0xff),
+		},
+		{
+			codec: "snappy",
+			compress: func(t *testing.T, b []byte) []byte {
+				return snappy.Encode(nil, b)
+			},
+			corrupt: []byte{0xff, 0xff, 0xff, 0xff, 0x0f},
+		},
+	} {
+		t.Run(tc.codec, func(t *testing.T) {
+			compressed := tc.compress(t, value)
+			require.NotEqual(t, value, compressed)
+			out, err := decompressValue(tc.codec, compressed)
+			require.NoError(t, err)
+			require.Equal(t, value, out)
+
+			_, err = decompressValue(tc.codec, tc.corrupt)
+			require.Error(t, err)
+
+			_, err = decompressValue(tc.codec, compressed[:len(compressed)/2])
+			require.Error(t, err, "truncated values do not decompress")
+		})
+	}
+}
+
+func TestDecompressValueNotCompressed(t *testing.T) {
+	for _, codec := range []string{"gzip", "zstd", "lz4"} {
+		_, err := decompressValue(codec, []byte(`{"id":1}`))
+		require.EqualError(t, err, "value is not "+codec+" compressed")
+	}
+	_, err := decompressValue("brotli", []byte("x"))
+	require.EqualError(t, err, `unsupported compression "brotli"`)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/IBM/sarama"
+)
+
+// deduplicator suppresses messages with a key seen before. In first mode the
+// first message per key is printed right away, in last mode the latest message
+// per key is kept and printed by flush. At most maxKeys keys are tracked,
+// messages with further keys are passed through.
+type deduplicator struct {
+	mu      sync.Mutex
+	last    bool
+	maxKeys int
+
+	seenKeys map[string]struct{}
+	latest   map[string]*sarama.ConsumerMessage
+
+	seen, passed int64
+	warned       bool
+}
+
+func newDeduplicator(mode string, maxKeys int) *deduplicator {
+	return &deduplicator{
+		last:     mode == "last",
+		maxKeys:  maxKeys,
+		seenKeys: make(map[string]struct{}),
+		latest:   make(map[string]*sarama.ConsumerMessage),
+	}
+}
+
+func (d *deduplicator) size() int {
+	if d.last {
+		return len(d.latest)
+	}
+	return len(d.seenKeys)
+}
+
+// offer returns whether msg is to be printed now.
+func (d *deduplicator) offer(msg *sarama.ConsumerMessage) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.seen++
+	key := string(msg.Key)
+
+	_, tracked := d.seenKeys[key]
+	if !tracked {
+		_, tracked = d.latest[key]
+	}
+	if !tracked && d.size() >= d.maxKeys {
+		if !d.warned {
+			fmt.Fprintf(errWriter, "Warning: --dedup-max-keys limit of %v keys reached, messages with further keys are not deduplicated. Raising the limit increases memory usage\n", d.maxKeys)
+			d.warned = true
+		}
+		d.passed++
+		return true
+	}
+
+	if d.last {
+		d.latest[key] = msg
+		return false
+	}
+	if tracked {
+		return false
+	}
+	d.seenKeys[key] = struct{}{}
+	d.passed++
+	return true
+}
+
+// flush returns the messages kept in last mode, ordered by partition and
+// offset.
+func (d *deduplicator) flush() []*sarama.ConsumerMessage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	msgs := make([]*sarama.ConsumerMessage, 0, len(d.latest))
+	for _, msg := range d.latest {
+		msgs = append(msgs, msg)
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		if msgs[i].Partition != msgs[j].Partition {
+			return msgs[i].Partition < msgs[j].Partition
+		}
+		return msgs[i].Offset < msgs[j].Offset
+	})
+	d.passed += int64(len(msgs))
+	d.latest = make(map[string]*sarama.ConsumerMessage)
+	return msgs
+}
+
+func (d *deduplicator) printSummary() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.seen == 0 {
+		return
+	}
+	suppressed := d.seen - d.passed
+	fmt.Fprintf(errWriter, "Deduplicated %v messages to %v (%.1f%% duplicates)\n", d.seen, d.passed, float64(suppressed)/float64(d.seen)*100)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicator(t *testing.T) {
+	msgs := []*sarama.ConsumerMessage{
+		{Key: []byte("a"), Offset: 0, Value: []byte("a1")},
+		{Key: []byte("b"), Offset: 1, Value: []byte("b1")},
+		{Key: []byte("a"), Offset: 2, Value: []byte("a2")},
+		{Key: []byte("c"), Offset: 3, Value: []byte("c1")},
+	}
+
+	first := newDeduplicator("first", 10)
+	var printed []string
+	for _, msg := range msgs {
+		if first.offer(msg) {
+			printed = append(printed, string(msg.Value))
+		}
+	}
+	require.Equal(t, []string{"a1", "b1", "c1"}, printed)
+
+	last := newDeduplicator("last", 10)
+	for _, msg := range msgs {
+		require.False(t, last.offer(msg))
+	}
+	printed = nil
+	for _, msg := range last.flush() {
+		printed = append(printed, string(msg.Value))
+	}
+	require.Equal(t, []string{"b1", "a2", "c1"}, printed)
+
+	// Keys beyond the limit are passed through.
+	bounded := newDeduplicator("first", 1)
+	require.True(t, bounded.offer(msgs[0]))
+	require.True(t, bounded.offer(msgs[1]))
+	require.True(t, bounded.offer(msgs[1]))
+	require.False(t, bounded.offer(msgs[2]))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/birdayz/kaf/pkg/kaf"
+)
+
+var (
+	diagnoseFlag bool
+
+	// diagnosed is set once the connection was diagnosed, so a failure after
+	// --diagnose is not diagnosed twice.
+	diagnosed bool
+)
+
+// runDiagnosis checks the connection to the brokers of the current cluster
+// and exits if a step failed.
+func runDiagnosis() {
+	diagnosed = true
+	if !writeDiagnosis(errWriter, kaf.Diagnose(currentCluster)) {
+		os.Exit(1)
+	}
+}
+
+// diagnoseConnectionError diagnoses the connection if err is a connection
+// failure, to explain the opaque error of sarama.
+func diagnoseConnectionError(err error) {
+	if diagnosed || outputFormat == OutputFormatJSON || classifyError(err) != errorCodeConnection {
+		return
+	}
+	diagnosed = true
+	fmt.Fprintf(errWriter, "Unable to connect, diagnosing the connection:\n")
+	writeDiagnosis(errWriter, kaf.Diagnose(currentCluster))
+}
+
+// writeDiagnosis prints the checks grouped by broker with the hint of failed
+// checks. It returns whether all checks succeeded.
+func writeDiagnosis(w io.Writer, checks []kaf.Check) bool {
+	ok := true
+	broker := "\x00"
+	for _, check := range checks {
+		if check.Broker != broker {
+			broker = check.Broker
+			if broker != "" {
+				fmt.Fprintf(w, "%v\n", broker)
+			}
+		}
+		if check.Err != nil {
+			ok = false
+			fmt.Fprintf(w, "  \xE2\x9D\x8C %-6v %v\n", check.Step, check.Err)
+			fmt.Fprintf(w, "         %v\n", check.Hint)
+			continue
+		}
+		fmt.Fprintf(w, "  \xE2\x9C\x85 %-6v %v\n", check.Step, check.Detail)
+	}
+	return ok
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/birdayz/kaf/pkg/kaf"
+)
+
+func TestWriteDiagnosis(t *testing.T) {
+	var buf bytes.Buffer
+	ok := writeDiagnosis(&buf, []kaf.Check{
+		{Step: kaf.StepToken, Detail: "fetched a token"},
+		{Broker: "kafka-1:9093", Step: kaf.StepDNS, Detail: "resolved to 10.0.0.1 in 1ms"},
+		{Broker: "kafka-1:9093", Step: kaf.StepTLS, Err: errors.New("x509: certificate signed by unknown authority"), Hint: "Set TLS.cafile."},
+	})
+	require.False(t, ok)
+	require.Equal(t, "  ✅ token  fetched a token\n"+
+		"kafka-1:9093\n"+
+		"  ✅ dns    resolved to 10.0.0.1 in 1ms\n"+
+		"  ❌ tls    x509: certificate signed by unknown authority\n"+
+		"         Set TLS.cafile.\n", buf.String())
+
+	require.True(t, writeDiagnosis(&bytes.Buffer{}, []kaf.Check{{Broker: "kafka-1:9093", Step: kaf.StepSASL}}))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/IBM/sarama"
+)
+
+var (
+	digestFlag bool
+
+	// digest holds the per partition digests with --digest.
+	digest *digester
+)
+
+// partitionDigest is the running SHA-256 of the messages of a partition.
+type partitionDigest struct {
+	hash  hash.Hash
+	count int64
+}
+
+// add hashes the offset, key and value of msg. Key and value are prefixed
+// with their length, -1 for nil, so that moving bytes between them changes
+// the digest.
+func (p *partitionDigest) add(msg *sarama.ConsumerMessage) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(msg.Offset))
+	p.hash.Write(buf[:])
+	for _, b := range [][]byte{msg.Key, msg.Value} {
+		length := int64(len(b))
+		if b == nil {
+			length = -1
+		}
+		binary.BigEndian.PutUint64(buf[:], uint64(length))
+		p.hash.Write(buf[:])
+		p.hash.Write(b)
+	}
+	p.count++
+}
+
+// digester computes a digest per partition and a combined digest, to compare
+// topics or runs for equality.
+type digester struct {
+	mu         sync.Mutex
+	partitions map[int32]*partitionDigest
+}
+
+func newDigester() *digester {
+	return &digester{partitions: make(map[int32]*partitionDigest)}
+}
+
+// partition returns the digest of partition. Messages of a partition are
+// consumed by one goroutine, so the returned digest needs no locking.
+func (d *digester) partition(partition int32) *partitionDigest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p, ok := d.partitions[partition]
+	if !ok {
+		p = &partitionDigest{hash: sha256.New()}
+		d.partitions[partition] = p
+	}
+	return p
+}
+
+type partitionDigestJSON struct {
+	Count  int64  `json:"count"`
+	Digest string `json:"digest"`
+}
+
+type digestJSON struct {
+	Partitions map[string]partitionDigestJSON `json:"partitions"`
+	Count      int64                          `json:"count"`
+	Digest     string                         `json:"digest"`
+}
+
+// summary returns the digests of partitions and the combined digest, the
+// SHA-256 of partition, count and digest of every partition in partition
+// order.
+func (d *digester) summary(partitions []int32) digestJSON {
+	sorted := append([]int32(nil), partitions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	s := digestJSON{Partitions: make(map[string]partitionDigestJSON, len(sorted))}
+	total := sha256.New()
+	for _, partition := range sorted {
+		p := d.partition(partition)
+		sum := p.hash.Sum(nil)
+		var buf [12]byte
+		binary.BigEndian.PutUint32(buf[:4], uint32(partition))
+		binary.BigEndian.PutUint64(buf[4:], uint64(p.count))
+		total.Write(buf[:])
+		total.Write(sum)
+
+		s.Partitions[strconv.Itoa(int(partition))] = partitionDigestJSON{Count: p.count, Digest: hex.EncodeToString(sum)}
+		s.Count += p.count
+	}
+	s.Digest = hex.EncodeToString(total.Sum(nil))
+	return s
+}
+
+func printDigests(w io.Writer, partitions []int32, s digestJSON) {
+	if outputFormat == OutputFormatJSON {
+		b, err := json.Marshal(s)
+		if err != nil {
+			errorExit("Failed to encode digests: %v", err)
+		}
+		fmt.Fprintln(w, string(b))
+		return
+	}
+
+	sorted := append([]int32(nil), partitions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	tw := tabwriter.NewWriter(w, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	if !noHeaderFlag {
+		fmt.Fprintf(tw, "PARTITION\tCOUNT\tDIGEST\t\n")
+	}
+	for _, partition := range sorted {
+		p := s.Partitions[strconv.Itoa(int(partition))]
+		fmt.Fprintf(tw, "%v\t%v\t%v\t\n", partition, p.Count, p.Digest)
+	}
+	fmt.Fprintf(tw, "Total\t%v\t%v\t\n", s.Count, s.Digest)
+	tw.Flush()
+}
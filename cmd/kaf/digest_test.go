@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+func digestOf(msgs ...*sarama.ConsumerMessage) digestJSON {
+	d := newDigester()
+	for _, msg := range msgs {
+		d.partition(msg.Partition).add(msg)
+	}
+	return d.summary([]int32{1, 0})
+}
+
+func TestDigest(t *testing.T) {
+	a := &sarama.ConsumerMessage{Partition: 0, Offset: 0, Key: []byte("k"), Value: []byte("v1")}
+	b := &sarama.ConsumerMessage{Partition: 0, Offset: 1, Value: []byte("v2")}
+	c := &sarama.ConsumerMessage{Partition: 1, Offset: 0, Key: []byte("k"), Value: []byte("v3")}
+
+	s := digestOf(a, b, c)
+	require.Equal(t, int64(3), s.Count)
+	require.Equal(t, int64(2), s.Partitions["0"].Count)
+	require.Equal(t, s, digestOf(c, a, b), "the order across partitions does not matter")
+
+	// Moving bytes between key and value or changing an offset changes the
+	// digest.
+	moved := &sarama.ConsumerMessage{Partition: 0, Offset: 0, Key: []byte("kv"), Value: []byte("1")}
+	require.NotEqual(t, s.Partitions["0"].Digest, digestOf(moved, b, c).Partitions["0"].Digest)
+	shifted := &sarama.ConsumerMessage{Partition: 0, Offset: 2, Value: []byte("v2")}
+	require.NotEqual(t, s.Digest, digestOf(a, shifted, c).Digest)
+	require.Equal(t, s.Partitions["1"], digestOf(a, shifted, c).Partitions["1"])
+
+	// An empty key differs from no key.
+	empty := &sarama.ConsumerMessage{Partition: 0, Offset: 1, Key: []byte{}, Value: []byte("v2")}
+	require.NotEqual(t, s.Digest, digestOf(a, empty, c).Digest)
+
+	// Partitions without messages are part of the digest.
+	empties := newDigester().summary([]int32{0, 1})
+	require.Equal(t, int64(0), empties.Count)
+	require.Len(t, empties.Partitions, 2)
+	require.NotEqual(t, newDigester().summary([]int32{0}).Digest, empties.Digest)
+}
+
+func TestPrintDigests(t *testing.T) {
+	s := digestOf(&sarama.ConsumerMessage{Partition: 1, Value: []byte("v")})
+
+	var buf bytes.Buffer
+	printDigests(&buf, []int32{1, 0}, s)
+	require.Contains(t, buf.String(), "PARTITION")
+	require.Contains(t, buf.String(), s.Digest)
+
+	outputFormat = OutputFormatJSON
+	defer func() { outputFormat = OutputFormatDefault }()
+	buf.Reset()
+	printDigests(&buf, []int32{1, 0}, s)
+	var decoded digestJSON
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.Equal(t, s, decoded)
+}
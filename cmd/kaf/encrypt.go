@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/IBM/sarama"
+)
+
+var (
+	encryptFlag           bool
+	decryptFlag           bool
+	encryptionKeyFileFlag string
+
+	// valueCipher encrypts values with --encrypt and decrypts them with
+	// --decrypt.
+	valueCipher cipher.AEAD
+)
+
+// encryptionMagic starts every value encrypted by kaf, followed by the
+// 12 byte nonce and the AES-GCM ciphertext and tag. The last byte is the
+// version of the format.
+var encryptionMagic = []byte{'K', 'A', 'F', 0x01}
+
+var errNotEncrypted = errors.New("value is not encrypted by kaf")
+
+// loadEncryptionKey reads an AES key of 16, 24 or 32 bytes, for AES-128,
+// AES-192 or AES-256, from a file. The key may be stored raw, hex or base64
+// encoded.
+func loadEncryptionKey(path string) (cipher.AEAD, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key := parseEncryptionKey(b)
+	if key == nil {
+		return nil, fmt.Errorf("%v does not contain a 16, 24 or 32 byte key, raw, hex or base64 encoded", path)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// parseEncryptionKey tries hex and base64 before the raw bytes, so that a hex
+// encoded AES-128 key is not taken as a raw AES-256 key.
+func parseEncryptionKey(b []byte) []byte {
+	validLength := func(key []byte) bool {
+		return len(key) == 16 || len(key) == 24 || len(key) == 32
+	}
+	trimmed := bytes.TrimSpace(b)
+	if key, err := hex.DecodeString(string(trimmed)); err == nil && validLength(key) {
+		return key
+	}
+	if key, err := base64.StdEncoding.DecodeString(string(trimmed)); err == nil && validLength(key) {
+		return key
+	}
+	for _, key := range [][]byte{b, trimmed} {
+		if validLength(key) {
+			return key
+		}
+	}
+	return nil
+}
+
+func encryptValue(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(encryptionMagic)+len(nonce)+len(plaintext)+aead.Overhead())
+	out = append(out, encryptionMagic...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, nil), nil
+}
+
+// decryptValue returns errNotEncrypted for values without the kaf framing.
+func decryptValue(aead cipher.AEAD, b []byte) ([]byte, error) {
+	if !bytes.HasPrefix(b, encryptionMagic) || len(b) < len(encryptionMagic)+aead.NonceSize() {
+		return nil, errNotEncrypted
+	}
+	b = b[len(encryptionMagic):]
+	nonce, ciphertext := b[:aead.NonceSize()], b[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt value, wrong key or corrupted value: %w", err)
+	}
+	return plaintext, nil
+}
+
+// setupEncryption loads the --encryption-key-file if enabled is set.
+func setupEncryption(enabled bool, flag string) {
+	if !enabled {
+		if encryptionKeyFileFlag != "" {
+			errorExit("--encryption-key-file requires %v", flag)
+		}
+		return
+	}
+	if encryptionKeyFileFlag == "" {
+		errorExit("%v requires --encryption-key-file", flag)
+	}
+	var err error
+	valueCipher, err = loadEncryptionKey(encryptionKeyFileFlag)
+	if err != nil {
+		errorExit("Invalid --encryption-key-file: %v", err)
+	}
+}
+
+// decryptMessage returns a copy of msg with the decrypted value. Values that
+// cannot be decrypted are kept as they are, with a warning.
+func decryptMessage(msg *sarama.ConsumerMessage) *sarama.ConsumerMessage {
+	if len(msg.Value) == 0 {
+		return msg
+	}
+	plaintext, err := decryptValue(valueCipher, msg.Value)
+	if err != nil {
+		fmt.Fprintf(errWriter, "could not decrypt value at partition %v offset %v, using it as is: %v\n", msg.Partition, msg.Offset, err)
+		return msg
+	}
+	decrypted := *msg
+	decrypted.Value = plaintext
+	return &decrypted
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptValue(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f\n"), 0o600))
+
+	aead, err := loadEncryptionKey(keyFile)
+	require.NoError(t, err)
+
+	encrypted, err := encryptValue(aead, []byte(`{"card":"4111"}`))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(encrypted, encryptionMagic))
+	require.NotContains(t, string(encrypted), "4111")
+
+	decrypted, err := decryptValue(aead, encrypted)
+	require.NoError(t, err)
+	require.Equal(t, `{"card":"4111"}`, string(decrypted))
+
+	_, err = decryptValue(aead, []byte(`{"card":"4111"}`))
+	require.ErrorIs(t, err, errNotEncrypted)
+
+	encrypted[len(encrypted)-1] ^= 0xff
+	_, err = decryptValue(aead, encrypted)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("too short"), 0o600))
+	_, err = loadEncryptionKey(keyFile)
+	require.Error(t, err)
+
+	require.Len(t, parseEncryptionKey([]byte("AAECAwQFBgcICQoLDA0ODw==")), 16)
+	require.Len(t, parseEncryptionKey([]byte("0123456789abcdef")), 16)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/IBM/sarama"
+
+	"github.com/birdayz/kaf/pkg/kaf"
+)
+
+// Error codes of the JSON error objects printed with --output json.
+const (
+	errorCodeConnection = "connection"
+	errorCodeAuth       = "auth"
+	errorCodeNotFound   = "not_found"
+	errorCodeConfig     = "config"
+	errorCodeKafka      = "kafka"
+	errorCodeGeneric    = "error"
+)
+
+// jsonError is printed instead of the plain message if a command fails with
+// --output json.
+type jsonError struct {
+	Error   string `json:"error"`
+	Code    string `json:"code"`
+	Cluster string `json:"cluster,omitempty"`
+}
+
+// errorCode classifies the first error among args.
+func errorCode(args []interface{}) string {
+	for _, arg := range args {
+		err, ok := arg.(error)
+		if !ok {
+			continue
+		}
+		return classifyError(err)
+	}
+	return errorCodeGeneric
+}
+
+func classifyError(err error) string {
+	var kerr sarama.KError
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var pathErr *os.PathError
+	var configErr sarama.ConfigurationError
+	var tokenErr *kaf.TokenFetchError
+	switch {
+	case errors.Is(err, sarama.ErrOutOfBrokers), errors.Is(err, sarama.ErrNotConnected), errors.Is(err, sarama.ErrBrokerNotAvailable), errors.As(err, &opErr), errors.As(err, &dnsErr):
+		return errorCodeConnection
+	case errors.As(err, &tokenErr):
+		return errorCodeAuth
+	case errors.Is(err, kaf.ErrPlainWithoutTLS), errors.As(err, &configErr), errors.As(err, &pathErr):
+		return errorCodeConfig
+	case errors.As(err, &kerr):
+		switch kerr {
+		case sarama.ErrSASLAuthenticationFailed, sarama.ErrTopicAuthorizationFailed, sarama.ErrGroupAuthorizationFailed,
+			sarama.ErrClusterAuthorizationFailed, sarama.ErrTransactionalIDAuthorizationFailed, sarama.ErrDelegationTokenAuthorizationFailed:
+			return errorCodeAuth
+		case sarama.ErrUnknownTopicOrPartition, sarama.ErrGroupIDNotFound:
+			return errorCodeNotFound
+		}
+		return errorCodeKafka
+	}
+	return errorCodeGeneric
+}
+
+func writeJSONError(w io.Writer, message string, code string) {
+	obj := jsonError{Error: strings.TrimSpace(message), Code: code}
+	if currentCluster != nil {
+		obj.Cluster = currentCluster.Name
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		fmt.Fprintln(w, message)
+		return
+	}
+	fmt.Fprintln(w, string(b))
+}
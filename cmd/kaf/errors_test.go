@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+
+	"github.com/birdayz/kaf/pkg/config"
+	"github.com/birdayz/kaf/pkg/kaf"
+)
+
+func TestErrorCode(t *testing.T) {
+	for code, err := range map[string]error{
+		errorCodeConnection: fmt.Errorf("kafka: client has run out of available brokers: %w", sarama.ErrOutOfBrokers),
+		errorCodeAuth:       sarama.ErrTopicAuthorizationFailed,
+		errorCodeNotFound:   fmt.Errorf("describe: %w", sarama.ErrUnknownTopicOrPartition),
+		errorCodeKafka:      sarama.ErrNotController,
+		errorCodeConfig:     kaf.ErrPlainWithoutTLS,
+		errorCodeGeneric:    errors.New("boom"),
+	} {
+		require.Equal(t, code, errorCode([]interface{}{"ignored", err}), err.Error())
+	}
+	_, err := os.Open("/does/not/exist")
+	require.Equal(t, errorCodeConfig, errorCode([]interface{}{err}))
+	require.Equal(t, errorCodeAuth, errorCode([]interface{}{fmt.Errorf("sasl: %w", &kaf.TokenFetchError{Err: errors.New("oauth2: 401")})}))
+	require.Equal(t, errorCodeGeneric, errorCode([]interface{}{"no error", 3}))
+}
+
+func TestWriteJSONError(t *testing.T) {
+	defer func(c *config.Cluster) { currentCluster = c }(currentCluster)
+	currentCluster = &config.Cluster{Name: "staging"}
+
+	var buf bytes.Buffer
+	writeJSONError(&buf, "Unable to get client: kafka: client has run out of available brokers\n", errorCodeConnection)
+	require.JSONEq(t, `{"error": "Unable to get client: kafka: client has run out of available brokers", "code": "connection", "cluster": "staging"}`, buf.String())
+}
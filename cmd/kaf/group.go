@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"sort"
+	"strings"
 	"unicode"
 
 	"text/tabwriter"
@@ -39,6 +42,7 @@ func init() {
 	rootCmd.AddCommand(groupCmd)
 	rootCmd.AddCommand(groupsCmd)
 	groupCmd.AddCommand(groupDescribeCmd)
+	groupCmd.AddCommand(groupMembersCmd)
 	groupCmd.AddCommand(groupLsCmd)
 	groupCmd.AddCommand(groupDeleteCmd)
 	groupCmd.AddCommand(groupPeekCmd)
@@ -54,6 +58,16 @@ func init() {
 
 	groupDescribeCmd.Flags().BoolVar(&flagNoMembers, "no-members", false, "Hide members section of the output")
 	groupDescribeCmd.Flags().StringSliceVarP(&flagDescribeTopics, "topic", "t", []string{}, "topics to display for the group. defaults to all topics.")
+	groupDescribeCmd.Flags().Var(&outputFormat, "output", "Set output format: default, json")
+	if err := groupDescribeCmd.RegisterFlagCompletionFunc("output", completeOutputFormat); err != nil {
+		errorExit("Failed to register flag completion: %v", err)
+	}
+
+	groupMembersCmd.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers")
+	groupMembersCmd.Flags().Var(&outputFormat, "output", "Set output format: default, json")
+	if err := groupMembersCmd.RegisterFlagCompletionFunc("output", completeOutputFormat); err != nil {
+		errorExit("Failed to register flag completion: %v", err)
+	}
 }
 
 const (
@@ -98,11 +112,10 @@ var groupDeleteCmd = &cobra.Command{
 }
 
 type resetHandler struct {
-	topic            string
-	partitionOffsets map[int32]int64
-	offset           int64
-	client           sarama.Client
-	group            string
+	// offsets to commit by topic and partition.
+	offsets map[string]map[int32]int64
+	client  sarama.Client
+	group   string
 }
 
 func (r *resetHandler) Setup(s sarama.ConsumerGroupSession) error {
@@ -113,18 +126,28 @@ func (r *resetHandler) Setup(s sarama.ConsumerGroupSession) error {
 		ConsumerID:              s.MemberID(),
 	}
 
-	for p, o := range r.partitionOffsets {
-		req.AddBlock(r.topic, p, o, 0, "")
+	// All offsets are committed in a single request.
+	for topic, partitionOffsets := range r.offsets {
+		for p, o := range partitionOffsets {
+			req.AddBlock(topic, p, o, 0, "")
+		}
 	}
 	br, err := r.client.Coordinator(r.group)
 	if err != nil {
 		return err
 	}
 	_ = br.Open(getConfig())
-	_, err = br.CommitOffset(req)
+	resp, err := br.CommitOffset(req)
 	if err != nil {
 		return err
 	}
+	for topic, errs := range resp.Errors {
+		for partition, kerr := range errs {
+			if kerr != sarama.ErrNoError {
+				return fmt.Errorf("%v/%v: %w", topic, partition, kerr)
+			}
+		}
+	}
 	return nil
 }
 
@@ -143,15 +166,28 @@ func createGroupCommitOffsetCmd() *cobra.Command {
 	var allPartitions bool
 	var offsetMap string
 	var noconfirm bool
+	var planFile string
+	var dryRun bool
 	res := &cobra.Command{
 		Use:   "commit",
 		Short: "Set offset for given consumer group",
-		Long:  "Set offset for a given consumer group, creates one if it does not exist. Offsets cannot be set on a consumer group with active consumers.",
+		Long:  "Set offset for a given consumer group, creates one if it does not exist. Offsets cannot be set on a consumer group with active consumers.\n\nWith --plan, offsets of any number of topics and partitions are read from a CSV file with topic,partition,offset lines or a JSON array of {\"topic\", \"partition\", \"offset\"} objects, and committed in a single request.\n\nWith --dry-run, the offsets before and after and the resulting lag of every partition are printed without committing anything.",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
+			if outputFormat == OutputFormatJSON && !dryRun {
+				errorExit("--output json is only supported with --dry-run")
+			}
 			client := getClient()
 
 			group := args[0]
+
+			if planFile != "" {
+				if topic != "" || offset != "" || offsetMap != "" || allPartitions || cmd.Flags().Changed("partition") {
+					errorExit("--plan cannot be combined with --topic, --offset, --offset-map, --partition or --all-partitions")
+				}
+				commitResetPlan(client, group, planFile, noconfirm, dryRun)
+				return
+			}
 			partitionOffsets := make(map[int32]int64)
 
 			if offsetMap != "" {
@@ -189,10 +225,13 @@ func createGroupCommitOffsetCmd() *cobra.Command {
 
 				// TODO offset must be calced per partition
 				var wg sync.WaitGroup
+				p := newProgress("Resolving offsets of partitions", len(partitions))
+				out := p.writer(outWriter)
 				for _, partition := range partitions {
 					wg.Add(1)
 					go func(partition int32) {
 						defer wg.Done()
+						defer p.increment()
 						i, err := strconv.ParseInt(offset, 10, 64)
 						if err != nil {
 							// Try oldest/newest/..
@@ -215,20 +254,21 @@ func createGroupCommitOffsetCmd() *cobra.Command {
 							}
 
 							if o == -1 {
-								fmt.Printf("Partition %v: could not determine offset from timestamp. Skipping.\n", partition)
+								fmt.Fprintf(out, "Partition %v: could not determine offset from timestamp. Skipping.\n", partition)
 								return
 								//errorExit("Determined offset -1 from timestamp. Skipping.", o)
 							}
 
 							assignments <- Assignment{partition: partition, offset: o}
 
-							fmt.Printf("Partition %v: determined offset %v from timestamp.\n", partition, o)
+							fmt.Fprintf(out, "Partition %v: determined offset %v from timestamp.\n", partition, o)
 						} else {
 							assignments <- Assignment{partition: partition, offset: i}
 						}
 					}(partition)
 				}
 				wg.Wait()
+				p.finish()
 				close(assignments)
 
 				for assign := range assignments {
@@ -236,64 +276,135 @@ func createGroupCommitOffsetCmd() *cobra.Command {
 				}
 			}
 
-			// Verify the Consumer Group is Empty
-			admin := getClusterAdmin()
-			groupDescs, err := admin.DescribeConsumerGroups([]string{args[0]})
-			if err != nil {
-				errorExit("Unable to describe consumer groups: %v\n", err)
+			entries := make([]*resetPlanEntry, 0, len(partitionOffsets))
+			for partition, offset := range partitionOffsets {
+				entries = append(entries, &resetPlanEntry{Topic: topic, Partition: partition, Offset: offset})
 			}
-			for _, detail := range groupDescs {
-				state := detail.State
-				if state != "Empty" {
-					errorExit("Consumer group %s has active consumers in it, cannot set offset\n", group)
-				}
+			if len(entries) == 0 {
+				errorExit("No offsets to commit")
 			}
+			sort.Slice(entries, func(i, j int) bool { return entries[i].Partition < entries[j].Partition })
+			applyResetPlan(client, group, entries, noconfirm, dryRun)
+		},
+	}
+	res.Flags().StringVarP(&topic, "topic", "t", "", "topic")
+	res.Flags().StringVarP(&offset, "offset", "o", "", "offset to commit")
+	res.Flags().Int32VarP(&partitionFlag, "partition", "p", 0, "partition")
+	res.Flags().BoolVar(&allPartitions, "all-partitions", false, "apply to all partitions")
+	res.Flags().StringVar(&offsetMap, "offset-map", "", "set different offsets per different partitions in JSON format, e.g. {\"0\": 123, \"1\": 42}")
+	res.Flags().BoolVar(&noconfirm, "noconfirm", false, "Do not prompt for confirmation")
+	res.Flags().StringVar(&planFile, "plan", "", "CSV or JSON file with the topic, partition and offset of every partition to set")
+	res.Flags().BoolVar(&dryRun, "dry-run", false, "Print the offsets before and after and the lag change of every partition without committing")
+	res.Flags().Var(&outputFormat, "output", "Set output format of the --dry-run plan: default, json")
+	_ = res.RegisterFlagCompletionFunc("output", completeOutputFormat)
+	return res
+}
 
-			fmt.Printf("Resetting offsets to: %v\n", partitionOffsets)
+// commitResetPlan commits the offsets of a --plan file after validating them
+// against the partition bounds.
+func commitResetPlan(client sarama.Client, group, planFile string, noconfirm, dryRun bool) {
+	file, err := os.Open(planFile)
+	if err != nil {
+		errorExit("Unable to read plan: %v", err)
+	}
+	entries, err := parseResetPlan(file)
+	file.Close()
+	if err != nil {
+		errorExit("Invalid plan %v: %v", planFile, err)
+	}
 
-			if !noconfirm {
-				prompt := promptui.Prompt{
-					Label:     "Reset offsets as described",
-					IsConfirm: true,
-				}
+	if problems := validateResetPlan(client, entries); len(problems) > 0 {
+		errorExit("Plan %v has invalid entries, nothing was committed:\n  %v", planFile, strings.Join(problems, "\n  "))
+	}
 
-				_, err := prompt.Run()
-				if err != nil {
-					errorExit("Aborted, exiting.\n")
-					return
-				}
-			}
+	applyResetPlan(client, group, entries, noconfirm, dryRun)
+}
 
-			g, err := sarama.NewConsumerGroupFromClient(group, client)
-			if err != nil {
-				errorExit("Failed to create consumer group: %v\n", err)
+// applyResetPlan prints the offsets before and after and the lag change of
+// every entry and commits the offsets in a single request. With dryRun the
+// plan is only printed.
+func applyResetPlan(client sarama.Client, group string, entries []*resetPlanEntry, noconfirm, dryRun bool) {
+	admin := getClusterAdmin()
+	groupDescs, err := admin.DescribeConsumerGroups([]string{group})
+	if err != nil {
+		errorExit("Unable to describe consumer groups: %v\n", err)
+	}
+	for _, detail := range groupDescs {
+		if detail.State != "Empty" && detail.State != "Dead" {
+			if !dryRun {
+				errorExit("Consumer group %s has active consumers in it, cannot set offset\n", group)
 			}
+			fmt.Fprintf(errWriter, "Warning: consumer group %s has active consumers in it, the plan cannot be applied until it is empty.\n", group)
+		}
+	}
 
-			err = g.Consume(context.Background(), []string{topic}, &resetHandler{
-				topic:            topic,
-				partitionOffsets: partitionOffsets,
-				client:           client,
-				group:            group,
-			})
-			if err != nil {
-				errorExit("Failed to commit offset: %v\n", err)
+	offsets := planOffsets(entries)
+	topicPartitions := make(map[string][]int32, len(offsets))
+	for topic, partitionOffsets := range offsets {
+		for partition := range partitionOffsets {
+			topicPartitions[topic] = append(topicPartitions[topic], partition)
+		}
+	}
+	committed, err := admin.ListConsumerGroupOffsets(group, topicPartitions)
+	if err != nil {
+		errorExit("Unable to get current offsets: %v\n", err)
+	}
+	for _, entry := range entries {
+		entry.before = -1
+		if block := committed.GetBlock(entry.Topic, entry.Partition); block != nil {
+			entry.before = block.Offset
+		}
+		entry.end, err = client.GetOffset(entry.Topic, entry.Partition, sarama.OffsetNewest)
+		if err != nil {
+			errorExit("Unable to get end offset of %v/%v: %v\n", entry.Topic, entry.Partition, err)
+		}
+	}
+
+	if dryRun {
+		if outputFormat == OutputFormatJSON {
+			if err := json.NewEncoder(outWriter).Encode(resetPlanJSON(entries)); err != nil {
+				errorExit("Unable to write plan: %v", err)
 			}
+			return
+		}
+		printResetPlan(outWriter, entries)
+		fmt.Fprintf(outWriter, "Dry run, no offsets were committed.\n")
+		return
+	}
 
-			fmt.Printf("Successfully committed offsets to %v.\n", partitionOffsets)
+	printResetPlan(outWriter, entries)
 
-			closeErr := g.Close()
-			if closeErr != nil {
-				fmt.Printf("Warning: Failed to close consumer group: %v\n", closeErr)
-			}
-		},
+	if !noconfirm {
+		prompt := promptui.Prompt{
+			Label:     "Reset offsets as described",
+			IsConfirm: true,
+		}
+		if _, err := prompt.Run(); err != nil {
+			errorExit("Aborted, exiting.\n")
+		}
 	}
-	res.Flags().StringVarP(&topic, "topic", "t", "", "topic")
-	res.Flags().StringVarP(&offset, "offset", "o", "", "offset to commit")
-	res.Flags().Int32VarP(&partitionFlag, "partition", "p", 0, "partition")
-	res.Flags().BoolVar(&allPartitions, "all-partitions", false, "apply to all partitions")
-	res.Flags().StringVar(&offsetMap, "offset-map", "", "set different offsets per different partitions in JSON format, e.g. {\"0\": 123, \"1\": 42}")
-	res.Flags().BoolVar(&noconfirm, "noconfirm", false, "Do not prompt for confirmation")
-	return res
+
+	g, err := sarama.NewConsumerGroupFromClient(group, client)
+	if err != nil {
+		errorExit("Failed to create consumer group: %v\n", err)
+	}
+	topics := make([]string, 0, len(offsets))
+	for topic := range offsets {
+		topics = append(topics, topic)
+	}
+	err = g.Consume(context.Background(), topics, &resetHandler{
+		offsets: offsets,
+		client:  client,
+		group:   group,
+	})
+	if err != nil {
+		errorExit("Failed to commit offset: %v\n", err)
+	}
+	if err := g.Close(); err != nil {
+		fmt.Fprintf(errWriter, "Warning: Failed to close consumer group: %v\n", err)
+	}
+
+	fmt.Fprintf(outWriter, "Successfully committed offsets of %v partitions.\n", len(entries))
 }
 
 var groupLsCmd = &cobra.Command{
@@ -469,74 +580,50 @@ var groupDescribeCmd = &cobra.Command{
 		group := groups[0]
 
 		if group.State == "Dead" {
+			if outputFormat == OutputFormatJSON {
+				errorExit("Group %v not found.", args[0])
+			}
 			fmt.Printf("Group %v not found.\n", args[0])
 			return
 		}
 
-		w := tabwriter.NewWriter(outWriter, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
-		fmt.Fprintf(w, "Group ID:\t%v\n", group.GroupId)
-		fmt.Fprintf(w, "State:\t%v\n", group.State)
-		fmt.Fprintf(w, "Protocol:\t%v\n", group.Protocol)
-		fmt.Fprintf(w, "Protocol Type:\t%v\n", group.ProtocolType)
-
-		fmt.Fprintf(w, "Offsets:\t\n")
-
-		w.Flush()
-		w.Init(outWriter, tabwriterMinWidthNested, 4, 2, tabwriterPadChar, tabwriterFlags)
-
 		offsetAndMetadata, err := admin.ListConsumerGroupOffsets(args[0], nil)
 		if err != nil {
 			errorExit("Failed to fetch group offsets: %v\n", err)
 		}
-
-		topics := make([]string, 0, len(offsetAndMetadata.Blocks))
-		for k := range offsetAndMetadata.Blocks {
-			topics = append(topics, k)
-		}
-		sort.Strings(topics)
-
-		for _, topic := range topics {
-			partitions := offsetAndMetadata.Blocks[topic]
-			if len(flagDescribeTopics) > 0 {
-				var found bool
-				for _, topicToShow := range flagDescribeTopics {
-					if topic == topicToShow {
-						found = true
-					}
-				}
-
-				if !found {
-					continue
-				}
+		watermarks := make(map[string]map[int32]int64, len(offsetAndMetadata.Blocks))
+		for topic, blocks := range offsetAndMetadata.Blocks {
+			if !showTopic(topic) {
+				continue
 			}
-			fmt.Fprintf(w, "\t%v:\n", topic)
-			fmt.Fprintf(w, "\t\tPartition\tGroup Offset\tHigh Watermark\tLag\tMetadata\t\n")
-			fmt.Fprintf(w, "\t\t---------\t------------\t--------------\t---\t--------\n")
-
-			var p []int32
-
-			for partition := range partitions {
+			p := make([]int32, 0, len(blocks))
+			for partition := range blocks {
 				p = append(p, partition)
 			}
+			watermarks[topic] = getHighWatermarks(topic, p)
+		}
+		description := describeGroup(group, offsetAndMetadata, watermarks)
+
+		if outputFormat == OutputFormatJSON {
+			if err := json.NewEncoder(outWriter).Encode(description); err != nil {
+				errorExit("Unable to write group description: %v", err)
+			}
+			return
+		}
 
-			sort.Slice(p, func(i, j int) bool {
-				return p[i] < p[j]
-			})
+		w := tabwriter.NewWriter(outWriter, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		fmt.Fprintf(w, "Group ID:\t%v\n", group.GroupId)
+		fmt.Fprintf(w, "State:\t%v\n", group.State)
+		fmt.Fprintf(w, "Protocol:\t%v\n", group.Protocol)
+		fmt.Fprintf(w, "Protocol Type:\t%v\n", group.ProtocolType)
+		fmt.Fprintf(w, "Member Count:\t%v\n", len(group.Members))
 
-			wms := getHighWatermarks(topic, p)
+		fmt.Fprintf(w, "Offsets:\t\n")
 
-			lagSum := 0
-			offsetSum := 0
-			for _, partition := range p {
-				lag := (wms[partition] - partitions[partition].Offset)
-				lagSum += int(lag)
-				offset := partitions[partition].Offset
-				offsetSum += int(offset)
-				fmt.Fprintf(w, "\t\t%v\t%v\t%v\t%v\t%v\n", partition, partitions[partition].Offset, wms[partition], (wms[partition] - partitions[partition].Offset), partitions[partition].Metadata)
-			}
+		w.Flush()
+		w.Init(outWriter, tabwriterMinWidthNested, 4, 2, tabwriterPadChar, tabwriterFlags)
 
-			fmt.Fprintf(w, "\t\tTotal\t%d\t\t%d\t\n", offsetSum, lagSum)
-		}
+		writeGroupOffsets(w, description.Partitions)
 
 		if !flagNoMembers {
 
@@ -546,12 +633,16 @@ var groupDescribeCmd = &cobra.Command{
 			w.Init(outWriter, tabwriterMinWidthNested, 4, 2, tabwriterPadChar, tabwriterFlags)
 
 			fmt.Fprintln(w)
-			for _, member := range group.Members {
+			for _, member := range sortedMembers(group) {
 				fmt.Fprintf(w, "\t%v:\n", member.ClientId)
+				fmt.Fprintf(w, "\t\tMember ID:\t%v\n", member.MemberId)
 				fmt.Fprintf(w, "\t\tHost:\t%v\n", member.ClientHost)
 
 				assignment, err := member.GetMemberAssignment()
-				if err != nil || assignment == nil {
+				if err != nil || assignment == nil || len(assignment.Topics) == 0 {
+					if isRebalancing(group.State) {
+						fmt.Fprintf(w, "\t\tAssignments:\tawaiting assignment (%v)\n", group.State)
+					}
 					continue
 				}
 
@@ -560,7 +651,15 @@ var groupDescribeCmd = &cobra.Command{
 				fmt.Fprintf(w, "\t\t  Topic\tPartitions\t\n")
 				fmt.Fprintf(w, "\t\t  -----\t----------\t")
 
-				for topic, partitions := range assignment.Topics {
+				assignedTopics := make([]string, 0, len(assignment.Topics))
+				for topic := range assignment.Topics {
+					assignedTopics = append(assignedTopics, topic)
+				}
+				sort.Strings(assignedTopics)
+
+				for _, topic := range assignedTopics {
+					partitions := assignment.Topics[topic]
+					sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
 					fmt.Fprintf(w, "\n\t\t  %v\t%v\t", topic, partitions)
 				}
 
@@ -597,7 +696,139 @@ var groupDescribeCmd = &cobra.Command{
 	},
 }
 
+// groupPartition is the committed offset and lag of a partition of a group.
+// MemberID and ClientHost are empty for partitions not assigned to a member.
+type groupPartition struct {
+	Topic           string `json:"topic"`
+	Partition       int32  `json:"partition"`
+	CommittedOffset int64  `json:"committedOffset"`
+	HighWatermark   int64  `json:"highWatermark"`
+	Lag             int64  `json:"lag"`
+	Metadata        string `json:"metadata"`
+	MemberID        string `json:"memberId"`
+	ClientHost      string `json:"clientHost"`
+}
+
+// groupDescription is the JSON representation of group describe.
+type groupDescription struct {
+	Group        string           `json:"group"`
+	State        string           `json:"state"`
+	Protocol     string           `json:"protocol"`
+	ProtocolType string           `json:"protocolType"`
+	MemberCount  int              `json:"memberCount"`
+	Partitions   []groupPartition `json:"partitions"`
+	TotalLag     int64            `json:"totalLag"`
+}
+
+// showTopic reports whether group describe shows topic with the --topic
+// filter.
+func showTopic(topic string) bool {
+	if len(flagDescribeTopics) == 0 {
+		return true
+	}
+	for _, t := range flagDescribeTopics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// describeGroup returns the offsets and lag of every partition of the topics
+// in watermarks, sorted by topic and partition, with the member the partition
+// is assigned to. The table and the JSON output of group describe are both
+// printed from it.
+func describeGroup(group *sarama.GroupDescription, committed *sarama.OffsetFetchResponse, watermarks map[string]map[int32]int64) groupDescription {
+	owners := make(map[string]map[int32]*sarama.GroupMemberDescription)
+	for _, member := range group.Members {
+		assignment, err := member.GetMemberAssignment()
+		if err != nil || assignment == nil {
+			continue
+		}
+		for topic, partitions := range assignment.Topics {
+			if owners[topic] == nil {
+				owners[topic] = make(map[int32]*sarama.GroupMemberDescription)
+			}
+			for _, partition := range partitions {
+				owners[topic][partition] = member
+			}
+		}
+	}
+
+	description := groupDescription{
+		Group:        group.GroupId,
+		State:        group.State,
+		Protocol:     group.Protocol,
+		ProtocolType: group.ProtocolType,
+		MemberCount:  len(group.Members),
+		Partitions:   []groupPartition{},
+	}
+	for topic, wms := range watermarks {
+		for partition, block := range committed.Blocks[topic] {
+			p := groupPartition{
+				Topic:           topic,
+				Partition:       partition,
+				CommittedOffset: block.Offset,
+				HighWatermark:   wms[partition],
+				Lag:             wms[partition] - block.Offset,
+				Metadata:        block.Metadata,
+			}
+			if owner := owners[topic][partition]; owner != nil {
+				p.MemberID = owner.MemberId
+				p.ClientHost = owner.ClientHost
+			}
+			description.Partitions = append(description.Partitions, p)
+			description.TotalLag += p.Lag
+		}
+	}
+	sort.Slice(description.Partitions, func(i, j int) bool {
+		a, b := description.Partitions[i], description.Partitions[j]
+		if a.Topic != b.Topic {
+			return a.Topic < b.Topic
+		}
+		return a.Partition < b.Partition
+	})
+	return description
+}
+
+// writeGroupOffsets prints a table of the partitions per topic with the sum
+// of offsets and lag.
+func writeGroupOffsets(w io.Writer, partitions []groupPartition) {
+	for i := 0; i < len(partitions); {
+		topic := partitions[i].Topic
+		fmt.Fprintf(w, "\t%v:\n", topic)
+		fmt.Fprintf(w, "\t\tPartition\tGroup Offset\tHigh Watermark\tLag\tMetadata\t\n")
+		fmt.Fprintf(w, "\t\t---------\t------------\t--------------\t---\t--------\n")
+
+		var offsetSum, lagSum int64
+		for ; i < len(partitions) && partitions[i].Topic == topic; i++ {
+			p := partitions[i]
+			offsetSum += p.CommittedOffset
+			lagSum += p.Lag
+			fmt.Fprintf(w, "\t\t%v\t%v\t%v\t%v\t%v\n", p.Partition, p.CommittedOffset, p.HighWatermark, p.Lag, p.Metadata)
+		}
+		fmt.Fprintf(w, "\t\tTotal\t%d\t\t%d\t\n", offsetSum, lagSum)
+	}
+}
+
 func getHighWatermarks(topic string, partitions []int32) (watermarks map[int32]int64) {
+	return getEndOffsets(topic, partitions, sarama.ReadUncommitted)
+}
+
+// getLastStableOffsets returns the last stable offset of each partition, the
+// offset up to which all transactions are decided. It returns nil for brokers
+// older than 0.11, which do not support transactions.
+func getLastStableOffsets(topic string, partitions []int32) map[int32]int64 {
+	if !getConfig().Version.IsAtLeast(sarama.V0_11_0_0) {
+		return nil
+	}
+	return getEndOffsets(topic, partitions, sarama.ReadCommitted)
+}
+
+// getEndOffsets returns the end offset of each partition as seen by a consumer
+// with the given isolation level: the high watermark for ReadUncommitted, the
+// last stable offset for ReadCommitted.
+func getEndOffsets(topic string, partitions []int32, isolation sarama.IsolationLevel) (watermarks map[int32]int64) {
 	client := getClient()
 	leaders := make(map[*sarama.Broker][]int32)
 
@@ -617,6 +848,10 @@ func getHighWatermarks(topic string, partitions []int32) (watermarks map[int32]i
 		req := &sarama.OffsetRequest{
 			Version: int16(1),
 		}
+		if isolation == sarama.ReadCommitted {
+			req.Version = 2
+			req.IsolationLevel = isolation
+		}
 
 		for _, partition := range partitions {
 			req.AddBlock(topic, partition, int64(-1), int32(0))
@@ -654,6 +889,102 @@ func getHighWatermarks(topic string, partitions []int32) (watermarks map[int32]i
 	return
 }
 
+// sortedMembers returns the members of a group ordered by client ID and
+// member ID, so that the output of describe is stable between runs.
+func sortedMembers(group *sarama.GroupDescription) []*sarama.GroupMemberDescription {
+	members := make([]*sarama.GroupMemberDescription, 0, len(group.Members))
+	for _, member := range group.Members {
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].ClientId != members[j].ClientId {
+			return members[i].ClientId < members[j].ClientId
+		}
+		return members[i].MemberId < members[j].MemberId
+	})
+	return members
+}
+
+// groupMember is a member of a consumer group with the number of partitions
+// assigned to it.
+type groupMember struct {
+	MemberID   string `json:"memberId"`
+	ClientID   string `json:"clientId"`
+	Host       string `json:"host"`
+	Partitions int    `json:"partitions"`
+}
+
+// groupMembers returns the members of a group sorted by client ID. Members
+// without a decodable assignment, e.g. during a rebalance, have 0 partitions.
+func groupMembers(group *sarama.GroupDescription) []groupMember {
+	members := make([]groupMember, 0, len(group.Members))
+	for _, member := range sortedMembers(group) {
+		m := groupMember{
+			MemberID: member.MemberId,
+			ClientID: member.ClientId,
+			Host:     member.ClientHost,
+		}
+		if assignment, err := member.GetMemberAssignment(); err == nil && assignment != nil {
+			for _, partitions := range assignment.Topics {
+				m.Partitions += len(partitions)
+			}
+		}
+		members = append(members, m)
+	}
+	return members
+}
+
+var groupMembersCmd = &cobra.Command{
+	Use:               "members GROUP",
+	Short:             "List the members of a consumer group",
+	Long:              "List member IDs, client IDs, hosts and the number of assigned partitions of a consumer group. Unlike describe, no offsets or lag are fetched.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: validGroupArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		admin := getClusterAdmin()
+
+		groups, err := admin.DescribeConsumerGroups([]string{args[0]})
+		if err != nil {
+			errorExit("Unable to describe consumer groups: %v\n", err)
+		}
+		if len(groups) == 0 {
+			errorExit("Did not receive expected describe consumergroup result\n")
+		}
+		group := groups[0]
+		if group.State == "Dead" {
+			errorExit("Group %v not found.\n", args[0])
+		}
+
+		members := groupMembers(group)
+
+		if outputFormat == OutputFormatJSON {
+			b, err := json.Marshal(members)
+			if err != nil {
+				errorExit("Failed to encode members: %v", err)
+			}
+			fmt.Fprintln(outWriter, string(b))
+			return
+		}
+
+		if isRebalancing(group.State) {
+			fmt.Fprintf(errWriter, "Group %v is rebalancing (%v), assignments may be incomplete.\n", group.GroupId, group.State)
+		}
+
+		w := tabwriter.NewWriter(outWriter, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		if !noHeaderFlag {
+			fmt.Fprintf(w, "MEMBER ID\tCLIENT ID\tHOST\tPARTITIONS\t\n")
+		}
+		for _, m := range members {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t\n", m.MemberID, m.ClientID, m.Host, m.Partitions)
+		}
+		w.Flush()
+	},
+}
+
+func isRebalancing(state string) bool {
+	return state == "PreparingRebalance" || state == "CompletingRebalance"
+}
+
 // IsASCIIPrintable returns true if the string is ASCII printable.
 func IsASCIIPrintable(s string) bool {
 	for _, r := range s {
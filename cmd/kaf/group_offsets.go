@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/IBM/sarama"
+	"github.com/spf13/cobra"
+)
+
+var flagOffsetsTopics []string
+
+func init() {
+	groupCmd.AddCommand(groupOffsetsCmd)
+
+	groupOffsetsCmd.Flags().StringSliceVarP(&flagOffsetsTopics, "topic", "t", []string{}, "Topics to print the offsets of. Defaults to all topics of the group")
+	groupOffsetsCmd.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers")
+	groupOffsetsCmd.Flags().Var(&outputFormat, "output", "Set output format: default, json")
+	if err := groupOffsetsCmd.RegisterFlagCompletionFunc("output", completeOutputFormat); err != nil {
+		errorExit("Failed to register flag completion: %v", err)
+	}
+}
+
+var groupOffsetsCmd = &cobra.Command{
+	Use:   "offsets GROUP",
+	Short: "Print the committed offsets of a group",
+	Long: "Print the committed offset and metadata of every partition of a group with a single request. " +
+		"Unlike group describe, lag and log end offsets are not fetched, which makes it a quick snapshot of the position of a group.",
+	Example:           "kaf group offsets billing\nkaf group offsets billing --topic orders --output json",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: validGroupArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		committed, err := getClusterAdmin().ListConsumerGroupOffsets(args[0], nil)
+		if err != nil {
+			errorExit("Failed to fetch group offsets: %v", err)
+		}
+		offsets, err := committedOffsetsOf(committed, flagOffsetsTopics)
+		if err != nil {
+			errorExit("Failed to fetch offsets of group %v: %v", args[0], err)
+		}
+		if err := writeCommittedOffsets(outWriter, offsets); err != nil {
+			errorExit("Failed to write offsets: %v", err)
+		}
+	},
+}
+
+// committedOffset is the committed offset of a partition as printed by group
+// offsets.
+type committedOffset struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Metadata  string `json:"metadata"`
+}
+
+// committedOffsetsOf returns the committed offsets of the topics, or of all
+// topics if none are given, ordered by topic and partition. Partitions
+// without committed offset are left out.
+func committedOffsetsOf(committed *sarama.OffsetFetchResponse, topics []string) ([]committedOffset, error) {
+	if committed.Err != sarama.ErrNoError {
+		return nil, committed.Err
+	}
+	offsets := []committedOffset{}
+	for topic, blocks := range committed.Blocks {
+		if len(topics) > 0 && !containsString(topics, topic) {
+			continue
+		}
+		for partition, block := range blocks {
+			if block.Err != sarama.ErrNoError {
+				return nil, fmt.Errorf("topic %v partition %v: %w", topic, partition, block.Err)
+			}
+			if block.Offset < 0 {
+				continue
+			}
+			offsets = append(offsets, committedOffset{Topic: topic, Partition: partition, Offset: block.Offset, Metadata: block.Metadata})
+		}
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		if offsets[i].Topic != offsets[j].Topic {
+			return offsets[i].Topic < offsets[j].Topic
+		}
+		return offsets[i].Partition < offsets[j].Partition
+	})
+	return offsets, nil
+}
+
+// writeCommittedOffsets prints offsets as a table, or as a JSON array with
+// --output json. Metadata that is not printable is shown base64 encoded in
+// the table.
+func writeCommittedOffsets(w io.Writer, offsets []committedOffset) error {
+	if outputFormat == OutputFormatJSON {
+		b, err := json.Marshal(offsets)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	if !noHeaderFlag {
+		fmt.Fprintf(tw, "TOPIC\tPARTITION\tOFFSET\tMETADATA\t\n")
+	}
+	for _, offset := range offsets {
+		metadata := offset.Metadata
+		if !IsASCIIPrintable(metadata) {
+			metadata = base64.StdEncoding.EncodeToString([]byte(metadata))
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t\n", offset.Topic, offset.Partition, offset.Offset, metadata)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommittedOffsets(t *testing.T) {
+	committed := &sarama.OffsetFetchResponse{}
+	committed.AddBlock("payments", 0, &sarama.OffsetFetchResponseBlock{Offset: 5})
+	committed.AddBlock("orders", 1, &sarama.OffsetFetchResponseBlock{Offset: 90, Metadata: "m"})
+	committed.AddBlock("orders", 0, &sarama.OffsetFetchResponseBlock{Offset: 40, Metadata: "\x00\x01"})
+	committed.AddBlock("orders", 2, &sarama.OffsetFetchResponseBlock{Offset: -1})
+
+	offsets, err := committedOffsetsOf(committed, nil)
+	require.NoError(t, err)
+	require.Equal(t, []committedOffset{
+		{Topic: "orders", Partition: 0, Offset: 40, Metadata: "\x00\x01"},
+		{Topic: "orders", Partition: 1, Offset: 90, Metadata: "m"},
+		{Topic: "payments", Partition: 0, Offset: 5},
+	}, offsets)
+
+	offsets, err = committedOffsetsOf(committed, []string{"payments"})
+	require.NoError(t, err)
+	require.Equal(t, []committedOffset{{Topic: "payments", Partition: 0, Offset: 5}}, offsets)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeCommittedOffsets(&buf, []committedOffset{{Topic: "orders", Partition: 0, Offset: 40, Metadata: "\x00\x01"}}))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Equal(t, []string{"orders", "0", "40", "AAE="}, strings.Fields(lines[1]))
+
+	committed.AddBlock("refunds", 0, &sarama.OffsetFetchResponseBlock{Err: sarama.ErrUnknownTopicOrPartition})
+	_, err = committedOffsetsOf(committed, nil)
+	require.ErrorIs(t, err, sarama.ErrUnknownTopicOrPartition)
+}
+
+func TestWriteCommittedOffsetsJSON(t *testing.T) {
+	defer func(f OutputFormat) { outputFormat = f }(outputFormat)
+	outputFormat = OutputFormatJSON
+
+	var buf bytes.Buffer
+	require.NoError(t, writeCommittedOffsets(&buf, []committedOffset{}))
+	require.Equal(t, "[]\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeCommittedOffsets(&buf, []committedOffset{{Topic: "orders", Partition: 1, Offset: 90, Metadata: "m"}}))
+	require.JSONEq(t, `[{"topic": "orders", "partition": 1, "offset": 90, "metadata": "m"}]`, buf.String())
+}
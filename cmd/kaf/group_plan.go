@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/IBM/sarama"
+)
+
+// resetPlanEntry is one partition of a group commit --plan file.
+type resetPlanEntry struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+
+	// before is the offset committed when the plan is applied, -1 if none.
+	before int64
+	// end is the log end offset of the partition.
+	end int64
+}
+
+// lagBefore returns the lag of the committed offset, or false if the group
+// has no offset for the partition.
+func (e *resetPlanEntry) lagBefore() (int64, bool) {
+	if e.before < 0 {
+		return 0, false
+	}
+	return e.end - e.before, true
+}
+
+func (e *resetPlanEntry) lagAfter() int64 {
+	return e.end - e.Offset
+}
+
+// resetPlanChange is the JSON representation of an entry of a --dry-run plan.
+// The offset and lag before are null if the group has no offset for the
+// partition.
+type resetPlanChange struct {
+	Topic        string `json:"topic"`
+	Partition    int32  `json:"partition"`
+	OldOffset    *int64 `json:"oldOffset"`
+	NewOffset    int64  `json:"newOffset"`
+	LogEndOffset int64  `json:"logEndOffset"`
+	OldLag       *int64 `json:"oldLag"`
+	NewLag       int64  `json:"newLag"`
+	LagChange    *int64 `json:"lagChange"`
+}
+
+func resetPlanJSON(entries []*resetPlanEntry) []resetPlanChange {
+	changes := make([]resetPlanChange, 0, len(entries))
+	for _, entry := range entries {
+		change := resetPlanChange{
+			Topic:        entry.Topic,
+			Partition:    entry.Partition,
+			NewOffset:    entry.Offset,
+			LogEndOffset: entry.end,
+			NewLag:       entry.lagAfter(),
+		}
+		if lag, ok := entry.lagBefore(); ok {
+			before := entry.before
+			delta := change.NewLag - lag
+			change.OldOffset = &before
+			change.OldLag = &lag
+			change.LagChange = &delta
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// parseResetPlan reads a plan of topic, partition and offset entries, either
+// as a JSON array of objects or as CSV with an optional topic,partition,offset
+// header.
+func parseResetPlan(r io.Reader) ([]*resetPlanEntry, error) {
+	br := bufio.NewReader(r)
+	var entries []*resetPlanEntry
+
+	first, err := peekNonSpace(br)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if first == '[' {
+		decoder := json.NewDecoder(br)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&entries); err != nil {
+			return nil, fmt.Errorf("invalid JSON plan: %w", err)
+		}
+		for i, entry := range entries {
+			if entry.Topic == "" {
+				return nil, fmt.Errorf("entry %v: missing topic", i)
+			}
+		}
+	} else {
+		reader := csv.NewReader(br)
+		reader.FieldsPerRecord = 3
+		reader.TrimLeadingSpace = true
+		reader.Comment = '#'
+		for line := 1; ; line++ {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid CSV plan: %w", err)
+			}
+			if line == 1 && strings.EqualFold(record[0], "topic") {
+				continue
+			}
+			partition, err := strconv.ParseInt(record[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %v: invalid partition %q", line, record[1])
+			}
+			offset, err := strconv.ParseInt(record[2], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %v: invalid offset %q", line, record[2])
+			}
+			entries = append(entries, &resetPlanEntry{Topic: record[0], Partition: int32(partition), Offset: offset})
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("plan is empty")
+	}
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if entry.Offset < 0 {
+			return nil, fmt.Errorf("%v/%v: offset must not be negative", entry.Topic, entry.Partition)
+		}
+		id := entry.Topic + "/" + strconv.Itoa(int(entry.Partition))
+		if seen[id] {
+			return nil, fmt.Errorf("%v is listed more than once", id)
+		}
+		seen[id] = true
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Topic != entries[j].Topic {
+			return entries[i].Topic < entries[j].Topic
+		}
+		return entries[i].Partition < entries[j].Partition
+	})
+	return entries, nil
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b)) {
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+// validateResetPlan checks that every partition of the plan exists and every
+// offset is within the partition bounds. All problems are returned at once.
+func validateResetPlan(client sarama.Client, entries []*resetPlanEntry) []string {
+	var problems []string
+	partitions := make(map[string][]int32)
+	for _, entry := range entries {
+		available, ok := partitions[entry.Topic]
+		if !ok {
+			var err error
+			available, err = client.Partitions(entry.Topic)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%v: %v", entry.Topic, err))
+			}
+			partitions[entry.Topic] = available
+		}
+		if available == nil {
+			continue
+		}
+		if !containsPartition(available, entry.Partition) {
+			problems = append(problems, fmt.Sprintf("%v/%v: partition does not exist", entry.Topic, entry.Partition))
+			continue
+		}
+		offsets, err := getOffsets(client, entry.Topic, entry.Partition)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%v/%v: %v", entry.Topic, entry.Partition, err))
+			continue
+		}
+		if entry.Offset < offsets.oldest || entry.Offset > offsets.newest {
+			problems = append(problems, fmt.Sprintf("%v/%v: offset %v is outside of [%v, %v]", entry.Topic, entry.Partition, entry.Offset, offsets.oldest, offsets.newest))
+		}
+	}
+	return problems
+}
+
+// planOffsets groups the offsets of a plan by topic and partition.
+func planOffsets(entries []*resetPlanEntry) map[string]map[int32]int64 {
+	offsets := make(map[string]map[int32]int64)
+	for _, entry := range entries {
+		if offsets[entry.Topic] == nil {
+			offsets[entry.Topic] = make(map[int32]int64)
+		}
+		offsets[entry.Topic][entry.Partition] = entry.Offset
+	}
+	return offsets
+}
+
+func printResetPlan(w io.Writer, entries []*resetPlanEntry) {
+	tw := tabwriter.NewWriter(w, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	fmt.Fprintf(tw, "TOPIC\tPARTITION\tBEFORE\tAFTER\tLAG\tCHANGE\t\n")
+	for _, entry := range entries {
+		before, change := "-", "-"
+		if lag, ok := entry.lagBefore(); ok {
+			before = strconv.FormatInt(entry.before, 10)
+			change = fmt.Sprintf("%+d", entry.lagAfter()-lag)
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t\n", entry.Topic, entry.Partition, before, entry.Offset, entry.lagAfter(), change)
+	}
+	tw.Flush()
+}
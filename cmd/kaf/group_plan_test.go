@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResetPlan(t *testing.T) {
+	csvPlan := "topic,partition,offset\norders,1,42\n# reprocess from here\norders,0,10\npayments,0,7\n"
+	entries, err := parseResetPlan(strings.NewReader(csvPlan))
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, resetPlanEntry{Topic: "orders", Partition: 0, Offset: 10}, *entries[0])
+	require.Equal(t, resetPlanEntry{Topic: "orders", Partition: 1, Offset: 42}, *entries[1])
+
+	jsonPlan := `  [{"topic": "orders", "partition": 1, "offset": 42}, {"topic": "orders", "partition": 0, "offset": 10}]`
+	fromJSON, err := parseResetPlan(strings.NewReader(jsonPlan))
+	require.NoError(t, err)
+	require.Equal(t, entries[:2], fromJSON)
+
+	require.Equal(t, map[string]map[int32]int64{
+		"orders":   {0: 10, 1: 42},
+		"payments": {0: 7},
+	}, planOffsets(entries))
+
+	for name, plan := range map[string]string{
+		"empty":           "",
+		"header only":     "topic,partition,offset\n",
+		"missing column":  "orders,1\n",
+		"bad offset":      "orders,1,latest\n",
+		"negative offset": "orders,1,-2\n",
+		"duplicate":       "orders,1,2\norders,1,3\n",
+		"json no topic":   `[{"partition": 1, "offset": 2}]`,
+		"json unknown":    `[{"topic": "orders", "partition": 1, "offset": 2, "group": "x"}]`,
+	} {
+		_, err := parseResetPlan(strings.NewReader(plan))
+		require.Error(t, err, name)
+	}
+}
+
+func TestPrintResetPlan(t *testing.T) {
+	var buf bytes.Buffer
+	printResetPlan(&buf, []*resetPlanEntry{
+		{Topic: "orders", Partition: 0, Offset: 10, before: 25, end: 30},
+		{Topic: "orders", Partition: 1, Offset: 42, before: -1, end: 50},
+	})
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t, []string{"TOPIC", "PARTITION", "BEFORE", "AFTER", "LAG", "CHANGE"}, strings.Fields(lines[0]))
+	require.Equal(t, []string{"orders", "0", "25", "10", "20", "+15"}, strings.Fields(lines[1]))
+	require.Equal(t, []string{"orders", "1", "-", "42", "8", "-"}, strings.Fields(lines[2]))
+}
+
+func TestResetPlanJSON(t *testing.T) {
+	out, err := json.Marshal(resetPlanJSON([]*resetPlanEntry{
+		{Topic: "orders", Partition: 0, Offset: 30, before: 25, end: 30},
+		{Topic: "orders", Partition: 1, Offset: 0, before: -1, end: 50},
+	}))
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"topic": "orders", "partition": 0, "oldOffset": 25, "newOffset": 30, "logEndOffset": 30, "oldLag": 5, "newLag": 0, "lagChange": -5},
+		{"topic": "orders", "partition": 1, "oldOffset": null, "newOffset": 0, "logEndOffset": 50, "oldLag": null, "newLag": 50, "lagChange": null}
+	]`, string(out))
+}
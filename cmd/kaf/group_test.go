@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeAssignment encodes a consumer protocol member assignment.
+func encodeAssignment(topics map[string][]int32) []byte {
+	var b bytes.Buffer
+	write := func(v interface{}) { _ = binary.Write(&b, binary.BigEndian, v) }
+	write(int16(0))
+	write(int32(len(topics)))
+	for topic, partitions := range topics {
+		write(int16(len(topic)))
+		b.WriteString(topic)
+		write(int32(len(partitions)))
+		write(partitions)
+	}
+	write(int32(-1))
+	return b.Bytes()
+}
+
+func TestGroupMembers(t *testing.T) {
+	group := &sarama.GroupDescription{
+		GroupId: "g",
+		Members: map[string]*sarama.GroupMemberDescription{
+			"b-1": {MemberId: "b-1", ClientId: "b", ClientHost: "/10.0.0.2", MemberAssignment: encodeAssignment(map[string][]int32{"t1": {0, 1}, "t2": {3}})},
+			"a-1": {MemberId: "a-1", ClientId: "a", ClientHost: "/10.0.0.1"},
+		},
+	}
+
+	require.Equal(t, []groupMember{
+		{MemberID: "a-1", ClientID: "a", Host: "/10.0.0.1", Partitions: 0},
+		{MemberID: "b-1", ClientID: "b", Host: "/10.0.0.2", Partitions: 3},
+	}, groupMembers(group))
+
+	b, err := json.Marshal(groupMembers(group)[:1])
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"memberId":"a-1","clientId":"a","host":"/10.0.0.1","partitions":0}]`, string(b))
+}
+
+func TestDescribeGroup(t *testing.T) {
+	group := &sarama.GroupDescription{
+		GroupId:      "g",
+		State:        "Stable",
+		Protocol:     "range",
+		ProtocolType: "consumer",
+		Members: map[string]*sarama.GroupMemberDescription{
+			"a-1": {MemberId: "a-1", ClientId: "a", ClientHost: "/10.0.0.1", MemberAssignment: encodeAssignment(map[string][]int32{"orders": {1}})},
+		},
+	}
+	committed := &sarama.OffsetFetchResponse{}
+	committed.AddBlock("orders", 1, &sarama.OffsetFetchResponseBlock{Offset: 90, Metadata: "m"})
+	committed.AddBlock("orders", 0, &sarama.OffsetFetchResponseBlock{Offset: 40})
+	committed.AddBlock("payments", 0, &sarama.OffsetFetchResponseBlock{Offset: 5})
+
+	description := describeGroup(group, committed, map[string]map[int32]int64{
+		"orders": {0: 50, 1: 100},
+	})
+	require.Equal(t, groupDescription{
+		Group:        "g",
+		State:        "Stable",
+		Protocol:     "range",
+		ProtocolType: "consumer",
+		MemberCount:  1,
+		Partitions: []groupPartition{
+			{Topic: "orders", Partition: 0, CommittedOffset: 40, HighWatermark: 50, Lag: 10},
+			{Topic: "orders", Partition: 1, CommittedOffset: 90, HighWatermark: 100, Lag: 10, Metadata: "m", MemberID: "a-1", ClientHost: "/10.0.0.1"},
+		},
+		TotalLag: 20,
+	}, description)
+
+	var buf bytes.Buffer
+	writeGroupOffsets(&buf, description.Partitions)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 6)
+	require.Equal(t, []string{"0", "40", "50", "10"}, strings.Fields(lines[3]))
+	require.Equal(t, []string{"Total", "130", "20"}, strings.Fields(lines[5]))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	idleTimeoutFlag time.Duration
+
+	// idle stops a --follow or group consume after --idle-timeout without
+	// messages, it is nil otherwise.
+	idle *idleWatcher
+)
+
+// idleWatcher calls cancel once no message was seen for timeout. Its methods
+// can be called on a nil watcher.
+type idleWatcher struct {
+	timeout time.Duration
+
+	mu       sync.Mutex
+	timer    *time.Timer
+	expired  bool
+	messages int64
+}
+
+func startIdleWatcher(timeout time.Duration, cancel context.CancelFunc) *idleWatcher {
+	w := &idleWatcher{timeout: timeout}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timer = time.AfterFunc(timeout, func() {
+		w.mu.Lock()
+		w.expired = true
+		w.mu.Unlock()
+		cancel()
+	})
+	return w
+}
+
+// seen restarts the timeout after a message was received.
+func (w *idleWatcher) seen() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired {
+		return
+	}
+	w.messages++
+	w.timer.Reset(w.timeout)
+}
+
+func (w *idleWatcher) stop() {
+	if w == nil {
+		return
+	}
+	w.timer.Stop()
+}
+
+// report prints why the consume stopped if the timeout expired. It exits with
+// a non-zero code if no message was received at all.
+func (w *idleWatcher) report() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	expired, messages := w.expired, w.messages
+	w.mu.Unlock()
+	if !expired {
+		return
+	}
+	if messages == 0 {
+		errorExit("No messages received within --idle-timeout of %v", w.timeout)
+	}
+	fmt.Fprintf(errWriter, "No new messages for %v, received %v messages.\n", w.timeout, messages)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdleWatcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := startIdleWatcher(50*time.Millisecond, cancel)
+	defer w.stop()
+
+	// Messages keep the watcher from expiring.
+	for i := 0; i < 5; i++ {
+		time.Sleep(20 * time.Millisecond)
+		w.seen()
+	}
+	require.NoError(t, ctx.Err())
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("watcher did not cancel after the idle timeout")
+	}
+	require.True(t, w.expired)
+	require.Equal(t, int64(5), w.messages)
+
+	// Methods of a nil watcher are no-ops.
+	var none *idleWatcher
+	none.seen()
+	none.stop()
+	none.report()
+}
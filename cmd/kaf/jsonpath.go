@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// lookupJSONPath returns the value at a dotted path such as ".order.id" or
+// "items.0.sku" in the JSON document data. The leading dot is optional,
+// numeric segments index into arrays. ok is false if the path does not exist.
+func lookupJSONPath(data []byte, path string) (value interface{}, ok bool, err error) {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // Keep large integer IDs intact.
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, false, fmt.Errorf("value is not valid JSON: %w", err)
+	}
+
+	value = doc
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		if segment == "" {
+			continue
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value, ok = v[segment]
+			if !ok {
+				return nil, false, nil
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false, nil
+			}
+			value = v[i]
+		default:
+			return nil, false, nil
+		}
+	}
+	return value, true, nil
+}
+
+// jsonPathString returns the value at path in data as string. Strings are
+// returned as is, all other values in their JSON encoding.
+func jsonPathString(data []byte, path string) (s string, ok bool, err error) {
+	value, ok, err := lookupJSONPath(data, path)
+	if err != nil || !ok {
+		return "", ok, err
+	}
+	if s, isString := value.(string); isString {
+		return s, true, nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", false, err
+	}
+	return string(b), true, nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONPathString(t *testing.T) {
+	value := []byte(`{"orderId":"o-1","customer":{"id":12345678901234567},"items":[{"sku":"a"}]}`)
+
+	for path, expected := range map[string]string{
+		".orderId":     "o-1",
+		"orderId":      "o-1",
+		".customer.id": "12345678901234567",
+		".items.0.sku": "a",
+		".customer":    `{"id":12345678901234567}`,
+	} {
+		s, ok, err := jsonPathString(value, path)
+		require.NoError(t, err)
+		require.True(t, ok, path)
+		require.Equal(t, expected, s, path)
+	}
+
+	for _, path := range []string{".missing", ".items.1.sku", ".orderId.x"} {
+		_, ok, err := jsonPathString(value, path)
+		require.NoError(t, err)
+		require.False(t, ok, path)
+	}
+
+	_, _, err := jsonPathString([]byte("not json"), ".orderId")
+	require.Error(t, err)
+}
@@ -3,12 +3,10 @@ package main
 import (
 	"fmt"
 	"io"
-
-	"crypto/tls"
-	"crypto/x509"
-	"io/ioutil"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/IBM/sarama"
 	"github.com/mattn/go-colorable"
@@ -16,103 +14,19 @@ import (
 
 	"github.com/birdayz/kaf/pkg/avro"
 	"github.com/birdayz/kaf/pkg/config"
+	"github.com/birdayz/kaf/pkg/kaf"
 	"github.com/birdayz/kaf/pkg/proto"
 )
 
 var cfgFile string
 
 func getConfig() (saramaConfig *sarama.Config) {
-	saramaConfig = sarama.NewConfig()
-	saramaConfig.Version = sarama.V1_1_0_0
-	saramaConfig.Producer.Return.Successes = true
-
-	cluster := currentCluster
-	if cluster.Version != "" {
-		parsedVersion, err := sarama.ParseKafkaVersion(cluster.Version)
-		if err != nil {
-			errorExit("Unable to parse Kafka version: %v\n", err)
-		}
-		saramaConfig.Version = parsedVersion
-	}
-	if cluster.SASL != nil {
-		saramaConfig.Net.SASL.Enable = true
-		if cluster.SASL.Mechanism != "OAUTHBEARER" {
-			saramaConfig.Net.SASL.User = cluster.SASL.Username
-			saramaConfig.Net.SASL.Password = cluster.SASL.Password
-		}
-		saramaConfig.Net.SASL.Version = cluster.SASL.Version
-	}
-	if cluster.TLS != nil && cluster.SecurityProtocol != "SASL_SSL" {
-		saramaConfig.Net.TLS.Enable = true
-		tlsConfig := &tls.Config{
-			InsecureSkipVerify: cluster.TLS.Insecure,
-		}
-
-		if cluster.TLS.Cafile != "" {
-			caCert, err := ioutil.ReadFile(cluster.TLS.Cafile)
-			if err != nil {
-				errorExit("Unable to read Cafile :%v\n", err)
-			}
-			caCertPool := x509.NewCertPool()
-			caCertPool.AppendCertsFromPEM(caCert)
-			tlsConfig.RootCAs = caCertPool
-		}
-
-		if cluster.TLS.Clientfile != "" && cluster.TLS.Clientkeyfile != "" {
-			clientCert, err := ioutil.ReadFile(cluster.TLS.Clientfile)
-			if err != nil {
-				errorExit("Unable to read Clientfile :%v\n", err)
-			}
-			clientKey, err := ioutil.ReadFile(cluster.TLS.Clientkeyfile)
-			if err != nil {
-				errorExit("Unable to read Clientkeyfile :%v\n", err)
-			}
-
-			cert, err := tls.X509KeyPair([]byte(clientCert), []byte(clientKey))
-			if err != nil {
-				errorExit("Unable to create KeyPair: %v\n", err)
-			}
-			tlsConfig.Certificates = []tls.Certificate{cert}
-
-			// nolint
-			tlsConfig.BuildNameToCertificate()
-		}
-		saramaConfig.Net.TLS.Config = tlsConfig
-	}
-	if cluster.SecurityProtocol == "SASL_SSL" {
-		saramaConfig.Net.TLS.Enable = true
-		if cluster.TLS != nil {
-			tlsConfig := &tls.Config{
-				InsecureSkipVerify: cluster.TLS.Insecure,
-			}
-			if cluster.TLS.Cafile != "" {
-				caCert, err := ioutil.ReadFile(cluster.TLS.Cafile)
-				if err != nil {
-					fmt.Println(err)
-					os.Exit(1)
-				}
-				caCertPool := x509.NewCertPool()
-				caCertPool.AppendCertsFromPEM(caCert)
-				tlsConfig.RootCAs = caCertPool
-			}
-			saramaConfig.Net.TLS.Config = tlsConfig
-
-		} else {
-			saramaConfig.Net.TLS.Config = &tls.Config{InsecureSkipVerify: false}
-		}
+	saramaConfig, err := kaf.NewSaramaConfig(currentCluster)
+	if err != nil {
+		errorExit("Invalid cluster config: %v\n", err)
 	}
-	if cluster.SecurityProtocol == "SASL_SSL" || cluster.SecurityProtocol == "SASL_PLAINTEXT" {
-		if cluster.SASL.Mechanism == "SCRAM-SHA-512" {
-			saramaConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &XDGSCRAMClient{HashGeneratorFcn: SHA512} }
-			saramaConfig.Net.SASL.Mechanism = sarama.SASLMechanism(sarama.SASLTypeSCRAMSHA512)
-		} else if cluster.SASL.Mechanism == "SCRAM-SHA-256" {
-			saramaConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &XDGSCRAMClient{HashGeneratorFcn: SHA256} }
-			saramaConfig.Net.SASL.Mechanism = sarama.SASLMechanism(sarama.SASLTypeSCRAMSHA256)
-		} else if cluster.SASL.Mechanism == "OAUTHBEARER" || cluster.SASL.Mechanism == "AWS_MSK_IAM" {
-			//Here setup get token function
-			saramaConfig.Net.SASL.Mechanism = sarama.SASLMechanism(sarama.SASLTypeOAuth)
-			saramaConfig.Net.SASL.TokenProvider = newTokenProvider()
-		}
+	if metricRegistry != nil {
+		saramaConfig.MetricRegistry = metricRegistry
 	}
 	return saramaConfig
 }
@@ -142,6 +56,13 @@ var rootCmd = &cobra.Command{
 		if outWriter != os.Stdout {
 			colorableOut = outWriter
 		}
+
+		if clusterSelectorFlag != "" {
+			runAcrossClusters(cmd, args)
+		}
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		stopMetrics()
 	},
 }
 
@@ -163,14 +84,29 @@ var (
 	decodeMsgPack     bool
 	verbose           bool
 	clusterOverride   string
+	clientIDFlag      string
+	insecurePlaintext bool
+	noTokenCacheFlag  bool
 )
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.kaf/config)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file, read and written by all commands (default is $KAF_CONFIG or $HOME/.kaf/config). - reads the config from stdin and an http or https URL fetches it, such configs are never written")
 	rootCmd.PersistentFlags().StringSliceVarP(&brokersFlag, "brokers", "b", nil, "Comma separated list of broker ip:port pairs")
+	rootCmd.PersistentFlags().StringVar(&saslMechanismFlag, "sasl-mechanism", "", "SASL mechanism: [PLAIN|SCRAM-SHA-256|SCRAM-SHA-512|OAUTHBEARER|AWS_MSK_IAM|GSSAPI]. Without --cluster, the --sasl-* and --tls flags connect to a cluster that is not in the config")
+	rootCmd.PersistentFlags().StringVar(&saslUsernameFlag, "sasl-username", "", "SASL username")
+	rootCmd.PersistentFlags().StringVar(&saslPasswordFlag, "sasl-password", "", "SASL password, or the token with --sasl-mechanism OAUTHBEARER")
+	rootCmd.PersistentFlags().BoolVar(&noTokenCacheFlag, "no-token-cache", false, "Fetch a new OAUTHBEARER token instead of reusing the one cached in $HOME/.kaf/token-cache by earlier invocations")
+	rootCmd.PersistentFlags().BoolVar(&tlsFlag, "tls", false, "Connect with TLS, verifying the brokers with the system certificates")
+	rootCmd.PersistentFlags().BoolVar(&diagnoseFlag, "diagnose", false, "Check name resolution, TCP, TLS and SASL of every broker before running the command, with hints for failing steps. Connection failures are diagnosed automatically")
 	rootCmd.PersistentFlags().StringVar(&schemaRegistryURL, "schema-registry", "", "URL to a Confluent schema registry. Used for attempting to decode Avro-encoded messages")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Whether to turn on sarama logging")
 	rootCmd.PersistentFlags().StringVarP(&clusterOverride, "cluster", "c", "", "set a temporary current cluster")
+	rootCmd.PersistentFlags().StringVar(&clusterSelectorFlag, "cluster-selector", "", "Run a read-only command against every cluster whose labels match, like env=prod,region!=us, with the output grouped by cluster")
+	rootCmd.PersistentFlags().BoolVar(&insecurePlaintext, "insecure-plaintext", false, "Allow SASL mechanism PLAIN without TLS, which sends the password in clear text")
+	rootCmd.PersistentFlags().BoolVar(&metricsFlag, "metrics", false, "Print request rate, latency and batch size metrics of the Kafka client to stderr every --metrics-interval, and a summary at exit")
+	rootCmd.PersistentFlags().DurationVar(&metricsIntervalFlag, "metrics-interval", 5*time.Second, "How often metrics are printed with --metrics or sent with --statsd-addr")
+	rootCmd.PersistentFlags().StringVar(&statsdAddrFlag, "statsd-addr", "", "Send metrics of the Kafka client as statsd gauges prefixed with kaf. to this UDP host:port")
+	rootCmd.PersistentFlags().StringVar(&clientIDFlag, "client-id", "", "Client ID sent to the brokers (default is kaf-<version> or client-id of the cluster config)")
 	cobra.OnInitialize(onInit)
 }
 
@@ -194,9 +130,14 @@ func onInit() {
 	cfg.ClusterOverride = clusterOverride
 
 	cluster := cfg.ActiveCluster()
+	if connectionFlagsSet() && clusterOverride == "" {
+		// Without --cluster, the flags describe a cluster that is not
+		// in the config.
+		cluster = nil
+	}
 	if cluster != nil {
 		// Use active cluster from config
-		currentCluster = cluster
+		currentCluster = copyCluster(cluster)
 	} else {
 		// Create sane default if not configured
 		currentCluster = &config.Cluster{
@@ -208,20 +149,51 @@ func onInit() {
 	if schemaRegistryURL != "" {
 		currentCluster.SchemaRegistryURL = schemaRegistryURL
 		currentCluster.SchemaRegistryCredentials = nil
+		currentCluster.SchemaRegistries = nil
 	}
 
 	if brokersFlag != nil {
-		currentCluster.Brokers = brokersFlag
+		brokers, err := parseBrokers(brokersFlag)
+		if err != nil {
+			errorExit("%v", err)
+		}
+		if verbose && cluster != nil {
+			fmt.Fprintf(errWriter, "Using brokers %v instead of %v of cluster %v, keeping its other settings.\n", strings.Join(brokers, ","), strings.Join(cluster.Brokers, ","), cluster.Name)
+		}
+		currentCluster.Brokers = brokers
+	}
+
+	if err := applyConnectionFlags(currentCluster); err != nil {
+		errorExit("%v", err)
+	}
+
+	if insecurePlaintext && currentCluster.SASL != nil {
+		currentCluster.SASL.InsecurePlaintext = true
+	}
+
+	kaf.DefaultClientID = "kaf-" + version
+	if !noTokenCacheFlag {
+		kaf.TokenCacheDir = kaf.DefaultTokenCacheDir()
+	}
+	if clientIDFlag != "" {
+		currentCluster.ClientID = clientIDFlag
 	}
 
 	if verbose {
 		sarama.Logger = log.New(errWriter, "[sarama] ", log.Lshortfile|log.LstdFlags)
 	}
+
+	startMetrics()
+
+	if diagnoseFlag && clusterSelectorFlag == "" {
+		runDiagnosis()
+	}
 }
 
 func getClusterAdmin() (admin sarama.ClusterAdmin) {
 	clusterAdmin, err := sarama.NewClusterAdmin(currentCluster.Brokers, getConfig())
 	if err != nil {
+		diagnoseConnectionError(err)
 		errorExit("Unable to get cluster admin: %v\n", err)
 	}
 
@@ -229,38 +201,75 @@ func getClusterAdmin() (admin sarama.ClusterAdmin) {
 }
 
 func getClient() (client sarama.Client) {
-	client, err := sarama.NewClient(currentCluster.Brokers, getConfig())
-	if err != nil {
-		errorExit("Unable to get client: %v\n", err)
-	}
-	return client
+	return getClientFromConfig(getConfig())
 }
 
 func getClientFromConfig(config *sarama.Config) (client sarama.Client) {
-	client, err := sarama.NewClient(currentCluster.Brokers, config)
+	return getKafClientFromConfig(config).Sarama()
+}
+
+func getKafClientFromConfig(config *sarama.Config) *kaf.Client {
+	c, err := kaf.NewClientFromConfig(currentCluster, config)
 	if err != nil {
+		diagnoseConnectionError(err)
 		errorExit("Unable to get client: %v\n", err)
 	}
-	return client
+	return c
+}
+
+// getSchemaCache returns a schema cache for the registry responsible for the
+// value subject of topic, using the TopicNameStrategy subject names.
+func getSchemaCache(topic string) (cache *avro.SchemaCache) {
+	return getSchemaCacheForSubject(topic + "-value")
+}
+
+// getKeySchemaCache returns a schema cache for the registry responsible for
+// the key subject of topic.
+func getKeySchemaCache(topic string) (cache *avro.SchemaCache) {
+	return getSchemaCacheForSubject(topic + "-key")
+}
+
+// getProtoRegistryDecoder returns a Protobuf decoder for the registry
+// responsible for subject.
+func getProtoRegistryDecoder(subject string) *proto.RegistryDecoder {
+	registry := currentCluster.SchemaRegistryForSubject(subject)
+	if registry == nil {
+		return nil
+	}
+	var username, password string
+	if creds := registry.Credentials; creds != nil {
+		username = creds.Username
+		password = creds.Password
+	}
+	return proto.NewRegistryDecoder(registry.URL, username, password)
 }
 
-func getSchemaCache() (cache *avro.SchemaCache) {
-	if currentCluster.SchemaRegistryURL == "" {
+func getSchemaCacheForSubject(subject string) (cache *avro.SchemaCache) {
+	registry := currentCluster.SchemaRegistryForSubject(subject)
+	if registry == nil {
 		return nil
 	}
 	var username, password string
-	if creds := currentCluster.SchemaRegistryCredentials; creds != nil {
+	if creds := registry.Credentials; creds != nil {
 		username = creds.Username
 		password = creds.Password
 	}
-	cache, err := avro.NewSchemaCache(currentCluster.SchemaRegistryURL, username, password)
+	cache, err := avro.NewSchemaCache(registry.URL, username, password)
 	if err != nil {
 		errorExit("Unable to get schema cache :%v\n", err)
 	}
+	cache.NativeJSON = avroNativeJSONFlag
 	return cache
 }
 
+// errorExit prints the message to stderr and exits. With --output json, the
+// message is printed as a JSON error object with the code of the first error
+// argument.
 func errorExit(format string, a ...interface{}) {
-	fmt.Fprintf(errWriter, format+"\n", a...)
+	if outputFormat == OutputFormatJSON {
+		writeJSONError(errWriter, fmt.Sprintf(format+"\n", a...), errorCode(a))
+	} else {
+		fmt.Fprintf(errWriter, format+"\n", a...)
+	}
 	os.Exit(1)
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+var (
+	metricsFlag         bool
+	metricsIntervalFlag time.Duration
+	statsdAddrFlag      string
+
+	// metricRegistry collects the metrics of all sarama clients if --metrics
+	// or --statsd-addr is given.
+	metricRegistry metrics.Registry
+	metricsDone    chan struct{}
+	metricsWG      sync.WaitGroup
+)
+
+// intervalMetrics are printed every --metrics-interval, all metrics are part of
+// the summary at exit.
+var intervalMetrics = []string{"request-rate", "request-latency-in-ms", "record-send-rate", "batch-size", "consumer-fetch-rate", "incoming-byte-rate", "outgoing-byte-rate"}
+
+// retainingRegistry keeps metrics when sarama unregisters them on closing a
+// client, so that the summary at exit includes all clients.
+type retainingRegistry struct {
+	metrics.Registry
+}
+
+func (retainingRegistry) Unregister(string) {}
+
+func (retainingRegistry) UnregisterAll() {}
+
+// startMetrics starts reporting sarama metrics if --metrics or --statsd-addr
+// is given.
+func startMetrics() {
+	if !metricsFlag && statsdAddrFlag == "" {
+		return
+	}
+	if metricsIntervalFlag <= 0 {
+		errorExit("--metrics-interval must be positive")
+	}
+
+	var statsd net.Conn
+	if statsdAddrFlag != "" {
+		var err error
+		statsd, err = net.Dial("udp", statsdAddrFlag)
+		if err != nil {
+			errorExit("Unable to connect to statsd at %v: %v", statsdAddrFlag, err)
+		}
+	}
+
+	metricRegistry = retainingRegistry{metrics.NewRegistry()}
+	metricsDone = make(chan struct{})
+	metricsWG.Add(1)
+	go func() {
+		defer metricsWG.Done()
+		if statsd != nil {
+			defer statsd.Close()
+		}
+		ticker := time.NewTicker(metricsIntervalFlag)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-metricsDone:
+				if statsd != nil {
+					sendStatsd(statsd, metricRegistry)
+				}
+				return
+			case <-ticker.C:
+				if metricsFlag {
+					if line := formatIntervalMetrics(metricRegistry); line != "" {
+						fmt.Fprintf(errWriter, "[metrics] %v\n", line)
+					}
+				}
+				if statsd != nil {
+					sendStatsd(statsd, metricRegistry)
+				}
+			}
+		}
+	}()
+}
+
+// stopMetrics stops reporting and prints the summary with --metrics.
+func stopMetrics() {
+	if metricRegistry == nil {
+		return
+	}
+	close(metricsDone)
+	metricsWG.Wait()
+	if metricsFlag {
+		writeMetricsSummary(errWriter, metricRegistry)
+	}
+}
+
+func formatIntervalMetrics(registry metrics.Registry) string {
+	var parts []string
+	for _, name := range intervalMetrics {
+		switch m := registry.Get(name).(type) {
+		case metrics.Meter:
+			parts = append(parts, fmt.Sprintf("%v=%.1f/s", name, m.Rate1()))
+		case metrics.Histogram:
+			if m.Count() > 0 {
+				parts = append(parts, fmt.Sprintf("%v=mean %.1f p99 %.1f", name, m.Mean(), m.Percentile(0.99)))
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// writeMetricsSummary prints all metrics except the per broker and per topic
+// ones.
+func writeMetricsSummary(w io.Writer, registry metrics.Registry) {
+	var names []string
+	registry.Each(func(name string, _ interface{}) {
+		if !strings.Contains(name, "-for-broker-") && !strings.Contains(name, "-for-topic-") {
+			names = append(names, name)
+		}
+	})
+	if len(names) == 0 {
+		return
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	fmt.Fprintf(tw, "METRIC\tCOUNT\tMEAN RATE\tMEAN\tP99\tMAX\t\n")
+	for _, name := range names {
+		switch m := registry.Get(name).(type) {
+		case metrics.Meter:
+			s := m.Snapshot()
+			fmt.Fprintf(tw, "%v\t%v\t%.1f/s\t\t\t\t\n", name, s.Count(), s.RateMean())
+		case metrics.Histogram:
+			s := m.Snapshot()
+			fmt.Fprintf(tw, "%v\t%v\t\t%.1f\t%.1f\t%v\t\n", name, s.Count(), s.Mean(), s.Percentile(0.99), s.Max())
+		case metrics.Counter:
+			fmt.Fprintf(tw, "%v\t%v\t\t\t\t\t\n", name, m.Count())
+		}
+	}
+	tw.Flush()
+}
+
+// statsdLines returns gauges in the statsd line format for all metrics,
+// prefixed with kaf.
+func statsdLines(registry metrics.Registry) []string {
+	var lines []string
+	registry.Each(func(name string, metric interface{}) {
+		name = "kaf." + name
+		switch m := metric.(type) {
+		case metrics.Meter:
+			s := m.Snapshot()
+			lines = append(lines, fmt.Sprintf("%v.count:%d|g", name, s.Count()), fmt.Sprintf("%v.rate1:%f|g", name, s.Rate1()))
+		case metrics.Histogram:
+			s := m.Snapshot()
+			lines = append(lines, fmt.Sprintf("%v.mean:%f|g", name, s.Mean()), fmt.Sprintf("%v.p99:%f|g", name, s.Percentile(0.99)), fmt.Sprintf("%v.max:%d|g", name, s.Max()))
+		case metrics.Counter:
+			lines = append(lines, fmt.Sprintf("%v:%d|g", name, m.Count()))
+		}
+	})
+	sort.Strings(lines)
+	return lines
+}
+
+// statsdMaxPacket keeps packets below common MTUs.
+const statsdMaxPacket = 1400
+
+func sendStatsd(conn net.Conn, registry metrics.Registry) {
+	var packet []byte
+	for _, line := range statsdLines(registry) {
+		if len(packet) > 0 && len(packet)+1+len(line) > statsdMaxPacket {
+			_, _ = conn.Write(packet)
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		_, _ = conn.Write(packet)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsSummary(t *testing.T) {
+	registry := retainingRegistry{metrics.NewRegistry()}
+	metrics.GetOrRegisterMeter("request-rate", registry).Mark(3)
+	metrics.GetOrRegisterMeter("request-rate-for-broker-1", registry).Mark(3)
+	h := metrics.GetOrRegisterHistogram("request-latency-in-ms", registry, metrics.NewUniformSample(10))
+	h.Update(10)
+	h.Update(30)
+	metrics.GetOrRegisterCounter("requests-in-flight", registry).Inc(1)
+
+	// sarama unregisters the metrics of closed clients.
+	registry.UnregisterAll()
+
+	var out bytes.Buffer
+	writeMetricsSummary(&out, registry)
+	summary := out.String()
+	require.Contains(t, summary, "METRIC")
+	require.Regexp(t, `request-latency-in-ms\s+2\s+20.0\s+30.0\s+30`, summary)
+	require.Regexp(t, `request-rate\s+3\s+`, summary)
+	require.Regexp(t, `requests-in-flight\s+1`, summary)
+	require.NotContains(t, summary, "for-broker")
+
+	require.Contains(t, formatIntervalMetrics(registry), "request-latency-in-ms=mean 20.0 p99 30.0")
+
+	lines := statsdLines(registry)
+	require.Contains(t, lines, "kaf.request-rate.count:3|g")
+	require.Contains(t, lines, "kaf.request-rate-for-broker-1.count:3|g")
+	require.Contains(t, lines, "kaf.request-latency-in-ms.max:30|g")
+	require.Contains(t, lines, "kaf.requests-in-flight:1|g")
+}
+
+func TestSendStatsd(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	registry := metrics.NewRegistry()
+	for _, name := range []string{"a", "b", "c"} {
+		metrics.GetOrRegisterCounter(strings.Repeat(name, 600), registry).Inc(1)
+	}
+
+	conn, err := net.Dial("udp", listener.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	sendStatsd(conn, registry)
+
+	var received []string
+	buf := make([]byte, 2048)
+	for len(received) < 3 {
+		n, _, err := listener.ReadFrom(buf)
+		require.NoError(t, err)
+		require.LessOrEqual(t, n, statsdMaxPacket)
+		received = append(received, strings.Split(string(buf[:n]), "\n")...)
+	}
+	require.Equal(t, statsdLines(registry), received)
+}
@@ -1,19 +1,48 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"text/tabwriter"
 
 	"sort"
 
+	"github.com/IBM/sarama"
 	"github.com/spf13/cobra"
 )
 
+var (
+	logDirsTopicFlag string
+	balanceTopicFlag string
+
+	nodeSetConfigAllFlag    bool
+	nodeSetConfigDryRunFlag bool
+)
+
 func init() {
 	rootCmd.AddCommand(nodeCommand)
 	rootCmd.AddCommand(nodesCommand)
 	nodeCommand.AddCommand(nodeLsCommand)
 	nodeLsCommand.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers")
+
+	nodeCommand.AddCommand(nodeLogDirsCommand)
+	nodeLogDirsCommand.Flags().StringVar(&logDirsTopicFlag, "topic", "", "Only show partitions of this topic")
+	nodeLogDirsCommand.Flags().Var(&outputFormat, "output", "Set output format: default, json")
+	nodeLogDirsCommand.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers")
+	if err := nodeLogDirsCommand.RegisterFlagCompletionFunc("output", completeOutputFormat); err != nil {
+		errorExit("Failed to register flag completion: %v", err)
+	}
+
+	nodeCommand.AddCommand(nodeBalanceCommand)
+	nodeBalanceCommand.Flags().StringVar(&balanceTopicFlag, "topic", "", "Only count partitions of this topic")
+	nodeBalanceCommand.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers")
+
+	nodeCommand.AddCommand(nodeSetConfigCommand)
+	nodeSetConfigCommand.Flags().BoolVar(&nodeSetConfigAllFlag, "all", false, "Apply to every broker of the cluster")
+	nodeSetConfigCommand.Flags().BoolVar(&nodeSetConfigDryRunFlag, "dry-run", false, "Only validate the change, do not apply it")
+	nodeSetConfigCommand.Flags().StringVar(&configOperationFlag, "operation", "set", "How to change the configs: set, delete (reset to default), append or subtract (list configs)")
 }
 
 var nodesCommand = &cobra.Command{
@@ -54,3 +83,232 @@ var nodeLsCommand = &cobra.Command{
 		w.Flush()
 	},
 }
+
+var nodeBalanceCommand = &cobra.Command{
+	Use:   "balance",
+	Short: "Show how partition leaders and replicas are distributed across brokers",
+	Long:  "Show the number of partition leaders, preferred leaders and replicas per broker for the whole cluster or a topic, flagging brokers leading more partitions than the average.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		admin := getClusterAdmin()
+
+		brokers, _, err := admin.DescribeCluster()
+		if err != nil {
+			errorExit("Unable to describe cluster: %v\n", err)
+		}
+		brokerIDs := make([]int32, 0, len(brokers))
+		for _, broker := range brokers {
+			brokerIDs = append(brokerIDs, broker.ID())
+		}
+
+		var topics []string
+		if balanceTopicFlag != "" {
+			topics = []string{balanceTopicFlag}
+		} else {
+			details, err := admin.ListTopics()
+			if err != nil {
+				errorExit("Unable to list topics: %v\n", err)
+			}
+			for topic := range details {
+				topics = append(topics, topic)
+			}
+		}
+
+		metadata, err := admin.DescribeTopics(topics)
+		if err != nil {
+			errorExit("Unable to describe topics: %v\n", err)
+		}
+		for _, m := range metadata {
+			if m.Err != sarama.ErrNoError {
+				errorExit("Unable to describe topic %v: %v\n", m.Name, m.Err)
+			}
+		}
+
+		writeBalance(outWriter, computeBalance(brokerIDs, metadata))
+	},
+}
+
+// logDirReplica is a partition replica stored in a broker log directory.
+type logDirReplica struct {
+	Broker    int32  `json:"broker"`
+	LogDir    string `json:"logDir"`
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Size      int64  `json:"size"`
+	OffsetLag int64  `json:"offsetLag"`
+	// Future is set for the replica a partition is being moved to.
+	Future bool `json:"future"`
+}
+
+var nodeLogDirsCommand = &cobra.Command{
+	Use:   "logdirs [BROKER_ID]",
+	Short: "Show on-disk size of partitions per broker log directory",
+	Long:  "Show on-disk size of partitions per broker log directory, sorted by size. Replicas marked as future are the target of a log directory move still in progress.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		admin := getClusterAdmin()
+		defer admin.Close()
+
+		var brokerIDs []int32
+		if len(args) == 1 {
+			id, err := strconv.ParseInt(args[0], 10, 32)
+			if err != nil {
+				errorExit("Invalid broker ID %q", args[0])
+			}
+			brokerIDs = []int32{int32(id)}
+		} else {
+			brokers, _, err := admin.DescribeCluster()
+			if err != nil {
+				errorExit("Unable to describe cluster: %v\n", err)
+			}
+			for _, broker := range brokers {
+				brokerIDs = append(brokerIDs, broker.ID())
+			}
+		}
+
+		logDirs, err := admin.DescribeLogDirs(brokerIDs)
+		if err != nil {
+			errorExit("Unable to describe log dirs: %v\n", err)
+		}
+
+		var replicas []logDirReplica
+		for broker, dirs := range logDirs {
+			for _, dir := range dirs {
+				if dir.ErrorCode != 0 {
+					fmt.Fprintf(errWriter, "Broker %v log dir %v: %v\n", broker, dir.Path, dir.ErrorCode)
+					continue
+				}
+				for _, topic := range dir.Topics {
+					if logDirsTopicFlag != "" && topic.Topic != logDirsTopicFlag {
+						continue
+					}
+					for _, partition := range topic.Partitions {
+						replicas = append(replicas, logDirReplica{
+							Broker:    broker,
+							LogDir:    dir.Path,
+							Topic:     topic.Topic,
+							Partition: partition.PartitionID,
+							Size:      partition.Size,
+							OffsetLag: partition.OffsetLag,
+							Future:    partition.IsTemporary,
+						})
+					}
+				}
+			}
+		}
+
+		sort.Slice(replicas, func(i, j int) bool {
+			a, b := replicas[i], replicas[j]
+			if a.Size != b.Size {
+				return a.Size > b.Size
+			}
+			if a.Topic != b.Topic {
+				return a.Topic < b.Topic
+			}
+			if a.Partition != b.Partition {
+				return a.Partition < b.Partition
+			}
+			return a.Broker < b.Broker
+		})
+
+		if outputFormat == OutputFormatJSON {
+			if replicas == nil {
+				replicas = []logDirReplica{}
+			}
+			b, err := json.Marshal(replicas)
+			if err != nil {
+				errorExit("Failed to encode log dirs: %v", err)
+			}
+			fmt.Fprintln(outWriter, string(b))
+			return
+		}
+
+		w := tabwriter.NewWriter(outWriter, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		if !noHeaderFlag {
+			_, _ = fmt.Fprintf(w, "BROKER\tLOG DIR\tTOPIC\tPARTITION\tSIZE\tOFFSET LAG\tFUTURE\t\n")
+		}
+		for _, r := range replicas {
+			_, _ = fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t\n", r.Broker, r.LogDir, r.Topic, r.Partition, humanBytes(r.Size), r.OffsetLag, r.Future)
+		}
+		w.Flush()
+	},
+}
+
+var nodeSetConfigCommand = &cobra.Command{
+	Use:   "set-config [BROKER_ID] KEY=VALUE...",
+	Short: "Set dynamic broker configs",
+	Long:  "Set dynamic broker configs like log.cleaner.threads without a restart. Read-only configs can only be changed in the broker configuration file. Only the given configs are changed on Kafka >=2.3.0, older clusters reset all other dynamic configs of the broker.",
+	Example: "kaf node set-config 1 log.cleaner.threads=2\n" +
+		"kaf node set-config --all --dry-run log.cleaner.threads=2\n" +
+		"kaf node set-config 1 --operation delete log.cleaner.threads",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if nodeSetConfigAllFlag {
+			return cobra.MinimumNArgs(1)(cmd, args)
+		}
+		return cobra.MinimumNArgs(2)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		admin := getClusterAdmin()
+		defer admin.Close()
+
+		var brokerIDs []string
+		if nodeSetConfigAllFlag {
+			brokers, _, err := admin.DescribeCluster()
+			if err != nil {
+				errorExit("Unable to describe cluster: %v\n", err)
+			}
+			sort.Slice(brokers, func(i, j int) bool { return brokers[i].ID() < brokers[j].ID() })
+			for _, broker := range brokers {
+				brokerIDs = append(brokerIDs, strconv.Itoa(int(broker.ID())))
+			}
+		} else {
+			if _, err := strconv.ParseInt(args[0], 10, 32); err != nil {
+				errorExit("Invalid broker ID %q", args[0])
+			}
+			brokerIDs = []string{args[0]}
+			args = args[1:]
+		}
+
+		operation, err := parseConfigOperation(configOperationFlag)
+		if err != nil {
+			errorExit("%v", err)
+		}
+		entries, err := parseConfigArgs(args, operation)
+		if err != nil {
+			errorExit("%v", err)
+		}
+		keys := make([]string, 0, len(entries))
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, id := range brokerIDs {
+			current, err := admin.DescribeConfig(sarama.ConfigResource{
+				Type:        sarama.BrokerResource,
+				Name:        id,
+				ConfigNames: keys,
+			})
+			if err != nil {
+				errorExit("Unable to describe config of broker %v: %v\n", id, err)
+			}
+			for _, entry := range current {
+				if entry.ReadOnly {
+					errorExit("Config %v of broker %v is read-only, it can only be changed in the broker configuration followed by a restart", entry.Name, id)
+				}
+			}
+		}
+
+		incremental := getIncrementalAlterConfigs()
+		for _, id := range brokerIDs {
+			if err := alterConfigs(admin, incremental, sarama.BrokerResource, id, entries, nodeSetConfigDryRunFlag); err != nil {
+				errorExit("Unable to alter config of broker %v: %v\n", id, err)
+			}
+			if nodeSetConfigDryRunFlag {
+				fmt.Fprintf(outWriter, "Validated config of broker %v, nothing was changed: %v\n", id, strings.Join(keys, ", "))
+			} else {
+				fmt.Fprintf(outWriter, "\xE2\x9C\x85 Updated config of broker %v: %v\n", id, strings.Join(keys, ", "))
+			}
+		}
+	},
+}
@@ -10,3 +10,12 @@ func TestNode(t *testing.T) {
 	out := runCmdWithBroker(t, nil, "node", "ls")
 	require.Contains(t, out, kafkaAddr)
 }
+
+func TestNodeLogDirs(t *testing.T) {
+	out := runCmdWithBroker(t, nil, "node", "logdirs")
+	require.Contains(t, out, "LOG DIR")
+
+	out = runCmdWithBroker(t, nil, "node", "logdirs", "--output", "json")
+	require.Contains(t, out, `"logDir":`)
+	require.Contains(t, out, `"offsetLag":`)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/IBM/sarama"
+)
+
+var (
+	maxValueSizeFlag int
+	oversizeModeFlag string
+
+	// oversizedSkipped and oversizedTruncated count values larger than
+	// --max-value-size.
+	oversizedSkipped, oversizedTruncated int64
+)
+
+// limitValueSize applies --max-value-size to msg before its value is decoded
+// and formatted, which copies the value several times for JSON output. It
+// returns msg with the value cut to the limit with --oversize-mode truncate,
+// or false if msg is skipped. A warning for every oversized value is written
+// to stderr.
+func limitValueSize(msg *sarama.ConsumerMessage, stderr io.Writer) (*sarama.ConsumerMessage, bool) {
+	if maxValueSizeFlag <= 0 || len(msg.Value) <= maxValueSizeFlag {
+		return msg, true
+	}
+	if oversizeModeFlag == "truncate" {
+		atomic.AddInt64(&oversizedTruncated, 1)
+		fmt.Fprintf(stderr, "value at partition %v offset %v is %v bytes, truncated to --max-value-size %v\n", msg.Partition, msg.Offset, len(msg.Value), maxValueSizeFlag)
+		truncated := *msg
+		truncated.Value = msg.Value[:maxValueSizeFlag]
+		return &truncated, true
+	}
+	atomic.AddInt64(&oversizedSkipped, 1)
+	fmt.Fprintf(stderr, "value at partition %v offset %v is %v bytes, skipped as larger than --max-value-size %v\n", msg.Partition, msg.Offset, len(msg.Value), maxValueSizeFlag)
+	return msg, false
+}
+
+func reportOversized() {
+	skipped, truncated := atomic.LoadInt64(&oversizedSkipped), atomic.LoadInt64(&oversizedTruncated)
+	if skipped > 0 {
+		fmt.Fprintf(errWriter, "Skipped %v messages with values larger than --max-value-size %v\n", skipped, maxValueSizeFlag)
+	}
+	if truncated > 0 {
+		fmt.Fprintf(errWriter, "Truncated %v values larger than --max-value-size %v\n", truncated, maxValueSizeFlag)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitValueSize(t *testing.T) {
+	defer func(size int, mode string) {
+		maxValueSizeFlag, oversizeModeFlag = size, mode
+		oversizedSkipped, oversizedTruncated = 0, 0
+	}(maxValueSizeFlag, oversizeModeFlag)
+
+	msg := &sarama.ConsumerMessage{Partition: 1, Offset: 7, Value: []byte("0123456789")}
+	var stderr bytes.Buffer
+
+	maxValueSizeFlag = 0
+	out, ok := limitValueSize(msg, &stderr)
+	require.True(t, ok)
+	require.Same(t, msg, out)
+
+	maxValueSizeFlag, oversizeModeFlag = 10, "skip"
+	out, ok = limitValueSize(msg, &stderr)
+	require.True(t, ok)
+	require.Same(t, msg, out)
+	require.Empty(t, stderr.String())
+
+	maxValueSizeFlag = 4
+	_, ok = limitValueSize(msg, &stderr)
+	require.False(t, ok)
+	require.Contains(t, stderr.String(), "partition 1 offset 7 is 10 bytes, skipped")
+	require.EqualValues(t, 1, oversizedSkipped)
+
+	oversizeModeFlag = "truncate"
+	out, ok = limitValueSize(msg, &stderr)
+	require.True(t, ok)
+	require.Equal(t, "0123", string(out.Value))
+	require.Equal(t, "0123456789", string(msg.Value), "the consumed message is not changed")
+	require.EqualValues(t, 1, oversizedTruncated)
+}
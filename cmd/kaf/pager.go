@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	pageFlag bool
+
+	// pager pauses the output after every screenful with --page, nil if
+	// paging is disabled.
+	pager *pagedWriter
+)
+
+// errPagerQuit is returned for messages not written because the user quit
+// the pager.
+var errPagerQuit = errors.New("quit paging")
+
+// defaultPageRows is the height of the terminal if $LINES is not set.
+const defaultPageRows = 24
+
+// setupPager enables --page if stdout is a terminal and the terminal can be
+// read for the key presses advancing the pages.
+func setupPager() {
+	if !pageFlag || !isTerminal(outWriter) {
+		return
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		if verbose {
+			fmt.Fprintf(errWriter, "Not paging, the terminal cannot be read: %v\n", err)
+		}
+		return
+	}
+	pager = newPagedWriter(pageRows(os.Getenv("LINES")), tty, tty)
+}
+
+// pageRows returns the number of lines of the terminal from $LINES.
+func pageRows(lines string) int {
+	rows, err := strconv.Atoi(lines)
+	if err != nil || rows < 2 {
+		return defaultPageRows
+	}
+	return rows
+}
+
+// pagedWriter counts the lines written to the terminal and, once the next
+// message would scroll the first line of the page out of view, waits for
+// Enter before the message is written. Partitions write one message at a
+// time, so the consumers stop fetching while a page is shown.
+type pagedWriter struct {
+	mu     sync.Mutex
+	rows   int
+	lines  int
+	prompt io.Writer
+	input  chan string
+	quit   bool
+
+	ctx  context.Context
+	stop context.CancelFunc
+}
+
+func newPagedWriter(rows int, in io.Reader, prompt io.Writer) *pagedWriter {
+	p := &pagedWriter{
+		rows:   rows,
+		prompt: prompt,
+		input:  make(chan string),
+		ctx:    context.Background(),
+		stop:   func() {},
+	}
+	go func() {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			p.input <- scanner.Text()
+		}
+		close(p.input)
+	}()
+	return p
+}
+
+// start sets the context of the consume, which ends a wait for the next page,
+// and stop, which is called if the user quits.
+func (p *pagedWriter) start(ctx context.Context, stop context.CancelFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ctx = ctx
+	p.stop = stop
+}
+
+// wait blocks until the next message, spanning data and diagnostics, may be
+// written. It returns errPagerQuit once the user quit.
+func (p *pagedWriter) wait(data []byte, diagnostics []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.quit {
+		return errPagerQuit
+	}
+
+	n := bytes.Count(data, []byte("\n")) + 1 + bytes.Count(diagnostics, []byte("\n"))
+	// The last row of the page holds the prompt.
+	if p.lines > 0 && p.lines+n > p.rows-1 {
+		fmt.Fprint(p.prompt, "-- More -- Enter for the next page, q to quit")
+		var answer string
+		var ok bool
+		select {
+		case answer, ok = <-p.input:
+		case <-p.ctx.Done():
+			fmt.Fprintln(p.prompt)
+			return errPagerQuit
+		}
+		// Remove the prompt.
+		fmt.Fprint(p.prompt, "\033[1A\033[2K\r")
+		if !ok || strings.EqualFold(strings.TrimSpace(answer), "q") {
+			p.quit = true
+			p.stop()
+			return errPagerQuit
+		}
+		p.lines = 0
+	}
+	p.lines += n
+	return nil
+}
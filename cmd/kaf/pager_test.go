@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagedWriter(t *testing.T) {
+	in, answers := io.Pipe()
+	var prompt bytes.Buffer
+	p := newPagedWriter(4, in, &prompt)
+	stopped := false
+	p.start(context.Background(), func() { stopped = true })
+
+	// Three rows fit above the prompt.
+	require.NoError(t, p.wait([]byte("a"), nil))
+	require.NoError(t, p.wait([]byte("b\nc"), nil))
+	require.Empty(t, prompt.String())
+
+	go answers.Write([]byte("\n"))
+	require.NoError(t, p.wait([]byte("d"), []byte("decoded d\n")))
+	require.Contains(t, prompt.String(), "-- More --")
+	require.False(t, stopped)
+
+	// A message taller than the page is written on a page of its own.
+	go answers.Write([]byte("\n"))
+	require.NoError(t, p.wait([]byte("1\n2\n3\n4\n5"), nil))
+
+	go answers.Write([]byte("q\n"))
+	require.ErrorIs(t, p.wait([]byte("e"), nil), errPagerQuit)
+	require.True(t, stopped)
+	require.ErrorIs(t, p.wait([]byte("f"), nil), errPagerQuit)
+}
+
+func TestPagedWriterCanceled(t *testing.T) {
+	in, _ := io.Pipe()
+	p := newPagedWriter(2, in, io.Discard)
+	ctx, cancel := context.WithCancel(context.Background())
+	p.start(ctx, cancel)
+
+	require.NoError(t, p.wait([]byte("a"), nil))
+	cancel()
+	require.ErrorIs(t, p.wait([]byte("b"), nil), errPagerQuit)
+}
+
+func TestPageRows(t *testing.T) {
+	require.Equal(t, 50, pageRows("50"))
+	require.Equal(t, defaultPageRows, pageRows(""))
+	require.Equal(t, defaultPageRows, pageRows("1"))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/spf13/cobra"
+)
+
+var pingTimeoutFlag time.Duration
+
+func init() {
+	rootCmd.AddCommand(pingCmd)
+
+	pingCmd.Flags().DurationVar(&pingTimeoutFlag, "timeout", 10*time.Second, "Fail if connecting, authenticating or the metadata request takes longer")
+	pingCmd.Flags().Var(&outputFormat, "output", "Set output format: default or json")
+	if err := pingCmd.RegisterFlagCompletionFunc("output", completeOutputFormat); err != nil {
+		errorExit("Failed to register flag completion: %v", err)
+	}
+}
+
+var pingCmd = &cobra.Command{
+	Use:   "ping [CLUSTER]",
+	Short: "Check that a cluster is reachable with a single metadata request",
+	Long: "Connect and authenticate to a broker of the current cluster, or of CLUSTER, and request the cluster metadata with brokers and controller. " +
+		"Exits with 0 if the cluster answered and 1 otherwise, for monitoring jobs and liveness probes. " +
+		"Failures are not diagnosed, use --diagnose for that.",
+	Example:           "kaf ping\nkaf ping prod --timeout 3s --output json",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: validConfigArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 1 && args[0] != currentCluster.Name {
+			if clusterOverride != "" {
+				errorExit("ping %v cannot be combined with --cluster %v", args[0], clusterOverride)
+			}
+			cfg.ClusterOverride = args[0]
+			active := cfg.ActiveCluster()
+			if active == nil {
+				errorExit("Cluster %v not found in %v", args[0], cfg.Path())
+			}
+			cluster := copyCluster(active)
+			if err := applyConnectionFlags(cluster); err != nil {
+				errorExit("%v", err)
+			}
+			if insecurePlaintext && cluster.SASL != nil {
+				cluster.SASL.InsecurePlaintext = true
+			}
+			if brokersFlag != nil {
+				cluster.Brokers = currentCluster.Brokers
+			}
+			if clientIDFlag != "" {
+				cluster.ClientID = clientIDFlag
+			}
+			currentCluster = cluster
+		}
+		if pingTimeoutFlag <= 0 {
+			errorExit("--timeout must be positive")
+		}
+
+		result, err := ping(currentCluster.Brokers, getConfig(), pingTimeoutFlag)
+		if err != nil {
+			errorExit("Ping of cluster %v failed: %v", clusterName(), err)
+		}
+		writePing(outWriter, clusterName(), result)
+	},
+}
+
+// pingResult is the answer of a cluster to ping.
+type pingResult struct {
+	LatencyMs  int64 `json:"latencyMs"`
+	Brokers    int   `json:"brokers"`
+	Controller int32 `json:"controller"`
+
+	controllerAddr string
+}
+
+// ping connects to the brokers with cfg and requests the metadata, which also
+// authenticates. Requests are not retried and time out after timeout. sarama
+// cannot request metadata without topics, an empty topic list requests all.
+func ping(brokers []string, cfg *sarama.Config, timeout time.Duration) (pingResult, error) {
+	cfg.Metadata.Retry.Max = 0
+	cfg.Metadata.Timeout = timeout
+	cfg.Net.DialTimeout = timeout
+	cfg.Net.ReadTimeout = timeout
+	cfg.Net.WriteTimeout = timeout
+
+	start := time.Now()
+	client, err := sarama.NewClient(brokers, cfg)
+	if err != nil {
+		return pingResult{}, err
+	}
+	defer client.Close()
+	controller, err := client.Controller()
+	if err != nil {
+		return pingResult{}, err
+	}
+	return pingResult{
+		LatencyMs:      time.Since(start).Milliseconds(),
+		Brokers:        len(client.Brokers()),
+		Controller:     controller.ID(),
+		controllerAddr: controller.Addr(),
+	}, nil
+}
+
+func writePing(w io.Writer, cluster string, result pingResult) {
+	if outputFormat == OutputFormatJSON {
+		b, err := json.Marshal(result)
+		if err != nil {
+			errorExit("Failed to encode ping result: %v", err)
+		}
+		fmt.Fprintln(w, string(b))
+		return
+	}
+	fmt.Fprintf(w, "\xE2\x9C\x85 %v answered in %vms: %v brokers, controller %v (%v)\n", cluster, result.LatencyMs, result.Brokers, result.Controller, result.controllerAddr)
+}
+
+// clusterName names the current cluster in messages, by its brokers if it is
+// not in the config.
+func clusterName() string {
+	if currentCluster.Name != "" {
+		return currentCluster.Name
+	}
+	return fmt.Sprint(currentCluster.Brokers)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPing(t *testing.T) {
+	out := runCmdWithBroker(t, nil, "ping")
+	require.Contains(t, out, "answered in")
+	require.Contains(t, out, "1 brokers")
+
+	out = runCmdWithBroker(t, nil, "ping", "--output", "json")
+	var result map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	require.EqualValues(t, 1, result["brokers"])
+	require.Contains(t, result, "latencyMs")
+	require.Contains(t, result, "controller")
+}
+
+func TestWritePing(t *testing.T) {
+	var buf bytes.Buffer
+	writePing(&buf, "prod", pingResult{LatencyMs: 12, Brokers: 3, Controller: 2, controllerAddr: "kafka-2:9092"})
+	require.Equal(t, "\xE2\x9C\x85 prod answered in 12ms: 3 brokers, controller 2 (kafka-2:9092)\n", buf.String())
+}
@@ -4,19 +4,24 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/base64"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
-	"strings"
+	"strconv"
 	"text/template"
 
 	"time"
 
 	"github.com/Masterminds/sprig"
 	"github.com/IBM/sarama"
+	"github.com/birdayz/kaf/pkg/avro"
+	"github.com/birdayz/kaf/pkg/parquet"
 	"github.com/birdayz/kaf/pkg/partitioner"
 	pb "github.com/golang/protobuf/proto"
+	"github.com/linkedin/goavro/v2"
 	"github.com/spf13/cobra"
 )
 
@@ -33,15 +38,49 @@ var (
 	avroSchemaID    int
 	avroKeySchemaID int
 	templateFlag    bool
+	acksFlag        string
+	retriesFlag     int
+	timeoutFlag     time.Duration
+	idempotentFlag  bool
+	inputFraming    string
+	delimiterFlag   string
+	fileFlag        string
+	fromAvroFlag    string
+	fromParquetFlag string
+	maxInFlightFlag int
+	keyFromFlag     string
+	keyFromRequired bool
+	createMissing   bool
+	validateSchema  bool
+	continueOnError bool
+	kvDelimiterFlag string
+	headerDelimFlag string
+	valueFileFlag   string
+	keyFileFlag     string
+	compressionFlag string
+	repeatDelayFlag time.Duration
+	// createPartitionsFlag and createReplicasFlag configure topics created
+	// by --create-missing, 0 uses the broker defaults.
+	createPartitionsFlag int32
+	createReplicasFlag   int16
 )
 
 func init() {
 	rootCmd.AddCommand(produceCmd)
 
 	produceCmd.Flags().StringVarP(&keyFlag, "key", "k", "", "Key for the record. Currently only strings are supported.")
+	produceCmd.Flags().StringVar(&keyFromFlag, "key-from", "", "Dotted path of a field in the JSON value to use as key, e.g. .orderId or .customer.id")
+	produceCmd.Flags().BoolVar(&keyFromRequired, "key-from-required", false, "Fail if the --key-from path does not exist in a value. By default such records are sent without key")
 	produceCmd.Flags().BoolVar(&rawKeyFlag, "raw-key", false, "Treat value of --key as base64 and use its decoded raw value as key")
-	produceCmd.Flags().StringArrayVarP(&headerFlag, "header", "H", []string{}, "Header in format <key>:<value>. May be used multiple times to add more headers.")
+	produceCmd.Flags().StringArrayVarP(&headerFlag, "header", "H", []string{}, "Header in format <key>:<value> or <key>:<type>:<value> with type str, int, long, double, bool, hex, base64 or env (value of an environment variable). int, long and double are big endian binary. May be used multiple times to add more headers.")
+	produceCmd.Flags().StringVar(&headerFileFlag, "header-file", "", "File with one header per line in the format of --header, added before the --header headers. Empty lines and lines starting with # are skipped")
+	produceCmd.Flags().StringVar(&headerDelimFlag, "header-delimiter", ":", "Delimiter between key and value of --header. A backslash escapes the delimiter in the header key")
+	produceCmd.Flags().StringVarP(&kvDelimiterFlag, "kv-delimiter", "K", "", "Split every input line at the first delimiter into record key and value, e.g. ':' for key:value lines. A backslash escapes the delimiter in the key")
+	produceCmd.Flags().StringVar(&outputTemplateFlag, "output-template", "", "Go template of the line printed for every produced record, instead of the default confirmation. Fields: .Topic, .Partition, .Offset, .Key and .Timestamp")
+	produceCmd.Flags().BoolVarP(&quietFlag, "quiet", "q", false, "Only print the offset of every produced record, one per line")
+	produceCmd.Flags().Var(&outputFormat, "output", "Set output format: default, or json for a JSON object with topic, partition, offset, key and timestamp per produced record. Errors are printed as JSON objects to stderr")
 	produceCmd.Flags().IntVarP(&repeatFlag, "repeat", "n", 1, "Repeat records to send.")
+	produceCmd.Flags().DurationVar(&repeatDelayFlag, "repeat-delay", 0, "Time to wait between repeats of a record with --repeat")
 
 	produceCmd.Flags().StringSliceVar(&protoFiles, "proto-include", []string{}, "Path to proto files")
 	produceCmd.Flags().StringSliceVar(&protoExclude, "proto-exclude", []string{}, "Proto exclusions (path prefixes)")
@@ -55,11 +94,46 @@ func init() {
 	produceCmd.Flags().IntVarP(&avroSchemaID, "avro-schema-id", "", -1, "Value schema id for avro messsage encoding")
 	produceCmd.Flags().IntVarP(&avroKeySchemaID, "avro-key-schema-id", "", -1, "Key schema id for avro messsage encoding")
 
-	produceCmd.Flags().StringVarP(&inputModeFlag, "input-mode", "", "line", "Scanning input mode: [line|full]")
+	produceCmd.Flags().StringVarP(&inputModeFlag, "input-mode", "", "line", "Scanning input mode: [line|full|jsonl]. With jsonl, every line is a JSON object with value, and optionally key, headers, timestamp and topic, as printed by consume --output json")
 	produceCmd.Flags().IntVarP(&bufferSizeFlag, "line-length-limit", "", 0, "line length limit in line input mode")
+	produceCmd.Flags().StringVar(&inputFraming, "input-framing", "", "Framing of binary input: [length]. With length, input is a stream of 4 byte big endian length prefixes, each followed by a record value. --key applies to every record")
+	produceCmd.Flags().StringVar(&delimiterFlag, "delimiter", "", "Record separator in line input mode, instead of newlines. Escape sequences such as \\t or \\x00 are supported")
 
-	produceCmd.Flags().BoolVar(&templateFlag, "template", false, "run data through go template engine")
+	produceCmd.Flags().BoolVar(&createMissing, "create-missing", false, "Create topics that do not exist, instead of failing. Without it, kaf refuses to produce to a missing topic so that brokers with auto.create.topics.enable do not create it with their defaults")
+	produceCmd.Flags().Int32Var(&createPartitionsFlag, "create-partitions", 0, "Number of partitions of topics created by --create-missing, defaults to num.partitions of the brokers")
+	produceCmd.Flags().Int16Var(&createReplicasFlag, "create-replicas", 0, "Replication factor of topics created by --create-missing, defaults to default.replication.factor of the brokers")
+
+	produceCmd.Flags().BoolVar(&validateSchema, "validate-schema", false, "Validate values against the latest JSON Schema registered for the <topic>-value subject before sending")
+	produceCmd.Flags().BoolVar(&continueOnError, "continue-on-error", false, "Skip records failing --validate-schema or Avro encoding instead of aborting")
 
+	produceCmd.Flags().BoolVar(&templateFlag, "template", false, "run data through go template engine")
+	produceCmd.Flags().BoolVar(&encryptFlag, "encrypt", false, "Encrypt values with AES-GCM and the key of --encryption-key-file after encoding. This is application level encryption of the stored values, independent of TLS")
+	produceCmd.Flags().StringVar(&encryptionKeyFileFlag, "encryption-key-file", "", "File with the AES-128, AES-192 or AES-256 key for --encrypt, raw, hex or base64 encoded")
+	produceCmd.Flags().StringVar(&transformFlag, "transform", "", "Lua script defining a function transform(record) applied to every record before encoding. It returns the changed record, or nil to skip the record")
+	produceCmd.Flags().DurationVar(&transformTimeoutFlag, "transform-timeout", 100*time.Millisecond, "Maximum time --transform may run per record")
+
+	produceCmd.Flags().StringVar(&valueFileFlag, "value-file", "", "Send the exact content of this file as the value of a single record")
+	produceCmd.Flags().StringVar(&keyFileFlag, "key-file", "", "Use the exact content of this file as key")
+	produceCmd.Flags().StringVar(&compressionFlag, "compression", "none", "Compression of record batches: [none|gzip|snappy|lz4|zstd]")
+	produceCmd.Flags().StringVar(&fileFlag, "file", "", "Read records from file instead of stdin. Records are sent asynchronously with progress reporting")
+	produceCmd.Flags().StringVar(&fromAvroFlag, "from-avro", "", "Read records from an Avro object container file. Each record is sent as JSON, or encoded with --avro-schema-id. Use --key-from to select the key field")
+	produceCmd.Flags().StringVar(&fromParquetFlag, "from-parquet", "", "Read records from a Parquet file with a flat schema of primitive columns. Each row is sent as a JSON object with the columns in schema order, timestamps and dates as RFC 3339 strings, decimals as strings and binary columns without string type as base64. Use --key-from to select the key column")
+	produceCmd.Flags().StringVar(&fromDumpFlag, "from-dump", "", "Read jsonl records, as printed by consume --output json, from this file. Same as --file with --input-mode jsonl")
+	produceCmd.Flags().BoolVar(&preserveTimingFlag, "preserve-timing", false, "Replay jsonl records with the gaps between their timestamps, divided by --speed. Records are sent in file order")
+	produceCmd.Flags().Float64Var(&speedFlag, "speed", 1, "Speed factor of --preserve-timing, e.g. 2 to replay twice as fast. 0 sends records as fast as possible")
+	produceCmd.Flags().IntVar(&maxInFlightFlag, "max-in-flight", 1000, "Maximum number of unacknowledged records when producing from --file, --from-avro or --from-parquet")
+	produceCmd.Flags().IntVar(&maxMessageBytesFlag, "max-message-bytes", 1024*1024, "Largest record the producer sends, in bytes. Records are checked against it and against max.message.bytes of the topic before they are sent")
+	produceCmd.Flags().DurationVar(&flushFrequencyFlag, "flush-frequency", 0, "Send batched records at least this often when producing from --file, --from-avro, --from-parquet or --from-dump. 0 sends as soon as possible")
+	produceCmd.Flags().IntVar(&flushMessagesFlag, "flush-messages", 0, "Send a batch once it holds this many records when producing from --file, --from-avro, --from-parquet or --from-dump, or after --flush-frequency. Larger batches raise throughput")
+
+	produceCmd.Flags().StringVar(&acksFlag, "acks", "leader", "Required acks for a record: [none|leader|all]")
+	produceCmd.Flags().IntVar(&retriesFlag, "retries", 3, "Number of times to retry sending a record")
+	produceCmd.Flags().DurationVar(&timeoutFlag, "timeout", 10*time.Second, "Maximum time to wait for the required acks")
+	produceCmd.Flags().BoolVar(&idempotentFlag, "idempotent", false, "Enable the idempotent producer. Requires --acks all")
+
+	if err := produceCmd.RegisterFlagCompletionFunc("output", completeOutputFormat); err != nil {
+		errorExit("Failed to register flag completion: %v", err)
+	}
 }
 
 func readLines(reader io.Reader, out chan []byte) {
@@ -67,9 +141,17 @@ func readLines(reader io.Reader, out chan []byte) {
 	if bufferSizeFlag > 0 {
 		scanner.Buffer(make([]byte, bufferSizeFlag), bufferSizeFlag)
 	}
+	if delimiterFlag != "" {
+		delimiter, err := strconv.Unquote(`"` + delimiterFlag + `"`)
+		if err != nil {
+			errorExit("Invalid --delimiter: %v\n", err)
+		}
+		scanner.Split(splitOn([]byte(delimiter)))
+	}
 
 	for scanner.Scan() {
-		out <- scanner.Bytes()
+		// The scanner reuses its buffer, records may still be in flight.
+		out <- append([]byte(nil), scanner.Bytes()...)
 	}
 	close(out)
 
@@ -78,8 +160,173 @@ func readLines(reader io.Reader, out chan []byte) {
 	}
 }
 
+// splitOn returns a bufio.SplitFunc splitting input at every occurrence of
+// delimiter.
+func splitOn(delimiter []byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := bytes.Index(data, delimiter); i >= 0 {
+			return i + len(delimiter), data[:i], nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// unquoteDelimiter resolves escape sequences such as \t in a delimiter flag.
+func unquoteDelimiter(flag, delimiter string) []byte {
+	unquoted, err := strconv.Unquote(`"` + delimiter + `"`)
+	if err != nil || unquoted == "" {
+		errorExit("Invalid %v %q\n", flag, delimiter)
+	}
+	return []byte(unquoted)
+}
+
+// splitKeyValue splits data at the first delimiter that is not escaped with
+// a backslash. In the key, escaped delimiters and backslashes are unescaped,
+// the value is returned as is. ok is false if data contains no unescaped
+// delimiter.
+func splitKeyValue(data []byte, delimiter []byte) (key []byte, value []byte, ok bool) {
+	key = make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == '\\' && i+1 < len(data) {
+			if bytes.HasPrefix(data[i+1:], delimiter) {
+				key = append(key, delimiter...)
+				i += len(delimiter)
+				continue
+			}
+			if data[i+1] == '\\' {
+				key = append(key, '\\')
+				i++
+				continue
+			}
+		}
+		if bytes.HasPrefix(data[i:], delimiter) {
+			return key, data[i+len(delimiter):], true
+		}
+		key = append(key, data[i])
+	}
+	return nil, nil, false
+}
+
+// readLengthDelimited reads records prefixed with their length as a 4 byte
+// big endian integer. Lengths above maxLength are rejected before the record
+// is allocated.
+func readLengthDelimited(reader io.Reader, maxLength int, out chan []byte) {
+	for {
+		data, err := readLengthDelimitedRecord(reader, maxLength)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errorExit("%v\n", err)
+		}
+		out <- data
+	}
+	close(out)
+}
+
+// readLengthDelimitedRecord reads the next length delimited record, io.EOF if
+// there is none.
+func readLengthDelimitedRecord(reader io.Reader, maxLength int) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("reading record length failed: %w", err)
+	}
+
+	length := binary.BigEndian.Uint32(header)
+	if uint64(length) > uint64(maxLength) {
+		return nil, fmt.Errorf("record length %v exceeds --max-message-bytes %v", length, maxLength)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, fmt.Errorf("reading record failed: %w", err)
+	}
+	return data, nil
+}
+
+// avroContainerCodec is the codec of the --from-avro file, set before its
+// first record is read.
+var avroContainerCodec *goavro.Codec
+
+// readAvroContainer reads the records of an Avro object container file as
+// JSON, counting them in rows.
+func readAvroContainer(reader io.Reader, out chan []byte, rows *int) {
+	ocf, err := goavro.NewOCFReader(reader)
+	if err != nil {
+		errorExit("Unable to read Avro file: %v\n", err)
+	}
+	avroContainerCodec = ocf.Codec()
+	for ocf.Scan() {
+		native, err := ocf.Read()
+		if err != nil {
+			errorExit("Unable to read row %v of Avro file: %v\n", *rows+1, err)
+		}
+		*rows++
+		data, err := ocf.Codec().TextualFromNative(nil, native)
+		if err != nil {
+			errorExit("Unable to convert row %v of Avro file to JSON: %v\n", *rows, err)
+		}
+		out <- data
+	}
+	if err := ocf.Err(); err != nil {
+		errorExit("Unable to read Avro file: %v\n", err)
+	}
+	close(out)
+}
+
+// avroKeySource returns the --from-avro record value as plain JSON for
+// --key-from. Records are sent in the Avro JSON encoding, which wraps union
+// values as {"string": "x"}, so paths of optional fields would not match.
+// Other values are returned as is.
+func avroKeySource(value []byte) []byte {
+	if avroContainerCodec == nil {
+		return value
+	}
+	native, _, err := avroContainerCodec.NativeFromTextual(value)
+	if err != nil {
+		errorExit("Failed to extract key from value: %v", err)
+	}
+	plain, err := avro.NativeJSON(avroContainerCodec.Schema(), native)
+	if err != nil {
+		errorExit("Failed to extract key from value: %v", err)
+	}
+	return plain
+}
+
+// readParquet reads the rows of a Parquet file as JSON, counting them in rows.
+func readParquet(file *os.File, out chan []byte, rows *int) {
+	info, err := file.Stat()
+	if err != nil {
+		errorExit("Unable to read Parquet file: %v\n", err)
+	}
+	reader, err := parquet.NewReader(file, info.Size())
+	if err != nil {
+		errorExit("Unable to read Parquet file: %v\n", err)
+	}
+	for {
+		data, err := reader.ReadJSON()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errorExit("Unable to read row %v of Parquet file: %v\n", *rows+1, err)
+		}
+		*rows++
+		out <- data
+	}
+	close(out)
+}
+
 func readFull(reader io.Reader, out chan []byte) {
-	data, err := ioutil.ReadAll(inReader)
+	data, err := ioutil.ReadAll(reader)
 	if err != nil {
 		errorExit("Unable to read data\n")
 	}
@@ -88,12 +335,23 @@ func readFull(reader io.Reader, out chan []byte) {
 }
 
 var produceCmd = &cobra.Command{
-	Use:               "produce TOPIC",
-	Short:             "Produce record. Reads data from stdin.",
-	Args:              cobra.ExactArgs(1),
+	Use:   "produce TOPIC",
+	Short: "Produce record. Reads data from stdin.",
+	Args: func(cmd *cobra.Command, args []string) error {
+		// In jsonl mode the topic may be given per record instead.
+		if inputModeFlag == "jsonl" || fromDumpFlag != "" {
+			return cobra.MaximumNArgs(1)(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	ValidArgsFunction: validTopicArgs,
 	PreRun:            setupProtoDescriptorRegistry,
 	Run: func(cmd *cobra.Command, args []string) {
+		var topicArg string
+		if len(args) > 0 {
+			topicArg = args[0]
+		}
+
 		cfg := getConfig()
 		switch partitionerFlag {
 		case "jvm":
@@ -108,28 +366,194 @@ var produceCmd = &cobra.Command{
 			cfg.Producer.Partitioner = sarama.NewManualPartitioner
 		}
 
-		producer, err := sarama.NewSyncProducer(currentCluster.Brokers, cfg)
-		if err != nil {
-			errorExit("Unable to create new sync producer: %v\n", err)
+		switch acksFlag {
+		case "none":
+			cfg.Producer.RequiredAcks = sarama.NoResponse
+		case "leader":
+			cfg.Producer.RequiredAcks = sarama.WaitForLocal
+		case "all":
+			cfg.Producer.RequiredAcks = sarama.WaitForAll
+		default:
+			errorExit("Invalid --acks %q. Possible values: none, leader, all", acksFlag)
+		}
+
+		if retriesFlag < 0 {
+			errorExit("--retries must not be negative")
+		}
+		cfg.Producer.Retry.Max = retriesFlag
+		cfg.Producer.Timeout = timeoutFlag
+
+		switch compressionFlag {
+		case "none":
+			cfg.Producer.Compression = sarama.CompressionNone
+		case "gzip":
+			cfg.Producer.Compression = sarama.CompressionGZIP
+		case "snappy":
+			cfg.Producer.Compression = sarama.CompressionSnappy
+		case "lz4":
+			cfg.Producer.Compression = sarama.CompressionLZ4
+		case "zstd":
+			if !cfg.Version.IsAtLeast(sarama.V2_1_0_0) {
+				errorExit("--compression zstd requires Kafka 2.1 or later, set the cluster version")
+			}
+			cfg.Producer.Compression = sarama.CompressionZSTD
+		default:
+			errorExit("Invalid --compression %q. Possible values: none, gzip, snappy, lz4, zstd", compressionFlag)
+		}
+
+		if idempotentFlag {
+			if cfg.Producer.RequiredAcks != sarama.WaitForAll {
+				errorExit("--idempotent requires --acks all")
+			}
+			if retriesFlag == 0 {
+				errorExit("--idempotent requires --retries to be at least 1")
+			}
+			cfg.Producer.Idempotent = true
+			cfg.Net.MaxOpenRequests = 1
+		}
+
+		setupRecordOutput(cfg.Producer.RequiredAcks)
+		setupReplay(cmd)
+		applyProduceTuningFlags(cmd, cfg)
+
+		var err error
+		source := inReader
+		var send func(msg *sarama.ProducerMessage)
+		var closeProducer func()
+		if (fileFlag != "" && fromAvroFlag != "") || (fromParquetFlag != "" && (fileFlag != "" || fromAvroFlag != "")) {
+			errorExit("--file, --from-avro and --from-parquet cannot be combined")
+		}
+		if valueFileFlag != "" && (fileFlag != "" || fromAvroFlag != "" || fromParquetFlag != "" || inputFraming != "" || kvDelimiterFlag != "" || cmd.Flags().Changed("input-mode")) {
+			errorExit("--value-file cannot be combined with --file, --from-avro, --from-parquet, --input-framing, --kv-delimiter or --input-mode")
+		}
+		if valueFileFlag != "" {
+			file, err := os.Open(valueFileFlag)
+			if err != nil {
+				errorExit("Unable to open value file: %v\n", err)
+			}
+			defer file.Close()
+			source = file
+		}
+		if path := fileFlag + fromAvroFlag + fromParquetFlag; path != "" {
+			file, err := os.Open(path)
+			if err != nil {
+				errorExit("Unable to open file: %v\n", err)
+			}
+			defer file.Close()
+			source = file
+
+			if maxInFlightFlag < 1 {
+				errorExit("--max-in-flight must be at least 1")
+			}
+			batch := newBatchProducer(cfg, maxInFlightFlag)
+			send = batch.send
+			closeProducer = batch.close
+		} else {
+			client := getKafClientFromConfig(cfg)
+			send = func(msg *sarama.ProducerMessage) {
+				err := client.Produce(cmd.Context(), []*sarama.ProducerMessage{msg})
+				partition, offset := msg.Partition, msg.Offset
+				if err != nil {
+					var perrs sarama.ProducerErrors
+					if errors.As(err, &perrs) && len(perrs) > 0 {
+						err = perrs[0].Err
+					}
+					if outputFormat == OutputFormatJSON {
+						errorExit("Failed to send record to topic %v: %v", msg.Topic, err)
+					}
+					fmt.Fprintf(outWriter, "Failed to send record: %v.", err)
+					os.Exit(1)
+				}
+
+				if printsRecords() {
+					printProducedRecord(outWriter, msg)
+				} else if cfg.Producer.RequiredAcks == sarama.NoResponse {
+					fmt.Fprintf(outWriter, "Sent record to partition %v.\n", partition)
+				} else {
+					fmt.Fprintf(outWriter, "Sent record to partition %v at offset %v.\n", partition, offset)
+				}
+			}
+			closeProducer = func() {
+				if err := client.Close(); err != nil {
+					errorExit("Failed to close producer: %v\n", err)
+				}
+			}
 		}
 
 		if avroSchemaID != -1 || avroKeySchemaID != -1 {
-			schemaCache = getSchemaCache()
+			schemaCache = getSchemaCache(topicArg)
 			if schemaCache == nil {
 				errorExit("Could not connect to schema registry")
 			}
 		}
 
+		// jsonl files are read twice, first to check all records and topics,
+		// so that a bad record does not leave a partial write. Records from
+		// stdin are checked as they arrive.
+		var fileTopics []string
+		if file, ok := source.(*os.File); ok && inputModeFlag == "jsonl" && fileFlag != "" && isRegularFile(file) {
+			fileTopics, err = scanJSONLTopics(file, topicArg)
+			if err != nil {
+				errorExit("%v", err)
+			}
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				errorExit("Unable to read file: %v\n", err)
+			}
+		}
+
 		out := make(chan []byte, 1)
-		switch inputModeFlag {
-		case "full":
-			go readFull(inReader, out)
+		var fileRows int
+		switch {
+		case fromAvroFlag != "":
+			go readAvroContainer(source, out, &fileRows)
+		case fromParquetFlag != "":
+			go readParquet(source.(*os.File), out, &fileRows)
+		case valueFileFlag != "":
+			go readFull(source, out)
+		case inputFraming == "length":
+			go readLengthDelimited(source, cfg.Producer.MaxMessageBytes, out)
+		case inputFraming != "":
+			errorExit("Invalid --input-framing %q. Possible values: length", inputFraming)
+		case inputModeFlag == "full":
+			go readFull(source, out)
+		case inputModeFlag == "line", inputModeFlag == "jsonl":
+			go readLines(source, out)
 		default:
-			go readLines(inReader, out)
+			errorExit("Invalid --input-mode %q. Possible values: line, full, jsonl", inputModeFlag)
+		}
+
+		if keyFromFlag != "" && (keyFlag != "" || rawKeyFlag || keyProtoType != "" || avroKeySchemaID != -1) {
+			errorExit("--key-from cannot be combined with --key, --raw-key, --key-proto-type or --avro-key-schema-id")
+		}
+
+		if transformFlag != "" && (keyProtoType != "" || avroKeySchemaID != -1) {
+			errorExit("--transform cannot be combined with --key-proto-type or --avro-key-schema-id")
+		}
+		setupTransformer()
+		setupEncryption(encryptFlag, "--encrypt")
+
+		var kvDelimiter []byte
+		if kvDelimiterFlag != "" {
+			if keyFlag != "" || keyFromFlag != "" || rawKeyFlag || keyProtoType != "" || avroKeySchemaID != -1 {
+				errorExit("--kv-delimiter cannot be combined with --key, --key-from, --raw-key, --key-proto-type or --avro-key-schema-id")
+			}
+			if inputModeFlag != "line" || inputFraming != "" || fromAvroFlag != "" || fromParquetFlag != "" {
+				errorExit("--kv-delimiter requires --input-mode line")
+			}
+			kvDelimiter = unquoteDelimiter("--kv-delimiter", kvDelimiterFlag)
 		}
 
 		var key sarama.Encoder
-		if rawKeyFlag {
+		if keyFileFlag != "" {
+			if keyFlag != "" || rawKeyFlag || keyFromFlag != "" || kvDelimiterFlag != "" || keyProtoType != "" || avroKeySchemaID != -1 {
+				errorExit("--key-file cannot be combined with --key, --raw-key, --key-from, --kv-delimiter, --key-proto-type or --avro-key-schema-id")
+			}
+			keyBytes, err := ioutil.ReadFile(keyFileFlag)
+			if err != nil {
+				errorExit("Unable to read key file: %v\n", err)
+			}
+			key = sarama.ByteEncoder(keyBytes)
+		} else if rawKeyFlag {
 			keyBytes, err := base64.RawStdEncoding.DecodeString(keyFlag)
 			if err != nil {
 				errorExit("--raw-key is given, but value of --key is not base64")
@@ -163,20 +587,100 @@ var produceCmd = &cobra.Command{
 			key = sarama.ByteEncoder(avroKey)
 		}
 
+		headerDelimiter := unquoteDelimiter("--header-delimiter", headerDelimFlag)
 		var headers []sarama.RecordHeader
+		if headerFileFlag != "" {
+			var err error
+			headers, err = readHeaderFile(headerFileFlag, headerDelimiter)
+			if err != nil {
+				errorExit("Invalid --header-file: %v", err)
+			}
+		}
 		for _, h := range headerFlag {
-			v := strings.SplitN(h, ":", 2)
-			if len(v) == 2 {
-				headers = append(headers, sarama.RecordHeader{
-					Key:   []byte(v[0]),
-					Value: []byte(v[1]),
-				})
+			header, err := parseHeader([]byte(h), headerDelimiter)
+			if err != nil {
+				errorExit("Invalid --header: %v", err)
 			}
+			headers = append(headers, header)
+		}
+
+		if (createPartitionsFlag != 0 || createReplicasFlag != 0) && !createMissing {
+			errorExit("--create-partitions and --create-replicas require --create-missing")
+		}
+		if createPartitionsFlag < 0 || createReplicasFlag < 0 {
+			errorExit("--create-partitions and --create-replicas must not be negative")
+		}
+		topics := &topicChecker{createMissing: createMissing, partitions: createPartitionsFlag, replicas: createReplicasFlag}
+		defer topics.close()
+		if topicArg != "" {
+			if err := topics.ensure(topicArg); err != nil {
+				errorExit("%v", err)
+			}
+		}
+		for _, topic := range fileTopics {
+			if err := topics.ensure(topic); err != nil {
+				errorExit("%v", err)
+			}
+		}
+		sizeLimit := newRecordSizeLimit(cfg, topics)
+		if repeatFlag < 1 {
+			errorExit("--repeat must be at least 1")
+		}
+		if repeatDelayFlag < 0 {
+			errorExit("--repeat-delay must not be negative")
+		}
+
+		topicCounts := make(map[string]int)
+		var sent int
+		start := time.Now()
+		// abortStream stops at a bad record of stdin, after the records
+		// before it were sent.
+		abortStream := func(err error) {
+			closeProducer()
+			errorExit("%v. Aborting, %v records before it were sent", err, sent)
+		}
+
+		var validator *schemaValidator
+		if validateSchema {
+			validator = newSchemaValidator()
 		}
+		var rejected int
 
 		for data := range out {
+			topic := topicArg
+			var record *jsonlRecord
+			if inputModeFlag == "jsonl" {
+				if len(bytes.TrimSpace(data)) == 0 {
+					continue
+				}
+				record, err = parseJSONLRecord(data)
+				if err != nil {
+					abortStream(fmt.Errorf("invalid jsonl record %s: %w", data, err))
+				}
+				topic, err = record.topic(topicArg)
+				if err != nil {
+					abortStream(fmt.Errorf("%v: %s", err, data))
+				}
+				if err := topics.ensure(topic); err != nil {
+					abortStream(err)
+				}
+				data = record.value()
+			}
+
+			var lineKey sarama.Encoder
+			if kvDelimiter != nil {
+				k, v, ok := splitKeyValue(data, kvDelimiter)
+				if !ok {
+					errorExit("Line %q does not contain the --kv-delimiter %q", data, kvDelimiter)
+				}
+				lineKey = sarama.ByteEncoder(k)
+				data = v
+			}
 
 			for i := 0; i < repeatFlag; i++ {
+				if i > 0 && repeatDelayFlag > 0 {
+					time.Sleep(repeatDelayFlag)
+				}
 
 				input := data
 
@@ -199,6 +703,40 @@ var produceCmd = &cobra.Command{
 					input = buf.Bytes()
 				}
 
+				recordKey, recordHeaders := key, headers
+				if record != nil {
+					if k := record.key(); k != nil {
+						recordKey = k
+					}
+					recordHeaders = append(append([]sarama.RecordHeader(nil), headers...), record.headers...)
+				}
+				if keyFromFlag != "" {
+					recordKey = keyFromValue(avroKeySource(input))
+				}
+				if lineKey != nil {
+					recordKey = lineKey
+				}
+
+				if transformer != nil {
+					var keep bool
+					recordKey, input, recordHeaders, keep = transformProducerRecord(topic, recordKey, input, recordHeaders)
+					if !keep {
+						continue
+					}
+				}
+
+				if validator != nil {
+					if err := validator.validate(topic, input); err != nil {
+						if !continueOnError {
+							closeProducer()
+							errorExit("Record failed schema validation %v. Aborting, no further records are sent", err)
+						}
+						fmt.Fprintf(errWriter, "Skipping record failing schema validation %v\n", err)
+						rejected++
+						continue
+					}
+				}
+
 				// Encode to..something
 
 				var marshaledInput []byte
@@ -220,14 +758,27 @@ var produceCmd = &cobra.Command{
 						errorExit("Failed to load payload proto type")
 					}
 				} else if avroSchemaID != -1 {
-					avro, err := schemaCache.EncodeMessage(avroSchemaID, data)
+					avro, err := schemaCache.EncodeMessage(avroSchemaID, input)
 					if err != nil {
-						errorExit("Failed to encode avro value", err)
+						if !continueOnError {
+							closeProducer()
+							errorExit("Failed to encode avro value: %v", err)
+						}
+						fmt.Fprintf(errWriter, "Skipping record failing Avro encoding: %v\n", err)
+						rejected++
+						continue
 					}
 					marshaledInput = avro
 				} else {
 					marshaledInput = input
 				}
+				if valueCipher != nil {
+					marshaledInput, err = encryptValue(valueCipher, marshaledInput)
+					if err != nil {
+						closeProducer()
+						errorExit("Failed to encrypt value: %v", err)
+					}
+				}
 
 				var ts time.Time
 				t, err := time.Parse(time.RFC3339, timestampFlag)
@@ -237,24 +788,67 @@ var produceCmd = &cobra.Command{
 					ts = t
 				}
 
+				if record != nil && record.Timestamp != nil {
+					ts = *record.Timestamp
+					if replay != nil {
+						replay.wait(ts)
+					}
+				}
+
 				msg := &sarama.ProducerMessage{
-					Topic:     args[0],
-					Key:       key,
-					Headers:   headers,
+					Topic:     topic,
+					Key:       recordKey,
+					Headers:   recordHeaders,
 					Timestamp: ts,
 					Value:     sarama.ByteEncoder(marshaledInput),
 				}
 				if partitionFlag != -1 {
 					msg.Partition = partitionFlag
 				}
-				partition, offset, err := producer.SendMessage(msg)
-				if err != nil {
-					fmt.Fprintf(outWriter, "Failed to send record: %v.", err)
-					os.Exit(1)
+				if err := sizeLimit.check(msg); err != nil {
+					closeProducer()
+					errorExit("Failed to send record: %v", err)
 				}
-
-				fmt.Fprintf(outWriter, "Sent record to partition %v at offset %v.\n", partition, offset)
+				send(msg)
+				topicCounts[topic]++
+				sent++
 			}
 		}
+		closeProducer()
+
+		if inputModeFlag == "jsonl" {
+			printTopicCounts(topicCounts)
+		}
+		if repeatFlag > 1 {
+			printProduceRate(sent, time.Since(start))
+		}
+		if path := fromAvroFlag + fromParquetFlag; path != "" {
+			fmt.Fprintf(errWriter, "Read %v rows from %v.\n", fileRows, path)
+		}
+		if rejected > 0 {
+			errorExit("%v records failed schema validation or encoding and were not sent", rejected)
+		}
 	},
 }
+
+// printProduceRate prints the number of records sent and the effective rate.
+func printProduceRate(total int, elapsed time.Duration) {
+	rate := float64(total) / elapsed.Seconds()
+	fmt.Fprintf(errWriter, "Produced %v records in %v (%.1f records/s).\n", total, elapsed.Round(time.Millisecond), rate)
+}
+
+// keyFromValue extracts the record key from the JSON value at the path given
+// with --key-from.
+func keyFromValue(value []byte) sarama.Encoder {
+	k, ok, err := jsonPathString(value, keyFromFlag)
+	if err != nil {
+		errorExit("Failed to extract key from value: %v", err)
+	}
+	if !ok {
+		if keyFromRequired {
+			errorExit("Value has no field %v: %s", keyFromFlag, value)
+		}
+		return nil
+	}
+	return sarama.StringEncoder(k)
+}
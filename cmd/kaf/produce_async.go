@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// batchProducer sends records through an async producer, keeping at most
+// maxInFlight records unacknowledged at a time and periodically reporting
+// progress on stderr.
+type batchProducer struct {
+	producer sarama.AsyncProducer
+	inFlight chan struct{}
+	wg       sync.WaitGroup
+	done     chan struct{}
+
+	start     time.Time
+	succeeded int64
+	failed    int64
+}
+
+func newBatchProducer(cfg *sarama.Config, maxInFlight int) *batchProducer {
+	producer, err := sarama.NewAsyncProducer(currentCluster.Brokers, cfg)
+	if err != nil {
+		errorExit("Unable to create new async producer: %v\n", err)
+	}
+
+	b := &batchProducer{
+		producer: producer,
+		inFlight: make(chan struct{}, maxInFlight),
+		done:     make(chan struct{}),
+		start:    time.Now(),
+	}
+
+	b.wg.Add(2)
+	go func() {
+		defer b.wg.Done()
+		for msg := range producer.Successes() {
+			if printsRecords() {
+				printProducedRecord(outWriter, msg)
+			}
+			atomic.AddInt64(&b.succeeded, 1)
+			<-b.inFlight
+		}
+	}()
+	go func() {
+		defer b.wg.Done()
+		for perr := range producer.Errors() {
+			atomic.AddInt64(&b.failed, 1)
+			printSendFailure(errWriter, perr.Msg, perr.Err)
+			<-b.inFlight
+		}
+	}()
+	go b.reportProgress()
+
+	return b
+}
+
+func (b *batchProducer) send(msg *sarama.ProducerMessage) {
+	b.inFlight <- struct{}{}
+	b.producer.Input() <- msg
+}
+
+func (b *batchProducer) reportProgress() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+			sent := atomic.LoadInt64(&b.succeeded)
+			fmt.Fprintf(errWriter, "Produced %v records (%.0f records/s)\n", sent, float64(sent)/time.Since(b.start).Seconds())
+		}
+	}
+}
+
+// close flushes all in-flight records and prints the final tally. It exits
+// with a non-zero code if any record failed.
+func (b *batchProducer) close() {
+	b.producer.AsyncClose()
+	b.wg.Wait()
+	close(b.done)
+
+	elapsed := time.Since(b.start)
+	fmt.Fprintf(summaryWriter(), "Produced %v records in %v (%.0f records/s), %v failed.\n", b.succeeded, elapsed.Round(time.Millisecond), float64(b.succeeded)/elapsed.Seconds(), b.failed)
+	if b.failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/IBM/sarama"
 	"github.com/stretchr/testify/require"
 )
 
@@ -22,3 +25,55 @@ func TestProduceConsume(t *testing.T) {
 		require.Contains(t, out, msg)
 	})
 }
+
+func TestConsumeEmptyTopic(t *testing.T) {
+	out := runCmdWithBroker(t, nil, "consume", "kaf-testing", "--exit-on-eof")
+	require.Contains(t, out, "0 messages")
+}
+
+func TestConsumeReadCommitted(t *testing.T) {
+	defer func(timeout time.Duration) { consumeEndIdleTimeout = timeout }(consumeEndIdleTimeout)
+	consumeEndIdleTimeout = 500 * time.Millisecond
+
+	topic := fmt.Sprintf("transactional-%d", time.Now().Unix())
+	runCmdWithBroker(t, nil, "topic", "create", topic, "--wait")
+
+	cfg := sarama.NewConfig()
+	cfg.Version = sarama.V2_0_0_0
+	cfg.Producer.Idempotent = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.Transaction.ID = topic
+	cfg.Net.MaxOpenRequests = 1
+	producer, err := sarama.NewSyncProducer([]string{kafkaAddr}, cfg)
+	require.NoError(t, err)
+	defer producer.Close()
+
+	require.NoError(t, producer.BeginTxn())
+	for _, value := range []string{"committed-1", "committed-2"} {
+		_, _, err := producer.SendMessage(&sarama.ProducerMessage{Topic: topic, Value: sarama.StringEncoder(value)})
+		require.NoError(t, err)
+	}
+	require.NoError(t, producer.CommitTxn())
+
+	// The commit marker after the records is never delivered, the consume
+	// exits once no further message arrives instead of waiting for it.
+	start := time.Now()
+	out := runCmdWithBroker(t, nil, "consume", topic, "--isolation", "read_committed")
+	require.Contains(t, out, "committed-1")
+	require.Contains(t, out, "committed-2")
+	require.Less(t, time.Since(start), 1800*time.Millisecond)
+}
+
+func TestConsumeCountEmptyRange(t *testing.T) {
+	for _, offset := range []string{"newest", "1000000"} {
+		t.Run(offset, func(t *testing.T) {
+			start := time.Now()
+			out := runCmdWithBroker(t, nil, "consume", "kaf-testing", "--count", "--offset", offset)
+			require.Contains(t, out, "Total")
+			// Nothing to count, the consume exits without waiting for
+			// new messages.
+			require.Less(t, time.Since(start), 1500*time.Millisecond)
+		})
+	}
+}
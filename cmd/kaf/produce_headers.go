@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/IBM/sarama"
+)
+
+var headerFileFlag string
+
+// headerTypes are the encodings of typed headers, key:type:value. int, long
+// and double are big endian like the serializers of the Java client.
+var headerTypes = []string{"str", "int", "long", "double", "bool", "hex", "base64", "env"}
+
+// parseHeader parses a header given as key:value or key:type:value, with
+// delimiter between the parts. A value is only typed if the part after the
+// key is one of headerTypes, so key:str:value produces the value "value" and
+// key:int:5 four bytes.
+func parseHeader(h []byte, delimiter []byte) (sarama.RecordHeader, error) {
+	key, value, ok := splitKeyValue(h, delimiter)
+	if !ok {
+		return sarama.RecordHeader{}, fmt.Errorf("header %q is not in the format key%vvalue", h, string(delimiter))
+	}
+	for _, t := range headerTypes {
+		prefix := append([]byte(t), delimiter...)
+		if !bytes.HasPrefix(value, prefix) {
+			continue
+		}
+		encoded, err := encodeHeaderValue(t, string(value[len(prefix):]))
+		if err != nil {
+			return sarama.RecordHeader{}, fmt.Errorf("header %q: %w", key, err)
+		}
+		return sarama.RecordHeader{Key: key, Value: encoded}, nil
+	}
+	return sarama.RecordHeader{Key: key, Value: value}, nil
+}
+
+func encodeHeaderValue(t string, value string) ([]byte, error) {
+	switch t {
+	case "int":
+		i, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int %q", value)
+		}
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, uint32(i))
+		return b, nil
+	case "long":
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid long %q", value)
+		}
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, uint64(i))
+		return b, nil
+	case "double":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid double %q", value)
+		}
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, math.Float64bits(f))
+		return b, nil
+	case "bool":
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool %q", value)
+		}
+		if v {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case "hex":
+		b, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex: %v", err)
+		}
+		return b, nil
+	case "base64":
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64: %v", err)
+		}
+		return b, nil
+	case "env":
+		v, ok := os.LookupEnv(value)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %v is not set", value)
+		}
+		return []byte(v), nil
+	}
+	return []byte(value), nil
+}
+
+// readHeaderFile reads one header per line in the format of --header. Empty
+// lines and lines starting with # are skipped.
+func readHeaderFile(path string, delimiter []byte) ([]sarama.RecordHeader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var headers []sarama.RecordHeader
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimRight(scanner.Bytes(), "\r")
+		if len(bytes.TrimSpace(text)) == 0 || text[0] == '#' {
+			continue
+		}
+		header, err := parseHeader(append([]byte(nil), text...), delimiter)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %w", line, err)
+		}
+		headers = append(headers, header)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
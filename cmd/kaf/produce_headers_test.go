@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHeader(t *testing.T) {
+	t.Setenv("KAF_TEST_TRACE", "abc")
+	delim := []byte(":")
+	for h, want := range map[string][]byte{
+		"trace:abc":                []byte("abc"),
+		"url:http://example":       []byte("http://example"),
+		"trace:str:int:5":          []byte("int:5"),
+		"count:int:5":              {0, 0, 0, 5},
+		"count:int:-1":             {0xff, 0xff, 0xff, 0xff},
+		"count:long:258":           {0, 0, 0, 0, 0, 0, 1, 2},
+		"ratio:double:1":           {0x3f, 0xf0, 0, 0, 0, 0, 0, 0},
+		"flag:bool:true":           {1},
+		"id:hex:00ff":              {0, 0xff},
+		"id:base64:AP8=":           {0, 0xff},
+		"trace:env:KAF_TEST_TRACE": []byte("abc"),
+		"empty:":                   {},
+	} {
+		header, err := parseHeader([]byte(h), delim)
+		require.NoError(t, err, h)
+		require.Equal(t, want, header.Value, h)
+	}
+
+	header, err := parseHeader([]byte("a=int=7"), []byte("="))
+	require.NoError(t, err)
+	require.Equal(t, sarama.RecordHeader{Key: []byte("a"), Value: []byte{0, 0, 0, 7}}, header)
+
+	for _, h := range []string{"novalue", "count:int:x", "count:int:3000000000", "id:hex:xyz", "trace:env:KAF_TEST_UNSET"} {
+		_, err := parseHeader([]byte(h), delim)
+		require.Error(t, err, h)
+	}
+}
+
+func TestReadHeaderFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "headers")
+	require.NoError(t, os.WriteFile(path, []byte("# tracing\ntrace:abc\n\ncount:int:1\r\n"), 0o600))
+	headers, err := readHeaderFile(path, []byte(":"))
+	require.NoError(t, err)
+	require.Equal(t, []sarama.RecordHeader{
+		{Key: []byte("trace"), Value: []byte("abc")},
+		{Key: []byte("count"), Value: []byte{0, 0, 0, 1}},
+	}, headers)
+
+	require.NoError(t, os.WriteFile(path, []byte("trace:abc\ninvalid\n"), 0o600))
+	_, err = readHeaderFile(path, []byte(":"))
+	require.EqualError(t, err, `line 2: header "invalid" is not in the format key:value`)
+}
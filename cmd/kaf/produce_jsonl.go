@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// jsonlRecord is a record of the jsonl input mode. The fields match the output
+// of consume --output json, so exports can be produced again as they are.
+type jsonlRecord struct {
+	Topic     string          `json:"topic"`
+	Timestamp *time.Time      `json:"timestamp"`
+	Key       json.RawMessage `json:"key"`
+	Value     json.RawMessage `json:"value"`
+	Payload   json.RawMessage `json:"payload"`
+	Headers   json.RawMessage `json:"headers"`
+
+	headers []sarama.RecordHeader
+}
+
+func parseJSONLRecord(line []byte) (*jsonlRecord, error) {
+	var record jsonlRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		return nil, err
+	}
+
+	headers := bytes.TrimSpace(record.Headers)
+	switch {
+	case len(headers) == 0 || bytes.Equal(headers, []byte("null")):
+	case headers[0] == '[':
+		// Headers as printed by consume --output json.
+		var list []sarama.RecordHeader
+		if err := json.Unmarshal(headers, &list); err != nil {
+			return nil, fmt.Errorf("invalid headers: %w", err)
+		}
+		record.headers = list
+	default:
+		var m map[string]string
+		if err := json.Unmarshal(headers, &m); err != nil {
+			return nil, fmt.Errorf("invalid headers: %w", err)
+		}
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			record.headers = append(record.headers, sarama.RecordHeader{Key: []byte(name), Value: []byte(m[name])})
+		}
+	}
+	return &record, nil
+}
+
+// topic returns the topic of the record, its own or topicArg. A record topic
+// differing from topicArg is an error, as is a record without any.
+func (r *jsonlRecord) topic(topicArg string) (string, error) {
+	if r.Topic == "" {
+		if topicArg == "" {
+			return "", fmt.Errorf("record has no topic and no topic argument is given")
+		}
+		return topicArg, nil
+	}
+	if topicArg != "" && r.Topic != topicArg {
+		return "", fmt.Errorf("record topic %v conflicts with topic argument %v", r.Topic, topicArg)
+	}
+	return r.Topic, nil
+}
+
+// scanJSONLTopics reads all jsonl records of r and returns the topics they are
+// produced to, sorted. Files are scanned before producing, so that an invalid
+// record or a missing topic fails the run before any record is sent.
+func scanJSONLTopics(r io.Reader, topicArg string) ([]string, error) {
+	out := make(chan []byte, 1)
+	go readLines(r, out)
+
+	seen := make(map[string]bool)
+	var topics []string
+	var err error
+	for data := range out {
+		if err != nil || len(bytes.TrimSpace(data)) == 0 {
+			// Drain the reader.
+			continue
+		}
+		var record *jsonlRecord
+		record, err = parseJSONLRecord(data)
+		if err != nil {
+			err = fmt.Errorf("invalid jsonl record %s: %w", data, err)
+			continue
+		}
+		var topic string
+		topic, err = record.topic(topicArg)
+		if err != nil {
+			err = fmt.Errorf("%v: %s", err, data)
+			continue
+		}
+		if !seen[topic] {
+			seen[topic] = true
+			topics = append(topics, topic)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
+// isRegularFile reports whether file can be read again from the start, which
+// pipes and devices given with --file cannot.
+func isRegularFile(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode().IsRegular()
+}
+
+// key returns the record key, or nil if the record has none.
+func (r *jsonlRecord) key() sarama.Encoder {
+	if b := jsonlBytes(r.Key); len(b) > 0 {
+		return sarama.ByteEncoder(b)
+	}
+	return nil
+}
+
+// value returns the record value, taken from "value" or from "payload".
+func (r *jsonlRecord) value() []byte {
+	if r.Value != nil {
+		return jsonlBytes(r.Value)
+	}
+	return jsonlBytes(r.Payload)
+}
+
+// jsonlBytes returns the content of JSON strings and the JSON encoding of all
+// other values. null is returned as nil.
+func jsonlBytes(raw json.RawMessage) []byte {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil
+	}
+	var s string
+	if raw[0] == '"' && json.Unmarshal(raw, &s) == nil {
+		return []byte(s)
+	}
+	return raw
+}
+
+// topicChecker verifies that topics exist before producing to them, creating
+// missing topics if requested. Missing topics are created with partitions and
+// replicas, if set, or the broker defaults.
+type topicChecker struct {
+	admin         sarama.ClusterAdmin
+	known         map[string]bool
+	createMissing bool
+	partitions    int32
+	replicas      int16
+	// limits caches max.message.bytes per topic, 0 if unknown.
+	limits map[string]int
+}
+
+func (c *topicChecker) ensure(topic string) error {
+	if c.known == nil {
+		c.admin = getClusterAdmin()
+		topics, err := c.admin.ListTopics()
+		if err != nil {
+			return fmt.Errorf("unable to list topics: %w", err)
+		}
+		c.known = make(map[string]bool, len(topics))
+		for name := range topics {
+			c.known[name] = true
+		}
+	}
+	if c.known[topic] {
+		return nil
+	}
+	if !c.createMissing {
+		return fmt.Errorf("topic %v does not exist. Create it with kaf topic create %v, or pass --create-missing", topic, topic)
+	}
+
+	partitions, replicas := c.partitions, c.replicas
+	if partitions == 0 || replicas == 0 {
+		defaultPartitions, defaultReplicas := c.brokerTopicDefaults()
+		if partitions == 0 {
+			partitions = defaultPartitions
+		}
+		if replicas == 0 {
+			replicas = defaultReplicas
+		}
+	}
+	err := c.admin.CreateTopic(topic, &sarama.TopicDetail{
+		NumPartitions:     partitions,
+		ReplicationFactor: replicas,
+	}, false)
+	if err != nil {
+		return fmt.Errorf("could not create topic %v: %w", topic, err)
+	}
+	fmt.Fprintf(errWriter, "Created topic %v with %v partitions and replication factor %v.\n", topic, partitions, replicas)
+	c.known[topic] = true
+	return nil
+}
+
+// brokerTopicDefaults returns num.partitions and default.replication.factor of
+// the controller, which are used for auto created topics as well.
+func (c *topicChecker) brokerTopicDefaults() (partitions int32, replicas int16) {
+	partitions, replicas = 1, 1
+
+	_, controllerID, err := c.admin.DescribeCluster()
+	if err != nil {
+		return partitions, replicas
+	}
+	entries, err := c.admin.DescribeConfig(sarama.ConfigResource{
+		Type:        sarama.BrokerResource,
+		Name:        strconv.Itoa(int(controllerID)),
+		ConfigNames: []string{"num.partitions", "default.replication.factor"},
+	})
+	if err != nil {
+		return partitions, replicas
+	}
+	for _, entry := range entries {
+		switch entry.Name {
+		case "num.partitions":
+			if n, err := strconv.ParseInt(entry.Value, 10, 32); err == nil {
+				partitions = int32(n)
+			}
+		case "default.replication.factor":
+			if n, err := strconv.ParseInt(entry.Value, 10, 16); err == nil {
+				replicas = int16(n)
+			}
+		}
+	}
+	return partitions, replicas
+}
+
+func (c *topicChecker) close() {
+	if c.admin != nil {
+		c.admin.Close()
+	}
+}
+
+func printTopicCounts(counts map[string]int) {
+	topics := make([]string, 0, len(counts))
+	for topic := range counts {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	w := tabwriter.NewWriter(errWriter, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	fmt.Fprintf(w, "TOPIC\tRECORDS\t\n")
+	for _, topic := range topics {
+		fmt.Fprintf(w, "%v\t%v\t\n", topic, counts[topic])
+	}
+	w.Flush()
+}
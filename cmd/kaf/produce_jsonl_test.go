@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJSONLRecord(t *testing.T) {
+	record, err := parseJSONLRecord([]byte(`{"topic":"orders","key":"o-1","value":{"id":1},"headers":{"b":"2","a":"1"}}`))
+	require.NoError(t, err)
+	require.Equal(t, "orders", record.Topic)
+	require.Equal(t, sarama.ByteEncoder("o-1"), record.key())
+	require.Equal(t, `{"id":1}`, string(record.value()))
+	require.Equal(t, []sarama.RecordHeader{
+		{Key: []byte("a"), Value: []byte("1")},
+		{Key: []byte("b"), Value: []byte("2")},
+	}, record.headers)
+
+	// Output of consume --output json.
+	record, err = parseJSONLRecord([]byte(`{"partition":0,"offset":3,"timestamp":"2024-01-02T03:04:05Z","headers":[{"Key":"aA==","Value":"dg=="}],"key":"","payload":"text"}`))
+	require.NoError(t, err)
+	require.Nil(t, record.key())
+	require.Equal(t, "text", string(record.value()))
+	require.Equal(t, []sarama.RecordHeader{{Key: []byte("h"), Value: []byte("v")}}, record.headers)
+	require.Equal(t, int64(1704164645), record.Timestamp.Unix())
+
+	_, err = parseJSONLRecord([]byte(`{"headers":1}`))
+	require.Error(t, err)
+}
+
+func TestScanJSONLTopics(t *testing.T) {
+	input := `{"topic":"orders","value":1}
+
+{"topic":"users","value":2}
+{"topic":"orders","value":3}
+`
+	topics, err := scanJSONLTopics(strings.NewReader(input), "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"orders", "users"}, topics)
+
+	topics, err = scanJSONLTopics(strings.NewReader(`{"value":1}`), "orders")
+	require.NoError(t, err)
+	require.Equal(t, []string{"orders"}, topics)
+
+	// The first bad record is reported.
+	_, err = scanJSONLTopics(strings.NewReader(input+`{"value":4}`+"\n"+`{`), "")
+	require.EqualError(t, err, `record has no topic and no topic argument is given: {"value":4}`)
+
+	_, err = scanJSONLTopics(strings.NewReader(input), "orders")
+	require.EqualError(t, err, `record topic users conflicts with topic argument orders: {"topic":"users","value":2}`)
+
+	_, err = scanJSONLTopics(strings.NewReader(`{"topic":`), "")
+	require.Error(t, err)
+}
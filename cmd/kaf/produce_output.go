@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+var (
+	outputTemplateFlag string
+	quietFlag          bool
+
+	// recordTemplate formats the line printed for every produced record if
+	// --output-template or --quiet is given.
+	recordTemplate *template.Template
+)
+
+// producedRecord is the data passed to --output-template, and the JSON object
+// printed with --output json.
+type producedRecord struct {
+	Topic     string    `json:"topic"`
+	Partition int32     `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Key       string    `json:"key"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// setupRecordOutput parses the --output-template, --quiet prints only the
+// offset of every record and --output json a JSON object per record.
+func setupRecordOutput(acks sarama.RequiredAcks) {
+	if quietFlag && outputTemplateFlag != "" {
+		errorExit("--quiet cannot be combined with --output-template")
+	}
+	switch outputFormat {
+	case OutputFormatDefault:
+	case OutputFormatJSON:
+		if quietFlag || outputTemplateFlag != "" {
+			errorExit("--output json cannot be combined with --quiet or --output-template")
+		}
+		if acks == sarama.NoResponse {
+			errorExit("--output json requires --acks leader or all, offsets are unknown without acks")
+		}
+		return
+	default:
+		errorExit("Invalid --output %v for produce. Possible values: default, json", outputFormat)
+	}
+	text := outputTemplateFlag
+	if quietFlag {
+		text = "{{.Offset}}"
+	}
+	if text == "" {
+		return
+	}
+	if acks == sarama.NoResponse {
+		errorExit("--output-template and --quiet require --acks leader or all, offsets are unknown without acks")
+	}
+	var err error
+	recordTemplate, err = parseRecordTemplate(text)
+	if err != nil {
+		errorExit("Invalid --output-template: %v", err)
+	}
+}
+
+func parseRecordTemplate(text string) (*template.Template, error) {
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return template.New("output").Parse(text)
+}
+
+// printsRecords reports whether a line is printed for every acknowledged
+// record instead of the default confirmation.
+func printsRecords() bool {
+	return recordTemplate != nil || outputFormat == OutputFormatJSON
+}
+
+// printProducedRecord prints the --output-template line, or the JSON object
+// with --output json, of an acknowledged record.
+func printProducedRecord(w io.Writer, msg *sarama.ProducerMessage) {
+	if outputFormat == OutputFormatJSON {
+		if err := writeProducedRecordJSON(w, msg); err != nil {
+			errorExit("Failed to write produced record: %v", err)
+		}
+		return
+	}
+	if err := writeProducedRecord(w, recordTemplate, msg); err != nil {
+		errorExit("Failed to execute --output-template: %v", err)
+	}
+}
+
+func writeProducedRecord(w io.Writer, t *template.Template, msg *sarama.ProducerMessage) error {
+	return t.Execute(w, newProducedRecord(msg))
+}
+
+func writeProducedRecordJSON(w io.Writer, msg *sarama.ProducerMessage) error {
+	b, err := json.Marshal(newProducedRecord(msg))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+func newProducedRecord(msg *sarama.ProducerMessage) producedRecord {
+	record := producedRecord{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Timestamp: msg.Timestamp,
+	}
+	if msg.Key != nil {
+		if key, err := msg.Key.Encode(); err == nil {
+			record.Key = string(key)
+		}
+	}
+	return record
+}
+
+// printSendFailure reports a record that could not be sent, as a JSON error
+// object with --output json.
+func printSendFailure(w io.Writer, msg *sarama.ProducerMessage, err error) {
+	message := fmt.Sprintf("Failed to send record to topic %v partition %v: %v", msg.Topic, msg.Partition, err)
+	if outputFormat == OutputFormatJSON {
+		writeJSONError(w, message, classifyError(err))
+		return
+	}
+	fmt.Fprintln(w, message)
+}
+
+// summaryWriter is where summaries after producing are printed. With
+// --output-template, --quiet or --output json, stdout only has the record
+// lines.
+func summaryWriter() io.Writer {
+	if printsRecords() {
+		return errWriter
+	}
+	return outWriter
+}
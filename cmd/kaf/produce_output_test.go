@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteProducedRecord(t *testing.T) {
+	msg := &sarama.ProducerMessage{Topic: "orders", Partition: 2, Offset: 41, Key: sarama.StringEncoder("o-1")}
+
+	tmpl, err := parseRecordTemplate("{{.Topic}}/{{.Partition}}@{{.Offset}} {{.Key}}")
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, writeProducedRecord(&buf, tmpl, msg))
+	require.NoError(t, writeProducedRecord(&buf, tmpl, &sarama.ProducerMessage{Topic: "orders", Partition: 0, Offset: 7}))
+	require.Equal(t, "orders/2@41 o-1\norders/0@7 \n", buf.String())
+
+	tmpl, err = parseRecordTemplate("{{.Offset}}\n")
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, writeProducedRecord(&buf, tmpl, msg))
+	require.Equal(t, "41\n", buf.String())
+
+	tmpl, err = parseRecordTemplate("{{.Missing}}")
+	require.NoError(t, err)
+	require.Error(t, writeProducedRecord(&buf, tmpl, msg))
+
+	_, err = parseRecordTemplate("{{.Offset")
+	require.Error(t, err)
+}
+
+func TestWriteProducedRecordJSON(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	var buf bytes.Buffer
+	require.NoError(t, writeProducedRecordJSON(&buf, &sarama.ProducerMessage{Topic: "orders", Partition: 2, Offset: 41, Key: sarama.StringEncoder("o-1"), Timestamp: ts}))
+	require.NoError(t, writeProducedRecordJSON(&buf, &sarama.ProducerMessage{Topic: "orders", Offset: 7, Timestamp: ts}))
+	require.Equal(t, `{"topic":"orders","partition":2,"offset":41,"key":"o-1","timestamp":"2024-01-02T15:04:05Z"}
+{"topic":"orders","partition":0,"offset":7,"key":"","timestamp":"2024-01-02T15:04:05Z"}
+`, buf.String())
+}
+
+func TestPrintSendFailure(t *testing.T) {
+	defer func(format OutputFormat) { outputFormat = format }(outputFormat)
+	msg := &sarama.ProducerMessage{Topic: "orders", Partition: 1}
+
+	var buf bytes.Buffer
+	outputFormat = OutputFormatDefault
+	printSendFailure(&buf, msg, sarama.ErrMessageSizeTooLarge)
+	require.Equal(t, "Failed to send record to topic orders partition 1: "+sarama.ErrMessageSizeTooLarge.Error()+"\n", buf.String())
+
+	buf.Reset()
+	outputFormat = OutputFormatJSON
+	printSendFailure(&buf, msg, sarama.ErrTopicAuthorizationFailed)
+	var obj jsonError
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &obj))
+	require.Equal(t, errorCodeAuth, obj.Code)
+	require.Contains(t, obj.Error, "topic orders partition 1")
+}
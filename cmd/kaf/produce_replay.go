@@ -0,0 +1,75 @@
+package main
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	fromDumpFlag       string
+	preserveTimingFlag bool
+	speedFlag          float64
+
+	// replay delays jsonl records with --preserve-timing.
+	replay *replayClock
+)
+
+// setupReplay applies --from-dump, which reads jsonl records from a file, and
+// validates --preserve-timing and --speed.
+func setupReplay(cmd *cobra.Command) {
+	if fromDumpFlag != "" {
+		if fileFlag != "" || fromAvroFlag != "" || fromParquetFlag != "" || valueFileFlag != "" || (cmd.Flags().Changed("input-mode") && inputModeFlag != "jsonl") {
+			errorExit("--from-dump cannot be combined with --file, --from-avro, --from-parquet, --value-file or --input-mode other than jsonl")
+		}
+		fileFlag = fromDumpFlag
+		inputModeFlag = "jsonl"
+	}
+
+	if cmd.Flags().Changed("speed") && !preserveTimingFlag {
+		errorExit("--speed requires --preserve-timing")
+	}
+	if !preserveTimingFlag {
+		return
+	}
+	if inputModeFlag != "jsonl" {
+		errorExit("--preserve-timing requires --from-dump or --input-mode jsonl, the timing is taken from the timestamps of the records")
+	}
+	if repeatFlag > 1 {
+		errorExit("--preserve-timing cannot be combined with --repeat")
+	}
+	if speedFlag < 0 {
+		errorExit("--speed must not be negative")
+	}
+	replay = &replayClock{speed: speedFlag, now: time.Now, sleep: time.Sleep}
+}
+
+// replayClock reproduces the gaps between the timestamps of records, divided
+// by speed. A speed of 0 sends records as fast as possible. Records are due
+// relative to the first record, so slow sends do not add up. Records with an
+// earlier timestamp than their predecessor are sent right away.
+type replayClock struct {
+	speed float64
+	now   func() time.Time
+	sleep func(time.Duration)
+
+	// first is the timestamp of the first record, sent at start.
+	first time.Time
+	start time.Time
+}
+
+// wait blocks until the record with timestamp ts is due.
+func (c *replayClock) wait(ts time.Time) {
+	if c.speed == 0 {
+		return
+	}
+	if c.start.IsZero() {
+		c.first = ts
+		c.start = c.now()
+		return
+	}
+	due := c.start.Add(time.Duration(float64(ts.Sub(c.first)) / c.speed))
+	if d := due.Sub(c.now()); d > 0 {
+		c.sleep(d)
+	}
+}
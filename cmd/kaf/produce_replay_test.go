@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock advances when slept on.
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+}
+
+func TestReplayClock(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := []time.Time{
+		base,
+		base.Add(2 * time.Second),
+		base.Add(3 * time.Second),
+		// Out of order, sent right away.
+		base.Add(time.Second),
+		base.Add(6 * time.Second),
+	}
+
+	for speed, want := range map[float64][]time.Duration{
+		1: {2 * time.Second, time.Second, 3 * time.Second},
+		2: {time.Second, 500 * time.Millisecond, 1500 * time.Millisecond},
+		0: nil,
+	} {
+		clock := &fakeClock{now: time.Unix(1000, 0)}
+		replay := &replayClock{speed: speed, now: func() time.Time { return clock.now }, sleep: clock.sleep}
+		for _, ts := range records {
+			replay.wait(ts)
+		}
+		require.Equal(t, want, clock.sleeps, "speed %v", speed)
+	}
+}
+
+func TestReplayClockSlowSends(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	replay := &replayClock{speed: 1, now: func() time.Time { return clock.now }, sleep: clock.sleep}
+
+	replay.wait(base)
+	// Sending took longer than the gap to the next record.
+	clock.now = clock.now.Add(3 * time.Second)
+	replay.wait(base.Add(2 * time.Second))
+	replay.wait(base.Add(4 * time.Second))
+
+	require.Equal(t, []time.Duration{time.Second}, clock.sleeps)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/spf13/cobra"
+)
+
+var (
+	maxMessageBytesFlag int
+	flushFrequencyFlag  time.Duration
+	flushMessagesFlag   int
+)
+
+// applyProduceTuningFlags sets the record size limit and the batching of the
+// producer from the flags and validates them. Without the flags the sarama
+// defaults apply.
+func applyProduceTuningFlags(cmd *cobra.Command, cfg *sarama.Config) {
+	if cmd.Flags().Changed("max-message-bytes") {
+		if maxMessageBytesFlag <= 0 || maxMessageBytesFlag >= int(sarama.MaxRequestSize) {
+			errorExit("--max-message-bytes must be between 1 and %v", sarama.MaxRequestSize-1)
+		}
+		cfg.Producer.MaxMessageBytes = maxMessageBytesFlag
+	}
+
+	if !cmd.Flags().Changed("flush-frequency") && !cmd.Flags().Changed("flush-messages") {
+		return
+	}
+	if fileFlag == "" && fromAvroFlag == "" && fromParquetFlag == "" && fromDumpFlag == "" {
+		errorExit("--flush-frequency and --flush-messages require --file, --from-avro, --from-parquet or --from-dump, other records are sent one at a time")
+	}
+	if flushFrequencyFlag < 0 {
+		errorExit("--flush-frequency must not be negative")
+	}
+	if flushMessagesFlag < 0 {
+		errorExit("--flush-messages must not be negative")
+	}
+	if flushMessagesFlag > 0 && flushFrequencyFlag == 0 {
+		// Batches of partitions receiving few records would wait forever
+		// while --max-in-flight blocks further records.
+		errorExit("--flush-messages requires --flush-frequency")
+	}
+	if flushMessagesFlag > maxInFlightFlag {
+		errorExit("--flush-messages %v must not exceed --max-in-flight %v", flushMessagesFlag, maxInFlightFlag)
+	}
+	cfg.Producer.Flush.Frequency = flushFrequencyFlag
+	cfg.Producer.Flush.Messages = flushMessagesFlag
+}
+
+// recordSizeLimit rejects records too large to be sent before they are sent,
+// with the limit they exceed.
+type recordSizeLimit struct {
+	maxMessageBytes int
+	// version is the record format version the producer encodes.
+	version    int
+	compressed bool
+	topics     *topicChecker
+}
+
+func newRecordSizeLimit(cfg *sarama.Config, topics *topicChecker) *recordSizeLimit {
+	version := 1
+	if cfg.Version.IsAtLeast(sarama.V0_11_0_0) {
+		version = 2
+	}
+	return &recordSizeLimit{
+		maxMessageBytes: cfg.Producer.MaxMessageBytes,
+		version:         version,
+		compressed:      cfg.Producer.Compression != sarama.CompressionNone,
+		topics:          topics,
+	}
+}
+
+// check returns an error if msg exceeds --max-message-bytes, or the
+// max.message.bytes of its topic. The topic limit applies to compressed
+// batches, so compressed records are left to the broker.
+func (l *recordSizeLimit) check(msg *sarama.ProducerMessage) error {
+	size := msg.ByteSize(l.version)
+	topicLimit, known := l.topics.maxMessageBytes(msg.Topic)
+	return recordSizeError(size, l.maxMessageBytes, topicLimit, known && !l.compressed, msg.Topic)
+}
+
+func recordSizeError(size, maxMessageBytes, topicLimit int, checkTopic bool, topic string) error {
+	if size > maxMessageBytes {
+		if checkTopic && size > topicLimit {
+			return fmt.Errorf("record of %v bytes exceeds --max-message-bytes %v and max.message.bytes %v of topic %v", size, maxMessageBytes, topicLimit, topic)
+		}
+		return fmt.Errorf("record of %v bytes exceeds --max-message-bytes %v, raise it to send the record", size, maxMessageBytes)
+	}
+	if checkTopic && size > topicLimit {
+		return fmt.Errorf("record of %v bytes exceeds max.message.bytes %v of topic %v, raise it with kaf topic set-config %v max.message.bytes=%v", size, topicLimit, topic, topic, size)
+	}
+	return nil
+}
+
+// maxMessageBytes returns max.message.bytes of topic, false if it cannot be
+// described, e.g. without permission. It is described once per topic.
+func (c *topicChecker) maxMessageBytes(topic string) (int, bool) {
+	if limit, ok := c.limits[topic]; ok {
+		return limit, limit > 0
+	}
+	if c.limits == nil {
+		c.limits = map[string]int{}
+	}
+	c.limits[topic] = topicMaxMessageBytes(c.admin, topic, errWriter)
+	return c.limits[topic], c.limits[topic] > 0
+}
+
+// topicMaxMessageBytes describes max.message.bytes of topic, 0 if unknown.
+// It warns if --max-message-bytes allows larger records than the topic.
+func topicMaxMessageBytes(admin sarama.ClusterAdmin, topic string, stderr io.Writer) int {
+	if admin == nil {
+		return 0
+	}
+	entries, err := admin.DescribeConfig(sarama.ConfigResource{
+		Type:        sarama.TopicResource,
+		Name:        topic,
+		ConfigNames: []string{"max.message.bytes"},
+	})
+	if err != nil {
+		return 0
+	}
+	for _, entry := range entries {
+		if entry.Name != "max.message.bytes" {
+			continue
+		}
+		limit, err := strconv.Atoi(entry.Value)
+		if err != nil {
+			return 0
+		}
+		if maxMessageBytesFlag > limit {
+			fmt.Fprintf(stderr, "Warning: --max-message-bytes %v is above max.message.bytes %v of topic %v, the broker rejects larger batches\n", maxMessageBytesFlag, limit, topic)
+		}
+		return limit
+	}
+	return 0
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordSizeError(t *testing.T) {
+	require.NoError(t, recordSizeError(100, 100, 100, true, "orders"))
+	require.NoError(t, recordSizeError(200, 1000, 100, false, "orders"), "compressed or unknown topic limits are left to the broker")
+
+	err := recordSizeError(200, 100, 0, false, "orders")
+	require.EqualError(t, err, "record of 200 bytes exceeds --max-message-bytes 100, raise it to send the record")
+
+	err = recordSizeError(200, 100, 150, true, "orders")
+	require.EqualError(t, err, "record of 200 bytes exceeds --max-message-bytes 100 and max.message.bytes 150 of topic orders")
+
+	err = recordSizeError(200, 1000, 150, true, "orders")
+	require.ErrorContains(t, err, "exceeds max.message.bytes 150 of topic orders")
+}
+
+func TestRecordSizeLimit(t *testing.T) {
+	cfg := sarama.NewConfig()
+	cfg.Version = sarama.V2_0_0_0
+	cfg.Producer.MaxMessageBytes = 100
+	limit := newRecordSizeLimit(cfg, &topicChecker{})
+
+	require.NoError(t, limit.check(&sarama.ProducerMessage{Topic: "orders", Value: sarama.StringEncoder("small")}))
+	err := limit.check(&sarama.ProducerMessage{Topic: "orders", Value: sarama.ByteEncoder(make([]byte, 200))})
+	require.ErrorContains(t, err, "exceeds --max-message-bytes 100")
+}
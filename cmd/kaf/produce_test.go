@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadAvroContainer(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := goavro.NewOCFWriter(goavro.OCFConfig{
+		W:      &buf,
+		Schema: `{"type":"record","name":"Order","fields":[{"name":"id","type":"string"},{"name":"amount","type":"long"}]}`,
+	})
+	require.NoError(t, err)
+	require.NoError(t, w.Append([]map[string]interface{}{
+		{"id": "o-1", "amount": 10},
+		{"id": "o-2", "amount": 20},
+	}))
+
+	out := make(chan []byte, 2)
+	var rows int
+	readAvroContainer(&buf, out, &rows)
+
+	var records []string
+	for data := range out {
+		records = append(records, string(data))
+	}
+	require.Equal(t, 2, rows)
+	require.Len(t, records, 2)
+	// Field order of the JSON rows is not stable.
+	require.JSONEq(t, `{"id":"o-1","amount":10}`, records[0])
+	require.JSONEq(t, `{"id":"o-2","amount":20}`, records[1])
+}
+
+func TestAvroKeySource(t *testing.T) {
+	codec, err := goavro.NewCodec(`{"type":"record","name":"Order","fields":[{"name":"id","type":["null","string"]},{"name":"amount","type":"long"}]}`)
+	require.NoError(t, err)
+	avroContainerCodec = codec
+	defer func() { avroContainerCodec = nil }()
+
+	value, err := codec.TextualFromNative(nil, map[string]interface{}{"id": goavro.Union("string", "o-1"), "amount": 10})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id":{"string":"o-1"},"amount":10}`, string(value))
+
+	key, ok, err := jsonPathString(avroKeySource(value), ".id")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "o-1", key)
+}
+
+func TestReadParquet(t *testing.T) {
+	file, err := os.Open("../../pkg/parquet/testdata/fixed_length_decimal.parquet")
+	require.NoError(t, err)
+	defer file.Close()
+
+	out := make(chan []byte, 100)
+	var rows int
+	readParquet(file, out, &rows)
+
+	var records []string
+	for data := range out {
+		records = append(records, string(data))
+	}
+	require.Equal(t, 24, rows)
+	require.Len(t, records, 24)
+	require.Equal(t, `{"value":"1.00"}`, records[0])
+	require.Equal(t, `{"value":"24.00"}`, records[23])
+}
+
+func TestSplitKeyValue(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		data      string
+		delimiter string
+		key       string
+		value     string
+		ok        bool
+	}{
+		{name: "simple", data: "k1:v1", delimiter: ":", key: "k1", value: "v1", ok: true},
+		{name: "first delimiter", data: "k1:v1:v2", delimiter: ":", key: "k1", value: "v1:v2", ok: true},
+		{name: "escaped delimiter", data: `k\:1:v1`, delimiter: ":", key: "k:1", value: "v1", ok: true},
+		{name: "escaped backslash", data: `k\\:v1`, delimiter: ":", key: `k\`, value: "v1", ok: true},
+		{name: "value is not unescaped", data: `k:v\:1`, delimiter: ":", key: "k", value: `v\:1`, ok: true},
+		{name: "multi byte delimiter", data: "k1::v1", delimiter: "::", key: "k1", value: "v1", ok: true},
+		{name: "empty key", data: ":v1", delimiter: ":", key: "", value: "v1", ok: true},
+		{name: "missing delimiter", data: "v1", delimiter: ":", ok: false},
+		{name: "only escaped delimiter", data: `k\:v1`, delimiter: ":", ok: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			key, value, ok := splitKeyValue([]byte(tc.data), []byte(tc.delimiter))
+			require.Equal(t, tc.ok, ok)
+			if ok {
+				require.Equal(t, tc.key, string(key))
+				require.Equal(t, tc.value, string(value))
+			}
+		})
+	}
+}
+
+func TestReadLengthDelimitedRecord(t *testing.T) {
+	input := bytes.NewReader([]byte{0, 0, 0, 3, 'a', 'b', 'c', 0, 0, 0, 0, 0, 0, 0, 5, 'r'})
+
+	data, err := readLengthDelimitedRecord(input, 4)
+	require.NoError(t, err)
+	require.Equal(t, "abc", string(data))
+
+	data, err = readLengthDelimitedRecord(input, 4)
+	require.NoError(t, err)
+	require.Empty(t, data)
+
+	_, err = readLengthDelimitedRecord(input, 4)
+	require.EqualError(t, err, "record length 5 exceeds --max-message-bytes 4")
+
+	_, err = readLengthDelimitedRecord(bytes.NewReader(nil), 4)
+	require.Equal(t, io.EOF, err)
+
+	_, err = readLengthDelimitedRecord(bytes.NewReader([]byte{0, 0, 0, 2, 'a'}), 4)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	_, err = readLengthDelimitedRecord(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff}), 1024*1024)
+	require.EqualError(t, err, "record length 4294967295 exceeds --max-message-bytes 1048576")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/mattn/go-isatty"
+)
+
+// progress reports how many of total units of work, e.g. partitions, are
+// done on a single line of errWriter that is redrawn on every update. It is
+// silent if errWriter is not a terminal, so redirected output contains no
+// progress lines.
+//
+// Goroutines write their output through writer, so that writes are
+// serialized with each other and with the progress line instead of
+// interleaving.
+type progress struct {
+	mu      sync.Mutex
+	label   string
+	total   int
+	done    int
+	enabled bool
+	drawn   bool
+}
+
+func newProgress(label string, total int) *progress {
+	return &progress{
+		label:   label,
+		total:   total,
+		enabled: isTerminal(errWriter),
+	}
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// increment marks one unit of work as done.
+func (p *progress) increment() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.draw()
+}
+
+// finish removes the progress line. Output written afterwards does not need
+// to go through writer.
+func (p *progress) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	p.enabled = false
+}
+
+// writer returns a writer to w whose writes are serialized with all other
+// writers of p. The progress line is removed while writing and redrawn
+// below the output.
+func (p *progress) writer(w io.Writer) io.Writer {
+	return &progressWriter{p: p, w: w}
+}
+
+func (p *progress) draw() {
+	if !p.enabled {
+		return
+	}
+	fmt.Fprintf(errWriter, "\r\033[K%v %v/%v", p.label, p.done, p.total)
+	p.drawn = true
+}
+
+func (p *progress) clear() {
+	if p.drawn {
+		fmt.Fprint(errWriter, "\r\033[K")
+		p.drawn = false
+	}
+}
+
+type progressWriter struct {
+	p *progress
+	w io.Writer
+}
+
+func (pw *progressWriter) Write(b []byte) (int, error) {
+	pw.p.mu.Lock()
+	defer pw.p.mu.Unlock()
+	pw.p.clear()
+	n, err := pw.w.Write(b)
+	pw.p.draw()
+	return n, err
+}
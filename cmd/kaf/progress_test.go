@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressWriter(t *testing.T) {
+	origErr := errWriter
+	defer func() { errWriter = origErr }()
+	var stderr bytes.Buffer
+	errWriter = &stderr
+
+	p := newProgress("Scanned partitions", 50)
+	var out bytes.Buffer
+	w := p.writer(&out)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fmt.Fprintf(w, "partition %v line 1\npartition %v line 2\n", i, i)
+			p.increment()
+		}(i)
+	}
+	wg.Wait()
+	p.finish()
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 100)
+	for i := 0; i < len(lines); i += 2 {
+		require.Equal(t, strings.Replace(lines[i], "line 1", "line 2", 1), lines[i+1])
+	}
+	// Not a terminal.
+	require.Empty(t, stderr.String())
+}
+
+func TestProgressLine(t *testing.T) {
+	origErr := errWriter
+	defer func() { errWriter = origErr }()
+	var stderr bytes.Buffer
+	errWriter = &stderr
+
+	p := newProgress("Resolving offsets", 2)
+	p.enabled = true
+	p.increment()
+	fmt.Fprint(p.writer(&stderr), "result\n")
+	p.increment()
+	p.finish()
+
+	require.Equal(t, "\r\033[KResolving offsets 1/2\r\033[Kresult\n\r\033[KResolving offsets 1/2\r\033[KResolving offsets 2/2\r\033[K", stderr.String())
+}
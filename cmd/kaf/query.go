@@ -45,14 +45,20 @@ var queryCmd = &cobra.Command{
 			errorExit("Unable to get partitions: %v\n", err)
 		}
 
-		schemaCache = getSchemaCache()
+		schemaCache = getSchemaCache(topic)
+		keySchemaCache = getKeySchemaCache(topic)
+		protoRegistry = getProtoRegistryDecoder(topic + "-value")
+		keyProtoRegistry = getProtoRegistryDecoder(topic + "-key")
 
 		wg := sync.WaitGroup{}
+		p := newProgress("Scanned partitions", len(partitions))
+		out := p.writer(outWriter)
 
 		for _, partition := range partitions {
 			wg.Add(1)
 			go func(partition int32) {
 				defer wg.Done()
+				defer p.increment()
 				highWatermark, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
 				if err != nil {
 					errorExit("Failed to get high watermark: %w", err)
@@ -74,7 +80,7 @@ var queryCmd = &cobra.Command{
 						if protoType != "" {
 							d, err := protoDecode(reg, msg.Value, protoType)
 							if err != nil {
-								fmt.Println("Failed proto decode")
+								fmt.Fprintln(out, "Failed proto decode")
 							}
 							valueTextRaw = string(d)
 						} else {
@@ -84,7 +90,7 @@ var queryCmd = &cobra.Command{
 						if keyProtoType != "" {
 							d, err := protoDecode(reg, msg.Key, keyProtoType)
 							if err != nil {
-								fmt.Println("Failed proto decode")
+								fmt.Fprintln(out, "Failed proto decode")
 							}
 							keyTextRaw = string(d)
 						} else {
@@ -99,8 +105,9 @@ var queryCmd = &cobra.Command{
 						}
 
 						if match {
-							fmt.Printf("Key: %v\n", keyTextRaw)
-							fmt.Printf("Value: %v\n", valueTextRaw)
+							// One write per match, so that matches of
+							// different partitions do not interleave.
+							fmt.Fprintf(out, "Key: %v\nValue: %v\n", keyTextRaw, valueTextRaw)
 						}
 
 						if msg.Offset == pc.HighWaterMarkOffset()-1 {
@@ -112,5 +119,6 @@ var queryCmd = &cobra.Command{
 		}
 
 		wg.Wait()
+		p.finish()
 	},
 }
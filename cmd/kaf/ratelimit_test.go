@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newRateLimiter(2)
+	l.now = func() time.Time { return now }
+	l.last = now
+
+	require.True(t, l.Allow())
+	require.False(t, l.Allow())
+
+	now = now.Add(500 * time.Millisecond)
+	require.True(t, l.Allow())
+	require.False(t, l.Allow())
+
+	// Refill is capped at one second worth of tokens.
+	now = now.Add(time.Minute)
+	require.True(t, l.Allow())
+	require.True(t, l.Allow())
+	require.False(t, l.Allow())
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// relativeOffset is a start offset of --offset relative to the watermarks of
+// each partition: -N is N before the newest offset, +N is N after the oldest.
+type relativeOffset struct {
+	delta      int64
+	fromNewest bool
+}
+
+// parseRelativeOffset parses --offset -N or +N, and returns nil for other
+// values.
+func parseRelativeOffset(s string) (*relativeOffset, error) {
+	if !strings.HasPrefix(s, "-") && !strings.HasPrefix(s, "+") {
+		return nil, nil
+	}
+	delta, err := strconv.ParseUint(s[1:], 10, 63)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a relative offset like -100 or +50", s)
+	}
+	return &relativeOffset{delta: int64(delta), fromNewest: s[0] == '-'}, nil
+}
+
+// resolve returns the absolute offset in the partition with the oldest and
+// newest offset, clamped to that range.
+func (r *relativeOffset) resolve(oldest, newest int64) int64 {
+	switch {
+	case r.delta >= newest-oldest && r.fromNewest:
+		return oldest
+	case r.delta >= newest-oldest:
+		return newest
+	case r.fromNewest:
+		return newest - r.delta
+	}
+	return oldest + r.delta
+}
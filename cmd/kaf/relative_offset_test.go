@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRelativeOffset(t *testing.T) {
+	for _, value := range []string{"oldest", "newest", "42"} {
+		relative, err := parseRelativeOffset(value)
+		require.NoError(t, err)
+		require.Nil(t, relative, value)
+	}
+
+	relative, err := parseRelativeOffset("-100")
+	require.NoError(t, err)
+	require.Equal(t, &relativeOffset{delta: 100, fromNewest: true}, relative)
+	relative, err = parseRelativeOffset("+50")
+	require.NoError(t, err)
+	require.Equal(t, &relativeOffset{delta: 50}, relative)
+
+	for _, value := range []string{"-", "+x", "--5", "-1.5"} {
+		_, err := parseRelativeOffset(value)
+		require.Error(t, err, value)
+	}
+}
+
+func TestRelativeOffsetResolve(t *testing.T) {
+	tests := []struct {
+		offset         string
+		oldest, newest int64
+		expected       int64
+	}{
+		{"-100", 0, 1000, 900},
+		{"-100", 950, 1000, 950},
+		{"-0", 10, 20, 20},
+		{"+50", 100, 1000, 150},
+		{"+50", 100, 120, 120},
+		{"+0", 10, 20, 10},
+		{"+9223372036854775807", 10, 20, 20},
+		{"-9223372036854775807", 10, 20, 10},
+		{"-5", 0, 0, 0},
+	}
+	for _, test := range tests {
+		relative, err := parseRelativeOffset(test.offset)
+		require.NoError(t, err)
+		require.Equal(t, test.expected, relative.resolve(test.oldest, test.newest), "%v of %v..%v", test.offset, test.oldest, test.newest)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/spf13/cobra"
+)
+
+// requeueCountHeader counts how often a record was requeued.
+const requeueCountHeader = "kaf-requeue-count"
+
+var (
+	requeueDelayFlag      time.Duration
+	requeueMaxFlag        int
+	requeueOnlyHeaderFlag string
+	requeueGroupFlag      string
+)
+
+func init() {
+	rootCmd.AddCommand(requeueCmd)
+
+	requeueCmd.Flags().DurationVar(&requeueDelayFlag, "delay", time.Minute, "Time after the timestamp of a record at which it is produced again")
+	requeueCmd.Flags().IntVar(&requeueMaxFlag, "max-requeues", 3, "Skip records that were already requeued this many times, counted in the "+requeueCountHeader+" header")
+	requeueCmd.Flags().StringVar(&requeueOnlyHeaderFlag, "only-header", "", "Only requeue records with this header, given as key or key=value. The header is removed from the requeued record")
+	requeueCmd.Flags().StringVarP(&requeueGroupFlag, "group", "g", "", "Consumer group tracking the requeued records (default kaf-requeue-<topic>)")
+	requeueCmd.Flags().StringVar(&transformFlag, "transform", "", "Lua script defining a function transform(record) applied to every record before it is requeued. It returns the changed record, or nil to drop the record")
+	requeueCmd.Flags().DurationVar(&transformTimeoutFlag, "transform-timeout", 100*time.Millisecond, "Maximum time --transform may run per record")
+}
+
+var requeueCmd = &cobra.Command{
+	Use:   "requeue TOPIC",
+	Short: "Produce records of a topic to the same topic again after a delay",
+	Long: "Consumes new records of a topic and produces them to the same topic again once --delay passed since their timestamp, " +
+		"with the " + requeueCountHeader + " header incremented. This is a simple delayed retry for consumers without a retry topic. " +
+		"Progress is committed with a consumer group, so a restarted requeue continues where it stopped.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: validTopicArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		topic := args[0]
+		if requeueDelayFlag < 0 {
+			errorExit("--delay must not be negative")
+		}
+		if requeueMaxFlag < 1 {
+			errorExit("--max-requeues must be at least 1")
+		}
+		filterKey, filterValue, hasValue := strings.Cut(requeueOnlyHeaderFlag, "=")
+		if cmd.Flags().Changed("only-header") && filterKey == "" {
+			errorExit("--only-header needs a header key")
+		}
+		group := requeueGroupFlag
+		if group == "" {
+			group = "kaf-requeue-" + topic
+		}
+
+		setupTransformer()
+		cfg := getConfig()
+		if !cfg.Version.IsAtLeast(sarama.V0_11_0_0) {
+			errorExit("requeue needs record headers, which require Kafka 0.11 or later")
+		}
+		cfg.Producer.Return.Successes = true
+		cfg.Producer.RequiredAcks = sarama.WaitForAll
+		client := getClientFromConfig(cfg)
+		producer, err := sarama.NewSyncProducerFromClient(client)
+		if err != nil {
+			errorExit("Unable to create producer: %v", err)
+		}
+		cg, err := sarama.NewConsumerGroupFromClient(group, client)
+		if err != nil {
+			errorExit("Failed to create consumer group: %v", err)
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		handler := &requeueHandler{
+			producer: producer,
+			filter:   headerFilter{key: filterKey, value: filterValue, hasValue: hasValue},
+			cancel:   cancel,
+		}
+
+		fmt.Fprintf(errWriter, "Requeueing records of %v after %v with group %v.\n", topic, requeueDelayFlag, group)
+		for ctx.Err() == nil {
+			if err := cg.Consume(ctx, []string{topic}, handler); err != nil {
+				if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+					break
+				}
+				errorExit("Error on consume: %v", err)
+			}
+		}
+		if err := cg.Close(); err != nil {
+			errorExit("Failed to close consumer group: %v", err)
+		}
+		if err := producer.Close(); err != nil {
+			errorExit("Failed to close producer: %v", err)
+		}
+		fmt.Fprintf(errWriter, "Requeued %v records, skipped %v at --max-requeues %v.\n", atomic.LoadInt64(&handler.requeued), atomic.LoadInt64(&handler.exhausted), requeueMaxFlag)
+		if transformer != nil {
+			fmt.Fprintf(errWriter, "Dropped %v records with --transform.\n", atomic.LoadInt64(&handler.dropped))
+		}
+		if handler.err != nil {
+			errorExit("Stopped requeueing, offsets were committed up to the last record requeued: %v", handler.err)
+		}
+	},
+}
+
+// headerFilter matches records with a header key, and value if hasValue.
+type headerFilter struct {
+	key      string
+	value    string
+	hasValue bool
+}
+
+func (f headerFilter) matches(headers []*sarama.RecordHeader) bool {
+	if f.key == "" {
+		return true
+	}
+	for _, h := range headers {
+		if string(h.Key) == f.key && (!f.hasValue || string(h.Value) == f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+type requeueHandler struct {
+	producer sarama.SyncProducer
+	filter   headerFilter
+
+	requeued  int64
+	exhausted int64
+	dropped   int64
+
+	// cancel stops requeueing after a --transform error, err is the first
+	// such error.
+	cancel context.CancelFunc
+	once   sync.Once
+	err    error
+}
+
+func (h *requeueHandler) fail(err error) {
+	h.once.Do(func() {
+		h.err = err
+		h.cancel()
+	})
+}
+
+func (h *requeueHandler) Setup(s sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *requeueHandler) Cleanup(s sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *requeueHandler) ConsumeClaim(s sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		if !h.filter.matches(msg.Headers) {
+			s.MarkMessage(msg, "")
+			continue
+		}
+		count := requeueCount(msg.Headers)
+		if count >= requeueMaxFlag {
+			atomic.AddInt64(&h.exhausted, 1)
+			fmt.Fprintf(errWriter, "Partition %v offset %v was requeued %v times, skipping.\n", msg.Partition, msg.Offset, count)
+			s.MarkMessage(msg, "")
+			continue
+		}
+
+		// Records of a partition are in timestamp order, so waiting for
+		// one delays the following ones at most until they are due.
+		due := msg.Timestamp.Add(requeueDelayFlag)
+		if msg.Timestamp.IsZero() {
+			due = time.Now().Add(requeueDelayFlag)
+		}
+		select {
+		case <-s.Context().Done():
+			return nil
+		case <-time.After(time.Until(due)):
+		}
+
+		record := requeueRecord(msg, h.filter.key, count+1)
+		if transformer != nil {
+			keep, err := transformRequeueRecord(msg, record)
+			if err != nil {
+				// Neither this nor later records of the claim are marked, so
+				// the group resumes at this record.
+				err = fmt.Errorf("transforming record at partition %v offset %v failed: %w", msg.Partition, msg.Offset, err)
+				h.fail(err)
+				return err
+			}
+			if !keep {
+				atomic.AddInt64(&h.dropped, 1)
+				s.MarkMessage(msg, "")
+				continue
+			}
+		}
+		if _, _, err := h.producer.SendMessage(record); err != nil {
+			// The record is not marked, the next session retries it.
+			fmt.Fprintf(errWriter, "Failed to requeue partition %v offset %v: %v\n", msg.Partition, msg.Offset, err)
+			return err
+		}
+		atomic.AddInt64(&h.requeued, 1)
+		s.MarkMessage(msg, "")
+	}
+	return nil
+}
+
+// requeueCount returns the value of the requeue count header, 0 if it is
+// missing or invalid.
+func requeueCount(headers []*sarama.RecordHeader) int {
+	for _, h := range headers {
+		if string(h.Key) == requeueCountHeader {
+			count, err := strconv.Atoi(string(h.Value))
+			if err != nil || count < 0 {
+				return 0
+			}
+			return count
+		}
+	}
+	return 0
+}
+
+// requeueRecord returns msg as a record to produce with the requeue count set
+// to count. Headers with the key of the --only-header filter are removed.
+func requeueRecord(msg *sarama.ConsumerMessage, filterKey string, count int) *sarama.ProducerMessage {
+	record := &sarama.ProducerMessage{
+		Topic: msg.Topic,
+		Value: sarama.ByteEncoder(msg.Value),
+	}
+	if msg.Key != nil {
+		record.Key = sarama.ByteEncoder(msg.Key)
+	}
+	for _, h := range msg.Headers {
+		key := string(h.Key)
+		if key == requeueCountHeader || (filterKey != "" && key == filterKey) {
+			continue
+		}
+		record.Headers = append(record.Headers, *h)
+	}
+	record.Headers = append(record.Headers, sarama.RecordHeader{Key: []byte(requeueCountHeader), Value: []byte(strconv.Itoa(count))})
+	return record
+}
+
+// transformRequeueRecord applies the transform to a record about to be
+// requeued, which has the requeue count header already incremented. The
+// script can change key, value and headers, but not the topic. It returns
+// false if the script dropped the record.
+func transformRequeueRecord(msg *sarama.ConsumerMessage, record *sarama.ProducerMessage) (bool, error) {
+	r := &transformRecord{
+		Topic:     record.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   record.Headers,
+	}
+	keep, err := transformer.apply(r)
+	if err != nil || !keep {
+		return false, err
+	}
+	record.Key = nil
+	if r.Key != nil {
+		record.Key = sarama.ByteEncoder(r.Key)
+	}
+	record.Value = sarama.ByteEncoder(r.Value)
+	record.Headers = r.Headers
+	return true, nil
+}
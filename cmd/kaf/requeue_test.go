@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequeueRecord(t *testing.T) {
+	msg := &sarama.ConsumerMessage{
+		Topic: "orders",
+		Key:   []byte("o-1"),
+		Value: []byte("payload"),
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte("trace"), Value: []byte("abc")},
+			{Key: []byte("retry"), Value: []byte("true")},
+			{Key: []byte(requeueCountHeader), Value: []byte("1")},
+		},
+	}
+	require.Equal(t, 1, requeueCount(msg.Headers))
+
+	filter := headerFilter{key: "retry", value: "true", hasValue: true}
+	require.True(t, filter.matches(msg.Headers))
+	require.False(t, headerFilter{key: "retry", value: "false", hasValue: true}.matches(msg.Headers))
+	require.True(t, headerFilter{key: "trace"}.matches(msg.Headers))
+	require.False(t, headerFilter{key: "missing"}.matches(msg.Headers))
+	require.True(t, headerFilter{}.matches(nil))
+
+	record := requeueRecord(msg, "retry", 2)
+	require.Equal(t, "orders", record.Topic)
+	require.Equal(t, sarama.ByteEncoder("o-1"), record.Key)
+	require.Equal(t, sarama.ByteEncoder("payload"), record.Value)
+	require.Equal(t, []sarama.RecordHeader{
+		{Key: []byte("trace"), Value: []byte("abc")},
+		{Key: []byte(requeueCountHeader), Value: []byte("2")},
+	}, record.Headers)
+	require.Equal(t, 2, requeueCount([]*sarama.RecordHeader{&record.Headers[1]}))
+
+	require.Nil(t, requeueRecord(&sarama.ConsumerMessage{Value: []byte("v")}, "", 1).Key)
+	require.Equal(t, 0, requeueCount([]*sarama.RecordHeader{{Key: []byte(requeueCountHeader), Value: []byte("x")}}))
+}
+
+func TestTransformRequeueRecord(t *testing.T) {
+	transformer = newTestTransformer(t, `
+function transform(record)
+  if record.value == "drop" then
+    return nil
+  end
+  record.header["origin-offset"] = tostring(record.offset)
+  record.header["seen-count"] = record.header["kaf-requeue-count"]
+  return record
+end
+`)
+	defer func() { transformer = nil }()
+
+	msg := &sarama.ConsumerMessage{Topic: "orders", Partition: 1, Offset: 42, Value: []byte("payload")}
+	record := requeueRecord(msg, "", 3)
+	keep, err := transformRequeueRecord(msg, record)
+	require.NoError(t, err)
+	require.True(t, keep)
+	require.Nil(t, record.Key)
+	require.Equal(t, sarama.ByteEncoder("payload"), record.Value)
+	require.Equal(t, []sarama.RecordHeader{
+		{Key: []byte(requeueCountHeader), Value: []byte("3")},
+		{Key: []byte("origin-offset"), Value: []byte("42")},
+		{Key: []byte("seen-count"), Value: []byte("3")},
+	}, record.Headers)
+
+	msg.Value = []byte("drop")
+	keep, err = transformRequeueRecord(msg, requeueRecord(msg, "", 1))
+	require.NoError(t, err)
+	require.False(t, keep)
+}
+
+type requeueSession struct {
+	sarama.ConsumerGroupSession
+	marked []int64
+}
+
+func (s *requeueSession) Context() context.Context { return context.Background() }
+
+func (s *requeueSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+type requeueClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *requeueClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func TestRequeueHandlerTransformError(t *testing.T) {
+	transformer = newTestTransformer(t, `
+function transform(record)
+  if record.value == "bad" then
+    error("bad record")
+  end
+  return nil
+end
+`)
+	defer func() { transformer = nil }()
+	oldMax, oldDelay := requeueMaxFlag, requeueDelayFlag
+	requeueMaxFlag, requeueDelayFlag = 3, 0
+	defer func() { requeueMaxFlag, requeueDelayFlag = oldMax, oldDelay }()
+
+	claim := &requeueClaim{messages: make(chan *sarama.ConsumerMessage, 3)}
+	for offset, value := range []string{"drop", "bad", "later"} {
+		claim.messages <- &sarama.ConsumerMessage{Topic: "orders", Offset: int64(offset), Value: []byte(value), Timestamp: time.Now()}
+	}
+	close(claim.messages)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler := &requeueHandler{cancel: cancel}
+	session := &requeueSession{}
+	err := handler.ConsumeClaim(session, claim)
+	require.ErrorContains(t, err, "transforming record at partition 0 offset 1 failed")
+	require.Equal(t, err, handler.err)
+	require.Error(t, ctx.Err(), "the consume is stopped")
+	require.Equal(t, []int64{0}, session.marked, "the failed record and later ones are not marked")
+	require.EqualValues(t, 1, handler.dropped)
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"math"
+)
+
+// sampled reports whether the message at partition and offset belongs to the
+// sample. The decision only depends on seed, partition and offset, so the
+// same seed selects the same messages regardless of consume order.
+func sampled(fraction float64, seed int64, partition int32, offset int64) bool {
+	if fraction >= 1 {
+		return true
+	}
+	h := mix64(mix64(uint64(seed)^uint64(partition)) ^ uint64(offset))
+	return float64(h) < fraction*math.MaxUint64
+}
+
+// mix64 is the splitmix64 finalizer, spreading consecutive inputs uniformly.
+func mix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/IBM/sarama"
+)
+
+var (
+	compactSnapshotFlag  bool
+	snapshotMaxKeysFlag  int
+	snapshotSpillDirFlag string
+	snapshotToFlag       string
+	snapshot             *compactSnapshot
+	// snapshotOut is the --to file of the snapshot, nil for stdout.
+	snapshotOut *snapshotFile
+)
+
+// snapshotSpillBuckets is the number of files keys are spilled to. Only one
+// bucket is held in memory when the snapshot is written.
+const snapshotSpillBuckets = 64
+
+// spilledMessage is a message written to a spill file. Tombstone is needed as
+// gob does not distinguish nil and empty values.
+type spilledMessage struct {
+	Msg       *sarama.ConsumerMessage
+	Tombstone bool
+}
+
+// compactSnapshot keeps the last message per key, like log compaction. A
+// tombstone removes its key. Keys are held in memory until maxKeys is
+// reached, then all messages are appended to spill files bucketed by key,
+// which are reduced to the last message per key bucket by bucket on flush.
+type compactSnapshot struct {
+	mu       sync.Mutex
+	maxKeys  int
+	spillDir string
+
+	latest map[string]*sarama.ConsumerMessage
+
+	// dir and buckets are set once spilled.
+	dir      string
+	files    []*os.File
+	encoders []*gob.Encoder
+
+	seen, unkeyed, tombstones int64
+}
+
+func newCompactSnapshot(maxKeys int, spillDir string) *compactSnapshot {
+	return &compactSnapshot{
+		maxKeys:  maxKeys,
+		spillDir: spillDir,
+		latest:   make(map[string]*sarama.ConsumerMessage),
+	}
+}
+
+// offer records msg. Messages without key are skipped, compaction does not
+// keep them either.
+func (s *compactSnapshot) offer(msg *sarama.ConsumerMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seen++
+	if msg.Key == nil {
+		s.unkeyed++
+		return nil
+	}
+	if msg.Value == nil {
+		s.tombstones++
+	}
+
+	if s.files == nil {
+		key := string(msg.Key)
+		if msg.Value == nil {
+			delete(s.latest, key)
+			return nil
+		}
+		if _, ok := s.latest[key]; ok || len(s.latest) < s.maxKeys {
+			s.latest[key] = msg
+			return nil
+		}
+		if err := s.spill(); err != nil {
+			return err
+		}
+	}
+	return s.write(msg)
+}
+
+// spill creates the spill files and moves the keys held in memory to them.
+func (s *compactSnapshot) spill() error {
+	dir, err := ioutil.TempDir(s.spillDir, "kaf-snapshot-")
+	if err != nil {
+		return fmt.Errorf("unable to create spill directory: %w", err)
+	}
+	s.dir = dir
+	fmt.Fprintf(errWriter, "Snapshot exceeds %v keys, spilling to %v\n", s.maxKeys, dir)
+	for i := 0; i < snapshotSpillBuckets; i++ {
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("bucket-%02d", i)))
+		if err != nil {
+			return fmt.Errorf("unable to create spill file: %w", err)
+		}
+		s.files = append(s.files, f)
+		s.encoders = append(s.encoders, gob.NewEncoder(f))
+	}
+	for _, msg := range sortedByOffset(s.latest) {
+		if err := s.write(msg); err != nil {
+			return err
+		}
+	}
+	s.latest = nil
+	return nil
+}
+
+func (s *compactSnapshot) write(msg *sarama.ConsumerMessage) error {
+	h := fnv.New32a()
+	_, _ = h.Write(msg.Key)
+	bucket := h.Sum32() % snapshotSpillBuckets
+	if err := s.encoders[bucket].Encode(spilledMessage{Msg: msg, Tombstone: msg.Value == nil}); err != nil {
+		return fmt.Errorf("unable to write spill file: %w", err)
+	}
+	return nil
+}
+
+// flush calls emit for the last message of every key that is not deleted by
+// a tombstone and removes the spill files. Without spilling messages are
+// ordered by partition and offset, otherwise by partition and offset within
+// each bucket. It stops at the first error of emit.
+func (s *compactSnapshot) flush(emit func(*sarama.ConsumerMessage) error) (keys int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.files == nil {
+		for _, msg := range sortedByOffset(s.latest) {
+			if err := emit(msg); err != nil {
+				return keys, err
+			}
+			keys++
+		}
+		return keys, nil
+	}
+
+	defer os.RemoveAll(s.dir)
+	for _, f := range s.files {
+		latest, err := readSpillFile(f)
+		if err != nil {
+			return keys, err
+		}
+		for _, msg := range sortedByOffset(latest) {
+			if err := emit(msg); err != nil {
+				return keys, err
+			}
+			keys++
+		}
+	}
+	return keys, nil
+}
+
+// readSpillFile returns the last message per key of a spill file.
+func readSpillFile(f *os.File) (map[string]*sarama.ConsumerMessage, error) {
+	defer f.Close()
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	latest := make(map[string]*sarama.ConsumerMessage)
+	decoder := gob.NewDecoder(f)
+	for {
+		var m spilledMessage
+		err := decoder.Decode(&m)
+		if err == io.EOF {
+			return latest, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read spill file: %w", err)
+		}
+		if m.Tombstone {
+			delete(latest, string(m.Msg.Key))
+		} else {
+			latest[string(m.Msg.Key)] = m.Msg
+		}
+	}
+}
+
+func sortedByOffset(latest map[string]*sarama.ConsumerMessage) []*sarama.ConsumerMessage {
+	msgs := make([]*sarama.ConsumerMessage, 0, len(latest))
+	for _, msg := range latest {
+		msgs = append(msgs, msg)
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		if msgs[i].Partition != msgs[j].Partition {
+			return msgs[i].Partition < msgs[j].Partition
+		}
+		return msgs[i].Offset < msgs[j].Offset
+	})
+	return msgs
+}
+
+func (s *compactSnapshot) printSummary(keys int64) {
+	fmt.Fprintf(errWriter, "Snapshot of %v keys from %v messages (%v tombstones, %v messages without key skipped)\n", keys, s.seen, s.tombstones, s.unkeyed)
+}
+
+// snapshotFile is the --to file of a snapshot. It is written as a temporary
+// file next to path and renamed to path once complete, so that an interrupted
+// run does not leave a partial snapshot behind.
+type snapshotFile struct {
+	*os.File
+	path string
+}
+
+func createSnapshotFile(path string) (*snapshotFile, error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotFile{File: f, path: path}, nil
+}
+
+// commit replaces path with the complete snapshot.
+func (f *snapshotFile) commit() error {
+	if err := f.Sync(); err != nil {
+		f.abort()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// abort removes the temporary file, leaving path unchanged.
+func (f *snapshotFile) abort() {
+	f.Close()
+	os.Remove(f.Name())
+}
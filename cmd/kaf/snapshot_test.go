@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactSnapshot(t *testing.T) {
+	origErr := errWriter
+	errWriter = ioutil.Discard
+	defer func() { errWriter = origErr }()
+
+	msgs := []*sarama.ConsumerMessage{
+		{Key: []byte("a"), Offset: 0, Value: []byte("a1")},
+		{Key: []byte("b"), Offset: 1, Value: []byte("b1")},
+		{Key: nil, Offset: 2, Value: []byte("unkeyed")},
+		{Key: []byte("a"), Offset: 3, Value: []byte("a2")},
+		{Key: []byte("c"), Offset: 4, Value: []byte("c1")},
+		{Key: []byte("b"), Offset: 5, Value: nil},
+		{Key: []byte("d"), Offset: 6, Value: []byte{}},
+	}
+
+	for _, maxKeys := range []int{100, 1} {
+		dir := t.TempDir()
+		s := newCompactSnapshot(maxKeys, dir)
+		for _, msg := range msgs {
+			require.NoError(t, s.offer(msg))
+		}
+		var values []string
+		keys, err := s.flush(func(msg *sarama.ConsumerMessage) error {
+			values = append(values, string(msg.Key)+"="+string(msg.Value))
+			return nil
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, 3, keys)
+		sort.Strings(values)
+		require.Equal(t, []string{"a=a2", "c=c1", "d="}, values, "max keys %v", maxKeys)
+		require.EqualValues(t, 1, s.tombstones)
+		require.EqualValues(t, 1, s.unkeyed)
+
+		left, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		require.Empty(t, left, "spill files are removed")
+	}
+}
+
+func TestCompactSnapshotFlushError(t *testing.T) {
+	s := newCompactSnapshot(100, t.TempDir())
+	require.NoError(t, s.offer(&sarama.ConsumerMessage{Key: []byte("a"), Value: []byte("a1")}))
+	require.NoError(t, s.offer(&sarama.ConsumerMessage{Key: []byte("b"), Offset: 1, Value: []byte("b1")}))
+
+	keys, err := s.flush(func(msg *sarama.ConsumerMessage) error { return errors.New("disk full") })
+	require.EqualError(t, err, "disk full")
+	require.EqualValues(t, 0, keys)
+}
+
+func TestSnapshotFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "users.jsonl")
+	require.NoError(t, ioutil.WriteFile(path, []byte("previous\n"), 0644))
+
+	f, err := createSnapshotFile(path)
+	require.NoError(t, err)
+	_, err = f.WriteString("partial\n")
+	require.NoError(t, err)
+	f.abort()
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "previous\n", string(b), "an aborted snapshot leaves the file unchanged")
+
+	f, err = createSnapshotFile(path)
+	require.NoError(t, err)
+	_, err = f.WriteString("complete\n")
+	require.NoError(t, err)
+	require.NoError(t, f.commit())
+	b, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "complete\n", string(b))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "no temporary files are left")
+}
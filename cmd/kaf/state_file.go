@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+var (
+	stateFileFlag string
+	// positions tracks the offsets consumed with --state-file.
+	positions *consumeState
+)
+
+// consumeState holds the last consumed offset per partition of a topic, read
+// from and written to --state-file. It replaces a consumer group for reruns
+// of consume, without writes to the brokers.
+type consumeState struct {
+	mu      sync.Mutex
+	path    string
+	topic   string
+	offsets map[int32]int64
+	// resumed is false if the state file did not exist yet.
+	resumed bool
+}
+
+// stateFile is the JSON format of --state-file. Offsets are the last
+// consumed offset per partition, keyed by the partition number.
+type stateFile struct {
+	Topic   string           `json:"topic"`
+	Offsets map[string]int64 `json:"offsets"`
+}
+
+// loadConsumeState reads the state of topic from path. A missing file is an
+// empty state, a state of another topic is an error.
+func loadConsumeState(path, topic string) (*consumeState, error) {
+	state := &consumeState{path: path, topic: topic, offsets: map[int32]int64{}}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file stateFile
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("%v is not a state file: %w", path, err)
+	}
+	if file.Topic != topic {
+		return nil, fmt.Errorf("%v holds offsets of topic %v, not %v", path, file.Topic, topic)
+	}
+	for key, offset := range file.Offsets {
+		partition, err := strconv.ParseInt(key, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%v has invalid partition %q", path, key)
+		}
+		state.offsets[int32(partition)] = offset
+	}
+	state.resumed = true
+	return state, nil
+}
+
+// start returns the offset to resume partition at, given its oldest and
+// newest offset. Partitions missing from a state file, such as partitions
+// added to the topic since, start at the oldest offset. Offsets removed by
+// retention or beyond the end of a recreated topic also fall back to the
+// oldest offset, with a warning on stderr. ok is false if the state file did
+// not exist yet, the partition then starts at --offset.
+func (s *consumeState) start(partition int32, oldest, newest int64, stderr io.Writer) (offset int64, ok bool) {
+	if !s.resumed {
+		return 0, false
+	}
+	s.mu.Lock()
+	last, found := s.offsets[partition]
+	s.mu.Unlock()
+	if !found {
+		return oldest, true
+	}
+	next := last + 1
+	switch {
+	case next < oldest:
+		fmt.Fprintf(stderr, "Warning: offset %v of partition %v in %v was deleted, resuming at the oldest offset %v\n", next, partition, s.path, oldest)
+		return oldest, true
+	case next > newest:
+		fmt.Fprintf(stderr, "Warning: offset %v of partition %v in %v is beyond the newest offset %v, the topic may have been recreated. Resuming at the oldest offset %v\n", next, partition, s.path, newest, oldest)
+		return oldest, true
+	}
+	return next, true
+}
+
+// consumed records offset of partition as consumed.
+func (s *consumeState) consumed(partition int32, offset int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offsets[partition] = offset
+}
+
+// save writes the state to a temporary file next to the state file and
+// renames it, so an interrupted save leaves the previous state intact.
+// Partitions not consumed in this run keep their offsets.
+func (s *consumeState) save() error {
+	s.mu.Lock()
+	file := stateFile{Topic: s.topic, Offsets: make(map[string]int64, len(s.offsets))}
+	for partition, offset := range s.offsets {
+		file.Offsets[strconv.Itoa(int(partition))] = offset
+	}
+	s.mu.Unlock()
+
+	b, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.path), "."+filepath.Base(s.path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
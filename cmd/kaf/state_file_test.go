@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumeState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pos.json")
+	var stderr bytes.Buffer
+
+	state, err := loadConsumeState(path, "orders")
+	require.NoError(t, err)
+	_, ok := state.start(0, 0, 10, &stderr)
+	require.False(t, ok, "first run starts at --offset")
+
+	state.consumed(0, 4)
+	state.consumed(1, 9)
+	state.consumed(2, 3)
+	require.NoError(t, state.save())
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), ".pos.json.tmp-*"))
+	require.NoError(t, err)
+	require.Empty(t, matches)
+
+	state, err = loadConsumeState(path, "orders")
+	require.NoError(t, err)
+	offset, ok := state.start(0, 0, 10, &stderr)
+	require.True(t, ok)
+	require.EqualValues(t, 5, offset)
+	offset, _ = state.start(3, 2, 10, &stderr)
+	require.EqualValues(t, 2, offset, "new partitions start at the oldest offset")
+	require.Empty(t, stderr.String())
+
+	offset, _ = state.start(1, 12, 20, &stderr)
+	require.EqualValues(t, 12, offset)
+	require.Contains(t, stderr.String(), "offset 10 of partition 1")
+	stderr.Reset()
+	offset, _ = state.start(2, 0, 2, &stderr)
+	require.EqualValues(t, 0, offset)
+	require.Contains(t, stderr.String(), "recreated")
+
+	// Partitions not consumed keep their offsets.
+	state.consumed(0, 7)
+	require.NoError(t, state.save())
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"topic": "orders", "offsets": {"0": 7, "1": 9, "2": 3}}`, string(b))
+
+	_, err = loadConsumeState(path, "payments")
+	require.ErrorContains(t, err, "holds offsets of topic orders")
+	require.NoError(t, ioutil.WriteFile(path, []byte("{"), 0o644))
+	_, err = loadConsumeState(path, "orders")
+	require.ErrorContains(t, err, "is not a state file")
+}
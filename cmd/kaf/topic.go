@@ -3,13 +3,18 @@ package main
 import (
 	"fmt"
 	"sort"
+	"strconv"
 	"text/tabwriter"
 
 	"strings"
+	"sync"
+	"time"
 
 	"encoding/json"
+	"path"
 
 	"github.com/IBM/sarama"
+	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 )
 
@@ -19,6 +24,15 @@ var (
 	replicasFlag             int16
 	noHeaderFlag             bool
 	compactFlag              bool
+	replicaAssignmentFlag    string
+	nonDefaultOnlyFlag       bool
+	humanFlag                bool
+	topicConsumersFlag       bool
+	deleteYesFlag            bool
+	deleteForceFlag          bool
+	createWaitFlag           bool
+	createWaitTimeoutFlag    time.Duration
+	lsDetailedFlag           bool
 )
 
 func init() {
@@ -30,14 +44,28 @@ func init() {
 	topicCmd.AddCommand(describeTopicCmd)
 	topicCmd.AddCommand(addConfigCmd)
 	topicCmd.AddCommand(topicSetConfig)
+	topicSetConfig.Flags().StringVar(&configOperationFlag, "operation", "set", "How to change the configs: set, delete (reset to default), append or subtract (list configs)")
 	topicCmd.AddCommand(updateTopicCmd)
 	topicCmd.AddCommand(lagCmd)
 
 	createTopicCmd.Flags().Int32VarP(&partitionsFlag, "partitions", "p", int32(1), "Number of partitions")
 	createTopicCmd.Flags().Int16VarP(&replicasFlag, "replicas", "r", int16(1), "Number of replicas")
 	createTopicCmd.Flags().BoolVar(&compactFlag, "compact", false, "Enable topic compaction")
+	createTopicCmd.Flags().StringVar(&replicaAssignmentFlag, "replica-assignment", "", "Explicit replica assignment, mapping partitions to broker IDs. Overrides --partitions and --replicas. Example: '0:1,2;1:2,3'")
+	createTopicCmd.Flags().BoolVar(&createWaitFlag, "wait", false, "Wait until every partition of the topic has a leader")
+	createTopicCmd.Flags().DurationVar(&createWaitTimeoutFlag, "wait-timeout", 30*time.Second, "Maximum time to wait with --wait")
+
+	describeTopicCmd.Flags().BoolVar(&nonDefaultOnlyFlag, "non-default-only", false, "Only show configs explicitly set on the topic")
+	describeTopicCmd.Flags().BoolVar(&humanFlag, "human", false, "Print durations and sizes of configs in human readable form next to the raw value")
+	describeTopicCmd.Flags().BoolVar(&topicConsumersFlag, "consumers", false, "List the consumer groups with committed offsets or assigned members on the topic, with their state and total lag")
+	describeTopicCmd.Flags().BoolVar(&topicWatchFlag, "watch", false, "Refresh leader, replicas and ISR of the partitions every --interval until interrupted, marking partitions that changed. Without a terminal, a timestamped snapshot is printed on every change")
+	describeTopicCmd.Flags().DurationVar(&topicWatchIntervalFlag, "interval", 2*time.Second, "Refresh interval of --watch")
+
+	deleteTopicCmd.Flags().BoolVarP(&deleteYesFlag, "yes", "y", false, "Delete topics matched by patterns or read by consumer groups without asking for confirmation")
+	deleteTopicCmd.Flags().BoolVar(&deleteForceFlag, "force", false, "Do not check for consumer groups reading the topics")
 
 	lsTopicsCmd.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers")
+	lsTopicsCmd.Flags().BoolVar(&lsDetailedFlag, "detailed", false, "Also show the number of messages and of consumer groups per topic. This fetches the offsets of every partition and group")
 	topicsCmd.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers")
 	updateTopicCmd.Flags().Int32VarP(&partitionsFlag, "partitions", "p", int32(-1), "Number of partitions")
 	updateTopicCmd.Flags().StringVar(&partitionAssignmentsFlag, "partition-assignments", "", "Partition Assignments. Optional. If set in combination with -p, an assignment must be provided for each new partition. Example: '[[1,2,3],[1,2,3]]' (JSON Array syntax) assigns two new partitions to brokers 1,2,3. If used by itself, a reassignment must be provided for all partitions.")
@@ -55,38 +83,26 @@ var topicsCmd = &cobra.Command{
 }
 
 var topicSetConfig = &cobra.Command{
-	Use:     "set-config",
-	Short:   "set topic config. requires Kafka >=2.3.0 on broker side and kaf cluster config.",
-	Example: "kaf topic set-config topic.name \"cleanup.policy=delete\"",
-	Args:    cobra.ExactArgs(2),
+	Use:   "set-config",
+	Short: "set topic config. Only the given configs are changed on Kafka >=2.3.0, older clusters reset all other dynamic configs of the topic.",
+	Example: "kaf topic set-config topic.name \"cleanup.policy=delete\"\n" +
+		"kaf topic set-config topic.name --operation delete \"retention.ms,retention.bytes\"",
+	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		admin := getClusterAdmin()
 
 		topic := args[0]
 
-		splt := strings.Split(args[1], ",")
-		configs := make(map[string]sarama.IncrementalAlterConfigsEntry)
-
-		for _, kv := range splt {
-			s := strings.Split(kv, "=")
-
-			if len(s) != 2 {
-				continue
-			}
-
-			key := s[0]
-			value := s[1]
-			configs[key] = sarama.IncrementalAlterConfigsEntry{
-				Operation: sarama.IncrementalAlterConfigsOperationSet,
-				Value:     &value,
-			}
+		operation, err := parseConfigOperation(configOperationFlag)
+		if err != nil {
+			errorExit("%v", err)
 		}
-
-		if len(configs) < 1 {
-			errorExit("No valid configs found")
+		configs, err := parseConfigArgs(splitConfigList(args[1], operation), operation)
+		if err != nil {
+			errorExit("No valid configs found: %v", err)
 		}
 
-		err := admin.IncrementalAlterConfig(sarama.TopicResource, topic, configs, false)
+		err = alterConfigs(admin, getIncrementalAlterConfigs(), sarama.TopicResource, topic, configs, false)
 		if err != nil {
 			errorExit("Unable to alter topic config: %v\n", err)
 		}
@@ -161,6 +177,29 @@ var lsTopicsCmd = &cobra.Command{
 
 		w := tabwriter.NewWriter(outWriter, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
 
+		if lsDetailedFlag {
+			groupCounts, err := topicGroupCounts(admin)
+			if err != nil {
+				errorExit("Unable to fetch consumer groups: %v\n", err)
+			}
+			client := getClient()
+			defer client.Close()
+			messageCounts := topicMessageCounts(client, topics)
+
+			if !noHeaderFlag {
+				fmt.Fprintf(w, "NAME\tPARTITIONS\tREPLICAS\tMESSAGES\tGROUPS\t\n")
+			}
+			for _, topic := range sortedTopics {
+				messages := "?"
+				if count, ok := messageCounts[topic.name]; ok {
+					messages = strconv.FormatInt(count, 10)
+				}
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t\n", topic.name, topic.NumPartitions, topic.ReplicationFactor, messages, groupCounts[topic.name])
+			}
+			w.Flush()
+			return
+		}
+
 		if !noHeaderFlag {
 			fmt.Fprintf(w, "NAME\tPARTITIONS\tREPLICAS\t\n")
 		}
@@ -172,6 +211,121 @@ var lsTopicsCmd = &cobra.Command{
 	},
 }
 
+// topicLookupConcurrency limits the concurrent per topic and per group
+// requests of topic ls --detailed.
+const topicLookupConcurrency = 8
+
+// forEachConcurrently calls f for every item with at most
+// topicLookupConcurrency calls at a time.
+func forEachConcurrently(items []string, f func(string)) {
+	sem := make(chan struct{}, topicLookupConcurrency)
+	var wg sync.WaitGroup
+	for _, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(item string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			f(item)
+		}(item)
+	}
+	wg.Wait()
+}
+
+// topicMessageCounts returns the sum of high watermark minus oldest offset
+// over the partitions of each topic. Topics whose offsets cannot be fetched
+// are missing, with a warning.
+func topicMessageCounts(client sarama.Client, topics map[string]sarama.TopicDetail) map[string]int64 {
+	names := make([]string, 0, len(topics))
+	for name := range topics {
+		names = append(names, name)
+	}
+
+	var mu sync.Mutex
+	counts := make(map[string]int64, len(topics))
+	forEachConcurrently(names, func(topic string) {
+		var count int64
+		for partition := int32(0); partition < topics[topic].NumPartitions; partition++ {
+			o, err := getOffsets(client, topic, partition)
+			if err != nil {
+				fmt.Fprintf(errWriter, "Unable to fetch offsets of %v partition %v: %v\n", topic, partition, err)
+				return
+			}
+			count += o.newest - o.oldest
+		}
+		mu.Lock()
+		counts[topic] = count
+		mu.Unlock()
+	})
+	return counts
+}
+
+// topicGroupCounts returns the number of consumer groups per topic that have
+// committed offsets on it or members assigned to it.
+func topicGroupCounts(admin sarama.ClusterAdmin) (map[string]int, error) {
+	groupList, err := admin.ListConsumerGroups()
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]string, 0, len(groupList))
+	for group := range groupList {
+		groups = append(groups, group)
+	}
+	counts := make(map[string]int)
+	if len(groups) == 0 {
+		return counts, nil
+	}
+
+	descriptions, err := admin.DescribeConsumerGroups(groups)
+	if err != nil {
+		return nil, err
+	}
+	assigned := make(map[string]map[string]struct{}, len(descriptions))
+	for _, description := range descriptions {
+		topics := make(map[string]struct{})
+		for _, member := range description.Members {
+			assignment, err := member.GetMemberAssignment()
+			if err != nil || assignment == nil {
+				continue
+			}
+			for topic := range assignment.Topics {
+				topics[topic] = struct{}{}
+			}
+		}
+		assigned[description.GroupId] = topics
+	}
+
+	var mu sync.Mutex
+	var firstErr error
+	forEachConcurrently(groups, func(group string) {
+		offsets, err := admin.ListConsumerGroupOffsets(group, nil)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+		topics := assigned[group]
+		if topics == nil {
+			topics = make(map[string]struct{})
+		}
+		for topic, blocks := range offsets.Blocks {
+			for _, block := range blocks {
+				if block.Offset >= 0 {
+					topics[topic] = struct{}{}
+					break
+				}
+			}
+		}
+		for topic := range topics {
+			counts[topic]++
+		}
+	})
+	return counts, firstErr
+}
+
 var describeTopicCmd = &cobra.Command{
 	Use:               "describe",
 	Short:             "Describe topic",
@@ -179,6 +333,14 @@ var describeTopicCmd = &cobra.Command{
 	Args:              cobra.ExactArgs(1),
 	ValidArgsFunction: validTopicArgs,
 	Run: func(cmd *cobra.Command, args []string) {
+		if topicWatchFlag {
+			if topicWatchIntervalFlag <= 0 {
+				errorExit("--interval must be positive")
+			}
+			if nonDefaultOnlyFlag || humanFlag || topicConsumersFlag {
+				errorExit("--watch only shows the partitions, it cannot be combined with --non-default-only, --human or --consumers")
+			}
+		}
 		admin := getClusterAdmin()
 
 		topicDetails, err := admin.DescribeTopics([]string{args[0]})
@@ -191,7 +353,14 @@ var describeTopicCmd = &cobra.Command{
 			return
 		}
 
-		cfg, err := admin.DescribeConfig(sarama.ConfigResource{
+		if topicWatchFlag {
+			watchTopic(cmd.Context(), admin, args[0], topicWatchIntervalFlag)
+			return
+		}
+
+		client := getClient()
+		defer client.Close()
+		cfg, err := describeConfigWithSynonyms(client, sarama.ConfigResource{
 			Type: sarama.TopicResource,
 			Name: args[0],
 		})
@@ -222,15 +391,23 @@ var describeTopicCmd = &cobra.Command{
 		w.Flush()
 		w.Init(outWriter, tabwriterMinWidthNested, 4, 2, tabwriterPadChar, tabwriterFlags)
 
-		fmt.Fprintf(w, "\tPartition\tHigh Watermark\tLeader\tReplicas\tISR\t\n")
-		fmt.Fprintf(w, "\t---------\t--------------\t------\t--------\t---\t\n")
-
 		partitions := make([]int32, 0, len(detail.Partitions))
 		for _, partition := range detail.Partitions {
 			partitions = append(partitions, partition.ID)
 		}
 		highWatermarks := getHighWatermarks(args[0], partitions)
 		highWatermarksSum := 0
+		// A last stable offset behind the high watermark means there are open
+		// transactions, a large gap usually means a hung producer.
+		lastStableOffsets := getLastStableOffsets(args[0], partitions)
+
+		if lastStableOffsets != nil {
+			fmt.Fprintf(w, "\tPartition\tHigh Watermark\tLast Stable Offset\tLeader\tReplicas\tISR\t\n")
+			fmt.Fprintf(w, "\t---------\t--------------\t------------------\t------\t--------\t---\t\n")
+		} else {
+			fmt.Fprintf(w, "\tPartition\tHigh Watermark\tLeader\tReplicas\tISR\t\n")
+			fmt.Fprintf(w, "\t---------\t--------------\t------\t--------\t---\t\n")
+		}
 
 		for _, partition := range detail.Partitions {
 			sortedReplicas := partition.Replicas
@@ -241,7 +418,11 @@ var describeTopicCmd = &cobra.Command{
 
 			highWatermarksSum += int(highWatermarks[partition.ID])
 
-			fmt.Fprintf(w, "\t%v\t%v\t%v\t%v\t%v\t\n", partition.ID, highWatermarks[partition.ID], partition.Leader, sortedReplicas, sortedISR)
+			if lastStableOffsets != nil {
+				fmt.Fprintf(w, "\t%v\t%v\t%v\t%v\t%v\t%v\t\n", partition.ID, highWatermarks[partition.ID], lastStableOffsets[partition.ID], partition.Leader, sortedReplicas, sortedISR)
+			} else {
+				fmt.Fprintf(w, "\t%v\t%v\t%v\t%v\t%v\t\n", partition.ID, highWatermarks[partition.ID], partition.Leader, sortedReplicas, sortedISR)
+			}
 		}
 
 		w.Flush()
@@ -250,20 +431,136 @@ var describeTopicCmd = &cobra.Command{
 		w.Flush()
 
 		fmt.Fprintf(w, "Config:\n")
-		fmt.Fprintf(w, "\tName\tValue\tReadOnly\tSensitive\t\n")
-		fmt.Fprintf(w, "\t----\t-----\t--------\t---------\t\n")
+		fmt.Fprintf(w, "\tName\tValue\tSource\tDefault\tReadOnly\tSensitive\t\n")
+		fmt.Fprintf(w, "\t----\t-----\t------\t-------\t--------\t---------\t\n")
 
 		for _, entry := range cfg {
 			if entry.Default {
 				continue
 			}
-			fmt.Fprintf(w, "\t%v\t%v\t%v\t%v\t\n", entry.Name, entry.Value, entry.ReadOnly, entry.Sensitive)
+			if nonDefaultOnlyFlag && entry.Source != sarama.SourceTopic {
+				continue
+			}
+			value, inherited := entry.Value, inheritedValue(entry)
+			if humanFlag {
+				value = humanizeConfigValue(entry.Name, value)
+				inherited = humanizeConfigValue(entry.Name, inherited)
+			}
+			fmt.Fprintf(w, "\t%v\t%v\t%v\t%v\t%v\t%v\t\n", entry.Name, value, entry.Source, inherited, entry.ReadOnly, entry.Sensitive)
 		}
 
 		w.Flush()
+
+		if topicConsumersFlag {
+			consumers, err := getTopicConsumers(admin, detail.Name, highWatermarks)
+			if err != nil {
+				errorExit("Unable to get consumers: %v\n", err)
+			}
+			fmt.Fprintf(w, "Consumers:\n")
+			if len(consumers) == 0 {
+				fmt.Fprintf(w, "\tNo consumer group has committed offsets on the topic.\n")
+			} else {
+				fmt.Fprintf(w, "\tGroup\tState\tLag\t\n")
+				fmt.Fprintf(w, "\t-----\t-----\t---\t\n")
+				for _, c := range consumers {
+					fmt.Fprintf(w, "\t%v\t%v\t%v\t\n", c.Group, c.State, c.Lag)
+				}
+			}
+			w.Flush()
+		}
 	},
 }
 
+// topicConsumer is a consumer group reading a topic.
+type topicConsumer struct {
+	Group string
+	State string
+	// Lag is the sum of the lag of the partitions with committed offsets.
+	Lag int64
+}
+
+// getTopicConsumers returns the consumer groups that have committed offsets
+// on topic or members assigned to it, sorted by group. highWatermarks holds
+// the high watermark of every partition of the topic.
+func getTopicConsumers(admin sarama.ClusterAdmin, topic string, highWatermarks map[int32]int64) ([]topicConsumer, error) {
+	consumers, err := getTopicsConsumers(admin, map[string]map[int32]int64{topic: highWatermarks})
+	if err != nil {
+		return nil, err
+	}
+	return consumers[topic], nil
+}
+
+// getTopicsConsumers is getTopicConsumers for several topics, keyed by topic.
+// The groups are listed and described once, and the offsets of each group are
+// fetched for all topics in one request.
+func getTopicsConsumers(admin sarama.ClusterAdmin, highWatermarks map[string]map[int32]int64) (map[string][]topicConsumer, error) {
+	groupList, err := admin.ListConsumerGroups()
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]string, 0, len(groupList))
+	for group := range groupList {
+		groups = append(groups, group)
+	}
+	if len(groups) == 0 {
+		return nil, nil
+	}
+	sort.Strings(groups)
+
+	descriptions, err := admin.DescribeConsumerGroups(groups)
+	if err != nil {
+		return nil, err
+	}
+
+	topicPartitions := make(map[string][]int32, len(highWatermarks))
+	for topic, watermarks := range highWatermarks {
+		partitions := make([]int32, 0, len(watermarks))
+		for partition := range watermarks {
+			partitions = append(partitions, partition)
+		}
+		topicPartitions[topic] = partitions
+	}
+
+	consumers := make(map[string][]topicConsumer)
+	for _, description := range descriptions {
+		assigned := make(map[string]bool)
+		for _, member := range description.Members {
+			assignment, err := member.GetMemberAssignment()
+			if err != nil || assignment == nil {
+				continue
+			}
+			for topic := range assignment.Topics {
+				assigned[topic] = true
+			}
+		}
+
+		offsets, err := admin.ListConsumerGroupOffsets(description.GroupId, topicPartitions)
+		if err != nil {
+			return nil, err
+		}
+		for topic := range highWatermarks {
+			committed := false
+			var lag int64
+			for partition, block := range offsets.Blocks[topic] {
+				if block.Offset < 0 {
+					continue
+				}
+				committed = true
+				if partitionLag := highWatermarks[topic][partition] - block.Offset; partitionLag > 0 {
+					lag += partitionLag
+				}
+			}
+			if committed || assigned[topic] {
+				consumers[topic] = append(consumers[topic], topicConsumer{Group: description.GroupId, State: description.State, Lag: lag})
+			}
+		}
+	}
+	for _, c := range consumers {
+		sort.Slice(c, func(i, j int) bool { return c[i].Group < c[j].Group })
+	}
+	return consumers, nil
+}
+
 var createTopicCmd = &cobra.Command{
 	Use:   "create TOPIC",
 	Short: "Create a topic",
@@ -276,27 +573,329 @@ var createTopicCmd = &cobra.Command{
 		if compactFlag {
 			compact = "compact"
 		}
-		err := admin.CreateTopic(topicName, &sarama.TopicDetail{
+		detail := &sarama.TopicDetail{
 			NumPartitions:     partitionsFlag,
 			ReplicationFactor: replicasFlag,
 			ConfigEntries: map[string]*string{
 				"cleanup.policy": &compact,
 			},
-		}, false)
+		}
+
+		if replicaAssignmentFlag != "" {
+			assignment, err := parseReplicaAssignment(replicaAssignmentFlag)
+			if err != nil {
+				errorExit("Invalid replica assignment: %v", err)
+			}
+
+			brokers, _, err := admin.DescribeCluster()
+			if err != nil {
+				errorExit("Unable to describe cluster: %v\n", err)
+			}
+			brokerIDs := make(map[int32]struct{}, len(brokers))
+			for _, broker := range brokers {
+				brokerIDs[broker.ID()] = struct{}{}
+			}
+			for partition, replicas := range assignment {
+				for _, replica := range replicas {
+					if _, ok := brokerIDs[replica]; !ok {
+						errorExit("Invalid replica assignment: broker %v of partition %v does not exist", replica, partition)
+					}
+				}
+			}
+
+			// Partition count and replication factor are implied by the
+			// assignment and must be unset in the request.
+			detail.NumPartitions = -1
+			detail.ReplicationFactor = -1
+			detail.ReplicaAssignment = assignment
+		}
+
+		err := admin.CreateTopic(topicName, detail, false)
 		if err != nil {
 			errorExit("Could not create topic %v: %v\n", topicName, err.Error())
 		} else {
 			w := tabwriter.NewWriter(outWriter, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
 			fmt.Fprintf(w, "\xE2\x9C\x85 Created topic!\n")
 			fmt.Fprintln(w, "\tTopic Name:\t", topicName)
-			fmt.Fprintln(w, "\tPartitions:\t", partitionsFlag)
-			fmt.Fprintln(w, "\tReplication Factor:\t", replicasFlag)
+			if detail.ReplicaAssignment != nil {
+				fmt.Fprintln(w, "\tPartitions:\t", len(detail.ReplicaAssignment))
+				fmt.Fprintln(w, "\tReplica Assignment:\t", replicaAssignmentFlag)
+			} else {
+				fmt.Fprintln(w, "\tPartitions:\t", partitionsFlag)
+				fmt.Fprintln(w, "\tReplication Factor:\t", replicasFlag)
+			}
 			fmt.Fprintln(w, "\tCleanup Policy:\t", compact)
 			w.Flush()
 		}
+
+		if createWaitFlag {
+			partitions := int(partitionsFlag)
+			if detail.ReplicaAssignment != nil {
+				partitions = len(detail.ReplicaAssignment)
+			}
+			took, err := waitForTopic(admin, topicName, partitions, createWaitTimeoutFlag)
+			if err != nil {
+				errorExit("%v", err)
+			}
+			fmt.Fprintf(outWriter, "\xE2\x9C\x85 Topic is available after %v.\n", took.Round(time.Millisecond))
+		}
 	},
 }
 
+// topicWaitInterval is the time between metadata requests of waitForTopic.
+var topicWaitInterval = 100 * time.Millisecond
+
+// waitForTopic polls the metadata of a topic until it has the given number of
+// partitions and each of them has a leader. It returns the time it took.
+func waitForTopic(admin sarama.ClusterAdmin, topic string, partitions int, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	var reason string
+	for {
+		reason = topicNotReadyReason(admin, topic, partitions)
+		if reason == "" {
+			return time.Since(start), nil
+		}
+		if time.Since(start)+topicWaitInterval > timeout {
+			return 0, fmt.Errorf("topic %v is not available after %v: %v", topic, timeout, reason)
+		}
+		time.Sleep(topicWaitInterval)
+	}
+}
+
+// topicNotReadyReason returns why a topic is not ready, or an empty string if
+// all partitions have a leader.
+func topicNotReadyReason(admin sarama.ClusterAdmin, topic string, partitions int) string {
+	metadata, err := admin.DescribeTopics([]string{topic})
+	if err != nil {
+		return err.Error()
+	}
+	if len(metadata) == 0 {
+		return "no metadata returned"
+	}
+	if metadata[0].Err != sarama.ErrNoError {
+		return metadata[0].Err.Error()
+	}
+	if len(metadata[0].Partitions) < partitions {
+		return fmt.Sprintf("%v of %v partitions known", len(metadata[0].Partitions), partitions)
+	}
+	var leaderless int
+	for _, p := range metadata[0].Partitions {
+		if p.Err != sarama.ErrNoError || p.Leader < 0 {
+			leaderless++
+		}
+	}
+	if leaderless > 0 {
+		return fmt.Sprintf("%v partitions without leader", leaderless)
+	}
+	return ""
+}
+
+// describeConfigWithSynonyms describes the config of a resource including the
+// synonyms of each entry, which tell where an overridden value would otherwise
+// be inherited from. The cluster admin does not request synonyms.
+func describeConfigWithSynonyms(client sarama.Client, resource sarama.ConfigResource) ([]*sarama.ConfigEntry, error) {
+	request := &sarama.DescribeConfigsRequest{
+		Resources:       []*sarama.ConfigResource{&resource},
+		IncludeSynonyms: true,
+	}
+	if client.Config().Version.IsAtLeast(sarama.V1_1_0_0) {
+		request.Version = 1
+	}
+	if client.Config().Version.IsAtLeast(sarama.V2_0_0_0) {
+		request.Version = 2
+	}
+
+	var broker *sarama.Broker
+	var err error
+	if resource.Type == sarama.BrokerResource {
+		var id int64
+		id, err = strconv.ParseInt(resource.Name, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		broker, err = client.Broker(int32(id))
+	} else {
+		broker, err = client.Controller()
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Brokers of the client are usually connected already.
+	if err := broker.Open(client.Config()); err != nil && err != sarama.ErrAlreadyConnected {
+		return nil, err
+	}
+
+	resp, err := broker.DescribeConfigs(request)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range resp.Resources {
+		if r.Name != resource.Name {
+			continue
+		}
+		if r.ErrorCode != 0 {
+			return nil, &sarama.DescribeConfigError{Err: sarama.KError(r.ErrorCode), ErrMsg: r.ErrorMsg}
+		}
+		return r.Configs, nil
+	}
+	return nil, nil
+}
+
+// inheritedValue returns the value a config entry would have if its own
+// override was removed, or an empty string if unknown.
+func inheritedValue(entry *sarama.ConfigEntry) string {
+	for _, synonym := range entry.Synonyms {
+		if synonym.Source != entry.Source {
+			return synonym.ConfigValue
+		}
+	}
+	return ""
+}
+
+// Configs holding durations in milliseconds and sizes in bytes, which are
+// rendered in human readable form with --human.
+var (
+	durationConfigs = map[string]bool{
+		"retention.ms":                        true,
+		"segment.ms":                          true,
+		"delete.retention.ms":                 true,
+		"min.compaction.lag.ms":               true,
+		"max.compaction.lag.ms":               true,
+		"local.retention.ms":                  true,
+		"file.delete.delay.ms":                true,
+		"message.timestamp.difference.max.ms": true,
+	}
+	sizeConfigs = map[string]bool{
+		"retention.bytes":       true,
+		"segment.bytes":         true,
+		"max.message.bytes":     true,
+		"local.retention.bytes": true,
+		"segment.index.bytes":   true,
+	}
+)
+
+// humanizeConfigValue appends a human readable form to duration and size
+// configs, e.g. "604800000 ms (7d)". Other values are returned unchanged.
+func humanizeConfigValue(name, value string) string {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return value
+	}
+
+	switch {
+	case durationConfigs[name]:
+		if n < 0 {
+			return fmt.Sprintf("%v (unlimited)", value)
+		}
+		return fmt.Sprintf("%v ms (%v)", value, humanDuration(time.Duration(n)*time.Millisecond))
+	case sizeConfigs[name]:
+		if n < 0 {
+			return fmt.Sprintf("%v (unlimited)", value)
+		}
+		return fmt.Sprintf("%v (%v)", value, humanBytes(n))
+	default:
+		return value
+	}
+}
+
+// humanDuration formats a duration using days, hours, minutes and seconds,
+// omitting zero units, e.g. "7d" or "1d12h".
+func humanDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.String()
+	}
+
+	units := []struct {
+		suffix string
+		size   time.Duration
+	}{
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+	}
+
+	var b strings.Builder
+	for _, unit := range units {
+		if d >= unit.size {
+			fmt.Fprintf(&b, "%d%v", d/unit.size, unit.suffix)
+			d %= unit.size
+		}
+	}
+	return b.String()
+}
+
+// humanBytes formats a size using binary units, e.g. "1 GiB" or "1.5 MiB".
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return strings.Replace(fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp]), ".0 ", " ", 1)
+}
+
+// parseReplicaAssignment parses an assignment in the format
+// "partition:broker,broker;partition:broker,broker". Partitions must be
+// numbered consecutively starting at 0 and have the same number of replicas.
+func parseReplicaAssignment(s string) (map[int32][]int32, error) {
+	assignment := make(map[int32][]int32)
+	replicaCount := -1
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("entry %q is not in the format partition:brokers", entry)
+		}
+
+		partition, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 32)
+		if err != nil || partition < 0 {
+			return nil, fmt.Errorf("invalid partition %q", parts[0])
+		}
+		if _, ok := assignment[int32(partition)]; ok {
+			return nil, fmt.Errorf("partition %v is assigned more than once", partition)
+		}
+
+		var replicas []int32
+		for _, b := range strings.Split(parts[1], ",") {
+			broker, err := strconv.ParseInt(strings.TrimSpace(b), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid broker ID %q for partition %v", b, partition)
+			}
+			if containsPartition(replicas, int32(broker)) {
+				return nil, fmt.Errorf("broker %v is listed more than once for partition %v", broker, partition)
+			}
+			replicas = append(replicas, int32(broker))
+		}
+
+		if replicaCount != -1 && len(replicas) != replicaCount {
+			return nil, fmt.Errorf("partition %v has %v replicas, expected %v", partition, len(replicas), replicaCount)
+		}
+		replicaCount = len(replicas)
+		assignment[int32(partition)] = replicas
+	}
+
+	if len(assignment) == 0 {
+		return nil, fmt.Errorf("no partitions given")
+	}
+	for i := 0; i < len(assignment); i++ {
+		if _, ok := assignment[int32(i)]; !ok {
+			return nil, fmt.Errorf("partition %v is missing", i)
+		}
+	}
+
+	return assignment, nil
+}
+
 var addConfigCmd = &cobra.Command{
 	Use:   "add-config TOPIC KEY VALUE",
 	Short: "Add config key/value pair to topic",
@@ -308,8 +907,8 @@ var addConfigCmd = &cobra.Command{
 		key := args[1]
 		value := args[2]
 
-		err := admin.AlterConfig(sarama.TopicResource, topic, map[string]*string{
-			key: &value,
+		err := alterConfigs(admin, getIncrementalAlterConfigs(), sarama.TopicResource, topic, map[string]sarama.IncrementalAlterConfigsEntry{
+			key: {Operation: sarama.IncrementalAlterConfigsOperationSet, Value: &value},
 		}, false)
 		if err != nil {
 			errorExit("failed to update topic config: %v", err)
@@ -320,22 +919,168 @@ var addConfigCmd = &cobra.Command{
 }
 
 var deleteTopicCmd = &cobra.Command{
-	Use:               "delete TOPIC",
-	Short:             "Delete a topic",
-	Args:              cobra.ExactArgs(1),
+	Use:   "delete TOPIC...",
+	Short: "Delete topics",
+	Long: "Delete topics by name or glob pattern, e.g. 'test-*'. Internal topics only match patterns starting with __. " +
+		"The topics patterns expand to are listed and the deletion must be confirmed, unless --yes is given. " +
+		"If consumer groups have committed offsets on a topic or members assigned to it, they are listed and the deletion must be confirmed as well, unless --yes is given or --force skips the check.",
+	Args:              cobra.MinimumNArgs(1),
 	ValidArgsFunction: validTopicArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		admin := getClusterAdmin()
 
-		topicName := args[0]
-		err := admin.DeleteTopic(topicName)
+		topics, err := resolveTopicArgs(admin, args)
 		if err != nil {
-			errorExit("Could not delete topic %v: %v\n", topicName, err.Error())
-		} else {
-			fmt.Fprintf(outWriter, "\xE2\x9C\x85 Deleted topic %v!\n", topicName)
+			errorExit("%v\n", err)
+		}
+
+		confirmDeleteTopics(admin, args, topics)
+
+		for _, topicName := range topics {
+			err := admin.DeleteTopic(topicName)
+			if err != nil {
+				errorExit("Could not delete topic %v: %v\n", topicName, err.Error())
+			} else {
+				fmt.Fprintf(outWriter, "\xE2\x9C\x85 Deleted topic %v!\n", topicName)
+			}
 		}
 	},
 }
+
+// isTopicPattern reports whether arg is a glob pattern rather than a topic.
+func isTopicPattern(arg string) bool {
+	return strings.ContainsAny(arg, "*?[")
+}
+
+// resolveTopicArgs expands glob patterns in args to the matching topics.
+// Internal topics only match patterns starting with __.
+func resolveTopicArgs(admin sarama.ClusterAdmin, args []string) ([]string, error) {
+	var existing map[string]sarama.TopicDetail
+	var topics []string
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		if !isTopicPattern(arg) {
+			if !seen[arg] {
+				seen[arg] = true
+				topics = append(topics, arg)
+			}
+			continue
+		}
+
+		if existing == nil {
+			var err error
+			existing, err = admin.ListTopics()
+			if err != nil {
+				return nil, fmt.Errorf("unable to list topics: %v", err)
+			}
+		}
+		var matches []string
+		for topic := range existing {
+			if strings.HasPrefix(topic, "__") && !strings.HasPrefix(arg, "__") {
+				continue
+			}
+			ok, err := path.Match(arg, topic)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %v", arg, err)
+			}
+			if ok {
+				matches = append(matches, topic)
+			}
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no topic matches %q", arg)
+		}
+		sort.Strings(matches)
+		for _, topic := range matches {
+			if !seen[topic] {
+				seen[topic] = true
+				topics = append(topics, topic)
+			}
+		}
+	}
+	return topics, nil
+}
+
+// confirmDeleteTopics lists the topics glob patterns expanded to and, unless
+// --force is given, the consumer groups of the topics. It asks for
+// confirmation if patterns were expanded or there are consumer groups, unless
+// --yes is given.
+func confirmDeleteTopics(admin sarama.ClusterAdmin, args []string, topics []string) {
+	expanded := false
+	for _, arg := range args {
+		if isTopicPattern(arg) {
+			expanded = true
+		}
+	}
+	if expanded {
+		fmt.Fprintf(outWriter, "Topics to delete:\n")
+		for _, topic := range topics {
+			fmt.Fprintf(outWriter, "\t%v\n", topic)
+		}
+	}
+
+	consumed := false
+	if !deleteForceFlag {
+		consumed = printTopicsConsumers(admin, topics)
+	}
+	if deleteYesFlag || (!expanded && !consumed) {
+		return
+	}
+	prompt := promptui.Prompt{
+		Label:     fmt.Sprintf("Delete %v topics", len(topics)),
+		IsConfirm: true,
+	}
+	if consumed {
+		prompt.Label = "Delete the topics anyway"
+	}
+	if _, err := prompt.Run(); err != nil {
+		errorExit("Aborted, exiting.\n")
+	}
+}
+
+// printTopicsConsumers prints the consumer groups of topics and reports
+// whether there are any.
+func printTopicsConsumers(admin sarama.ClusterAdmin, topics []string) bool {
+	details, err := admin.DescribeTopics(topics)
+	if err != nil {
+		errorExit("Unable to describe topics: %v\n", err)
+	}
+
+	highWatermarks := make(map[string]map[int32]int64, len(details))
+	for _, detail := range details {
+		if detail.Err != sarama.ErrNoError {
+			// Reported by the deletion.
+			continue
+		}
+		partitions := make([]int32, 0, len(detail.Partitions))
+		for _, partition := range detail.Partitions {
+			partitions = append(partitions, partition.ID)
+		}
+		highWatermarks[detail.Name] = getHighWatermarks(detail.Name, partitions)
+	}
+	if len(highWatermarks) == 0 {
+		return false
+	}
+	consumers, err := getTopicsConsumers(admin, highWatermarks)
+	if err != nil {
+		errorExit("Unable to get consumers of topics: %v\n", err)
+	}
+	if len(consumers) == 0 {
+		return false
+	}
+
+	fmt.Fprintf(outWriter, "Consumer groups read the topics to delete:\n")
+	w := tabwriter.NewWriter(outWriter, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	fmt.Fprintf(w, "TOPIC\tGROUP\tSTATE\tLAG\t\n")
+	for _, topic := range topics {
+		for _, c := range consumers[topic] {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t\n", topic, c.Group, c.State, c.Lag)
+		}
+	}
+	w.Flush()
+	return true
+}
+
 var lagCmd = &cobra.Command{
 	Use:   "lag",
 	Short: "Display the total lags for each consumer group",
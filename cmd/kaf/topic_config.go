@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/IBM/sarama"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	topicCmd.AddCommand(topicConfigCmd)
+	topicConfigCmd.AddCommand(topicConfigGetCmd)
+	topicConfigCmd.AddCommand(topicConfigSetCmd)
+	topicConfigCmd.AddCommand(topicConfigDeleteCmd)
+
+	topicConfigGetCmd.Flags().Var(&outputFormat, "output", "Set output format: default or json")
+	topicConfigGetCmd.Flags().BoolVar(&noHeaderFlag, "no-headers", false, "Hide table headers")
+	if err := topicConfigGetCmd.RegisterFlagCompletionFunc("output", completeOutputFormat); err != nil {
+		errorExit("Failed to register flag completion: %v", err)
+	}
+}
+
+var topicConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Get, set and delete configs of a topic",
+}
+
+var topicConfigGetCmd = &cobra.Command{
+	Use:   "get TOPIC [KEY]",
+	Short: "Print the configs of a topic, or the config KEY",
+	Long: "Print every config of a topic with its value and source, or only the config KEY. " +
+		"Values of sensitive configs are not returned by the brokers.",
+	Example:           "kaf topic config get orders\nkaf topic config get orders retention.ms --output json",
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: validTopicConfigArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client := getClient()
+		defer client.Close()
+		entries, err := describeConfigWithSynonyms(client, sarama.ConfigResource{
+			Type: sarama.TopicResource,
+			Name: args[0],
+		})
+		if err != nil {
+			errorExit("Unable to describe config of topic %v: %v", args[0], err)
+		}
+		configs := topicConfigsOf(entries)
+
+		if len(args) == 2 {
+			config, ok := findTopicConfig(configs, args[1])
+			if !ok {
+				errorExit("Topic %v has no config %v", args[0], args[1])
+			}
+			configs = []topicConfig{config}
+		}
+		if err := writeTopicConfigs(outWriter, configs, len(args) == 2); err != nil {
+			errorExit("Failed to write configs: %v", err)
+		}
+	},
+}
+
+var topicConfigSetCmd = &cobra.Command{
+	Use:               "set TOPIC KEY=VALUE...",
+	Short:             "Set configs of a topic",
+	Long:              "Set configs of a topic, leaving its other configs unchanged on Kafka >=2.3.0. Older clusters reset all other dynamic configs of the topic.",
+	Example:           "kaf topic config set orders retention.ms=86400000 cleanup.policy=compact,delete",
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: validTopicConfigArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		entries, err := parseConfigArgs(args[1:], sarama.IncrementalAlterConfigsOperationSet)
+		if err != nil {
+			errorExit("%v", err)
+		}
+		if err := alterConfigs(getClusterAdmin(), getIncrementalAlterConfigs(), sarama.TopicResource, args[0], entries, false); err != nil {
+			errorExit("Unable to set config of topic %v: %v", args[0], err)
+		}
+		fmt.Fprintf(outWriter, "\xE2\x9C\x85 Set %v on topic %v.\n", strings.Join(args[1:], " "), args[0])
+	},
+}
+
+var topicConfigDeleteCmd = &cobra.Command{
+	Use:               "delete TOPIC KEY...",
+	Short:             "Reset configs of a topic to the broker default",
+	Long:              "Remove configs set on a topic, so that it uses the broker or cluster default again. This requires Kafka >=2.3.0.",
+	Example:           "kaf topic config delete orders retention.ms",
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: validTopicConfigArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		entries, err := parseConfigArgs(args[1:], sarama.IncrementalAlterConfigsOperationDelete)
+		if err != nil {
+			errorExit("%v", err)
+		}
+		if err := alterConfigs(getClusterAdmin(), getIncrementalAlterConfigs(), sarama.TopicResource, args[0], entries, false); err != nil {
+			errorExit("Unable to delete config of topic %v: %v", args[0], err)
+		}
+		fmt.Fprintf(outWriter, "\xE2\x9C\x85 Reset %v of topic %v to the default.\n", strings.Join(args[1:], ", "), args[0])
+	},
+}
+
+// validTopicConfigArgs completes the topic, the first argument.
+func validTopicConfigArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return validTopicArgs(cmd, args, toComplete)
+}
+
+// topicConfig is a config of a topic as printed by topic config get.
+type topicConfig struct {
+	Name string `json:"name"`
+	// Value is nil for sensitive configs.
+	Value     *string `json:"value"`
+	Source    string  `json:"source"`
+	Default   bool    `json:"default"`
+	ReadOnly  bool    `json:"readOnly"`
+	Sensitive bool    `json:"sensitive"`
+}
+
+func topicConfigsOf(entries []*sarama.ConfigEntry) []topicConfig {
+	configs := make([]topicConfig, 0, len(entries))
+	for _, entry := range entries {
+		config := topicConfig{
+			Name:      entry.Name,
+			Source:    entry.Source.String(),
+			Default:   entry.Default,
+			ReadOnly:  entry.ReadOnly,
+			Sensitive: entry.Sensitive,
+		}
+		if !entry.Sensitive {
+			value := entry.Value
+			config.Value = &value
+		}
+		configs = append(configs, config)
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Name < configs[j].Name })
+	return configs
+}
+
+func findTopicConfig(configs []topicConfig, name string) (topicConfig, bool) {
+	for _, config := range configs {
+		if config.Name == name {
+			return config, true
+		}
+	}
+	return topicConfig{}, false
+}
+
+// writeTopicConfigs prints configs as a table, or as JSON with --output json:
+// an array, or the object of the config if single.
+func writeTopicConfigs(w io.Writer, configs []topicConfig, single bool) error {
+	if outputFormat == OutputFormatJSON {
+		var v interface{} = configs
+		if single {
+			v = configs[0]
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	if !noHeaderFlag {
+		fmt.Fprintf(tw, "NAME\tVALUE\tSOURCE\tREAD-ONLY\t\n")
+	}
+	for _, config := range configs {
+		value := "(sensitive)"
+		if config.Value != nil {
+			value = *config.Value
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t\n", config.Name, value, config.Source, config.ReadOnly)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTopicConfigs(t *testing.T) {
+	configs := topicConfigsOf([]*sarama.ConfigEntry{
+		{Name: "retention.ms", Value: "1000", Source: sarama.SourceTopic},
+		{Name: "cleanup.policy", Value: "delete", Source: sarama.SourceDefault, Default: true},
+		{Name: "sasl.jaas.config", Value: "", Source: sarama.SourceTopic, Sensitive: true},
+	})
+	require.Equal(t, "cleanup.policy", configs[0].Name)
+	require.Nil(t, configs[2].Value)
+
+	config, ok := findTopicConfig(configs, "retention.ms")
+	require.True(t, ok)
+	require.Equal(t, "1000", *config.Value)
+	_, ok = findTopicConfig(configs, "missing")
+	require.False(t, ok)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeTopicConfigs(&buf, configs, false))
+	require.Contains(t, buf.String(), "(sensitive)")
+
+	outputFormat = OutputFormatJSON
+	defer func() { outputFormat = OutputFormatDefault }()
+	buf.Reset()
+	require.NoError(t, writeTopicConfigs(&buf, []topicConfig{config}, true))
+	require.JSONEq(t, `{"name":"retention.ms","value":"1000","source":"`+sarama.SourceTopic.String()+`","default":false,"readOnly":false,"sensitive":false}`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeTopicConfigs(&buf, configs[2:], false))
+	require.JSONEq(t, `[{"name":"sasl.jaas.config","value":null,"source":"`+sarama.SourceTopic.String()+`","default":false,"readOnly":false,"sensitive":true}]`, buf.String())
+}
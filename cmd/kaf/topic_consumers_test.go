@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+// groupOffsetsAdmin is a cluster admin returning fixed groups and committed
+// offsets, group -> topic -> partition -> offset.
+type groupOffsetsAdmin struct {
+	sarama.ClusterAdmin
+	offsets     map[string]map[string]map[int32]int64
+	offsetCalls int
+}
+
+func (a *groupOffsetsAdmin) ListConsumerGroups() (map[string]string, error) {
+	groups := make(map[string]string, len(a.offsets))
+	for group := range a.offsets {
+		groups[group] = "consumer"
+	}
+	return groups, nil
+}
+
+func (a *groupOffsetsAdmin) DescribeConsumerGroups(groups []string) ([]*sarama.GroupDescription, error) {
+	descriptions := make([]*sarama.GroupDescription, 0, len(groups))
+	for _, group := range groups {
+		descriptions = append(descriptions, &sarama.GroupDescription{GroupId: group, State: "Empty"})
+	}
+	return descriptions, nil
+}
+
+func (a *groupOffsetsAdmin) ListConsumerGroupOffsets(group string, topicPartitions map[string][]int32) (*sarama.OffsetFetchResponse, error) {
+	a.offsetCalls++
+	resp := &sarama.OffsetFetchResponse{}
+	for topic, partitions := range topicPartitions {
+		for _, partition := range partitions {
+			offset, ok := a.offsets[group][topic][partition]
+			if !ok {
+				offset = -1
+			}
+			resp.AddBlock(topic, partition, &sarama.OffsetFetchResponseBlock{Offset: offset})
+		}
+	}
+	return resp, nil
+}
+
+func TestGetTopicsConsumers(t *testing.T) {
+	admin := &groupOffsetsAdmin{offsets: map[string]map[string]map[int32]int64{
+		"billing":   {"orders": {0: 5, 1: 10}},
+		"analytics": {"orders": {0: 10}, "users": {0: 3}},
+		"idle":      {},
+	}}
+	consumers, err := getTopicsConsumers(admin, map[string]map[int32]int64{
+		"orders":   {0: 10, 1: 10},
+		"users":    {0: 4},
+		"payments": {0: 1},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string][]topicConsumer{
+		"orders": {{Group: "analytics", State: "Empty"}, {Group: "billing", State: "Empty", Lag: 5}},
+		"users":  {{Group: "analytics", State: "Empty", Lag: 1}},
+	}, consumers)
+	// One offset request per group, not per group and topic.
+	require.Equal(t, 3, admin.offsetCalls)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// defaultTopicTail is the number of messages per partition topic tail prints
+// before following.
+const defaultTopicTail = 20
+
+func init() {
+	topicCmd.AddCommand(topicTailCmd)
+
+	// The flags, and their completions, are those of consume, which topic
+	// tail runs.
+	topicTailCmd.Flags().AddFlagSet(consumeCmd.Flags())
+}
+
+var topicTailCmd = &cobra.Command{
+	Use:   "tail TOPIC",
+	Short: "Print the last messages of each partition and follow new ones",
+	Long: "Print the last 20 messages of each partition of a topic and follow new messages until interrupted. " +
+		"Same as kaf consume TOPIC --tail 20 --follow, and takes the same flags for decoding and output. " +
+		"Pass --tail to change the number of messages, or --follow=false to exit after printing them. With --offset, --from-time, --offset-from-group, --group or --state-file, consuming starts there instead.",
+	Example:           "kaf topic tail orders\nkaf topic tail orders -n 5 --output json",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: validTopicArgs,
+	PreRun:            consumeCmd.PreRun,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Other start positions replace the default tail.
+		started := false
+		for _, flag := range []string{"tail", "offset", "from-time", "offset-from-group", "group", "state-file"} {
+			started = started || cmd.Flags().Changed(flag)
+		}
+		if !started {
+			tail = defaultTopicTail
+		}
+		if !cmd.Flags().Changed("follow") {
+			follow = true
+		}
+		consumeCmd.Run(cmd, args)
+	},
+}
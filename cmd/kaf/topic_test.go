@@ -22,16 +22,50 @@ func TestTopic(t *testing.T) {
 		require.Contains(t, out, newTopic)
 	})
 
+	t.Run("create with --wait", func(t *testing.T) {
+		waitTopic := fmt.Sprintf("wait-topic-%d", time.Now().Unix())
+		out := runCmdWithBroker(t, nil, "topic", "create", waitTopic, "--wait")
+		require.Contains(t, out, "Created topic!")
+		require.Contains(t, out, waitTopic)
+		require.Contains(t, out, "Topic is available after")
+		runCmdWithBroker(t, nil, "topic", "delete", waitTopic)
+	})
+
 	t.Run("ls", func(t *testing.T) {
 		out := runCmdWithBroker(t, nil, "topic", "ls")
 		require.Contains(t, out, newTopic)
 	})
 
+	t.Run("ls detailed", func(t *testing.T) {
+		out := runCmdWithBroker(t, nil, "topic", "ls", "--detailed")
+		require.Contains(t, out, "MESSAGES")
+		require.Contains(t, out, newTopic)
+	})
+
 	t.Run("describe", func(t *testing.T) {
 		out := runCmdWithBroker(t, nil, "topic", "describe", newTopic)
 		require.Contains(t, out, newTopic)
 	})
 
+	t.Run("describe consumers", func(t *testing.T) {
+		out := runCmdWithBroker(t, nil, "topic", "describe", newTopic, "--consumers")
+		require.Contains(t, out, "No consumer group has committed offsets on the topic.")
+	})
+
+	t.Run("config set, get and delete", func(t *testing.T) {
+		out := runCmdWithBroker(t, nil, "topic", "config", "set", newTopic, "retention.ms=3600000")
+		require.Contains(t, out, "Set retention.ms=3600000")
+
+		out = runCmdWithBroker(t, nil, "topic", "config", "get", newTopic, "retention.ms", "--output", "json")
+		require.Contains(t, out, `"value":"3600000"`)
+
+		out = runCmdWithBroker(t, nil, "topic", "config", "delete", newTopic, "retention.ms")
+		require.Contains(t, out, "Reset retention.ms")
+
+		out = runCmdWithBroker(t, nil, "topic", "config", "get", newTopic, "retention.ms", "--output", "json")
+		require.Contains(t, out, `"default":true`)
+	})
+
 	t.Run("delete", func(t *testing.T) {
 		out := runCmdWithBroker(t, nil, "topic", "delete", newTopic)
 		require.Contains(t, out, fmt.Sprintf("Deleted topic %s!", newTopic))
@@ -42,3 +76,24 @@ func TestTopic(t *testing.T) {
 		require.NotContains(t, out, newTopic)
 	})
 }
+
+func TestParseReplicaAssignment(t *testing.T) {
+	assignment, err := parseReplicaAssignment("0:1,2;1:2,3")
+	require.NoError(t, err)
+	require.Equal(t, map[int32][]int32{0: {1, 2}, 1: {2, 3}}, assignment)
+
+	_, err = parseReplicaAssignment("0:1,2;1:2")
+	require.Error(t, err)
+
+	_, err = parseReplicaAssignment("0:1;2:2")
+	require.Error(t, err)
+}
+
+func TestHumanizeConfigValue(t *testing.T) {
+	require.Equal(t, "604800000 ms (7d)", humanizeConfigValue("retention.ms", "604800000"))
+	require.Equal(t, "129600000 ms (1d12h)", humanizeConfigValue("retention.ms", "129600000"))
+	require.Equal(t, "1073741824 (1 GiB)", humanizeConfigValue("segment.bytes", "1073741824"))
+	require.Equal(t, "1572864 (1.5 MiB)", humanizeConfigValue("max.message.bytes", "1572864"))
+	require.Equal(t, "-1 (unlimited)", humanizeConfigValue("retention.bytes", "-1"))
+	require.Equal(t, "delete", humanizeConfigValue("cleanup.policy", "delete"))
+}
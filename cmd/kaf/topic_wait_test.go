@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+// describeTopicsAdmin is a cluster admin returning fixed topic metadata.
+type describeTopicsAdmin struct {
+	sarama.ClusterAdmin
+	metadata []*sarama.TopicMetadata
+	calls    int
+}
+
+func (a *describeTopicsAdmin) DescribeTopics(topics []string) ([]*sarama.TopicMetadata, error) {
+	a.calls++
+	return a.metadata, nil
+}
+
+func TestTopicNotReadyReason(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		metadata []*sarama.TopicMetadata
+		reason   string
+	}{
+		{
+			name:   "no metadata",
+			reason: "no metadata returned",
+		},
+		{
+			name:     "unknown topic",
+			metadata: []*sarama.TopicMetadata{{Name: "t", Err: sarama.ErrUnknownTopicOrPartition}},
+			reason:   sarama.ErrUnknownTopicOrPartition.Error(),
+		},
+		{
+			name:     "missing partitions",
+			metadata: []*sarama.TopicMetadata{{Name: "t", Partitions: []*sarama.PartitionMetadata{{ID: 0, Leader: 1}}}},
+			reason:   "1 of 2 partitions known",
+		},
+		{
+			name: "leaderless partition",
+			metadata: []*sarama.TopicMetadata{{Name: "t", Partitions: []*sarama.PartitionMetadata{
+				{ID: 0, Leader: 1},
+				{ID: 1, Leader: -1, Err: sarama.ErrLeaderNotAvailable},
+			}}},
+			reason: "1 partitions without leader",
+		},
+		{
+			name: "ready",
+			metadata: []*sarama.TopicMetadata{{Name: "t", Partitions: []*sarama.PartitionMetadata{
+				{ID: 0, Leader: 1},
+				{ID: 1, Leader: 2},
+			}}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.reason, topicNotReadyReason(&describeTopicsAdmin{metadata: tc.metadata}, "t", 2))
+		})
+	}
+}
+
+func TestWaitForTopicTimeout(t *testing.T) {
+	defer func(interval time.Duration) { topicWaitInterval = interval }(topicWaitInterval)
+	topicWaitInterval = time.Millisecond
+
+	admin := &describeTopicsAdmin{metadata: []*sarama.TopicMetadata{{Name: "t", Partitions: []*sarama.PartitionMetadata{
+		{ID: 0, Leader: -1},
+	}}}}
+	_, err := waitForTopic(admin, "t", 1, 20*time.Millisecond)
+	require.EqualError(t, err, "topic t is not available after 20ms: 1 partitions without leader")
+	require.Greater(t, admin.calls, 1)
+}
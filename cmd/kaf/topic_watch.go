@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+var (
+	topicWatchFlag         bool
+	topicWatchIntervalFlag time.Duration
+)
+
+// partitionLeadership is the leader, replicas and ISR of a partition at one
+// refresh of --watch.
+type partitionLeadership struct {
+	Leader   int32
+	Replicas []int32
+	ISR      []int32
+}
+
+func leadershipOf(detail *sarama.TopicMetadata) map[int32]partitionLeadership {
+	partitions := make(map[int32]partitionLeadership, len(detail.Partitions))
+	for _, p := range detail.Partitions {
+		replicas := append([]int32(nil), p.Replicas...)
+		sort.Slice(replicas, func(i, j int) bool { return replicas[i] < replicas[j] })
+		isr := append([]int32(nil), p.Isr...)
+		sort.Slice(isr, func(i, j int) bool { return isr[i] < isr[j] })
+		partitions[p.ID] = partitionLeadership{Leader: p.Leader, Replicas: replicas, ISR: isr}
+	}
+	return partitions
+}
+
+// leadershipChange describes how a partition changed between two refreshes,
+// or returns an empty string if it did not.
+func leadershipChange(before, after partitionLeadership) string {
+	var changes []string
+	if before.Leader != after.Leader {
+		changes = append(changes, fmt.Sprintf("leader %v -> %v", brokerName(before.Leader), brokerName(after.Leader)))
+	}
+	if joined := brokersMissing(after.ISR, before.ISR); len(joined) > 0 {
+		changes = append(changes, fmt.Sprintf("joined ISR %v", joined))
+	}
+	if left := brokersMissing(before.ISR, after.ISR); len(left) > 0 {
+		changes = append(changes, fmt.Sprintf("left ISR %v", left))
+	}
+	if fmt.Sprint(before.Replicas) != fmt.Sprint(after.Replicas) {
+		changes = append(changes, fmt.Sprintf("replicas %v -> %v", before.Replicas, after.Replicas))
+	}
+	return strings.Join(changes, ", ")
+}
+
+// brokersMissing returns the brokers of a that are not in b.
+func brokersMissing(a, b []int32) []int32 {
+	var missing []int32
+	for _, id := range a {
+		if !containsPartition(b, id) {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+func brokerName(id int32) string {
+	if id < 0 {
+		return "none"
+	}
+	return fmt.Sprint(id)
+}
+
+// lastChange is the latest change of a partition seen by --watch.
+type lastChange struct {
+	description string
+	at          time.Time
+}
+
+// topicWatch tracks the leadership of the partitions of a topic across
+// refreshes.
+type topicWatch struct {
+	topic      string
+	partitions map[int32]partitionLeadership
+	changes    map[int32]lastChange
+}
+
+// update records a refresh at time at and returns the partitions that changed
+// since the previous one.
+func (t *topicWatch) update(partitions map[int32]partitionLeadership, at time.Time) []int32 {
+	var changed []int32
+	if t.partitions != nil {
+		for id, after := range partitions {
+			before, ok := t.partitions[id]
+			description := "new partition"
+			if ok {
+				description = leadershipChange(before, after)
+			}
+			if description != "" {
+				t.changes[id] = lastChange{description: description, at: at}
+				changed = append(changed, id)
+			}
+		}
+	}
+	t.partitions = partitions
+	sort.Slice(changed, func(i, j int) bool { return changed[i] < changed[j] })
+	return changed
+}
+
+// write prints the partitions at time at. Rows marked with * changed in the
+// last refresh. With history, the latest earlier change of every partition is
+// shown with its age.
+func (t *topicWatch) write(w io.Writer, at time.Time, changed []int32, history bool) {
+	fmt.Fprintf(w, "%v at %v\n", t.topic, at.Format(time.RFC3339))
+	ids := make([]int32, 0, len(t.partitions))
+	for id := range t.partitions {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	tw := tabwriter.NewWriter(w, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+	fmt.Fprintf(tw, "\tPARTITION\tLEADER\tREPLICAS\tISR\tCHANGE\t\n")
+	for _, id := range ids {
+		p := t.partitions[id]
+		marker := ""
+		var change string
+		if containsPartition(changed, id) {
+			marker = "*"
+			change = t.changes[id].description
+		} else if c, ok := t.changes[id]; ok && history {
+			change = fmt.Sprintf("%v (%v ago)", c.description, at.Sub(c.at).Round(time.Second))
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t\n", marker, id, brokerName(p.Leader), p.Replicas, p.ISR, change)
+	}
+	tw.Flush()
+}
+
+// watchTopic describes topic every interval until interrupted. On a terminal
+// the table is redrawn, otherwise a timestamped snapshot is appended on start
+// and whenever leaders, replicas or ISR changed.
+func watchTopic(ctx context.Context, admin sarama.ClusterAdmin, topic string, interval time.Duration) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	tty := isTerminal(outWriter)
+	watch := &topicWatch{topic: topic, changes: make(map[int32]lastChange)}
+	for {
+		details, err := admin.DescribeTopics([]string{topic})
+		now := time.Now()
+		switch {
+		case err != nil:
+			fmt.Fprintf(errWriter, "%v: unable to describe topic: %v\n", now.Format(time.RFC3339), err)
+		case details[0].Err != sarama.ErrNoError:
+			fmt.Fprintf(errWriter, "%v: unable to describe topic: %v\n", now.Format(time.RFC3339), details[0].Err)
+		default:
+			first := watch.partitions == nil
+			changed := watch.update(leadershipOf(details[0]), now)
+			if tty {
+				// Move to the top left and clear the screen.
+				fmt.Fprint(outWriter, "\033[H\033[2J")
+				watch.write(outWriter, now, changed, true)
+			} else if first || len(changed) > 0 {
+				watch.write(outWriter, now, changed, false)
+				fmt.Fprintln(outWriter)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
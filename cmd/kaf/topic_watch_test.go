@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeadershipChange(t *testing.T) {
+	before := partitionLeadership{Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1, 2, 3}}
+	require.Empty(t, leadershipChange(before, before))
+
+	after := partitionLeadership{Leader: 2, Replicas: []int32{1, 2, 3}, ISR: []int32{2, 3}}
+	require.Equal(t, "leader 1 -> 2, left ISR [1]", leadershipChange(before, after))
+	require.Equal(t, "leader 2 -> 1, joined ISR [1]", leadershipChange(after, before))
+
+	offline := partitionLeadership{Leader: -1, Replicas: []int32{2, 3, 4}, ISR: []int32{}}
+	require.Equal(t, "leader 1 -> none, left ISR [1 2 3], replicas [1 2 3] -> [2 3 4]", leadershipChange(before, offline))
+}
+
+func TestTopicWatch(t *testing.T) {
+	detail := &sarama.TopicMetadata{Partitions: []*sarama.PartitionMetadata{
+		{ID: 1, Leader: 2, Replicas: []int32{2, 1}, Isr: []int32{2, 1}},
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2}, Isr: []int32{1, 2}},
+	}}
+	start := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	watch := &topicWatch{topic: "orders", changes: make(map[int32]lastChange)}
+	require.Empty(t, watch.update(leadershipOf(detail), start))
+	require.Equal(t, []int32{1, 2}, watch.partitions[1].Replicas)
+
+	// Partition 0 moves to broker 2 while broker 1 restarts.
+	detail.Partitions[1].Leader = 2
+	detail.Partitions[1].Isr = []int32{2}
+	require.Equal(t, []int32{0}, watch.update(leadershipOf(detail), start.Add(2*time.Second)))
+
+	var buf bytes.Buffer
+	watch.write(&buf, start.Add(2*time.Second), []int32{0}, true)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Equal(t, "orders at 2024-01-02T15:04:07Z", lines[0])
+	require.Len(t, lines, 4)
+	require.True(t, strings.HasPrefix(lines[2], "*"))
+	require.Contains(t, lines[2], "leader 1 -> 2, left ISR [1]")
+	require.NotContains(t, lines[3], "*")
+
+	// Later refreshes show the change with its age.
+	require.Empty(t, watch.update(leadershipOf(detail), start.Add(10*time.Second)))
+	buf.Reset()
+	watch.write(&buf, start.Add(10*time.Second), nil, true)
+	require.Contains(t, buf.String(), "leader 1 -> 2, left ISR [1] (8s ago)")
+	require.NotContains(t, buf.String(), "*")
+
+	buf.Reset()
+	watch.write(&buf, start.Add(10*time.Second), nil, false)
+	require.NotContains(t, buf.String(), "leader 1 -> 2")
+}
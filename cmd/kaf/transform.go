@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	lua "github.com/yuin/gopher-lua"
+)
+
+var (
+	transformFlag        string
+	transformTimeoutFlag time.Duration
+
+	transformer *luaTransformer
+)
+
+// unsafeLuaFuncs are base functions removed from the sandbox, they load code
+// from files or strings or control the garbage collector.
+var unsafeLuaFuncs = []string{"dofile", "loadfile", "load", "loadstring", "collectgarbage", "module", "require", "_printregs"}
+
+// transformRecord is the record passed to and returned by a transform script.
+type transformRecord struct {
+	Topic string
+	// Partition and Offset are -1 for produced records.
+	Partition int32
+	Offset    int64
+	// Key is nil if the record has no key.
+	Key     []byte
+	Value   []byte
+	Headers []sarama.RecordHeader
+}
+
+// luaTransformer runs the global transform function of a Lua script for
+// every record. The script can only use the base, string, table and math
+// libraries, it has no access to files, the network or other processes. print
+// writes to stderr.
+type luaTransformer struct {
+	// mu serializes calls, Lua states are not safe for concurrent use.
+	mu      sync.Mutex
+	state   *lua.LState
+	fn      lua.LValue
+	timeout time.Duration
+}
+
+func newLuaTransformer(path string, timeout time.Duration) (*luaTransformer, error) {
+	script, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range unsafeLuaFuncs {
+		L.SetGlobal(name, lua.LNil)
+	}
+	L.SetGlobal("print", L.NewFunction(luaPrint))
+
+	t := &luaTransformer{state: L, timeout: timeout}
+	if err := t.withTimeout(func() error { return L.DoString(string(script)) }); err != nil {
+		L.Close()
+		return nil, fmt.Errorf("unable to run %v: %w", path, err)
+	}
+	t.fn = L.GetGlobal("transform")
+	if t.fn.Type() != lua.LTFunction {
+		L.Close()
+		return nil, fmt.Errorf("%v does not define a function transform(record)", path)
+	}
+	return t, nil
+}
+
+func luaPrint(L *lua.LState) int {
+	parts := make([]string, 0, L.GetTop())
+	for i := 1; i <= L.GetTop(); i++ {
+		parts = append(parts, L.ToStringMeta(L.Get(i)).String())
+	}
+	fmt.Fprintln(errWriter, strings.Join(parts, "\t"))
+	return 0
+}
+
+// withTimeout runs f, aborting the script if it runs longer than the timeout.
+func (t *luaTransformer) withTimeout(f func() error) error {
+	if t.timeout <= 0 {
+		return f()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+	t.state.SetContext(ctx)
+	defer t.state.RemoveContext()
+	err := f()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("transform exceeded --transform-timeout of %v", t.timeout)
+	}
+	return err
+}
+
+// apply calls transform(record) with a table of topic, partition, offset,
+// key, value, headers, a list of tables with key and value, and header, a map
+// of header keys to the value of their first header. The function
+// returns the changed record, or nil or false to drop it, in which case apply
+// returns false.
+func (t *luaTransformer) apply(r *transformRecord) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	L := t.state
+
+	var keep bool
+	err := t.withTimeout(func() error {
+		if err := L.CallByParam(lua.P{Fn: t.fn, NRet: 1, Protect: true}, t.toTable(r)); err != nil {
+			return err
+		}
+		ret := L.Get(-1)
+		L.Pop(1)
+		switch v := ret.(type) {
+		case *lua.LTable:
+			keep = true
+			return fromLuaTable(v, r)
+		case *lua.LNilType:
+			return nil
+		case lua.LBool:
+			if v {
+				return fmt.Errorf("transform returned true, expected the record, nil or false")
+			}
+			return nil
+		default:
+			return fmt.Errorf("transform returned %v, expected the record, nil or false", ret.Type())
+		}
+	})
+	return keep, err
+}
+
+func (t *luaTransformer) toTable(r *transformRecord) *lua.LTable {
+	L := t.state
+	table := L.NewTable()
+	table.RawSetString("topic", lua.LString(r.Topic))
+	if r.Partition >= 0 {
+		table.RawSetString("partition", lua.LNumber(r.Partition))
+		table.RawSetString("offset", lua.LNumber(r.Offset))
+	}
+	if r.Key != nil {
+		table.RawSetString("key", lua.LString(r.Key))
+	}
+	table.RawSetString("value", lua.LString(r.Value))
+	headers := L.NewTable()
+	for _, h := range r.Headers {
+		header := L.NewTable()
+		header.RawSetString("key", lua.LString(h.Key))
+		header.RawSetString("value", lua.LString(h.Value))
+		headers.Append(header)
+	}
+	table.RawSetString("headers", headers)
+	header := L.NewTable()
+	for key, value := range headerMap(r.Headers) {
+		header.RawSetString(key, lua.LString(value))
+	}
+	table.RawSetString("header", header)
+	return table
+}
+
+// headerMap returns the value of the first header of every key.
+func headerMap(headers []sarama.RecordHeader) map[string]string {
+	m := make(map[string]string, len(headers))
+	for _, h := range headers {
+		if _, ok := m[string(h.Key)]; !ok {
+			m[string(h.Key)] = string(h.Value)
+		}
+	}
+	return m
+}
+
+// setHeader replaces all headers with key by a single one with value, at the
+// position of the first, or appends it.
+func setHeader(headers []sarama.RecordHeader, key string, value string) []sarama.RecordHeader {
+	out := headers[:0:0]
+	set := false
+	for _, h := range headers {
+		if string(h.Key) != key {
+			out = append(out, h)
+		} else if !set {
+			out = append(out, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+			set = true
+		}
+	}
+	if !set {
+		out = append(out, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+	}
+	return out
+}
+
+// removeHeader removes all headers with key.
+func removeHeader(headers []sarama.RecordHeader, key string) []sarama.RecordHeader {
+	out := headers[:0:0]
+	for _, h := range headers {
+		if string(h.Key) != key {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// fromLuaTable sets key, value and headers of r from a returned record. The
+// headers are taken from the headers list, then keys changed, added or
+// removed in the header map are applied to them.
+func fromLuaTable(table *lua.LTable, r *transformRecord) error {
+	original := headerMap(r.Headers)
+
+	switch key := table.RawGetString("key").(type) {
+	case *lua.LNilType:
+		r.Key = nil
+	case lua.LString:
+		r.Key = []byte(key)
+	default:
+		return fmt.Errorf("record key must be a string or nil, not %v", key.Type())
+	}
+
+	switch value := table.RawGetString("value").(type) {
+	case *lua.LNilType:
+		r.Value = nil
+	case lua.LString:
+		r.Value = []byte(value)
+	default:
+		return fmt.Errorf("record value must be a string or nil, not %v", value.Type())
+	}
+
+	r.Headers = nil
+	switch headers := table.RawGetString("headers").(type) {
+	case *lua.LNilType:
+	case *lua.LTable:
+		for i := 1; i <= headers.Len(); i++ {
+			header, ok := headers.RawGetInt(i).(*lua.LTable)
+			if !ok {
+				return fmt.Errorf("header %v must be a table with key and value", i)
+			}
+			key, keyOK := header.RawGetString("key").(lua.LString)
+			value, valueOK := header.RawGetString("value").(lua.LString)
+			if !keyOK || !valueOK {
+				return fmt.Errorf("header %v must have a string key and value", i)
+			}
+			r.Headers = append(r.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+		}
+	default:
+		return fmt.Errorf("record headers must be a list, not %v", headers.Type())
+	}
+
+	switch header := table.RawGetString("header").(type) {
+	case *lua.LNilType:
+	case *lua.LTable:
+		changed := make(map[string]string)
+		var err error
+		header.ForEach(func(k lua.LValue, v lua.LValue) {
+			key, keyOK := k.(lua.LString)
+			value, valueOK := v.(lua.LString)
+			if !keyOK || !valueOK {
+				if err == nil {
+					err = fmt.Errorf("record header must map strings to strings, not %v to %v", k.Type(), v.Type())
+				}
+				return
+			}
+			changed[string(key)] = string(value)
+		})
+		if err != nil {
+			return err
+		}
+		for key := range original {
+			if _, ok := changed[key]; !ok {
+				r.Headers = removeHeader(r.Headers, key)
+			}
+		}
+		// Added headers are appended in key order.
+		keys := make([]string, 0, len(changed))
+		for key := range changed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if old, ok := original[key]; !ok || old != changed[key] {
+				r.Headers = setHeader(r.Headers, key, changed[key])
+			}
+		}
+	default:
+		return fmt.Errorf("record header must be a table, not %v", header.Type())
+	}
+	return nil
+}
+
+// transformMessage applies the transform to a consumed message with its
+// decoded key and value. It returns a copy of msg with the transformed key
+// and headers.
+func transformMessage(msg *sarama.ConsumerMessage, key []byte, value []byte) (*sarama.ConsumerMessage, []byte, []byte, bool) {
+	r := &transformRecord{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Value:     value,
+	}
+	if msg.Key != nil {
+		r.Key = key
+	}
+	for _, h := range msg.Headers {
+		r.Headers = append(r.Headers, *h)
+	}
+
+	keep, err := transformer.apply(r)
+	if err != nil {
+		errorExit("Failed to transform message at partition %v offset %v: %v", msg.Partition, msg.Offset, err)
+	}
+	if !keep {
+		return msg, nil, nil, false
+	}
+
+	transformed := *msg
+	transformed.Key = r.Key
+	transformed.Headers = nil
+	for i := range r.Headers {
+		transformed.Headers = append(transformed.Headers, &r.Headers[i])
+	}
+	return &transformed, r.Key, r.Value, true
+}
+
+// transformProducerRecord applies the transform to a record before it is
+// encoded and sent. An empty key is passed as nil.
+func transformProducerRecord(topic string, key sarama.Encoder, value []byte, headers []sarama.RecordHeader) (sarama.Encoder, []byte, []sarama.RecordHeader, bool) {
+	r := &transformRecord{
+		Topic:     topic,
+		Partition: -1,
+		Offset:    -1,
+		Value:     value,
+		Headers:   headers,
+	}
+	if key != nil {
+		k, err := key.Encode()
+		if err != nil {
+			errorExit("Failed to encode key: %v", err)
+		}
+		if len(k) > 0 {
+			r.Key = k
+		}
+	}
+
+	keep, err := transformer.apply(r)
+	if err != nil {
+		errorExit("Failed to transform record: %v", err)
+	}
+	if !keep {
+		return nil, nil, nil, false
+	}
+	var transformedKey sarama.Encoder
+	if r.Key != nil {
+		transformedKey = sarama.ByteEncoder(r.Key)
+	}
+	return transformedKey, r.Value, r.Headers, true
+}
+
+// setupTransformer loads the --transform script, if any.
+func setupTransformer() {
+	if transformFlag == "" {
+		return
+	}
+	var err error
+	transformer, err = newLuaTransformer(transformFlag, transformTimeoutFlag)
+	if err != nil {
+		errorExit("Invalid --transform script: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTransformer(t *testing.T, script string) *luaTransformer {
+	path := filepath.Join(t.TempDir(), "transform.lua")
+	require.NoError(t, os.WriteFile(path, []byte(script), 0o600))
+	transformer, err := newLuaTransformer(path, 100*time.Millisecond)
+	require.NoError(t, err)
+	return transformer
+}
+
+func TestLuaTransformer(t *testing.T) {
+	// Same as examples/redact.lua.
+	transformer := newTestTransformer(t, `
+function transform(record)
+  if string.find(record.value, '"type":"heartbeat"', 1, true) then
+    return nil
+  end
+  record.value = string.gsub(record.value, "[%w%.%-_]+@[%w%.%-]+", "<redacted>")
+  local headers = {}
+  for _, header in ipairs(record.headers) do
+    if header.key ~= "authorization" then
+      table.insert(headers, header)
+    end
+  end
+  record.headers = headers
+  return record
+end
+`)
+
+	r := &transformRecord{
+		Topic:     "users",
+		Partition: 1,
+		Offset:    7,
+		Key:       []byte("user-1"),
+		Value:     []byte(`{"email":"jane@example.com"}`),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte("authorization"), Value: []byte("secret")},
+			{Key: []byte("trace"), Value: []byte("abc")},
+		},
+	}
+	keep, err := transformer.apply(r)
+	require.NoError(t, err)
+	require.True(t, keep)
+	require.Equal(t, "user-1", string(r.Key))
+	require.Equal(t, `{"email":"<redacted>"}`, string(r.Value))
+	require.Equal(t, []sarama.RecordHeader{{Key: []byte("trace"), Value: []byte("abc")}}, r.Headers)
+
+	keep, err = transformer.apply(&transformRecord{Partition: -1, Value: []byte(`{"type":"heartbeat"}`)})
+	require.NoError(t, err)
+	require.False(t, keep)
+}
+
+func TestLuaTransformerKey(t *testing.T) {
+	transformer := newTestTransformer(t, `
+function transform(record)
+  if record.key == nil then
+    record.key = record.topic .. "-" .. tostring(record.partition)
+  else
+    record.key = nil
+  end
+  return record
+end
+`)
+
+	r := &transformRecord{Topic: "orders", Partition: 3, Value: []byte("v")}
+	keep, err := transformer.apply(r)
+	require.NoError(t, err)
+	require.True(t, keep)
+	require.Equal(t, "orders-3", string(r.Key))
+
+	keep, err = transformer.apply(r)
+	require.NoError(t, err)
+	require.True(t, keep)
+	require.Nil(t, r.Key)
+}
+
+func TestLuaTransformerHeaderMap(t *testing.T) {
+	transformer := newTestTransformer(t, `
+function transform(record)
+  local retries = tonumber(record.header["retry-count"] or "0")
+  record.header["retry-count"] = tostring(retries + 1)
+  record.header["origin-topic"] = record.topic
+  record.header["trace"] = nil
+  return record
+end
+`)
+
+	r := &transformRecord{
+		Topic:     "orders",
+		Partition: -1,
+		Headers: []sarama.RecordHeader{
+			{Key: []byte("retry-count"), Value: []byte("2")},
+			{Key: []byte("trace"), Value: []byte("a")},
+			{Key: []byte("tag"), Value: []byte("x")},
+			{Key: []byte("trace"), Value: []byte("b")},
+			{Key: []byte("tag"), Value: []byte("y")},
+		},
+	}
+	keep, err := transformer.apply(r)
+	require.NoError(t, err)
+	require.True(t, keep)
+	// Repeated keys not touched in the map are kept.
+	require.Equal(t, []sarama.RecordHeader{
+		{Key: []byte("retry-count"), Value: []byte("3")},
+		{Key: []byte("tag"), Value: []byte("x")},
+		{Key: []byte("tag"), Value: []byte("y")},
+		{Key: []byte("origin-topic"), Value: []byte("orders")},
+	}, r.Headers)
+}
+
+func TestLuaTransformerSandbox(t *testing.T) {
+	transformer := newTestTransformer(t, `
+function transform(record)
+  record.value = tostring(os) .. " " .. tostring(io) .. " " .. tostring(dofile) .. " " .. tostring(require)
+  return record
+end
+`)
+	r := &transformRecord{Partition: -1}
+	_, err := transformer.apply(r)
+	require.NoError(t, err)
+	require.Equal(t, "nil nil nil nil", string(r.Value))
+}
+
+func TestLuaTransformerErrors(t *testing.T) {
+	transformer := newTestTransformer(t, `
+function transform(record)
+  if record.value == "loop" then
+    while true do end
+  end
+  if record.value == "number" then
+    return 42
+  end
+  if record.value == "header" then
+    record.header["count"] = 1
+    return record
+  end
+  record.headers = {"invalid"}
+  return record
+end
+`)
+
+	_, err := transformer.apply(&transformRecord{Partition: -1, Value: []byte("loop")})
+	require.EqualError(t, err, "transform exceeded --transform-timeout of 100ms")
+
+	// The state is still usable after a timeout.
+	_, err = transformer.apply(&transformRecord{Partition: -1, Value: []byte("number")})
+	require.EqualError(t, err, "transform returned number, expected the record, nil or false")
+
+	_, err = transformer.apply(&transformRecord{Partition: -1, Value: []byte("header")})
+	require.EqualError(t, err, "record header must map strings to strings, not string to number")
+
+	_, err = transformer.apply(&transformRecord{Partition: -1, Value: []byte("headers")})
+	require.EqualError(t, err, "header 1 must be a table with key and value")
+
+	path := filepath.Join(t.TempDir(), "empty.lua")
+	require.NoError(t, os.WriteFile(path, []byte("x = 1"), 0o600))
+	_, err = newLuaTransformer(path, time.Second)
+	require.Error(t, err)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// schemaValidator validates values against the latest JSON Schema registered
+// for the value subject of their topic.
+type schemaValidator struct {
+	schemas map[string]*jsonschema.Schema
+}
+
+func newSchemaValidator() *schemaValidator {
+	return &schemaValidator{schemas: make(map[string]*jsonschema.Schema)}
+}
+
+func (v *schemaValidator) schemaFor(topic string) (*jsonschema.Schema, error) {
+	if schema, ok := v.schemas[topic]; ok {
+		return schema, nil
+	}
+
+	subject := topic + "-value"
+	cache := getSchemaCache(topic)
+	if cache == nil {
+		return nil, fmt.Errorf("no schema registry configured for subject %v", subject)
+	}
+	source, err := cache.LatestSchema(subject)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch schema of subject %v: %w", subject, err)
+	}
+	schema, err := compileJSONSchema(subject, source)
+	if err != nil {
+		return nil, fmt.Errorf("subject %v: %w", subject, err)
+	}
+	v.schemas[topic] = schema
+	return schema, nil
+}
+
+// validate returns an error pointing to the failing fields if value does not
+// match the schema of topic.
+func (v *schemaValidator) validate(topic string, value []byte) error {
+	schema, err := v.schemaFor(topic)
+	if err != nil {
+		errorExit("Unable to validate schema: %v", err)
+	}
+	return validateJSON(schema, value)
+}
+
+func compileJSONSchema(name, source string) (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(name, strings.NewReader(source)); err != nil {
+		return nil, fmt.Errorf("not a JSON Schema: %w", err)
+	}
+	return compiler.Compile(name)
+}
+
+func validateJSON(schema *jsonschema.Schema, value []byte) error {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return fmt.Errorf("value is not valid JSON: %w", err)
+	}
+
+	err := schema.Validate(doc)
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	var problems []string
+	for _, leaf := range validationLeaves(validationErr) {
+		location := leaf.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		problems = append(problems, fmt.Sprintf("at %v: %v", location, leaf.Message))
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+// validationLeaves returns the innermost causes of a validation error, which
+// point to the actual failing fields.
+func validationLeaves(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	var leaves []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		leaves = append(leaves, validationLeaves(cause)...)
+	}
+	return leaves
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateJSON(t *testing.T) {
+	schema, err := compileJSONSchema("orders-value", `{
+		"type": "object",
+		"required": ["id"],
+		"properties": {
+			"id": {"type": "string"},
+			"customer": {"type": "object", "properties": {"age": {"type": "integer"}}}
+		}
+	}`)
+	require.NoError(t, err)
+
+	require.NoError(t, validateJSON(schema, []byte(`{"id":"o-1","customer":{"age":42}}`)))
+
+	err = validateJSON(schema, []byte(`{"id":"o-1","customer":{"age":"old"}}`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "at /customer/age:")
+
+	err = validateJSON(schema, []byte(`{}`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "at /:")
+
+	require.Error(t, validateJSON(schema, []byte(`not json`)))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
+
+// apiKeyNames names the protocol APIs shown by the version command.
+var apiKeyNames = map[int16]string{
+	0:  "Produce",
+	1:  "Fetch",
+	2:  "ListOffsets",
+	3:  "Metadata",
+	8:  "OffsetCommit",
+	9:  "OffsetFetch",
+	18: "ApiVersions",
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print version information of kaf and the selected cluster",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		w := tabwriter.NewWriter(outWriter, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		fmt.Fprintf(w, "Version:\t%v\n", version)
+		fmt.Fprintf(w, "Commit:\t%v\n", commit)
+		fmt.Fprintf(w, "Go Version:\t%v\n", runtime.Version())
+		fmt.Fprintf(w, "Platform:\t%v/%v\n", runtime.GOOS, runtime.GOARCH)
+		defer w.Flush()
+
+		if cfg.ActiveCluster() == nil && brokersFlag == nil {
+			return
+		}
+
+		saramaConfig := getConfig()
+		saramaConfig.Net.DialTimeout = 5 * time.Second
+		client, err := sarama.NewClient(currentCluster.Brokers, saramaConfig)
+		if err != nil {
+			fmt.Fprintf(w, "Cluster:\tunreachable (%v)\n", err)
+			return
+		}
+		defer client.Close()
+
+		brokers := client.Brokers()
+		if len(brokers) == 0 {
+			fmt.Fprintf(w, "Cluster:\tno brokers available\n")
+			return
+		}
+		broker := brokers[0]
+		if err := broker.Open(saramaConfig); err != nil && err != sarama.ErrAlreadyConnected {
+			fmt.Fprintf(w, "Broker:\t%v (unable to connect: %v)\n", broker.Addr(), err)
+			return
+		}
+		resp, err := broker.ApiVersions(&sarama.ApiVersionsRequest{})
+		if err != nil {
+			fmt.Fprintf(w, "Broker:\t%v (API versions unavailable: %v)\n", broker.Addr(), err)
+			return
+		}
+
+		fmt.Fprintf(w, "Broker:\t%v\n", broker.Addr())
+		fmt.Fprintf(w, "Client Protocol Version:\t%v\n", saramaConfig.Version)
+		fmt.Fprintf(w, "Supported APIs:\t%v\n", len(resp.ApiKeys))
+		w.Flush()
+
+		sort.Slice(resp.ApiKeys, func(i, j int) bool { return resp.ApiKeys[i].ApiKey < resp.ApiKeys[j].ApiKey })
+
+		w.Init(outWriter, tabwriterMinWidthNested, 4, 2, tabwriterPadChar, tabwriterFlags)
+		fmt.Fprintf(w, "\tAPI\tMin Version\tMax Version\t\n")
+		fmt.Fprintf(w, "\t---\t-----------\t-----------\t\n")
+		for _, key := range resp.ApiKeys {
+			name, ok := apiKeyNames[key.ApiKey]
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(w, "\t%v\t%v\t%v\t\n", name, key.MinVersion, key.MaxVersion)
+		}
+		w.Flush()
+	},
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersion(t *testing.T) {
+	out := runCmdWithBroker(t, nil, "version")
+	require.Contains(t, out, "Go Version:")
+	require.Contains(t, out, "Metadata")
+}
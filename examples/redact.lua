@@ -0,0 +1,27 @@
+-- Use with kaf consume --transform examples/redact.lua or
+-- kaf produce --transform examples/redact.lua.
+--
+-- transform receives a record with topic, key, value, headers, a list of
+-- tables with key and value, and header, a map of header keys to values.
+-- Consumed records also have partition and offset. Return the changed
+-- record, or nil to skip it.
+function transform(record)
+  -- Skip heartbeats.
+  if string.find(record.value, '"type":"heartbeat"', 1, true) then
+    return nil
+  end
+
+  -- Redact email addresses.
+  record.value = string.gsub(record.value, "[%w%.%-_]+@[%w%.%-]+", "<redacted>")
+
+  -- Drop authorization headers.
+  local headers = {}
+  for _, header in ipairs(record.headers) do
+    if header.key ~= "authorization" then
+      table.insert(headers, header)
+    end
+  end
+  record.headers = headers
+
+  return record
+end
@@ -0,0 +1,22 @@
+-- Use with kaf requeue orders --transform examples/retry-headers.lua.
+--
+-- record.header maps header keys to the value of their first header. Set a
+-- key to add or replace the header, set it to nil to remove it. The list in
+-- record.headers still works for records with repeated header keys.
+function transform(record)
+  -- Keep where the record came from on its first requeue.
+  if record.header["origin-topic"] == nil then
+    record.header["origin-topic"] = record.topic
+    record.header["origin-offset"] = tostring(record.offset)
+  end
+
+  -- Bump an application retry counter next to kaf-requeue-count.
+  local retries = tonumber(record.header["retry-count"] or "0") or 0
+  record.header["retry-count"] = tostring(retries + 1)
+
+  -- Drop records the application marked as poison.
+  if record.header["poison"] == "true" then
+    return nil
+  end
+  return record
+end
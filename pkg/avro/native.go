@@ -0,0 +1,332 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var primitiveTypes = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// NativeJSON returns the JSON representation of a native goavro datum of
+// schema without the Avro JSON encoding details: unions are collapsed to
+// their value, timestamps and dates are rendered as RFC 3339 strings, times
+// of day as HH:MM:SS.ffffff, decimals as decimal strings and bytes and fixed
+// values as base64. Record fields keep the order of the schema.
+//
+// Unlike the Avro JSON encoding, the result cannot be decoded with the schema
+// again, as union branches are lost.
+func NativeJSON(schema string, native interface{}) ([]byte, error) {
+	var s interface{}
+	if err := json.Unmarshal([]byte(schema), &s); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	c := &nativeConverter{named: make(map[string]namedType)}
+	c.collectNames(s, "")
+	v, err := c.convert(s, "", native)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+type namedType struct {
+	schema    map[string]interface{}
+	namespace string
+}
+
+type nativeConverter struct {
+	named map[string]namedType
+}
+
+// fullName returns the full name and namespace of a named type.
+func fullName(schema map[string]interface{}, enclosing string) (string, string) {
+	name, _ := schema["name"].(string)
+	if strings.Contains(name, ".") {
+		return name, name[:strings.LastIndex(name, ".")]
+	}
+	namespace := enclosing
+	if ns, ok := schema["namespace"].(string); ok {
+		namespace = ns
+	}
+	if namespace == "" {
+		return name, ""
+	}
+	return namespace + "." + name, namespace
+}
+
+// collectNames registers all named types, which may be referenced before
+// their definition is reached in a value.
+func (c *nativeConverter) collectNames(schema interface{}, namespace string) {
+	switch s := schema.(type) {
+	case []interface{}:
+		for _, branch := range s {
+			c.collectNames(branch, namespace)
+		}
+	case map[string]interface{}:
+		switch s["type"] {
+		case "record", "error", "enum", "fixed":
+			name, ns := fullName(s, namespace)
+			c.named[name] = namedType{schema: s, namespace: ns}
+			fields, _ := s["fields"].([]interface{})
+			for _, f := range fields {
+				if field, ok := f.(map[string]interface{}); ok {
+					c.collectNames(field["type"], ns)
+				}
+			}
+		case "array":
+			c.collectNames(s["items"], namespace)
+		case "map":
+			c.collectNames(s["values"], namespace)
+		default:
+			c.collectNames(s["type"], namespace)
+		}
+	}
+}
+
+func (c *nativeConverter) lookup(name string, namespace string) (namedType, bool) {
+	if namespace != "" && !strings.Contains(name, ".") {
+		if t, ok := c.named[namespace+"."+name]; ok {
+			return t, true
+		}
+	}
+	t, ok := c.named[name]
+	return t, ok
+}
+
+func (c *nativeConverter) convert(schema interface{}, namespace string, v interface{}) (interface{}, error) {
+	switch s := schema.(type) {
+	case string:
+		if primitiveTypes[s] {
+			return convertPrimitive(v, nil), nil
+		}
+		t, ok := c.lookup(s, namespace)
+		if !ok {
+			return nil, fmt.Errorf("unknown type %q", s)
+		}
+		return c.convert(t.schema, t.namespace, v)
+	case []interface{}:
+		return c.convertUnion(s, namespace, v)
+	case map[string]interface{}:
+		return c.convertComplex(s, namespace, v)
+	default:
+		return nil, fmt.Errorf("invalid schema %v", schema)
+	}
+}
+
+// convertUnion unwraps the single entry map goavro uses for non-null union
+// values, keyed by the name of the branch.
+func (c *nativeConverter) convertUnion(branches []interface{}, namespace string, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return nil, fmt.Errorf("expected a union value, got %T", v)
+	}
+	for name, inner := range m {
+		var nonNull []interface{}
+		for _, branch := range branches {
+			if branch == "null" {
+				continue
+			}
+			nonNull = append(nonNull, branch)
+			for _, candidate := range c.branchNames(branch, namespace) {
+				if candidate == name {
+					return c.convert(branch, namespace, inner)
+				}
+			}
+		}
+		if len(nonNull) == 1 {
+			return c.convert(nonNull[0], namespace, inner)
+		}
+		return nil, fmt.Errorf("union has no branch %q", name)
+	}
+	return nil, nil
+}
+
+// branchNames returns the names goavro may use for a union branch.
+func (c *nativeConverter) branchNames(branch interface{}, namespace string) []string {
+	switch b := branch.(type) {
+	case string:
+		if primitiveTypes[b] {
+			return []string{b}
+		}
+		if namespace != "" && !strings.Contains(b, ".") {
+			return []string{namespace + "." + b, b}
+		}
+		return []string{b}
+	case map[string]interface{}:
+		switch t := b["type"].(type) {
+		case string:
+			switch t {
+			case "record", "error", "enum", "fixed":
+				name, _ := fullName(b, namespace)
+				return []string{name}
+			}
+			if lt, ok := b["logicalType"].(string); ok {
+				return []string{t + "." + lt, t}
+			}
+			return []string{t}
+		default:
+			return c.branchNames(t, namespace)
+		}
+	}
+	return nil
+}
+
+func (c *nativeConverter) convertComplex(s map[string]interface{}, namespace string, v interface{}) (interface{}, error) {
+	t, ok := s["type"].(string)
+	if !ok {
+		return c.convert(s["type"], namespace, v)
+	}
+	switch t {
+	case "record", "error":
+		_, ns := fullName(s, namespace)
+		record, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a record, got %T", v)
+		}
+		fields, _ := s["fields"].([]interface{})
+		obj := make(orderedObject, 0, len(fields))
+		for _, f := range fields {
+			field, _ := f.(map[string]interface{})
+			name, _ := field["name"].(string)
+			fv, err := c.convert(field["type"], ns, record[name])
+			if err != nil {
+				return nil, fmt.Errorf("field %v: %w", name, err)
+			}
+			obj = append(obj, orderedField{name: name, value: fv})
+		}
+		return obj, nil
+	case "enum":
+		return v, nil
+	case "fixed":
+		return convertPrimitive(v, s), nil
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an array, got %T", v)
+		}
+		out := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			converted, err := c.convert(s["items"], namespace, item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, converted)
+		}
+		return out, nil
+	case "map":
+		values, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a map, got %T", v)
+		}
+		out := make(map[string]interface{}, len(values))
+		for k, value := range values {
+			converted, err := c.convert(s["values"], namespace, value)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = converted
+		}
+		return out, nil
+	default:
+		if primitiveTypes[t] {
+			return convertPrimitive(v, s), nil
+		}
+		return c.convert(t, namespace, v)
+	}
+}
+
+// convertPrimitive renders the native values of logical types, bytes and
+// floats JSON cannot represent. schema is the schema of a logical type or
+// fixed, if any.
+func convertPrimitive(v interface{}, schema map[string]interface{}) interface{} {
+	switch x := v.(type) {
+	case time.Time:
+		if schema != nil && schema["logicalType"] == "date" {
+			return x.UTC().Format("2006-01-02")
+		}
+		return x.UTC().Format(time.RFC3339Nano)
+	case time.Duration:
+		return formatTimeOfDay(x)
+	case *big.Rat:
+		scale := 0
+		if schema != nil {
+			if s, ok := schema["scale"].(float64); ok {
+				scale = int(s)
+			}
+		}
+		return x.FloatString(scale)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(x)
+	case float32:
+		return convertFloat(float64(x))
+	case float64:
+		return convertFloat(x)
+	}
+	return v
+}
+
+func convertFloat(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
+
+// formatTimeOfDay formats a time-millis or time-micros value.
+func formatTimeOfDay(d time.Duration) string {
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+	d -= s * time.Second
+	return fmt.Sprintf("%02d:%02d:%02d.%06d", h, m, s, d/time.Microsecond)
+}
+
+type orderedField struct {
+	name  string
+	value interface{}
+}
+
+// orderedObject is a JSON object with the fields in order.
+type orderedObject []orderedField
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(f.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,59 @@
+package avro
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+)
+
+const paymentSchema = `{
+  "type": "record",
+  "name": "Payment",
+  "namespace": "com.example",
+  "fields": [
+    {"name": "id", "type": {"type": "string", "logicalType": "uuid"}},
+    {"name": "amount", "type": {"type": "bytes", "logicalType": "decimal", "precision": 10, "scale": 2}},
+    {"name": "created", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "day", "type": {"type": "int", "logicalType": "date"}},
+    {"name": "at", "type": {"type": "int", "logicalType": "time-millis"}},
+    {"name": "note", "type": ["null", "string"]},
+    {"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["OK", "FAILED"]}},
+    {"name": "previous", "type": ["null", "Status"]},
+    {"name": "refund", "type": ["null", {"type": "long", "logicalType": "timestamp-millis"}, "string"]},
+    {"name": "tags", "type": {"type": "map", "values": ["null", "long"]}}
+  ]
+}`
+
+func TestNativeJSON(t *testing.T) {
+	codec, err := goavro.NewCodec(paymentSchema)
+	require.NoError(t, err)
+
+	textual := []byte(`{"id": "6f0f2f8e-4ab1-4f5b-9f43-4f5e0c7b5a11", "amount": "\u0000", "created": 1700000000123, "day": 19675, "at": 3723004,
+		"note": {"string": "first"}, "status": "OK", "previous": {"com.example.Status": "FAILED"},
+		"refund": {"long.timestamp-millis": 1700000000000}, "tags": {"a": {"long": 1}, "b": null}}`)
+	native, _, err := codec.NativeFromTextual(textual)
+	require.NoError(t, err)
+	native.(map[string]interface{})["amount"] = big.NewRat(1234, 100)
+	binary, err := codec.BinaryFromNative(nil, native)
+	require.NoError(t, err)
+	native, _, err = codec.NativeFromBinary(binary)
+	require.NoError(t, err)
+
+	out, err := NativeJSON(codec.Schema(), native)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"id": "6f0f2f8e-4ab1-4f5b-9f43-4f5e0c7b5a11",
+		"amount": "12.34",
+		"created": "2023-11-14T22:13:20.123Z",
+		"day": "2023-11-14",
+		"at": "01:02:03.004000",
+		"note": "first",
+		"status": "OK",
+		"previous": "FAILED",
+		"refund": "2023-11-14T22:13:20Z",
+		"tags": {"a": 1, "b": null}
+	}`, string(out))
+	require.Regexp(t, `^\{"id":.*"amount":.*"created":`, string(out), "fields keep the schema order")
+}
@@ -3,6 +3,8 @@ package avro
 import (
 	"encoding/base64"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"net/http"
 	"sync"
 
@@ -10,6 +12,9 @@ import (
 	"github.com/linkedin/goavro/v2"
 )
 
+// ErrSchemaNotFound is returned for schema IDs unknown to the registry.
+var ErrSchemaNotFound = errors.New("schema not found in the registry")
+
 type cachedCodec struct {
 	done  chan struct{}
 	codec *goavro.Codec
@@ -21,6 +26,10 @@ type cachedCodec struct {
 type SchemaCache struct {
 	client *schemaregistry.Client
 
+	// NativeJSON makes DecodeMessage return plain JSON as by NativeJSON
+	// instead of the Avro JSON encoding.
+	NativeJSON bool
+
 	mu               sync.RWMutex
 	codecsBySchemaID map[int]*cachedCodec
 }
@@ -95,6 +104,9 @@ func (c *SchemaCache) getCodecForSchemaID(schemaID int) (codec *goavro.Codec, er
 	}()
 
 	schema, err := c.client.GetSchemaById(schemaID)
+	if schemaregistry.IsSchemaNotFound(err) {
+		return nil, fmt.Errorf("schema %d: %w", schemaID, ErrSchemaNotFound)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -107,6 +119,11 @@ func (c *SchemaCache) getCodecForSchemaID(schemaID int) (codec *goavro.Codec, er
 	return codec, nil
 }
 
+// CodecForSchemaID returns the codec of the schema registered with schemaID.
+func (c *SchemaCache) CodecForSchemaID(schemaID int) (*goavro.Codec, error) {
+	return c.getCodecForSchemaID(schemaID)
+}
+
 // DecodeMessage returns a text representation of an Avro-encoded message.
 func (c *SchemaCache) DecodeMessage(b []byte) (message []byte, err error) {
 	// Ensure avro header is present with the magic start-byte.
@@ -128,6 +145,14 @@ func (c *SchemaCache) DecodeMessage(b []byte) (message []byte, err error) {
 		return b, err
 	}
 
+	if c.NativeJSON {
+		message, err = NativeJSON(codec.Schema(), native)
+		if err != nil {
+			return b, err
+		}
+		return message, nil
+	}
+
 	// Convert native Go form to textual Avro data
 	message, err = codec.TextualFromNative(nil, native)
 	if err != nil {
@@ -163,3 +188,12 @@ func (c *SchemaCache) EncodeMessage(schemaID int, json []byte) (message []byte,
 
 	return message, nil
 }
+
+// LatestSchema returns the latest schema registered for subject.
+func (c *SchemaCache) LatestSchema(subject string) (string, error) {
+	schema, err := c.client.GetLatestSchema(subject)
+	if err != nil {
+		return "", err
+	}
+	return schema.Schema, nil
+}
@@ -2,8 +2,11 @@ package config
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	homedir "github.com/mitchellh/go-homedir"
 	yaml "gopkg.in/yaml.v2"
@@ -20,6 +23,22 @@ type SASL struct {
 	Token        string   `yaml:"token"`
 	Version      int16    `yaml:"version"`
 	Profile      string   `yaml:"profile"`
+	// Audience is sent as the audience parameter of the token request, for
+	// identity providers issuing tokens per resource.
+	Audience string `yaml:"audience,omitempty"`
+	// InsecurePlaintext allows the PLAIN mechanism without TLS, which sends
+	// the password in clear text.
+	InsecurePlaintext bool `yaml:"insecure-plaintext,omitempty"`
+	// Kerberos settings of the GSSAPI mechanism. Username is the principal,
+	// which authenticates with KerberosKeytab if set and Password otherwise.
+	// KerberosConfig is the path of a krb5.conf, defaulting to
+	// /etc/krb5.conf unless KerberosKDC is set.
+	KerberosServiceName     string `yaml:"kerberosServiceName,omitempty"`
+	KerberosRealm           string `yaml:"kerberosRealm,omitempty"`
+	KerberosKeytab          string `yaml:"kerberosKeytab,omitempty"`
+	KerberosConfig          string `yaml:"kerberosConfig,omitempty"`
+	KerberosKDC             string `yaml:"kerberosKDC,omitempty"`
+	KerberosDisablePAFXFAST bool   `yaml:"kerberosDisablePAFXFAST,omitempty"`
 }
 
 type TLS struct {
@@ -34,6 +53,14 @@ type SchemaRegistryCredentials struct {
 	Password string `yaml:"password"`
 }
 
+// SchemaRegistry is an additional schema registry of a cluster, used for
+// all subjects starting with SubjectPrefix.
+type SchemaRegistry struct {
+	URL           string                     `yaml:"url"`
+	SubjectPrefix string                     `yaml:"subject-prefix"`
+	Credentials   *SchemaRegistryCredentials `yaml:"credentials"`
+}
+
 type Cluster struct {
 	Name                      string
 	Version                   string                     `yaml:"version"`
@@ -43,12 +70,91 @@ type Cluster struct {
 	SecurityProtocol          string                     `yaml:"security-protocol"`
 	SchemaRegistryURL         string                     `yaml:"schema-registry-url"`
 	SchemaRegistryCredentials *SchemaRegistryCredentials `yaml:"schema-registry-credentials"`
+	SchemaRegistries          []*SchemaRegistry          `yaml:"schema-registries,omitempty"`
+	// ClientID is sent to the brokers to attribute requests, metrics and
+	// quotas. Defaults to kaf and its version.
+	ClientID string `yaml:"client-id,omitempty"`
+	// RackID enables fetching from the closest replica in the same rack, it
+	// requires Kafka 2.4 or later with a broker replica.selector.class.
+	RackID string `yaml:"rack-id,omitempty"`
+	// DialTimeout, KeepAlive, ReadTimeout and WriteTimeout of broker
+	// connections, like 10s. Zero uses the sarama defaults.
+	DialTimeout  time.Duration `yaml:"dial-timeout,omitempty"`
+	KeepAlive    time.Duration `yaml:"keep-alive,omitempty"`
+	ReadTimeout  time.Duration `yaml:"read-timeout,omitempty"`
+	WriteTimeout time.Duration `yaml:"write-timeout,omitempty"`
+	// ProxyCommand is a shell command connecting to a broker, like the
+	// ProxyCommand of ssh. Its stdin and stdout are used as the connection,
+	// %h and %p are replaced by host and port of the broker.
+	ProxyCommand string `yaml:"proxy-command,omitempty"`
+	// FetchMinBytes, FetchMaxBytes and FetchMaxWait are the consumer fetch
+	// defaults of the cluster, see kaf consume --fetch-min-bytes. Zero uses
+	// the sarama defaults.
+	FetchMinBytes int32         `yaml:"fetch-min-bytes,omitempty"`
+	FetchMaxBytes int32         `yaml:"fetch-max-bytes,omitempty"`
+	FetchMaxWait  time.Duration `yaml:"fetch-max-wait,omitempty"`
+	// MetadataRefreshFrequency is how often metadata of the whole cluster
+	// is refreshed in the background. MetadataRetryMax and
+	// MetadataRetryBackoff control how often and how long apart metadata
+	// requests are retried while the cluster has no leader for a partition,
+	// e.g. during a controller change or a rolling restart. Unset values use
+	// the kaf defaults of 10m, 5 retries and 500ms.
+	MetadataRefreshFrequency time.Duration `yaml:"metadata-refresh-frequency,omitempty"`
+	MetadataRetryMax         *int          `yaml:"metadata-retry-max,omitempty"`
+	MetadataRetryBackoff     time.Duration `yaml:"metadata-retry-backoff,omitempty"`
+	// Labels group clusters, like env: prod, for commands run across all
+	// clusters matching --cluster-selector.
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+// SchemaRegistryForSubject returns the schema registry responsible for a
+// subject. The registry with the longest matching subject prefix wins, the
+// registry configured with schema-registry-url is the default. Nil is
+// returned if no registry is configured for the subject.
+func (c *Cluster) SchemaRegistryForSubject(subject string) *SchemaRegistry {
+	var match *SchemaRegistry
+	for _, registry := range c.SchemaRegistries {
+		if !strings.HasPrefix(subject, registry.SubjectPrefix) {
+			continue
+		}
+		if match == nil || len(registry.SubjectPrefix) > len(match.SubjectPrefix) {
+			match = registry
+		}
+	}
+	if match != nil {
+		return match
+	}
+
+	if c.SchemaRegistryURL == "" {
+		return nil
+	}
+	return &SchemaRegistry{
+		URL:         c.SchemaRegistryURL,
+		Credentials: c.SchemaRegistryCredentials,
+	}
 }
 
+// EnvConfigPath is the environment variable naming the config file used when
+// no path is passed to ReadConfig.
+const EnvConfigPath = "KAF_CONFIG"
+
 type Config struct {
 	CurrentCluster  string `yaml:"current-cluster"`
 	ClusterOverride string
 	Clusters        []*Cluster `yaml:"clusters"`
+
+	// path is the file the config was read from, Write writes to it.
+	path string
+	// readOnly is set for configs read from stdin or a URL.
+	readOnly bool
+}
+
+// Path returns the file the config is read from and written to.
+func (c *Config) Path() string {
+	if c.path == "" {
+		return getDefaultConfigPath()
+	}
+	return c.path
 }
 
 func (c *Config) SetCurrentCluster(name string) error {
@@ -100,19 +206,19 @@ func (c *Config) ActiveCluster() *Cluster {
 	return nil
 }
 
+// Write writes the config back to the file it was read from.
 func (c *Config) Write() error {
-	home, err := homedir.Dir()
-	if err != nil {
+	if c.readOnly {
+		return fmt.Errorf("the config was read from %v and cannot be changed, edit it at its source", redactURL(c.path))
+	}
+	configPath := c.Path()
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
 		return err
 	}
 
-	configDir := filepath.Join(home, ".kaf")
-	_ = os.MkdirAll(configDir, 0755)
-	configPath := filepath.Join(configDir, "config")
-
 	file, err := os.OpenFile(configPath, os.O_TRUNC|os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer file.Close()
 
@@ -120,36 +226,42 @@ func (c *Config) Write() error {
 	return encoder.Encode(&c)
 }
 
+// ReadConfig reads the config file at cfgPath. Without a path, the file named
+// by KAF_CONFIG is read, or $HOME/.kaf/config. A missing file is an empty
+// config, which Write creates at that path. A path of - reads the config from
+// stdin and an http or https URL fetches it, such configs cannot be written.
 func ReadConfig(cfgPath string) (c Config, err error) {
-	file, err := os.OpenFile(getConfigPath(cfgPath), os.O_RDONLY, 0644)
+	path := getConfigPath(cfgPath)
+	if isReadOnlyPath(path) {
+		return readReadOnlyConfig(path)
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY, 0644)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return Config{}, nil
+			return Config{path: path}, nil
 		}
 		return Config{}, err
 	}
 	defer file.Close()
 	decoder := yaml.NewDecoder(file)
 	err = decoder.Decode(&c)
-	if err != nil {
+	if err != nil && err != io.EOF {
 		return Config{}, err
 	}
+	c.path = path
 	return c, nil
 }
 
-func fileExists(filename string) bool {
-	info, err := os.Stat(filename)
-	if os.IsNotExist(err) {
-		return false
-	}
-	return !info.IsDir()
-}
-
+// getConfigPath returns the config file to use, in order of precedence the
+// --config flag, KAF_CONFIG and the default path.
 func getConfigPath(cfgPath string) string {
-	if !fileExists(cfgPath) {
-		return getDefaultConfigPath()
+	if cfgPath != "" {
+		return cfgPath
+	}
+	if env := os.Getenv(EnvConfigPath); env != "" {
+		return env
 	}
-	return cfgPath
+	return getDefaultConfigPath()
 }
 
 func getDefaultConfigPath() string {
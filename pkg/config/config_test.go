@@ -0,0 +1,162 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaRegistryForSubject(t *testing.T) {
+	cluster := &Cluster{
+		SchemaRegistryURL: "http://default",
+		SchemaRegistries: []*SchemaRegistry{
+			{URL: "http://payments", SubjectPrefix: "payments."},
+			{URL: "http://payments-eu", SubjectPrefix: "payments.eu."},
+		},
+	}
+
+	require.Equal(t, "http://payments", cluster.SchemaRegistryForSubject("payments.us-value").URL)
+	require.Equal(t, "http://payments-eu", cluster.SchemaRegistryForSubject("payments.eu.orders-value").URL)
+	require.Equal(t, "http://default", cluster.SchemaRegistryForSubject("orders-value").URL)
+
+	cluster.SchemaRegistryURL = ""
+	require.Nil(t, cluster.SchemaRegistryForSubject("orders-value"))
+}
+
+func TestReadConfigPath(t *testing.T) {
+	dir := t.TempDir()
+	flagPath := filepath.Join(dir, "flag.yaml")
+	envPath := filepath.Join(dir, "env.yaml")
+	require.NoError(t, os.WriteFile(envPath, []byte("current-cluster: staging\n"), 0644))
+	t.Setenv(EnvConfigPath, envPath)
+
+	c, err := ReadConfig("")
+	require.NoError(t, err)
+	require.Equal(t, envPath, c.Path())
+	require.Equal(t, "staging", c.CurrentCluster)
+
+	// The flag takes precedence, a missing file is created on write.
+	c, err = ReadConfig(flagPath)
+	require.NoError(t, err)
+	require.Equal(t, flagPath, c.Path())
+	require.Empty(t, c.Clusters)
+
+	c.Clusters = append(c.Clusters, &Cluster{Name: "prod", Brokers: []string{"localhost:9092"}})
+	require.NoError(t, c.SetCurrentCluster("prod"))
+
+	c, err = ReadConfig(flagPath)
+	require.NoError(t, err)
+	require.Equal(t, "prod", c.CurrentCluster)
+	require.Len(t, c.Clusters, 1)
+
+	c, err = ReadConfig("")
+	require.NoError(t, err)
+	require.Equal(t, "staging", c.CurrentCluster)
+}
+
+func TestReadConfigNetSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(`clusters:
+- name: tunnel
+  brokers:
+  - kafka-1:9092
+  dial-timeout: 5s
+  keep-alive: 1m
+  read-timeout: 30s
+  write-timeout: 30s
+  proxy-command: ssh -W %h:%p bastion
+  fetch-max-bytes: 8388608
+  fetch-max-wait: 1s
+  metadata-refresh-frequency: 1m
+  metadata-retry-max: 10
+  metadata-retry-backoff: 1s
+`), 0644))
+
+	c, err := ReadConfig(path)
+	require.NoError(t, err)
+	cluster := c.Clusters[0]
+	require.Equal(t, 5*time.Second, cluster.DialTimeout)
+	require.Equal(t, time.Minute, cluster.KeepAlive)
+	require.Equal(t, 30*time.Second, cluster.ReadTimeout)
+	require.Equal(t, 30*time.Second, cluster.WriteTimeout)
+	require.Equal(t, "ssh -W %h:%p bastion", cluster.ProxyCommand)
+	require.Equal(t, int32(8388608), cluster.FetchMaxBytes)
+	require.Equal(t, time.Second, cluster.FetchMaxWait)
+	require.Equal(t, time.Minute, cluster.MetadataRefreshFrequency)
+	require.Equal(t, 10, *cluster.MetadataRetryMax)
+	require.Equal(t, time.Second, cluster.MetadataRetryBackoff)
+}
+
+func TestReadConfigStdin(t *testing.T) {
+	stdin = strings.NewReader("current-cluster: ci\nclusters:\n- name: ci\n  brokers: [kafka:9092]\n")
+	defer func() { stdin = os.Stdin }()
+
+	c, err := ReadConfig(StdinPath)
+	require.NoError(t, err)
+	require.Equal(t, []string{"kafka:9092"}, c.ActiveCluster().Brokers)
+	require.EqualError(t, c.SetCurrentCluster("ci"), "the config was read from - and cannot be changed, edit it at its source")
+
+	stdin = strings.NewReader("clusters:\n- name: ci\n  brokerz: [kafka:9092]\n")
+	_, err = ReadConfig(StdinPath)
+	require.Error(t, err)
+}
+
+func TestReadConfigURL(t *testing.T) {
+	var fetches int
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		user, pass, _ := r.BasicAuth()
+		switch {
+		case r.URL.Path == "/redirect":
+			http.Redirect(w, r, "http://"+r.Host+"/kaf.yaml", http.StatusFound)
+		case r.URL.Path != "/kaf.yaml":
+			http.NotFound(w, r)
+		case user != "ci" || pass != "secret":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			_, _ = w.Write([]byte("current-cluster: prod\nclusters:\n- name: prod\n  brokers: [kafka:9092]\n"))
+		}
+	}))
+	defer server.Close()
+	defer func(transport http.RoundTripper) { remoteConfigClient.Transport = transport }(remoteConfigClient.Transport)
+	remoteConfigClient.Transport = server.Client().Transport
+	authURL := strings.Replace(server.URL, "https://", "https://ci:secret@", 1)
+
+	c, err := ReadConfig(authURL + "/kaf.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "prod", c.ActiveCluster().Name)
+	require.Error(t, c.Write())
+
+	// Every read fetches the config again.
+	_, err = ReadConfig(authURL + "/kaf.yaml")
+	require.NoError(t, err)
+	require.Equal(t, 2, fetches)
+
+	_, err = ReadConfig(server.URL + "/kaf.yaml")
+	require.EqualError(t, err, "unable to fetch config from "+server.URL+"/kaf.yaml: 401 Unauthorized")
+	_, err = ReadConfig(authURL + "/missing.yaml")
+	require.EqualError(t, err, "unable to fetch config from "+strings.Replace(server.URL, "https://", "https://***@", 1)+"/missing.yaml: 404 Not Found")
+
+	// Credentials and secrets are not fetched without TLS.
+	plainURL := strings.Replace(authURL, "https://", "http://", 1)
+	_, err = ReadConfig(plainURL + "/kaf.yaml")
+	require.EqualError(t, err, "refusing to fetch config from "+strings.Replace(server.URL, "https://", "http://***@", 1)+"/kaf.yaml, use an https URL")
+	require.Equal(t, 4, fetches)
+
+	// Nor after a redirect to http.
+	_, err = ReadConfig(authURL + "/redirect")
+	require.ErrorContains(t, err, "refusing redirect to http://")
+}
+
+func TestConfigValidate(t *testing.T) {
+	require.NoError(t, (&Config{}).validate())
+	require.EqualError(t, (&Config{Clusters: []*Cluster{{Name: "a"}, {Name: "a"}}}).validate(), "cluster a is defined twice")
+	require.EqualError(t, (&Config{Clusters: []*Cluster{{}}}).validate(), "cluster 1 has no name")
+	require.EqualError(t, (&Config{CurrentCluster: "b", Clusters: []*Cluster{{Name: "a"}}}).validate(), "current-cluster b is not defined")
+}
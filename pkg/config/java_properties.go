@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/magiconair/properties"
+)
+
+// ParseJavaClientProperties parses a Kafka Java client.properties file into a
+// cluster definition. Warnings are returned for settings that are present but
+// can not be represented in the kaf config.
+func ParseJavaClientProperties(path string) (cluster *Cluster, warnings []string, err error) {
+	p, err := properties.LoadFile(path, properties.UTF8)
+	if err != nil {
+		return nil, nil, err
+	}
+	props := p.Map()
+
+	servers, ok := props["bootstrap.servers"]
+	if !ok {
+		return nil, nil, fmt.Errorf("bootstrap.servers is missing")
+	}
+
+	cluster = &Cluster{}
+	for _, server := range strings.Split(servers, ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			cluster.Brokers = append(cluster.Brokers, server)
+		}
+	}
+
+	protocol := strings.ToUpper(props["security.protocol"])
+	switch protocol {
+	case "", "PLAINTEXT":
+	case "SSL":
+		cluster.TLS = &TLS{}
+	case "SASL_PLAINTEXT", "SASL_SSL":
+		cluster.SecurityProtocol = protocol
+	default:
+		return nil, nil, fmt.Errorf("unsupported security.protocol %q", protocol)
+	}
+
+	if strings.HasPrefix(protocol, "SASL_") {
+		mechanism := props["sasl.mechanism"]
+		if mechanism == "" {
+			mechanism = "GSSAPI"
+		}
+		cluster.SASL = &SASL{Mechanism: mechanism}
+
+		if jaas, ok := props["sasl.jaas.config"]; ok {
+			for _, word := range strings.Fields(jaas) {
+				if result, ok := extractValue("username", word); ok {
+					cluster.SASL.Username = result
+				}
+				if result, ok := extractValue("password", word); ok {
+					cluster.SASL.Password = result
+				}
+			}
+		}
+	}
+
+	if protocol == "SSL" || protocol == "SASL_SSL" {
+		if location, ok := props["ssl.truststore.location"]; ok {
+			if cluster.TLS == nil {
+				cluster.TLS = &TLS{}
+			}
+			cluster.TLS.Cafile = location
+			if t := props["ssl.truststore.type"]; !strings.EqualFold(t, "PEM") {
+				warnings = append(warnings, fmt.Sprintf("ssl.truststore.location must point to a PEM file, but ssl.truststore.type is %q", t))
+			}
+		}
+		if location, ok := props["ssl.keystore.location"]; ok {
+			if cluster.TLS == nil {
+				cluster.TLS = &TLS{}
+			}
+			// A PEM keystore contains both the certificate chain and the key.
+			cluster.TLS.Clientfile = location
+			cluster.TLS.Clientkeyfile = location
+			if t := props["ssl.keystore.type"]; !strings.EqualFold(t, "PEM") {
+				warnings = append(warnings, fmt.Sprintf("ssl.keystore.location must point to a PEM file, but ssl.keystore.type is %q", t))
+			}
+		}
+		if algorithm, ok := props["ssl.endpoint.identification.algorithm"]; ok && algorithm == "" {
+			if cluster.TLS == nil {
+				cluster.TLS = &TLS{}
+			}
+			cluster.TLS.Insecure = true
+			warnings = append(warnings, "hostname verification is disabled, imported as insecure TLS which also skips certificate verification")
+		}
+	}
+
+	for _, key := range []string{"ssl.truststore.password", "ssl.keystore.password", "ssl.key.password"} {
+		if _, ok := props[key]; ok {
+			warnings = append(warnings, fmt.Sprintf("%v is not supported and was ignored", key))
+		}
+	}
+
+	return cluster, warnings, nil
+}
+
+const maskedSecret = "********"
+
+// FormatJavaClientProperties renders a cluster definition as a Kafka Java
+// client.properties file. Secrets are masked unless showSecrets is set.
+// Warnings are returned for settings that have no Java client equivalent.
+func FormatJavaClientProperties(cluster *Cluster, showSecrets bool) (out string, warnings []string) {
+	var b strings.Builder
+	secret := func(s string) string {
+		if showSecrets || s == "" {
+			return s
+		}
+		return maskedSecret
+	}
+
+	fmt.Fprintf(&b, "bootstrap.servers=%v\n", strings.Join(cluster.Brokers, ","))
+
+	protocol := cluster.SecurityProtocol
+	if protocol == "" {
+		if cluster.TLS != nil {
+			protocol = "SSL"
+		} else {
+			protocol = "PLAINTEXT"
+		}
+	}
+	fmt.Fprintf(&b, "security.protocol=%v\n", protocol)
+
+	if cluster.SASL != nil && strings.HasPrefix(protocol, "SASL_") {
+		fmt.Fprintf(&b, "sasl.mechanism=%v\n", cluster.SASL.Mechanism)
+		switch cluster.SASL.Mechanism {
+		case "PLAIN":
+			fmt.Fprintf(&b, "sasl.jaas.config=org.apache.kafka.common.security.plain.PlainLoginModule required username=\"%v\" password=\"%v\";\n", cluster.SASL.Username, secret(cluster.SASL.Password))
+		case "SCRAM-SHA-256", "SCRAM-SHA-512":
+			fmt.Fprintf(&b, "sasl.jaas.config=org.apache.kafka.common.security.scram.ScramLoginModule required username=\"%v\" password=\"%v\";\n", cluster.SASL.Username, secret(cluster.SASL.Password))
+		case "OAUTHBEARER":
+			if cluster.SASL.TokenURL != "" {
+				fmt.Fprintf(&b, "sasl.oauthbearer.token.endpoint.url=%v\n", cluster.SASL.TokenURL)
+				fmt.Fprintf(&b, "sasl.login.callback.handler.class=org.apache.kafka.common.security.oauthbearer.secured.OAuthBearerLoginCallbackHandler\n")
+			}
+			fmt.Fprintf(&b, "sasl.jaas.config=org.apache.kafka.common.security.oauthbearer.OAuthBearerLoginModule required clientId=\"%v\" clientSecret=\"%v\";\n", cluster.SASL.ClientID, secret(cluster.SASL.ClientSecret))
+			if cluster.SASL.Token != "" {
+				warnings = append(warnings, "static OAUTHBEARER tokens can not be exported")
+			}
+			if cluster.SASL.Audience != "" {
+				warnings = append(warnings, "the OAUTHBEARER audience can not be exported")
+			}
+		default:
+			warnings = append(warnings, fmt.Sprintf("JAAS config for SASL mechanism %v can not be exported", cluster.SASL.Mechanism))
+		}
+	}
+
+	if cluster.TLS != nil && (protocol == "SSL" || protocol == "SASL_SSL") {
+		if cluster.TLS.Cafile != "" {
+			fmt.Fprintf(&b, "ssl.truststore.type=PEM\n")
+			fmt.Fprintf(&b, "ssl.truststore.location=%v\n", cluster.TLS.Cafile)
+		}
+		if cluster.TLS.Clientfile != "" {
+			if cluster.TLS.Clientkeyfile != cluster.TLS.Clientfile {
+				warnings = append(warnings, "Java PEM keystores require certificate and key in one file, ssl.keystore.location points to the certificate only")
+			}
+			fmt.Fprintf(&b, "ssl.keystore.type=PEM\n")
+			fmt.Fprintf(&b, "ssl.keystore.location=%v\n", cluster.TLS.Clientfile)
+		}
+		if cluster.TLS.Insecure {
+			warnings = append(warnings, "insecure TLS can only be approximated by disabling hostname verification")
+			fmt.Fprintf(&b, "ssl.endpoint.identification.algorithm=\n")
+		}
+	}
+
+	return b.String(), warnings
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJavaClientPropertiesRoundTrip(t *testing.T) {
+	cluster := &Cluster{
+		Brokers:          []string{"a:9092", "b:9092"},
+		SecurityProtocol: "SASL_SSL",
+		SASL: &SASL{
+			Mechanism: "SCRAM-SHA-512",
+			Username:  "alice",
+			Password:  "secret",
+		},
+		TLS: &TLS{
+			Cafile:        "/etc/kafka/ca.pem",
+			Clientfile:    "/etc/kafka/client.pem",
+			Clientkeyfile: "/etc/kafka/client.pem",
+		},
+	}
+
+	out, warnings := FormatJavaClientProperties(cluster, true)
+	require.Empty(t, warnings)
+
+	path := filepath.Join(t.TempDir(), "client.properties")
+	require.NoError(t, os.WriteFile(path, []byte(out), 0600))
+
+	imported, warnings, err := ParseJavaClientProperties(path)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+	require.Equal(t, cluster, imported)
+
+	masked, _ := FormatJavaClientProperties(cluster, false)
+	require.NotContains(t, masked, "secret")
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Selector selects clusters by their labels. Like the equality based label
+// selectors of Kubernetes, it is a comma separated list of key=value,
+// key!=value and key requirements, which all have to match. A bare key
+// requires the label to be set.
+type Selector []requirement
+
+type requirement struct {
+	key   string
+	value string
+	// negate requires the label to differ from value, a missing label
+	// differs from every value.
+	negate bool
+	// exists only requires the label to be set.
+	exists bool
+}
+
+// ParseSelector parses a selector like env=prod,region!=us.
+func ParseSelector(s string) (Selector, error) {
+	var selector Selector
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("empty requirement in selector %q", s)
+		}
+		var r requirement
+		switch {
+		case strings.Contains(part, "!="):
+			i := strings.Index(part, "!=")
+			r = requirement{key: part[:i], value: part[i+2:], negate: true}
+		case strings.Contains(part, "="):
+			i := strings.Index(part, "=")
+			r = requirement{key: part[:i], value: part[i+1:]}
+		default:
+			r = requirement{key: part, exists: true}
+		}
+		r.key = strings.TrimSpace(r.key)
+		r.value = strings.TrimSpace(r.value)
+		if r.key == "" {
+			return nil, fmt.Errorf("requirement %q of selector %q has no label", part, s)
+		}
+		if strings.ContainsAny(r.value, "=!") {
+			return nil, fmt.Errorf("requirement %q of selector %q has an invalid value", part, s)
+		}
+		selector = append(selector, r)
+	}
+	return selector, nil
+}
+
+// Matches reports whether labels satisfy all requirements of the selector.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		value, ok := labels[r.key]
+		switch {
+		case r.exists && !ok:
+			return false
+		case r.negate && ok && value == r.value:
+			return false
+		case !r.exists && !r.negate && (!ok || value != r.value):
+			return false
+		}
+	}
+	return true
+}
+
+// SelectClusters returns the clusters matching selector, in config order.
+func (c *Config) SelectClusters(selector Selector) []*Cluster {
+	var clusters []*Cluster
+	for _, cluster := range c.Clusters {
+		if selector.Matches(cluster.Labels) {
+			clusters = append(clusters, cluster)
+		}
+	}
+	return clusters
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestParseSelector(t *testing.T) {
+	selector, err := ParseSelector("env=prod, region!=us,tier")
+	require.NoError(t, err)
+	require.Equal(t, Selector{
+		{key: "env", value: "prod"},
+		{key: "region", value: "us", negate: true},
+		{key: "tier", exists: true},
+	}, selector)
+
+	for _, s := range []string{"", "env=prod,", "=prod", "!=us", "env==prod", "env=prod=eu"} {
+		_, err := ParseSelector(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestSelectClusters(t *testing.T) {
+	var c Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+clusters:
+- name: prod-eu
+  labels: {env: prod, region: eu, tier: gold}
+- name: prod-us
+  labels: {env: prod, region: us}
+- name: staging
+  labels: {env: staging, region: eu}
+- name: local
+`), &c))
+
+	names := func(selector string) []string {
+		s, err := ParseSelector(selector)
+		require.NoError(t, err)
+		var names []string
+		for _, cluster := range c.SelectClusters(s) {
+			names = append(names, cluster.Name)
+		}
+		return names
+	}
+	require.Equal(t, []string{"prod-eu", "prod-us"}, names("env=prod"))
+	require.Equal(t, []string{"prod-eu"}, names("env=prod,region=eu"))
+	require.Equal(t, []string{"prod-eu", "staging", "local"}, names("region!=us"))
+	require.Equal(t, []string{"prod-eu"}, names("tier"))
+	require.Empty(t, names("env=dev"))
+}
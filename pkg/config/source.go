@@ -0,0 +1,128 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// StdinPath is the config path reading the config from stdin.
+const StdinPath = "-"
+
+// remoteConfigTimeout bounds fetching a config from a URL.
+const remoteConfigTimeout = 30 * time.Second
+
+// stdin is read for StdinPath, replaced in tests.
+var stdin io.Reader = os.Stdin
+
+// remoteConfigClient fetches configs from URLs, replaced in tests. Redirects
+// are only followed to https URLs.
+var remoteConfigClient = &http.Client{
+	Timeout: remoteConfigTimeout,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if req.URL.Scheme != "https" {
+			return fmt.Errorf("refusing redirect to %v, configs are only fetched over https", redactURL(req.URL.String()))
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	},
+}
+
+// isReadOnlyPath reports whether a config path is stdin or a URL, which are
+// read on every run and never written. http URLs are recognized to reject
+// them in fetchConfig.
+func isReadOnlyPath(path string) bool {
+	return path == StdinPath || strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://")
+}
+
+// readReadOnlyConfig reads the config from stdin or fetches it from a URL. It
+// is decoded strictly and validated, as it cannot be fixed with kaf config
+// commands.
+func readReadOnlyConfig(path string) (Config, error) {
+	var data []byte
+	var err error
+	if path == StdinPath {
+		data, err = ioutil.ReadAll(stdin)
+		if err != nil {
+			return Config{}, fmt.Errorf("unable to read config from stdin: %w", err)
+		}
+	} else {
+		data, err = fetchConfig(path)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	var c Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.SetStrict(true)
+	if err := decoder.Decode(&c); err != nil && err != io.EOF {
+		return Config{}, fmt.Errorf("invalid config from %v: %w", redactURL(path), err)
+	}
+	if err := c.validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config from %v: %w", redactURL(path), err)
+	}
+	c.path = path
+	c.readOnly = true
+	return c, nil
+}
+
+// fetchConfig gets the config at url. Credentials in the URL are sent with
+// basic authentication. The config is not cached. Only https is allowed, as
+// the credentials and the secrets and proxy-command of the config would be
+// readable and replaceable in transit otherwise.
+func fetchConfig(url string) ([]byte, error) {
+	if !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("refusing to fetch config from %v, use an https URL", redactURL(url))
+	}
+	resp, err := remoteConfigClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch config: %w", errors.Unwrap(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch config from %v: %v", redactURL(url), resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// redactURL removes credentials from a config URL for error messages.
+func redactURL(path string) string {
+	scheme, rest, ok := strings.Cut(path, "://")
+	if !ok {
+		return path
+	}
+	if at := strings.Index(rest, "@"); at >= 0 && at < strings.IndexAny(rest+"/", "/?#") {
+		rest = "***@" + rest[at+1:]
+	}
+	return scheme + "://" + rest
+}
+
+// validate checks that clusters have unique names and that the current
+// cluster exists.
+func (c *Config) validate() error {
+	names := make(map[string]bool, len(c.Clusters))
+	for i, cluster := range c.Clusters {
+		if cluster == nil || cluster.Name == "" {
+			return fmt.Errorf("cluster %v has no name", i+1)
+		}
+		if names[cluster.Name] {
+			return fmt.Errorf("cluster %v is defined twice", cluster.Name)
+		}
+		names[cluster.Name] = true
+	}
+	if c.CurrentCluster != "" && !names[c.CurrentCluster] {
+		return fmt.Errorf("current-cluster %v is not defined", c.CurrentCluster)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Package kaf provides the cluster connection, consume and produce logic of
+// the kaf CLI, including SASL, TLS and OAuth, for use in other Go programs.
+package kaf
+
+import (
+	"sync"
+
+	"github.com/IBM/sarama"
+
+	"github.com/birdayz/kaf/pkg/config"
+)
+
+// Client is a connection to a cluster configured like a kaf config cluster
+// entry.
+type Client struct {
+	Cluster *config.Cluster
+	Config  *sarama.Config
+
+	client sarama.Client
+
+	producerMu sync.Mutex
+	producer   sarama.SyncProducer
+}
+
+// NewClient connects to cluster.
+func NewClient(cluster *config.Cluster) (*Client, error) {
+	cfg, err := NewSaramaConfig(cluster)
+	if err != nil {
+		return nil, err
+	}
+	return NewClientFromConfig(cluster, cfg)
+}
+
+// NewClientFromConfig connects to cluster using a sarama configuration
+// created by NewSaramaConfig and adjusted by the caller.
+func NewClientFromConfig(cluster *config.Cluster, cfg *sarama.Config) (*Client, error) {
+	client, err := sarama.NewClient(cluster.Brokers, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		Cluster: cluster,
+		Config:  cfg,
+		client:  client,
+	}, nil
+}
+
+// Sarama returns the underlying sarama client.
+func (c *Client) Sarama() sarama.Client {
+	return c.client
+}
+
+// Admin returns a cluster admin sharing the connection of c. Closing it closes
+// c as well.
+func (c *Client) Admin() (sarama.ClusterAdmin, error) {
+	return sarama.NewClusterAdminFromClient(c.client)
+}
+
+// Close closes the producer of Produce and the connection to the cluster.
+func (c *Client) Close() error {
+	c.producerMu.Lock()
+	defer c.producerMu.Unlock()
+	if c.producer != nil {
+		if err := c.producer.Close(); err != nil {
+			c.client.Close()
+			return err
+		}
+		c.producer = nil
+	}
+	return c.client.Close()
+}
@@ -0,0 +1,65 @@
+package kaf
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+
+	"github.com/birdayz/kaf/pkg/config"
+)
+
+func TestNewClient(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetController(broker.BrokerID()).
+			SetBroker(broker.Addr(), broker.BrokerID()),
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+	})
+
+	cluster := &config.Cluster{Brokers: []string{broker.Addr()}, Version: "2.8.0"}
+	client, err := NewClient(cluster)
+	require.NoError(t, err)
+	require.Equal(t, cluster, client.Cluster)
+	require.Equal(t, DefaultClientID, client.Config.ClientID)
+	require.Len(t, client.Sarama().Brokers(), 1)
+
+	admin, err := client.Admin()
+	require.NoError(t, err)
+	// Closing the admin closes the shared client.
+	require.NoError(t, admin.Close())
+	require.True(t, client.Sarama().Closed())
+}
+
+func TestNewClientFromConfig(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()),
+	})
+
+	cluster := &config.Cluster{Brokers: []string{broker.Addr()}}
+	cfg, err := NewSaramaConfig(cluster)
+	require.NoError(t, err)
+	cfg.ClientID = "custom"
+
+	client, err := NewClientFromConfig(cluster, cfg)
+	require.NoError(t, err)
+	require.Equal(t, "custom", client.Config.ClientID)
+	require.NoError(t, client.Close())
+	require.True(t, client.Sarama().Closed())
+}
+
+func TestNewClientErrors(t *testing.T) {
+	_, err := NewClient(&config.Cluster{Brokers: []string{"localhost:9092"}, Version: "not-a-version"})
+	require.Error(t, err)
+
+	cluster := &config.Cluster{}
+	cfg, err := NewSaramaConfig(cluster)
+	require.NoError(t, err)
+	_, err = NewClientFromConfig(cluster, cfg)
+	require.Error(t, err)
+}
@@ -0,0 +1,200 @@
+package kaf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"github.com/birdayz/kaf/pkg/config"
+)
+
+// DefaultClientID is the client ID of clusters without a configured one.
+var DefaultClientID = "kaf"
+
+// Metadata retries of clusters without metadata-retry-max and
+// metadata-retry-backoff. They are higher than the sarama defaults so that
+// commands survive a controller change of a few seconds.
+const (
+	DefaultMetadataRetryMax     = 5
+	DefaultMetadataRetryBackoff = 500 * time.Millisecond
+)
+
+// NewSaramaConfig returns a sarama configuration to connect to cluster, with
+// version, client ID, rack, network timeouts, fetch sizes, metadata retries,
+// proxy command, TLS and SASL set up as configured.
+func NewSaramaConfig(cluster *config.Cluster) (*sarama.Config, error) {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Version = sarama.V1_1_0_0
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.ClientID = DefaultClientID
+
+	if cluster.Version != "" {
+		parsedVersion, err := sarama.ParseKafkaVersion(cluster.Version)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse Kafka version: %w", err)
+		}
+		saramaConfig.Version = parsedVersion
+	}
+	if cluster.ClientID != "" {
+		saramaConfig.ClientID = cluster.ClientID
+	}
+	if cluster.RackID != "" {
+		// Fetching from followers needs fetch request version 11.
+		if !saramaConfig.Version.IsAtLeast(sarama.V2_4_0_0) {
+			return nil, fmt.Errorf("rack-id requires Kafka version 2.4.0 or later, set version in the cluster config")
+		}
+		saramaConfig.RackID = cluster.RackID
+	}
+	if cluster.DialTimeout > 0 {
+		saramaConfig.Net.DialTimeout = cluster.DialTimeout
+	}
+	if cluster.KeepAlive > 0 {
+		saramaConfig.Net.KeepAlive = cluster.KeepAlive
+	}
+	if cluster.ReadTimeout > 0 {
+		saramaConfig.Net.ReadTimeout = cluster.ReadTimeout
+	}
+	if cluster.WriteTimeout > 0 {
+		saramaConfig.Net.WriteTimeout = cluster.WriteTimeout
+	}
+	if cluster.FetchMinBytes > 0 {
+		saramaConfig.Consumer.Fetch.Min = cluster.FetchMinBytes
+	}
+	if cluster.FetchMaxBytes > 0 {
+		saramaConfig.Consumer.Fetch.Default = cluster.FetchMaxBytes
+	}
+	if cluster.FetchMaxWait > 0 {
+		saramaConfig.Consumer.MaxWaitTime = cluster.FetchMaxWait
+	}
+	saramaConfig.Metadata.Retry.Max = DefaultMetadataRetryMax
+	saramaConfig.Metadata.Retry.Backoff = DefaultMetadataRetryBackoff
+	if cluster.MetadataRetryMax != nil {
+		if *cluster.MetadataRetryMax < 0 {
+			return nil, fmt.Errorf("metadata-retry-max must not be negative")
+		}
+		saramaConfig.Metadata.Retry.Max = *cluster.MetadataRetryMax
+	}
+	if cluster.MetadataRetryBackoff > 0 {
+		saramaConfig.Metadata.Retry.Backoff = cluster.MetadataRetryBackoff
+	}
+	if cluster.MetadataRefreshFrequency > 0 {
+		saramaConfig.Metadata.RefreshFrequency = cluster.MetadataRefreshFrequency
+	}
+	if cluster.ProxyCommand != "" {
+		saramaConfig.Net.Proxy.Enable = true
+		saramaConfig.Net.Proxy.Dialer = &commandDialer{command: cluster.ProxyCommand}
+	}
+	if cluster.SASL != nil {
+		saramaConfig.Net.SASL.Enable = true
+		if cluster.SASL.Mechanism != "OAUTHBEARER" {
+			saramaConfig.Net.SASL.User = cluster.SASL.Username
+			saramaConfig.Net.SASL.Password = cluster.SASL.Password
+		}
+		saramaConfig.Net.SASL.Version = cluster.SASL.Version
+	}
+	if cluster.TLS != nil && cluster.SecurityProtocol != "SASL_SSL" {
+		saramaConfig.Net.TLS.Enable = true
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: cluster.TLS.Insecure,
+		}
+
+		if cluster.TLS.Cafile != "" {
+			caCertPool, err := readCertPool(cluster.TLS.Cafile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = caCertPool
+		}
+
+		if cluster.TLS.Clientfile != "" && cluster.TLS.Clientkeyfile != "" {
+			clientCert, err := os.ReadFile(cluster.TLS.Clientfile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read Clientfile: %w", err)
+			}
+			clientKey, err := os.ReadFile(cluster.TLS.Clientkeyfile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read Clientkeyfile: %w", err)
+			}
+
+			cert, err := tls.X509KeyPair(clientCert, clientKey)
+			if err != nil {
+				return nil, fmt.Errorf("unable to create KeyPair: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+
+			// nolint
+			tlsConfig.BuildNameToCertificate()
+		}
+		saramaConfig.Net.TLS.Config = tlsConfig
+	}
+	if cluster.SecurityProtocol == "SASL_SSL" {
+		saramaConfig.Net.TLS.Enable = true
+		if cluster.TLS != nil {
+			tlsConfig := &tls.Config{
+				InsecureSkipVerify: cluster.TLS.Insecure,
+			}
+			if cluster.TLS.Cafile != "" {
+				caCertPool, err := readCertPool(cluster.TLS.Cafile)
+				if err != nil {
+					return nil, err
+				}
+				tlsConfig.RootCAs = caCertPool
+			}
+			saramaConfig.Net.TLS.Config = tlsConfig
+
+		} else {
+			saramaConfig.Net.TLS.Config = &tls.Config{InsecureSkipVerify: false}
+		}
+	}
+	if cluster.SecurityProtocol == "SASL_SSL" || cluster.SecurityProtocol == "SASL_PLAINTEXT" {
+		if cluster.SASL.Mechanism == "SCRAM-SHA-512" {
+			saramaConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &XDGSCRAMClient{HashGeneratorFcn: SHA512} }
+			saramaConfig.Net.SASL.Mechanism = sarama.SASLMechanism(sarama.SASLTypeSCRAMSHA512)
+		} else if cluster.SASL.Mechanism == "SCRAM-SHA-256" {
+			saramaConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &XDGSCRAMClient{HashGeneratorFcn: SHA256} }
+			saramaConfig.Net.SASL.Mechanism = sarama.SASLMechanism(sarama.SASLTypeSCRAMSHA256)
+		} else if cluster.SASL.Mechanism == "OAUTHBEARER" || cluster.SASL.Mechanism == "AWS_MSK_IAM" {
+			//Here setup get token function
+			tokenProvider, err := NewTokenProvider(cluster)
+			if err != nil {
+				return nil, err
+			}
+			saramaConfig.Net.SASL.Mechanism = sarama.SASLMechanism(sarama.SASLTypeOAuth)
+			saramaConfig.Net.SASL.TokenProvider = tokenProvider
+		} else if cluster.SASL.Mechanism == "GSSAPI" {
+			gssapi, err := newGSSAPIConfig(cluster.SASL)
+			if err != nil {
+				return nil, err
+			}
+			saramaConfig.Net.SASL.Mechanism = sarama.SASLMechanism(sarama.SASLTypeGSSAPI)
+			saramaConfig.Net.SASL.GSSAPI = gssapi
+		}
+	}
+	// Sarama uses PLAIN if no mechanism is set.
+	plain := saramaConfig.Net.SASL.Mechanism == "" || saramaConfig.Net.SASL.Mechanism == sarama.SASLTypePlaintext
+	if saramaConfig.Net.SASL.Enable && plain && !saramaConfig.Net.TLS.Enable && !cluster.SASL.InsecurePlaintext {
+		return nil, ErrPlainWithoutTLS
+	}
+	return saramaConfig, nil
+}
+
+// ErrPlainWithoutTLS is returned for clusters using the SASL PLAIN mechanism
+// without TLS, unless SASL.InsecurePlaintext is set.
+var ErrPlainWithoutTLS = errors.New("SASL mechanism PLAIN without TLS sends the password in clear text. " +
+	"Set security-protocol: SASL_SSL in the cluster config to use TLS, " +
+	"or set insecure-plaintext: true in the SASL config or pass --insecure-plaintext if the connection is trusted")
+
+func readCertPool(cafile string) (*x509.CertPool, error) {
+	caCert, err := os.ReadFile(cafile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read Cafile: %w", err)
+	}
+	caCertPool := x509.NewCertPool()
+	caCertPool.AppendCertsFromPEM(caCert)
+	return caCertPool, nil
+}
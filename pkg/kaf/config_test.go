@@ -0,0 +1,79 @@
+package kaf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+
+	"github.com/birdayz/kaf/pkg/config"
+)
+
+func TestNewSaramaConfig(t *testing.T) {
+	cfg, err := NewSaramaConfig(&config.Cluster{
+		Version:          "2.8.0",
+		SecurityProtocol: "SASL_SSL",
+		SASL: &config.SASL{
+			Mechanism: "SCRAM-SHA-512",
+			Username:  "user",
+			Password:  "secret",
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, sarama.V2_8_0_0, cfg.Version)
+	require.True(t, cfg.Net.TLS.Enable)
+	require.True(t, cfg.Net.SASL.Enable)
+	require.Equal(t, sarama.SASLMechanism(sarama.SASLTypeSCRAMSHA512), cfg.Net.SASL.Mechanism)
+	require.Equal(t, "user", cfg.Net.SASL.User)
+
+	require.Equal(t, DefaultClientID, cfg.ClientID)
+	require.Empty(t, cfg.RackID)
+
+	cfg, err = NewSaramaConfig(&config.Cluster{Version: "2.4.0", ClientID: "reporting", RackID: "eu-west-1a"})
+	require.NoError(t, err)
+	require.Equal(t, "reporting", cfg.ClientID)
+	require.Equal(t, "eu-west-1a", cfg.RackID)
+
+	_, err = NewSaramaConfig(&config.Cluster{RackID: "eu-west-1a"})
+	require.Error(t, err)
+
+	plain := &config.Cluster{
+		SecurityProtocol: "SASL_PLAINTEXT",
+		SASL:             &config.SASL{Mechanism: "PLAIN", Username: "user", Password: "secret"},
+	}
+	_, err = NewSaramaConfig(plain)
+	require.ErrorIs(t, err, ErrPlainWithoutTLS)
+	plain.SASL.InsecurePlaintext = true
+	_, err = NewSaramaConfig(plain)
+	require.NoError(t, err)
+
+	cfg, err = NewSaramaConfig(&config.Cluster{FetchMinBytes: 1024, FetchMaxBytes: 8 << 20, FetchMaxWait: time.Second})
+	require.NoError(t, err)
+	require.Equal(t, int32(1024), cfg.Consumer.Fetch.Min)
+	require.Equal(t, int32(8<<20), cfg.Consumer.Fetch.Default)
+	require.Equal(t, time.Second, cfg.Consumer.MaxWaitTime)
+
+	cfg, err = NewSaramaConfig(&config.Cluster{})
+	require.NoError(t, err)
+	require.Equal(t, DefaultMetadataRetryMax, cfg.Metadata.Retry.Max)
+	require.Equal(t, DefaultMetadataRetryBackoff, cfg.Metadata.Retry.Backoff)
+	require.Equal(t, 10*time.Minute, cfg.Metadata.RefreshFrequency)
+
+	noRetries := 0
+	cfg, err = NewSaramaConfig(&config.Cluster{MetadataRetryMax: &noRetries, MetadataRetryBackoff: 2 * time.Second, MetadataRefreshFrequency: time.Minute})
+	require.NoError(t, err)
+	require.Equal(t, 0, cfg.Metadata.Retry.Max)
+	require.Equal(t, 2*time.Second, cfg.Metadata.Retry.Backoff)
+	require.Equal(t, time.Minute, cfg.Metadata.RefreshFrequency)
+
+	negative := -1
+	_, err = NewSaramaConfig(&config.Cluster{MetadataRetryMax: &negative})
+	require.Error(t, err)
+
+	_, err = NewSaramaConfig(&config.Cluster{Version: "not-a-version"})
+	require.Error(t, err)
+
+	_, err = NewSaramaConfig(&config.Cluster{TLS: &config.TLS{Cafile: "/does/not/exist"}})
+	require.Error(t, err)
+}
@@ -0,0 +1,243 @@
+package kaf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// ErrStopPartition is returned by a ConsumeOptions.Handle function to stop
+// consuming the partition of the message without failing Consume.
+var ErrStopPartition = errors.New("stop consuming partition")
+
+// ConsumeOptions selects the messages consumed by Client.Consume.
+type ConsumeOptions struct {
+	Topic string
+	// Partitions to consume, all partitions of Topic if empty.
+	Partitions []int32
+	// Offset to start at, an absolute offset or sarama.OffsetOldest or
+	// sarama.OffsetNewest.
+	Offset int64
+	// StartOffset, if set, returns the offset to start a partition at from
+	// its oldest and newest offset, instead of Offset.
+	StartOffset func(partition int32, oldest, newest int64) (int64, error)
+	// Follow keeps consuming new messages after reaching the end of a
+	// partition.
+	Follow bool
+	// WaitAtEnd waits for a new message without Follow if a partition has no
+	// message after the start offset, instead of returning at once. Empty
+	// partitions are skipped without Follow either way.
+	WaitAtEnd bool
+	// Limit is the maximum number of messages per partition, 0 for no limit.
+	Limit int64
+	// EndIdleTimeout is how long a partition is waited on for its next
+	// message before it counts as read to the end without Follow, 0 for
+	// DefaultEndIdleTimeout. Transaction markers, and with read_committed the
+	// records of aborted transactions, are never delivered, so the offset
+	// before the end may not arrive.
+	EndIdleTimeout time.Duration
+	// Handle is called for every message. Partitions are consumed
+	// concurrently, so it must be safe for concurrent use. Returning
+	// ErrStopPartition stops the partition, other errors stop Consume.
+	Handle func(*sarama.ConsumerMessage) error
+}
+
+// DefaultEndIdleTimeout returns four times Consumer.MaxWaitTime, at least 5s.
+// Fetches at the end of a partition return without records after
+// Consumer.MaxWaitTime, fetches before it return at once.
+func DefaultEndIdleTimeout(cfg *sarama.Config) time.Duration {
+	timeout := 4 * cfg.Consumer.MaxWaitTime
+	if timeout < 5*time.Second {
+		timeout = 5 * time.Second
+	}
+	return timeout
+}
+
+// PartitionOffsets returns the oldest offset of a partition and the end a
+// consumer with the isolation level of client reads to. For read_committed
+// it is the last stable offset, records of open transactions are not
+// returned. Otherwise it is the high watermark.
+func PartitionOffsets(client sarama.Client, topic string, partition int32) (oldest int64, newest int64, err error) {
+	if client.Config().Consumer.IsolationLevel == sarama.ReadCommitted {
+		newest, err = lastStableOffset(client, topic, partition)
+	} else {
+		newest, err = client.GetOffset(topic, partition, sarama.OffsetNewest)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	oldest, err = client.GetOffset(topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return 0, 0, err
+	}
+	return oldest, newest, nil
+}
+
+// lastStableOffset asks the partition leader for the last stable offset,
+// sarama.Client.GetOffset always returns the high watermark.
+func lastStableOffset(client sarama.Client, topic string, partition int32) (int64, error) {
+	leader, err := client.Leader(topic, partition)
+	if err != nil {
+		return 0, err
+	}
+	req := &sarama.OffsetRequest{
+		Version:        2,
+		IsolationLevel: sarama.ReadCommitted,
+	}
+	req.AddBlock(topic, partition, sarama.OffsetNewest, 1)
+	resp, err := leader.GetAvailableOffsets(req)
+	if err != nil {
+		return 0, err
+	}
+	block := resp.GetBlock(topic, partition)
+	if block == nil {
+		return 0, sarama.ErrIncompleteResponse
+	}
+	if block.Err != sarama.ErrNoError {
+		return 0, block.Err
+	}
+	return block.Offset, nil
+}
+
+// Consume calls opts.Handle for every message of the partitions selected by
+// opts. Unless opts.Follow is set, Consume returns once every partition was
+// read to its end. It returns ctx.Err() if ctx is done first.
+func (c *Client) Consume(ctx context.Context, opts ConsumeOptions) error {
+	consumer, err := sarama.NewConsumerFromClient(c.client)
+	if err != nil {
+		return err
+	}
+	defer consumer.Close()
+
+	partitions := opts.Partitions
+	if len(partitions) == 0 {
+		partitions, err = consumer.Partitions(opts.Topic)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, len(partitions))
+	wg := sync.WaitGroup{}
+	for _, partition := range partitions {
+		wg.Add(1)
+		go func(partition int32) {
+			defer wg.Done()
+			if err := c.consumePartition(ctx, consumer, opts, partition); err != nil {
+				errs <- fmt.Errorf("partition %v: %w", partition, err)
+				// The other partitions stop as well.
+				cancel()
+			}
+		}(partition)
+	}
+	wg.Wait()
+	close(errs)
+
+	// The first error caused the cancellation of the others.
+	var first error
+	for err := range errs {
+		if first == nil || errors.Is(first, context.Canceled) {
+			first = err
+		}
+	}
+	return first
+}
+
+func (c *Client) consumePartition(ctx context.Context, consumer sarama.Consumer, opts ConsumeOptions, partition int32) error {
+	oldest, newest, err := PartitionOffsets(c.client, opts.Topic, partition)
+	if err != nil {
+		return err
+	}
+
+	start := opts.Offset
+	if opts.StartOffset != nil {
+		start, err = opts.StartOffset(partition, oldest, newest)
+		if err != nil {
+			return err
+		}
+	}
+	if !opts.Follow && newest == oldest {
+		return nil
+	}
+	if !opts.Follow && !opts.WaitAtEnd {
+		resolved := start
+		switch start {
+		case sarama.OffsetOldest:
+			resolved = oldest
+		case sarama.OffsetNewest:
+			resolved = newest
+		}
+		if resolved >= newest {
+			return nil
+		}
+	}
+
+	pc, err := consumer.ConsumePartition(opts.Topic, partition, start)
+	if err != nil {
+		return err
+	}
+	defer pc.Close()
+
+	// Without Follow a partition is done once no message arrived for the
+	// timeout, from the start unless WaitAtEnd waits for the first message.
+	var atEnd <-chan time.Time
+	var endTimer *time.Timer
+	endTimeout := opts.EndIdleTimeout
+	if endTimeout == 0 {
+		endTimeout = DefaultEndIdleTimeout(c.client.Config())
+	}
+	if !opts.Follow {
+		endTimer = time.NewTimer(endTimeout)
+		defer endTimer.Stop()
+		if !opts.WaitAtEnd {
+			atEnd = endTimer.C
+		}
+	}
+
+	var count int64
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-atEnd:
+			return nil
+		case err := <-pc.Errors():
+			return err
+		case msg := <-pc.Messages():
+			if endTimer != nil {
+				if !endTimer.Stop() {
+					select {
+					case <-endTimer.C:
+					default:
+					}
+				}
+				endTimer.Reset(endTimeout)
+				atEnd = endTimer.C
+			}
+			if err := opts.Handle(msg); err == ErrStopPartition {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			count++
+			if opts.Limit > 0 && count >= opts.Limit {
+				return nil
+			}
+			end := pc.HighWaterMarkOffset()
+			if c.client.Config().Consumer.IsolationLevel == sarama.ReadCommitted {
+				// Reads stop at the last stable offset, the high watermark
+				// is not reached while transactions are open.
+				end = newest
+			}
+			if !opts.Follow && msg.Offset+1 >= end {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package kaf
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+
+	"github.com/birdayz/kaf/pkg/config"
+)
+
+// newTopicClient returns a client of a mock broker leading partition 0 of
+// topic t. Its offsets are 0 to newest, messages hold the offsets with
+// records.
+func newTopicClient(t *testing.T, newest int64, messages ...int64) *Client {
+	broker := sarama.NewMockBroker(t, 1)
+	t.Cleanup(broker.Close)
+
+	fetch := sarama.NewMockFetchResponse(t, 1).SetHighWaterMark("t", 0, newest)
+	for _, offset := range messages {
+		fetch.SetMessage("t", 0, offset, sarama.StringEncoder("record"))
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("t", 0, broker.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("t", 0, sarama.OffsetOldest, 0).
+			SetOffset("t", 0, sarama.OffsetNewest, newest),
+		"FetchRequest":   fetch,
+		"ProduceRequest": sarama.NewMockProduceResponse(t),
+	})
+
+	cluster := &config.Cluster{Brokers: []string{broker.Addr()}}
+	cfg, err := NewSaramaConfig(cluster)
+	require.NoError(t, err)
+	client, err := NewClientFromConfig(cluster, cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// collect returns a Handle function recording the offsets of messages.
+func collect(offsets *[]int64) func(*sarama.ConsumerMessage) error {
+	var mu sync.Mutex
+	return func(msg *sarama.ConsumerMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		*offsets = append(*offsets, msg.Offset)
+		return nil
+	}
+}
+
+func TestConsume(t *testing.T) {
+	client := newTopicClient(t, 3, 0, 1, 2)
+
+	var offsets []int64
+	require.NoError(t, client.Consume(context.Background(), ConsumeOptions{Topic: "t", Offset: sarama.OffsetOldest, Handle: collect(&offsets)}))
+	require.Equal(t, []int64{0, 1, 2}, offsets)
+
+	offsets = nil
+	require.NoError(t, client.Consume(context.Background(), ConsumeOptions{Topic: "t", Offset: sarama.OffsetOldest, Limit: 2, Handle: collect(&offsets)}))
+	require.Equal(t, []int64{0, 1}, offsets)
+
+	offsets = nil
+	require.NoError(t, client.Consume(context.Background(), ConsumeOptions{
+		Topic: "t",
+		StartOffset: func(partition int32, oldest, newest int64) (int64, error) {
+			require.Equal(t, int64(0), oldest)
+			require.Equal(t, int64(3), newest)
+			return newest - 1, nil
+		},
+		Handle: collect(&offsets),
+	}))
+	require.Equal(t, []int64{2}, offsets)
+
+	// Nothing is consumed from the end without WaitAtEnd.
+	require.NoError(t, client.Consume(context.Background(), ConsumeOptions{Topic: "t", Offset: sarama.OffsetNewest, Handle: func(*sarama.ConsumerMessage) error {
+		t.Fatal("unexpected message")
+		return nil
+	}}))
+}
+
+func TestConsumeStop(t *testing.T) {
+	client := newTopicClient(t, 3, 0, 1, 2)
+
+	var offsets []int64
+	require.NoError(t, client.Consume(context.Background(), ConsumeOptions{Topic: "t", Offset: sarama.OffsetOldest, Handle: func(msg *sarama.ConsumerMessage) error {
+		offsets = append(offsets, msg.Offset)
+		return ErrStopPartition
+	}}))
+	require.Equal(t, []int64{0}, offsets)
+
+	failed := errors.New("failed")
+	err := client.Consume(context.Background(), ConsumeOptions{Topic: "t", Offset: sarama.OffsetOldest, Handle: func(msg *sarama.ConsumerMessage) error {
+		return failed
+	}})
+	require.ErrorIs(t, err, failed)
+	require.EqualError(t, err, "partition 0: failed")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = client.Consume(ctx, ConsumeOptions{Topic: "t", Offset: sarama.OffsetOldest, Follow: true, Handle: collect(&offsets)})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestConsumeEndIdleTimeout(t *testing.T) {
+	// Offset 2 is a transaction marker, which is never delivered.
+	client := newTopicClient(t, 3, 0, 1)
+
+	var offsets []int64
+	start := time.Now()
+	require.NoError(t, client.Consume(context.Background(), ConsumeOptions{Topic: "t", Offset: sarama.OffsetOldest, EndIdleTimeout: 500 * time.Millisecond, Handle: collect(&offsets)}))
+	require.Equal(t, []int64{0, 1}, offsets)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestDefaultEndIdleTimeout(t *testing.T) {
+	cfg := sarama.NewConfig()
+	cfg.Consumer.MaxWaitTime = 500 * time.Millisecond
+	require.Equal(t, 5*time.Second, DefaultEndIdleTimeout(cfg))
+	cfg.Consumer.MaxWaitTime = 2 * time.Second
+	require.Equal(t, 8*time.Second, DefaultEndIdleTimeout(cfg))
+}
+
+func TestProduce(t *testing.T) {
+	client := newTopicClient(t, 0)
+
+	records := []*sarama.ProducerMessage{
+		{Topic: "t", Value: sarama.StringEncoder("a")},
+		{Topic: "t", Value: sarama.StringEncoder("b")},
+	}
+	require.NoError(t, client.Produce(context.Background(), records))
+	require.NoError(t, client.Produce(context.Background(), records[:1]))
+	require.Equal(t, int32(0), records[0].Partition)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, client.Produce(ctx, records), context.Canceled)
+
+	require.NoError(t, client.Close())
+}
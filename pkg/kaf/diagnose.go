@@ -0,0 +1,234 @@
+package kaf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"github.com/birdayz/kaf/pkg/config"
+)
+
+// Steps of a connection diagnosis, in the order they are checked.
+const (
+	StepConfig = "config"
+	StepToken  = "token"
+	StepDNS    = "dns"
+	StepTCP    = "tcp"
+	StepTLS    = "tls"
+	StepSASL   = "sasl"
+)
+
+// Check is the result of one step of a connection diagnosis. Broker is empty
+// for steps not specific to a broker.
+type Check struct {
+	Broker string
+	Step   string
+	// Err is nil if the step succeeded.
+	Err    error
+	Detail string
+	// Hint suggests a fix if the step failed.
+	Hint string
+}
+
+// Diagnose checks the connection to every broker of cluster step by step:
+// name resolution, TCP, the TLS handshake and SASL authentication, stopping
+// at the first failing step of a broker. The token of OAUTHBEARER and
+// AWS_MSK_IAM is fetched once with the token provider of the cluster before
+// the brokers are checked.
+func Diagnose(cluster *config.Cluster) []Check {
+	var checks []Check
+	if cluster.SASL != nil && (cluster.SASL.Mechanism == "OAUTHBEARER" || cluster.SASL.Mechanism == "AWS_MSK_IAM") {
+		check := Check{Step: StepToken}
+		tp, err := NewTokenProvider(cluster)
+		if err == nil {
+			_, err = tp.Token()
+		}
+		if err != nil {
+			check.Err = err
+			check.Hint = tokenHint(cluster.SASL.Mechanism)
+			var fetchErr *TokenFetchError
+			if errors.As(err, &fetchErr) {
+				check.Err = fetchErr.withoutHint()
+				check.Hint = fetchErr.Hint
+			}
+			return append(checks, check)
+		}
+		check.Detail = "fetched a token"
+		checks = append(checks, check)
+	}
+
+	cfg, err := NewSaramaConfig(cluster)
+	if err != nil {
+		return append(checks, Check{Step: StepConfig, Err: err, Hint: "Fix the cluster config."})
+	}
+	if len(cluster.Brokers) == 0 {
+		return append(checks, Check{Step: StepConfig, Err: errors.New("no brokers configured"), Hint: "Set brokers in the cluster config or pass --brokers."})
+	}
+	for _, addr := range cluster.Brokers {
+		checks = append(checks, diagnoseBroker(cfg, addr)...)
+	}
+	return checks
+}
+
+func diagnoseBroker(cfg *sarama.Config, addr string) []Check {
+	var checks []Check
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return append(checks, Check{Broker: addr, Step: StepConfig, Err: err, Hint: "Brokers must be given as host:port."})
+	}
+
+	// A proxy command resolves the host itself.
+	if !cfg.Net.Proxy.Enable {
+		start := time.Now()
+		ips, err := net.LookupHost(host)
+		if err != nil {
+			return append(checks, Check{Broker: addr, Step: StepDNS, Err: err,
+				Hint: fmt.Sprintf("%v cannot be resolved from this machine. Check the host name, and whether a VPN or /etc/hosts entry is needed.", host)})
+		}
+		checks = append(checks, Check{Broker: addr, Step: StepDNS, Detail: fmt.Sprintf("resolved to %v in %v", strings.Join(ips, ", "), since(start))})
+	}
+
+	start := time.Now()
+	conn, err := dial(cfg, addr)
+	if err != nil {
+		return append(checks, Check{Broker: addr, Step: StepTCP, Err: err, Hint: tcpHint(err)})
+	}
+	conn.Close()
+	checks = append(checks, Check{Broker: addr, Step: StepTCP, Detail: fmt.Sprintf("connected in %v", since(start))})
+
+	if cfg.Net.TLS.Enable {
+		start := time.Now()
+		check := Check{Broker: addr, Step: StepTLS}
+		if err := handshake(cfg, addr, host); err != nil {
+			check.Err = err
+			check.Hint = tlsHint(err)
+			return append(checks, check)
+		}
+		check.Detail = fmt.Sprintf("handshake completed in %v", since(start))
+		checks = append(checks, check)
+	}
+
+	// Sarama authenticates when opening the connection and reports failures
+	// on the first request.
+	start = time.Now()
+	step := StepSASL
+	if !cfg.Net.SASL.Enable {
+		step = StepTCP
+	}
+	broker := sarama.NewBroker(addr)
+	defer broker.Close()
+	err = broker.Open(cfg)
+	if err == nil {
+		_, err = broker.ApiVersions(&sarama.ApiVersionsRequest{})
+	}
+	if err != nil {
+		return append(checks, Check{Broker: addr, Step: step, Err: err, Hint: requestHint(cfg, err)})
+	}
+	if cfg.Net.SASL.Enable {
+		checks = append(checks, Check{Broker: addr, Step: StepSASL, Detail: fmt.Sprintf("authenticated with %v in %v", mechanismName(cfg), since(start))})
+	} else {
+		checks = append(checks, Check{Broker: addr, Step: StepTCP, Detail: fmt.Sprintf("broker answered in %v", since(start))})
+	}
+	return checks
+}
+
+func since(start time.Time) time.Duration {
+	return time.Since(start).Round(time.Millisecond)
+}
+
+func dial(cfg *sarama.Config, addr string) (net.Conn, error) {
+	if cfg.Net.Proxy.Enable {
+		return cfg.Net.Proxy.Dialer.Dial("tcp", addr)
+	}
+	return net.DialTimeout("tcp", addr, cfg.Net.DialTimeout)
+}
+
+func handshake(cfg *sarama.Config, addr string, host string) error {
+	conn, err := dial(cfg, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tlsConfig := &tls.Config{}
+	if cfg.Net.TLS.Config != nil {
+		tlsConfig = cfg.Net.TLS.Config.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.SetDeadline(time.Now().Add(cfg.Net.DialTimeout)); err != nil {
+		return err
+	}
+	return tlsConn.Handshake()
+}
+
+func mechanismName(cfg *sarama.Config) string {
+	if cfg.Net.SASL.Mechanism == "" {
+		return sarama.SASLTypePlaintext
+	}
+	return string(cfg.Net.SASL.Mechanism)
+}
+
+func tokenHint(mechanism string) string {
+	if mechanism == "AWS_MSK_IAM" {
+		return "Check the AWS credentials and region, e.g. with aws sts get-caller-identity, and the profile of the SASL config."
+	}
+	return "Check clientID, clientSecret, tokenURL and scopes of the SASL config, or the static token."
+}
+
+func tcpHint(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "Nothing listens on this port. Check the port, and that the broker is running with a listener on it."
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "The connection timed out. A firewall or security group may drop it, or the host is not reachable from this network."
+	}
+	return "Check that the broker is reachable from this machine."
+}
+
+func tlsHint(err error) string {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var recordHeader tls.RecordHeaderError
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return "The broker certificate is not signed by a trusted CA. Set TLS.cafile to the CA of the cluster."
+	case errors.As(err, &hostname):
+		return "The broker certificate does not match the host name. Connect with the name in the certificate or check the advertised listeners."
+	case errors.As(err, &invalid):
+		return "The broker certificate is invalid, e.g. expired. Check the certificates of the brokers."
+	case errors.As(err, &recordHeader), errors.Is(err, io.EOF), errors.Is(err, syscall.ECONNRESET):
+		return "The broker does not speak TLS on this port. Use the TLS listener, or remove TLS from the cluster config."
+	}
+	return "Check the TLS settings of the cluster config and the listener of the broker."
+}
+
+func requestHint(cfg *sarama.Config, err error) string {
+	switch {
+	case errors.Is(err, sarama.ErrSASLAuthenticationFailed):
+		return "The broker rejected the credentials. Check username and password, or the token, and the mechanism."
+	case errors.Is(err, sarama.ErrUnsupportedSASLMechanism):
+		return fmt.Sprintf("The broker does not enable %v on this listener. Check the mechanism of the SASL config.", mechanismName(cfg))
+	case errors.Is(err, sarama.ErrIllegalSASLState):
+		return "The broker did not expect SASL on this listener. Check security-protocol, or remove SASL from the cluster config."
+	}
+	if !cfg.Net.TLS.Enable {
+		return "The broker closed the connection. If the listener uses TLS, set security-protocol: SASL_SSL or pass --tls."
+	}
+	if !cfg.Net.SASL.Enable {
+		return "The broker closed the connection. If the listener requires SASL, configure SASL for the cluster."
+	}
+	return "Check the SASL settings of the cluster config."
+}
@@ -0,0 +1,79 @@
+package kaf
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/birdayz/kaf/pkg/config"
+)
+
+// listen accepts connections on a local port, writes reply if any and closes
+// them.
+func listen(t *testing.T, reply string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			if reply != "" {
+				_, _ = conn.Write([]byte(reply))
+			}
+			conn.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func lastCheck(checks []Check) Check {
+	return checks[len(checks)-1]
+}
+
+func TestDiagnoseConnectionRefused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	checks := Diagnose(&config.Cluster{Brokers: []string{addr}})
+	require.Len(t, checks, 2)
+	require.Equal(t, StepDNS, checks[0].Step)
+	require.NoError(t, checks[0].Err)
+	failed := lastCheck(checks)
+	require.Equal(t, StepTCP, failed.Step)
+	require.Error(t, failed.Err)
+	require.Contains(t, failed.Hint, "Nothing listens on this port")
+}
+
+func TestDiagnoseTLSOnPlaintextListener(t *testing.T) {
+	for _, reply := range []string{"", "HTTP/1.1 400 Bad Request\r\n\r\n"} {
+		addr := listen(t, reply)
+		checks := Diagnose(&config.Cluster{Brokers: []string{addr}, TLS: &config.TLS{}})
+		failed := lastCheck(checks)
+		require.Equal(t, StepTLS, failed.Step)
+		require.Error(t, failed.Err)
+		require.Contains(t, failed.Hint, "does not speak TLS", failed.Err.Error())
+	}
+}
+
+func TestDiagnoseBrokerClosesConnection(t *testing.T) {
+	addr := listen(t, "")
+	failed := lastCheck(Diagnose(&config.Cluster{Brokers: []string{addr}}))
+	require.Equal(t, StepTCP, failed.Step)
+	require.Error(t, failed.Err)
+	require.Contains(t, failed.Hint, "If the listener uses TLS")
+}
+
+func TestDiagnoseInvalidConfig(t *testing.T) {
+	checks := Diagnose(&config.Cluster{Brokers: []string{"kafka"}})
+	require.Equal(t, StepConfig, lastCheck(checks).Step)
+	require.Error(t, lastCheck(checks).Err)
+
+	checks = Diagnose(&config.Cluster{Version: "not-a-version", Brokers: []string{"localhost:9092"}})
+	require.Equal(t, StepConfig, lastCheck(checks).Step)
+}
@@ -0,0 +1,127 @@
+package kaf
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// commandDialer connects to brokers through the stdin and stdout of a
+// command, like the ProxyCommand of ssh. It implements sarama's proxy dialer.
+type commandDialer struct {
+	command string
+}
+
+// expandProxyCommand replaces %h and %p in command with host and port, and
+// %% with %. The host is advertised by the brokers, so host and port are
+// quoted with quote before they become part of the command.
+func expandProxyCommand(command string, host string, port string, quote func(string) string) string {
+	return strings.NewReplacer("%%", "%", "%h", quote(host), "%p", quote(port)).Replace(command)
+}
+
+// plainAddressChars are the characters of host names, IP addresses and ports.
+const plainAddressChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789.-_:[]"
+
+func isPlainAddress(s string) bool {
+	return s != "" && strings.Trim(s, plainAddressChars) == ""
+}
+
+// shellQuote quotes s as a single word for sh.
+func shellQuote(s string) string {
+	if isPlainAddress(s) && !strings.ContainsAny(s, "[]") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (d *commandDialer) Dial(network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var cmd *exec.Cmd
+	var command string
+	if runtime.GOOS == "windows" {
+		// cmd has no reliable quoting, so only plain addresses are passed.
+		if !isPlainAddress(host) || !isPlainAddress(port) {
+			return nil, fmt.Errorf("refusing to run proxy-command for broker address %q, it contains characters other than those of host names and ports", addr)
+		}
+		command = expandProxyCommand(d.command, host, port, func(s string) string { return s })
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		command = expandProxyCommand(d.command, host, port, shellQuote)
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+
+	// Pipes created with os.Pipe support deadlines, which sarama sets on
+	// every request.
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		stdinR.Close()
+		stdinW.Close()
+		return nil, err
+	}
+	cmd.Stdin = stdinR
+	cmd.Stdout = stdoutW
+	if err := cmd.Start(); err != nil {
+		for _, f := range []*os.File{stdinR, stdinW, stdoutR, stdoutW} {
+			f.Close()
+		}
+		return nil, fmt.Errorf("unable to start proxy-command %q: %w", command, err)
+	}
+	stdinR.Close()
+	stdoutW.Close()
+
+	return &commandConn{cmd: cmd, in: stdinW, out: stdoutR, addr: commandAddr(addr)}, nil
+}
+
+// commandConn is a connection over the stdin and stdout of a proxy command.
+type commandConn struct {
+	cmd  *exec.Cmd
+	in   *os.File
+	out  *os.File
+	addr commandAddr
+}
+
+func (c *commandConn) Read(b []byte) (int, error)  { return c.out.Read(b) }
+func (c *commandConn) Write(b []byte) (int, error) { return c.in.Write(b) }
+
+// Close closes stdin and stops the command.
+func (c *commandConn) Close() error {
+	c.in.Close()
+	c.out.Close()
+	if c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+	_ = c.cmd.Wait()
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr  { return c.addr }
+func (c *commandConn) RemoteAddr() net.Addr { return c.addr }
+
+func (c *commandConn) SetDeadline(t time.Time) error {
+	if err := c.out.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.in.SetWriteDeadline(t)
+}
+
+func (c *commandConn) SetReadDeadline(t time.Time) error  { return c.out.SetReadDeadline(t) }
+func (c *commandConn) SetWriteDeadline(t time.Time) error { return c.in.SetWriteDeadline(t) }
+
+// commandAddr is the broker address a proxy command connects to.
+type commandAddr string
+
+func (a commandAddr) Network() string { return "proxy-command" }
+func (a commandAddr) String() string  { return string(a) }
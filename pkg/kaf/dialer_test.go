@@ -0,0 +1,86 @@
+package kaf
+
+import (
+	"io"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/birdayz/kaf/pkg/config"
+)
+
+func TestExpandProxyCommand(t *testing.T) {
+	require.Equal(t, "ssh -W kafka-1:9092 bastion", expandProxyCommand("ssh -W %h:%p bastion", "kafka-1", "9092", shellQuote))
+	require.Equal(t, "printf 100%h", expandProxyCommand("printf 100%%h", "kafka-1", "9092", shellQuote))
+	require.Equal(t, `ssh -W 'a; id $(id) '\''x'\'''`+":9092 bastion",
+		expandProxyCommand("ssh -W %h:%p bastion", "a; id $(id) 'x'", "9092", shellQuote))
+	require.Equal(t, "''", shellQuote(""))
+}
+
+func TestCommandDialerQuotesHost(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	// The command prints the host instead of running what it contains.
+	host := "kafka-1; echo injected $(echo sub) `echo tick` 'quoted'"
+	d := &commandDialer{command: `printf '%%s\n' %h; cat`}
+	conn, err := d.Dial("tcp", net.JoinHostPort(host, "9092"))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, len(host)+1)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, host+"\n", string(buf))
+}
+
+func TestCommandDialer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	d := &commandDialer{command: "cat"}
+	conn, err := d.Dial("tcp", "kafka-1:9092")
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, "kafka-1:9092", conn.RemoteAddr().String())
+
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf))
+
+	// Reads time out like network connections.
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+	_, err = conn.Read(buf)
+	require.Error(t, err)
+}
+
+func TestNewSaramaConfigNet(t *testing.T) {
+	cfg, err := NewSaramaConfig(&config.Cluster{
+		DialTimeout:  5 * time.Second,
+		KeepAlive:    time.Minute,
+		ReadTimeout:  20 * time.Second,
+		WriteTimeout: 25 * time.Second,
+		ProxyCommand: "ssh -W %h:%p bastion",
+	})
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Second, cfg.Net.DialTimeout)
+	require.Equal(t, time.Minute, cfg.Net.KeepAlive)
+	require.Equal(t, 20*time.Second, cfg.Net.ReadTimeout)
+	require.Equal(t, 25*time.Second, cfg.Net.WriteTimeout)
+	require.True(t, cfg.Net.Proxy.Enable)
+
+	cfg, err = NewSaramaConfig(&config.Cluster{})
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, cfg.Net.DialTimeout)
+	require.False(t, cfg.Net.Proxy.Enable)
+}
@@ -0,0 +1,81 @@
+package kaf
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/IBM/sarama"
+
+	"github.com/birdayz/kaf/pkg/config"
+)
+
+// defaultKerberosConfig is used if neither KerberosConfig nor KerberosKDC is
+// set.
+const defaultKerberosConfig = "/etc/krb5.conf"
+
+// newGSSAPIConfig returns the sarama Kerberos config of the GSSAPI mechanism.
+// The keytab is checked here, sarama would only fail on the first connection.
+func newGSSAPIConfig(sasl *config.SASL) (sarama.GSSAPIConfig, error) {
+	gssapi := sarama.GSSAPIConfig{
+		ServiceName:     sasl.KerberosServiceName,
+		Username:        sasl.Username,
+		Realm:           sasl.KerberosRealm,
+		DisablePAFXFAST: sasl.KerberosDisablePAFXFAST,
+	}
+	if gssapi.ServiceName == "" {
+		gssapi.ServiceName = "kafka"
+	}
+	if gssapi.Username == "" {
+		return gssapi, fmt.Errorf("SASL mechanism GSSAPI requires username, the Kerberos principal")
+	}
+	if gssapi.Realm == "" {
+		return gssapi, fmt.Errorf("SASL mechanism GSSAPI requires kerberosRealm")
+	}
+
+	switch {
+	case sasl.KerberosKeytab != "":
+		f, err := os.Open(sasl.KerberosKeytab)
+		if err != nil {
+			return gssapi, fmt.Errorf("unable to read Kerberos keytab: %w", err)
+		}
+		f.Close()
+		gssapi.AuthType = sarama.KRB5_KEYTAB_AUTH
+		gssapi.KeyTabPath = sasl.KerberosKeytab
+	case sasl.Password != "":
+		gssapi.AuthType = sarama.KRB5_USER_AUTH
+		gssapi.Password = sasl.Password
+	default:
+		return gssapi, fmt.Errorf("SASL mechanism GSSAPI requires kerberosKeytab or password")
+	}
+
+	switch {
+	case sasl.KerberosConfig != "":
+		if _, err := os.Stat(sasl.KerberosConfig); err != nil {
+			return gssapi, fmt.Errorf("unable to read Kerberos config: %w", err)
+		}
+		gssapi.KerberosConfigPath = sasl.KerberosConfig
+	case sasl.KerberosKDC != "":
+		path, err := writeKerberosConfig(gssapi.Realm, sasl.KerberosKDC)
+		if err != nil {
+			return gssapi, err
+		}
+		gssapi.KerberosConfigPath = path
+	default:
+		gssapi.KerberosConfigPath = defaultKerberosConfig
+	}
+	return gssapi, nil
+}
+
+// writeKerberosConfig writes a krb5.conf for a single realm and KDC to the
+// temporary directory, sarama only loads the Kerberos config from a file.
+func writeKerberosConfig(realm string, kdc string) (string, error) {
+	content := fmt.Sprintf("[libdefaults]\n  default_realm = %s\n\n[realms]\n  %s = {\n    kdc = %s\n  }\n", realm, realm, kdc)
+	sum := sha256.Sum256([]byte(content))
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("kaf-krb5-%x.conf", sum[:8]))
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		return "", fmt.Errorf("unable to write Kerberos config: %w", err)
+	}
+	return path, nil
+}
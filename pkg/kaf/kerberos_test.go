@@ -0,0 +1,59 @@
+package kaf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+
+	"github.com/birdayz/kaf/pkg/config"
+)
+
+func TestNewSaramaConfigGSSAPI(t *testing.T) {
+	keytab := filepath.Join(t.TempDir(), "kaf.keytab")
+	require.NoError(t, os.WriteFile(keytab, []byte{0x05, 0x02}, 0o600))
+
+	cfg, err := NewSaramaConfig(&config.Cluster{
+		SecurityProtocol: "SASL_PLAINTEXT",
+		SASL: &config.SASL{
+			Mechanism:      "GSSAPI",
+			Username:       "kaf",
+			KerberosRealm:  "EXAMPLE.COM",
+			KerberosKeytab: keytab,
+			KerberosKDC:    "kdc.example.com:88",
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, sarama.SASLMechanism(sarama.SASLTypeGSSAPI), cfg.Net.SASL.Mechanism)
+	gssapi := cfg.Net.SASL.GSSAPI
+	require.Equal(t, sarama.KRB5_KEYTAB_AUTH, gssapi.AuthType)
+	require.Equal(t, keytab, gssapi.KeyTabPath)
+	require.Equal(t, "kafka", gssapi.ServiceName)
+	krb5, err := os.ReadFile(gssapi.KerberosConfigPath)
+	require.NoError(t, err)
+	require.Contains(t, string(krb5), "kdc = kdc.example.com:88")
+	require.NoError(t, cfg.Validate())
+
+	cfg, err = NewSaramaConfig(&config.Cluster{
+		SecurityProtocol: "SASL_SSL",
+		SASL:             &config.SASL{Mechanism: "GSSAPI", Username: "kaf", Password: "secret", KerberosRealm: "EXAMPLE.COM", KerberosServiceName: "kafka-prod"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, sarama.KRB5_USER_AUTH, cfg.Net.SASL.GSSAPI.AuthType)
+	require.Equal(t, "kafka-prod", cfg.Net.SASL.GSSAPI.ServiceName)
+	require.Equal(t, defaultKerberosConfig, cfg.Net.SASL.GSSAPI.KerberosConfigPath)
+
+	_, err = NewSaramaConfig(&config.Cluster{
+		SecurityProtocol: "SASL_PLAINTEXT",
+		SASL:             &config.SASL{Mechanism: "GSSAPI", Username: "kaf", KerberosRealm: "EXAMPLE.COM", KerberosKeytab: "/does/not/exist"},
+	})
+	require.ErrorContains(t, err, "unable to read Kerberos keytab")
+
+	_, err = NewSaramaConfig(&config.Cluster{
+		SecurityProtocol: "SASL_PLAINTEXT",
+		SASL:             &config.SASL{Mechanism: "GSSAPI", Username: "kaf", KerberosRealm: "EXAMPLE.COM"},
+	})
+	require.Error(t, err)
+}
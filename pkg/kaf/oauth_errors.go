@@ -0,0 +1,105 @@
+package kaf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+
+	"golang.org/x/oauth2"
+)
+
+// TokenFetchError is returned if an OAUTHBEARER token cannot be fetched from
+// the token URL. Hint says how to fix the failure.
+type TokenFetchError struct {
+	// URL is the token URL without credentials and query.
+	URL  string
+	Hint string
+	Err  error
+}
+
+func (e *TokenFetchError) Error() string {
+	return fmt.Sprintf("%v. %v", e.withoutHint(), e.Hint)
+}
+
+func (e *TokenFetchError) Unwrap() error {
+	return e.Err
+}
+
+// withoutHint returns the error without the hint, for the diagnosis which
+// prints the hint on its own line.
+func (e *TokenFetchError) withoutHint() error {
+	cause := e.Err
+	// The url.Error repeats the token URL, including the query.
+	var urlErr *url.Error
+	if errors.As(cause, &urlErr) {
+		cause = urlErr.Err
+	}
+	return fmt.Errorf("could not fetch OAUTH token from %v: %w", e.URL, cause)
+}
+
+func newTokenFetchError(tokenURL string, err error) *TokenFetchError {
+	return &TokenFetchError{URL: redactTokenURL(tokenURL), Hint: tokenFetchHint(err), Err: err}
+}
+
+// redactTokenURL removes user info and query of tokenURL, which may hold
+// credentials.
+func redactTokenURL(tokenURL string) string {
+	u, err := url.Parse(tokenURL)
+	if err != nil {
+		return "the tokenURL"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+func tokenFetchHint(err error) string {
+	var retrieveErr *oauth2.RetrieveError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var recordHeader tls.RecordHeaderError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &retrieveErr) && retrieveErr.Response != nil:
+		return httpTokenHint(retrieveErr.Response.StatusCode)
+	case errors.As(err, &unknownAuthority):
+		return "The certificate of the token endpoint is not signed by a trusted CA, e.g. it is self-signed. Add the CA of the identity provider to the system certificates, or point SSL_CERT_FILE to it."
+	case errors.As(err, &hostname):
+		return "The certificate of the token endpoint does not match the host of tokenURL. Use the host name in the certificate."
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return "The certificate of the token endpoint expired or is not valid yet. Renew the certificate of the identity provider, or check the clock of this machine."
+	case errors.As(err, &invalid):
+		return "The certificate of the token endpoint is invalid. Check the certificate of the identity provider."
+	case errors.As(err, &recordHeader):
+		return "The token endpoint does not speak TLS. Check scheme and port of tokenURL."
+	case errors.As(err, &dnsErr):
+		return "The host of tokenURL cannot be resolved. Check tokenURL and the DNS settings of this machine."
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "Nothing listens on the port of tokenURL. Check the port, and that the identity provider is reachable from this network."
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Sprintf("The token endpoint did not answer within %v. A firewall or proxy may block it.", tokenFetchTimeout)
+	}
+	return "Check clientID, clientSecret, tokenURL and scopes of the SASL config."
+}
+
+func httpTokenHint(status int) string {
+	switch {
+	case status == http.StatusBadRequest || status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return fmt.Sprintf("The identity provider rejected the client credentials with HTTP %v. Check clientID, clientSecret and scopes of the SASL config.", status)
+	case status == http.StatusNotFound || status == http.StatusMethodNotAllowed:
+		return fmt.Sprintf("The token endpoint was not found, HTTP %v. Check that tokenURL is the token endpoint of the identity provider.", status)
+	case status >= 400 && status < 500:
+		return fmt.Sprintf("The identity provider rejected the request with HTTP %v. Check clientID, clientSecret, tokenURL and scopes of the SASL config.", status)
+	case status >= 500:
+		return fmt.Sprintf("The identity provider failed with HTTP %v. Retry later, or check the status of the identity provider.", status)
+	}
+	return fmt.Sprintf("The token endpoint answered HTTP %v without a token. Check that tokenURL is the token endpoint of the identity provider.", status)
+}
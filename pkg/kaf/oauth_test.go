@@ -0,0 +1,42 @@
+package kaf
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/birdayz/kaf/pkg/config"
+)
+
+func TestTokenProviderAudience(t *testing.T) {
+	origDir := TokenCacheDir
+	defer func() { TokenCacheDir = origDir }()
+	TokenCacheDir = ""
+
+	var audiences []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		audiences = append(audiences, r.PostForm.Get("audience"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"token","token_type":"bearer","expires_in":3600}`)
+	}))
+	defer server.Close()
+
+	sasl := &config.SASL{Mechanism: "OAUTHBEARER", ClientID: "kaf", TokenURL: server.URL, Audience: "https://kafka.example"}
+	tp, err := newTokenProvider(&config.Cluster{SASL: sasl})
+	require.NoError(t, err)
+	tp.replaceAt = time.Now().Add(-time.Second)
+	_, err = tp.Token()
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://kafka.example", "https://kafka.example"}, audiences, "sent on the first fetch and refreshes")
+
+	audiences = nil
+	sasl.Audience = ""
+	_, err = newTokenProvider(&config.Cluster{SASL: sasl})
+	require.NoError(t, err)
+	require.Equal(t, []string{""}, audiences)
+}
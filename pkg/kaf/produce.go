@@ -0,0 +1,32 @@
+package kaf
+
+import (
+	"context"
+
+	"github.com/IBM/sarama"
+)
+
+// Produce sends records and waits for them to be acknowledged, setting their
+// partition and offset. The returned error is a sarama.ProducerErrors listing
+// the failed records. ctx is checked before the records are sent, sending
+// cannot be interrupted. The producer is created on the first call and
+// closed by Close.
+func (c *Client) Produce(ctx context.Context, records []*sarama.ProducerMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.producerMu.Lock()
+	if c.producer == nil {
+		producer, err := sarama.NewSyncProducerFromClient(c.client)
+		if err != nil {
+			c.producerMu.Unlock()
+			return err
+		}
+		c.producer = producer
+	}
+	producer := c.producer
+	c.producerMu.Unlock()
+
+	return producer.SendMessages(records)
+}
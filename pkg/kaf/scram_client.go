@@ -1,4 +1,4 @@
-package main
+package kaf
 
 import (
 	"crypto/sha256"
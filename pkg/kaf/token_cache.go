@@ -0,0 +1,117 @@
+package kaf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/birdayz/kaf/pkg/config"
+)
+
+// TokenCacheDir is the directory OAUTHBEARER tokens fetched with client
+// credentials are cached in, so that later invocations reuse them until they
+// are due for replacement. Caching is disabled if it is empty, the default.
+var TokenCacheDir string
+
+// DefaultTokenCacheDir returns ~/.kaf/token-cache, the token cache directory
+// of the kaf CLI, or an empty string if the home directory is unknown.
+func DefaultTokenCacheDir() string {
+	home, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".kaf", "token-cache")
+}
+
+// tokenCache is the cache file of one cluster. The file records a hash of
+// the client credentials the token was fetched with, so a token is not
+// reused after the credentials, token URL, scopes or audience changed.
+type tokenCache struct {
+	path     string
+	identity string
+}
+
+type cachedToken struct {
+	Identity    string    `json:"identity"`
+	AccessToken string    `json:"accessToken"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// newTokenCache returns the cache of cluster, or nil if caching is disabled.
+func newTokenCache(cluster *config.Cluster, cfg *clientcredentials.Config) *tokenCache {
+	if TokenCacheDir == "" {
+		return nil
+	}
+	h := sha256.New()
+	for _, s := range append([]string{cfg.TokenURL, cfg.ClientID, cfg.ClientSecret, cfg.EndpointParams.Encode()}, cfg.Scopes...) {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	identity := hex.EncodeToString(h.Sum(nil))
+
+	name := unsafeFileChars.ReplaceAllString(cluster.Name, "_")
+	if strings.Trim(name, ".") == "" {
+		// Clusters given by flags have no name.
+		name = identity[:16]
+	}
+	return &tokenCache{path: filepath.Join(TokenCacheDir, name), identity: identity}
+}
+
+// load returns the cached token if it was fetched with the same credentials
+// and is not due for replacement at now.
+func (c *tokenCache) load(now time.Time) (cachedToken, bool) {
+	b, err := os.ReadFile(c.path)
+	if err != nil {
+		return cachedToken{}, false
+	}
+	var token cachedToken
+	if err := json.Unmarshal(b, &token); err != nil {
+		return cachedToken{}, false
+	}
+	if token.Identity != c.identity || token.AccessToken == "" || !now.Before(token.Expiry.Add(-refreshBuffer)) {
+		return cachedToken{}, false
+	}
+	return token, true
+}
+
+// store writes token to the cache, readable only by the user. The file is
+// replaced atomically so that concurrent invocations never read a partial
+// token. Failing to cache is not an error, the token is fetched again next
+// time.
+func (c *tokenCache) store(accessToken string, expiry time.Time) {
+	b, err := json.Marshal(cachedToken{Identity: c.identity, AccessToken: accessToken, Expiry: expiry})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return
+	}
+	f, err := os.CreateTemp(filepath.Dir(c.path), ".token-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(f.Name())
+	// Tokens are credentials, keep the file private.
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return
+	}
+	if err := f.Close(); err != nil {
+		return
+	}
+	os.Rename(f.Name(), c.path)
+}
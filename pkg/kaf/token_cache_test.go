@@ -0,0 +1,98 @@
+package kaf
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/birdayz/kaf/pkg/config"
+)
+
+func TestTokenProviderCache(t *testing.T) {
+	origDir := TokenCacheDir
+	defer func() { TokenCacheDir = origDir }()
+	TokenCacheDir = filepath.Join(t.TempDir(), "token-cache")
+
+	var fetches int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%v","token_type":"bearer","expires_in":3600}`, fetches)
+	}))
+	defer server.Close()
+
+	cluster := &config.Cluster{Name: "prod/eu", SASL: &config.SASL{
+		Mechanism:    "OAUTHBEARER",
+		ClientID:     "kaf",
+		ClientSecret: "secret",
+		TokenURL:     server.URL,
+	}}
+
+	tp, err := newTokenProvider(cluster)
+	require.NoError(t, err)
+	require.Equal(t, "token-1", tp.currentToken)
+
+	info, err := os.Stat(filepath.Join(TokenCacheDir, "prod_eu"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// A later invocation reuses the token.
+	tp, err = newTokenProvider(cluster)
+	require.NoError(t, err)
+	require.Equal(t, "token-1", tp.currentToken)
+	require.Equal(t, 1, fetches)
+
+	// Other credentials do not.
+	cluster.SASL.ClientSecret = "rotated"
+	tp, err = newTokenProvider(cluster)
+	require.NoError(t, err)
+	require.Equal(t, "token-2", tp.currentToken)
+
+	// Neither does a token due for replacement.
+	tp.cache.store("expiring", time.Now().Add(refreshBuffer/2))
+	tp, err = newTokenProvider(cluster)
+	require.NoError(t, err)
+	require.Equal(t, "token-3", tp.currentToken)
+
+	TokenCacheDir = ""
+	tp, err = newTokenProvider(cluster)
+	require.NoError(t, err)
+	require.Equal(t, "token-4", tp.currentToken)
+	require.Nil(t, tp.cache)
+}
+
+func TestTokenCacheLoad(t *testing.T) {
+	origDir := TokenCacheDir
+	defer func() { TokenCacheDir = origDir }()
+	TokenCacheDir = t.TempDir()
+
+	cache := newTokenCache(&config.Cluster{}, &clientcredentials.Config{ClientID: "kaf"})
+	_, ok := cache.load(time.Now())
+	require.False(t, ok)
+
+	require.NoError(t, os.WriteFile(cache.path, []byte("not json"), 0600))
+	_, ok = cache.load(time.Now())
+	require.False(t, ok)
+
+	expiry := time.Now().Add(time.Hour).Round(0)
+	cache.store("abc", expiry)
+	token, ok := cache.load(time.Now())
+	require.True(t, ok)
+	require.Equal(t, "abc", token.AccessToken)
+	require.True(t, expiry.Equal(token.Expiry))
+	_, ok = cache.load(expiry)
+	require.False(t, ok)
+}
+
+func TestDefaultTokenCacheDir(t *testing.T) {
+	// Programs using the package cache tokens only if they opt in.
+	require.Empty(t, TokenCacheDir)
+	require.Equal(t, filepath.Join(".kaf", "token-cache"), filepath.Join(filepath.Base(filepath.Dir(DefaultTokenCacheDir())), filepath.Base(DefaultTokenCacheDir())))
+}
@@ -0,0 +1,543 @@
+package parquet
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+)
+
+// Encodings.
+const (
+	encodingPlain                = 0
+	encodingPlainDictionary      = 2
+	encodingRLE                  = 3
+	encodingDeltaBinaryPacked    = 5
+	encodingDeltaLengthByteArray = 6
+	encodingDeltaByteArray       = 7
+	encodingRLEDictionary        = 8
+	encodingByteStreamSplit      = 9
+)
+
+var encodingNames = map[int64]string{
+	0: "PLAIN", 2: "PLAIN_DICTIONARY", 3: "RLE", 4: "BIT_PACKED",
+	5: "DELTA_BINARY_PACKED", 6: "DELTA_LENGTH_BYTE_ARRAY", 7: "DELTA_BYTE_ARRAY",
+	8: "RLE_DICTIONARY", 9: "BYTE_STREAM_SPLIT",
+}
+
+func encodingName(encoding int64) string {
+	if name, ok := encodingNames[encoding]; ok {
+		return name
+	}
+	return fmt.Sprint(encoding)
+}
+
+// decodeHybrid decodes count values of bitWidth bits encoded with the
+// RLE/bit-packing hybrid of definition levels and dictionary indexes.
+func decodeHybrid(data []byte, bitWidth int, count int) ([]uint32, error) {
+	if bitWidth < 0 || bitWidth > 32 {
+		return nil, fmt.Errorf("invalid bit width %v", bitWidth)
+	}
+	values := make([]uint32, 0, count)
+	byteWidth := (bitWidth + 7) / 8
+	pos := 0
+	for len(values) < count {
+		header, n := binary.Uvarint(data[pos:])
+		if n <= 0 {
+			return nil, fmt.Errorf("truncated RLE data, %v of %v values", len(values), count)
+		}
+		pos += n
+
+		if header&1 == 0 {
+			// A run of one repeated value.
+			run := header >> 1
+			if len(data)-pos < byteWidth {
+				return nil, fmt.Errorf("truncated RLE data, %v of %v values", len(values), count)
+			}
+			var v uint32
+			for i := 0; i < byteWidth; i++ {
+				v |= uint32(data[pos+i]) << (8 * i)
+			}
+			pos += byteWidth
+			for i := uint64(0); i < run && len(values) < count; i++ {
+				values = append(values, v)
+			}
+			continue
+		}
+
+		// Groups of 8 bit-packed values, least significant bit first.
+		groups := header >> 1
+		if groups > uint64(len(data)) {
+			return nil, fmt.Errorf("truncated RLE data, %v of %v values", len(values), count)
+		}
+		n = int(groups) * 8
+		if n > count-len(values) {
+			n = count - len(values)
+		}
+		if len(data)-pos < (n*bitWidth+7)/8 {
+			return nil, fmt.Errorf("truncated RLE data, %v of %v values", len(values), count)
+		}
+		for _, v := range unpackBits(nil, data[pos:], bitWidth, n) {
+			values = append(values, uint32(v))
+		}
+		pos += int(groups) * bitWidth
+		if pos > len(data) {
+			pos = len(data)
+		}
+	}
+	return values, nil
+}
+
+// unpackBits appends count values of bitWidth bits, packed least significant
+// bit first, from data to values.
+func unpackBits(values []uint64, data []byte, bitWidth int, count int) []uint64 {
+	for i := 0; i < count; i++ {
+		var v uint64
+		bit := i * bitWidth
+		for got := 0; got < bitWidth; {
+			n := 8 - bit%8
+			if n > bitWidth-got {
+				n = bitWidth - got
+			}
+			b := data[bit/8] >> uint(bit%8) & (1<<uint(n) - 1)
+			v |= uint64(b) << uint(got)
+			got += n
+			bit += n
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// decodeDeltaBinaryPacked decodes count DELTA_BINARY_PACKED integers and
+// returns them with the number of bytes they take. Deltas wrap around with
+// bits bits, 32 for INT32 columns.
+func decodeDeltaBinaryPacked(data []byte, count int, bits int) ([]int64, int, error) {
+	errTruncated := fmt.Errorf("truncated DELTA_BINARY_PACKED data")
+	pos := 0
+	uvarint := func() (uint64, bool) {
+		v, n := binary.Uvarint(data[pos:])
+		if n <= 0 {
+			return 0, false
+		}
+		pos += n
+		return v, true
+	}
+	varint := func() (int64, bool) {
+		v, n := binary.Varint(data[pos:])
+		if n <= 0 {
+			return 0, false
+		}
+		pos += n
+		return v, true
+	}
+
+	blockSize, ok1 := uvarint()
+	miniblocks, ok2 := uvarint()
+	total, ok3 := uvarint()
+	first, ok4 := varint()
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return nil, 0, errTruncated
+	}
+	if miniblocks == 0 || blockSize == 0 || blockSize%miniblocks != 0 || blockSize/miniblocks%8 != 0 || blockSize > 1<<20 {
+		return nil, 0, fmt.Errorf("invalid DELTA_BINARY_PACKED block size %v with %v miniblocks", blockSize, miniblocks)
+	}
+	if total < uint64(count) {
+		return nil, 0, fmt.Errorf("DELTA_BINARY_PACKED data has %v values, expected %v", total, count)
+	}
+	perMiniblock := int(blockSize / miniblocks)
+
+	values := make([]int64, 0, count)
+	if total == 0 {
+		return values, pos, nil
+	}
+	values = append(values, first)
+	last := first
+	deltas := make([]uint64, 0, perMiniblock)
+	// All values of the header are read, so that the returned length
+	// includes all blocks.
+	for remaining := int(total) - 1; remaining > 0; {
+		minDelta, ok := varint()
+		if !ok || len(data)-pos < int(miniblocks) {
+			return nil, 0, errTruncated
+		}
+		widths := data[pos : pos+int(miniblocks)]
+		pos += int(miniblocks)
+		for _, width := range widths {
+			if remaining == 0 {
+				break
+			}
+			if width > 64 {
+				return nil, 0, fmt.Errorf("invalid DELTA_BINARY_PACKED bit width %v", width)
+			}
+			size := perMiniblock * int(width) / 8
+			if len(data)-pos < size {
+				return nil, 0, errTruncated
+			}
+			deltas = unpackBits(deltas[:0], data[pos:pos+size], int(width), perMiniblock)
+			pos += size
+			for _, d := range deltas {
+				if remaining == 0 {
+					break
+				}
+				last += minDelta + int64(d)
+				if bits == 32 {
+					last = int64(int32(last))
+				}
+				if len(values) < count {
+					values = append(values, last)
+				}
+				remaining--
+			}
+		}
+	}
+	return values[:count], pos, nil
+}
+
+// decodeDeltaLengthByteArray decodes count DELTA_LENGTH_BYTE_ARRAY values, the
+// lengths followed by the concatenated values.
+func decodeDeltaLengthByteArray(data []byte, count int) ([][]byte, error) {
+	lengths, n, err := decodeDeltaBinaryPacked(data, count, 32)
+	if err != nil {
+		return nil, err
+	}
+	data = data[n:]
+	values := make([][]byte, 0, count)
+	for _, l := range lengths {
+		if l < 0 || l > int64(len(data)) {
+			return nil, fmt.Errorf("truncated DELTA_LENGTH_BYTE_ARRAY data")
+		}
+		values = append(values, data[:l])
+		data = data[l:]
+	}
+	return values, nil
+}
+
+// decodeDeltaByteArray decodes count DELTA_BYTE_ARRAY values, the lengths of
+// the prefixes shared with the previous value followed by the suffixes.
+func decodeDeltaByteArray(data []byte, count int) ([][]byte, error) {
+	prefixes, n, err := decodeDeltaBinaryPacked(data, count, 32)
+	if err != nil {
+		return nil, err
+	}
+	suffixes, err := decodeDeltaLengthByteArray(data[n:], count)
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]byte, 0, count)
+	var last []byte
+	for i, prefix := range prefixes {
+		if prefix < 0 || prefix > int64(len(last)) {
+			return nil, fmt.Errorf("invalid DELTA_BYTE_ARRAY prefix length %v", prefix)
+		}
+		v := append(append([]byte(nil), last[:prefix]...), suffixes[i]...)
+		values = append(values, v)
+		last = v
+	}
+	return values, nil
+}
+
+// decodeValues decodes count values of c encoded with encoding, other than
+// the dictionary encodings.
+func (c *column) decodeValues(encoding int64, data []byte, count int) ([]interface{}, error) {
+	switch encoding {
+	case encodingPlain:
+		return c.decodePlain(data, count)
+	case encodingRLE:
+		if c.typ != typeBoolean {
+			return nil, fmt.Errorf("RLE encoding is only supported for booleans")
+		}
+		if len(data) < 4 {
+			return nil, fmt.Errorf("truncated RLE data")
+		}
+		bits, err := decodeHybrid(data[4:], 1, count)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, 0, count)
+		for _, b := range bits {
+			values = append(values, b == 1)
+		}
+		return values, nil
+	case encodingDeltaBinaryPacked:
+		if c.typ != typeInt32 && c.typ != typeInt64 {
+			break
+		}
+		bits := 64
+		if c.typ == typeInt32 {
+			bits = 32
+		}
+		ints, _, err := decodeDeltaBinaryPacked(data, count, bits)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, 0, count)
+		for _, i := range ints {
+			var raw interface{} = i
+			if c.typ == typeInt32 {
+				raw = int32(i)
+			}
+			v, err := c.convert(raw)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case encodingDeltaLengthByteArray, encodingDeltaByteArray:
+		if c.typ != typeByteArray && c.typ != typeFixedLenByteArray {
+			break
+		}
+		var arrays [][]byte
+		var err error
+		if encoding == encodingDeltaLengthByteArray {
+			arrays, err = decodeDeltaLengthByteArray(data, count)
+		} else {
+			arrays, err = decodeDeltaByteArray(data, count)
+		}
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, 0, count)
+		for _, a := range arrays {
+			v, err := c.convert(a)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case encodingByteStreamSplit:
+		// Byte k of every value is in stream k, transpose them to plain.
+		size := c.plainSize()
+		if c.typ == typeBoolean || c.typ == typeByteArray {
+			break
+		}
+		if len(data) < size*count {
+			return nil, fmt.Errorf("truncated BYTE_STREAM_SPLIT data")
+		}
+		plain := make([]byte, size*count)
+		for i := 0; i < count; i++ {
+			for k := 0; k < size; k++ {
+				plain[i*size+k] = data[k*count+i]
+			}
+		}
+		return c.decodePlain(plain, count)
+	}
+	return nil, fmt.Errorf("encoding %v is not supported for type %v", encodingName(encoding), c.typ)
+}
+
+// plainSize returns the size of plain encoded values of fixed size types.
+func (c *column) plainSize() int {
+	switch c.typ {
+	case typeInt32, typeFloat:
+		return 4
+	case typeInt64, typeDouble:
+		return 8
+	case typeInt96:
+		return 12
+	}
+	return c.typeLength
+}
+
+// decodePlain decodes count plain encoded values of c.
+func (c *column) decodePlain(data []byte, count int) ([]interface{}, error) {
+	switch c.typ {
+	case typeBoolean:
+		if len(data)*8 < count {
+			return nil, fmt.Errorf("truncated data, %v bytes for %v booleans", len(data), count)
+		}
+		values := make([]interface{}, 0, count)
+		for i := 0; i < count; i++ {
+			values = append(values, data[i/8]&(1<<uint(i%8)) != 0)
+		}
+		return values, nil
+	case typeByteArray:
+		if len(data) < 4*count {
+			return nil, fmt.Errorf("truncated data, %v bytes for %v values", len(data), count)
+		}
+		values := make([]interface{}, 0, count)
+		pos := 0
+		for i := 0; i < count; i++ {
+			if len(data)-pos < 4 {
+				return nil, fmt.Errorf("truncated data, %v of %v values", i, count)
+			}
+			n := int(binary.LittleEndian.Uint32(data[pos:]))
+			pos += 4
+			if n < 0 || n > len(data)-pos {
+				return nil, fmt.Errorf("truncated data, %v of %v values", i, count)
+			}
+			v, err := c.convert(data[pos : pos+n])
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			pos += n
+		}
+		return values, nil
+	}
+
+	size := c.plainSize()
+	if len(data) < size*count {
+		return nil, fmt.Errorf("truncated data, %v bytes for %v values of %v bytes", len(data), count, size)
+	}
+	values := make([]interface{}, 0, count)
+	for i := 0; i < count; i++ {
+		b := data[i*size : (i+1)*size]
+		var raw interface{}
+		switch c.typ {
+		case typeInt32:
+			raw = int32(binary.LittleEndian.Uint32(b))
+		case typeInt64:
+			raw = int64(binary.LittleEndian.Uint64(b))
+		case typeInt96:
+			raw = int96Time(b)
+		case typeFloat:
+			raw = math.Float32frombits(binary.LittleEndian.Uint32(b))
+		case typeDouble:
+			raw = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		case typeFixedLenByteArray:
+			raw = b
+		}
+		v, err := c.convert(raw)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// julianUnixEpoch is the Julian day of 1970-01-01.
+const julianUnixEpoch = 2440588
+
+// int96Time returns the time of a legacy INT96 timestamp, the nanoseconds
+// of the day followed by the Julian day.
+func int96Time(b []byte) time.Time {
+	nanos := int64(binary.LittleEndian.Uint64(b))
+	day := int64(binary.LittleEndian.Uint32(b[8:]))
+	return time.Unix((day-julianUnixEpoch)*86400, nanos).UTC()
+}
+
+// convert returns the JSON value of a decoded physical value: timestamps and
+// dates as RFC 3339 strings, times of day as HH:MM:SS.ffffff, decimals as
+// decimal strings, strings as strings, JSON as it is and other bytes as base64.
+func (c *column) convert(raw interface{}) (interface{}, error) {
+	switch v := raw.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case float32:
+		if f, ok := convertFloat(float64(v)).(string); ok {
+			return f, nil
+		}
+		// Rendered with the precision of float32.
+		return v, nil
+	case float64:
+		return convertFloat(v), nil
+	case int32:
+		switch c.kind {
+		case kindUnsigned:
+			return uint32(v), nil
+		case kindDate:
+			return time.Unix(int64(v)*86400, 0).UTC().Format("2006-01-02"), nil
+		case kindTime:
+			return formatTimeOfDay(c.duration(int64(v))), nil
+		case kindDecimal:
+			return formatDecimal(big.NewInt(int64(v)), c.scale), nil
+		}
+		return v, nil
+	case int64:
+		switch c.kind {
+		case kindUnsigned:
+			return uint64(v), nil
+		case kindTime:
+			return formatTimeOfDay(c.duration(v)), nil
+		case kindTimestamp:
+			return c.timestamp(v).Format(time.RFC3339Nano), nil
+		case kindDecimal:
+			return formatDecimal(big.NewInt(v), c.scale), nil
+		}
+		return v, nil
+	case []byte:
+		switch c.kind {
+		case kindString:
+			return string(v), nil
+		case kindJSON:
+			if !json.Valid(v) {
+				return nil, fmt.Errorf("column %v: invalid JSON value %q", c.name, v)
+			}
+			return json.RawMessage(append([]byte(nil), v...)), nil
+		case kindDecimal:
+			return formatDecimal(signedBigEndian(v), c.scale), nil
+		case kindUUID:
+			if len(v) == 16 {
+				h := hex.EncodeToString(v)
+				return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+			}
+		}
+		return base64.StdEncoding.EncodeToString(v), nil
+	}
+	return raw, nil
+}
+
+// duration returns a time of day in the unit of c as a duration.
+func (c *column) duration(v int64) time.Duration {
+	switch c.unit {
+	case unitMillis:
+		return time.Duration(v) * time.Millisecond
+	case unitMicros:
+		return time.Duration(v) * time.Microsecond
+	}
+	return time.Duration(v)
+}
+
+// timestamp returns a timestamp value in the unit of c as a time.
+func (c *column) timestamp(v int64) time.Time {
+	switch c.unit {
+	case unitMillis:
+		return time.UnixMilli(v).UTC()
+	case unitMicros:
+		return time.UnixMicro(v).UTC()
+	}
+	return time.Unix(0, v).UTC()
+}
+
+// signedBigEndian returns the big-endian two's complement integer b.
+func signedBigEndian(b []byte) *big.Int {
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b))*8))
+	}
+	return n
+}
+
+func formatDecimal(unscaled *big.Int, scale int) string {
+	return new(big.Rat).SetFrac(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)).FloatString(scale)
+}
+
+func convertFloat(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
+
+// formatTimeOfDay formats a time of day like pkg/avro.
+func formatTimeOfDay(d time.Duration) string {
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+	d -= s * time.Second
+	return fmt.Sprintf("%02d:%02d:%02d.%06d", h, m, s, d/time.Microsecond)
+}
@@ -0,0 +1,390 @@
+// Package parquet reads the rows of Parquet files with flat schemas as JSON.
+//
+// Only what is needed to replay data extracts is supported: primitive
+// columns that are required or optional, the plain, dictionary, delta and
+// byte stream split encodings, data pages of version 1 and 2, and the codecs
+// snappy, gzip, zstd and lz4_raw.
+// Other files are rejected with an error naming what is not supported.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+var magic = []byte("PAR1")
+
+// Page types.
+const (
+	pageData       = 0
+	pageDictionary = 2
+	pageDataV2     = 3
+)
+
+// Compression codecs.
+const (
+	codecUncompressed = 0
+	codecSnappy       = 1
+	codecGzip         = 2
+	codecZstd         = 6
+	codecLz4Raw       = 7
+)
+
+var codecNames = map[int64]string{3: "LZO", 4: "BROTLI", 5: "LZ4"}
+
+// Reader reads the rows of a Parquet file one row group at a time.
+type Reader struct {
+	file      io.ReaderAt
+	columns   []*column
+	rowGroups []interface{}
+	numRows   int64
+
+	// Values of the current row group, by column.
+	group    int
+	values   [][]interface{}
+	row      int
+	groupLen int
+}
+
+// NewReader reads the metadata of a Parquet file of size bytes.
+func NewReader(file io.ReaderAt, size int64) (*Reader, error) {
+	if size < 12 {
+		return nil, fmt.Errorf("not a Parquet file, it is too short")
+	}
+	head := make([]byte, 4)
+	tail := make([]byte, 8)
+	if _, err := file.ReadAt(head, 0); err != nil {
+		return nil, err
+	}
+	if _, err := file.ReadAt(tail, size-8); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(head, magic) || !bytes.Equal(tail[4:], magic) {
+		return nil, fmt.Errorf("not a Parquet file, the magic bytes %q are missing", magic)
+	}
+
+	metaLen := int64(binary.LittleEndian.Uint32(tail))
+	if metaLen > size-12 {
+		return nil, fmt.Errorf("invalid metadata length %v", metaLen)
+	}
+	meta := make([]byte, metaLen)
+	if _, err := file.ReadAt(meta, size-8-metaLen); err != nil {
+		return nil, err
+	}
+	fields, _, err := decodeThriftStruct(meta)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+
+	columns, err := parseSchema(fields.list(2))
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		file:      file,
+		columns:   columns,
+		rowGroups: fields.list(4),
+		numRows:   fields.int(3),
+	}, nil
+}
+
+// Columns returns the names of the columns, in the order of the schema.
+func (r *Reader) Columns() []string {
+	names := make([]string, 0, len(r.columns))
+	for _, c := range r.columns {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// NumRows returns the number of rows in the file.
+func (r *Reader) NumRows() int64 {
+	return r.numRows
+}
+
+// ReadJSON returns the next row as a JSON object with the columns in schema
+// order, null for null values. It returns io.EOF after the last row.
+func (r *Reader) ReadJSON() ([]byte, error) {
+	for r.row >= r.groupLen {
+		if r.group >= len(r.rowGroups) {
+			return nil, io.EOF
+		}
+		if err := r.readRowGroup(r.group); err != nil {
+			return nil, fmt.Errorf("row group %v: %w", r.group, err)
+		}
+		r.group++
+	}
+
+	buf := bytes.NewBufferString("{")
+	for i, c := range r.columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(c.name)
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(r.values[i][r.row])
+		if err != nil {
+			return nil, fmt.Errorf("column %v: %w", c.name, err)
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	r.row++
+	return buf.Bytes(), nil
+}
+
+func (r *Reader) readRowGroup(index int) error {
+	group, _ := r.rowGroups[index].(thriftFields)
+	chunks := group.list(1)
+	if len(chunks) != len(r.columns) {
+		return fmt.Errorf("%v column chunks for %v columns", len(chunks), len(r.columns))
+	}
+	numRows := group.int(3)
+
+	values := make([][]interface{}, len(r.columns))
+	for i, c := range r.columns {
+		chunk, _ := chunks[i].(thriftFields)
+		v, err := r.readColumnChunk(c, chunk)
+		if err != nil {
+			return fmt.Errorf("column %v: %w", c.name, err)
+		}
+		if int64(len(v)) != numRows {
+			return fmt.Errorf("column %v: %v values for %v rows", c.name, len(v), numRows)
+		}
+		values[i] = v
+	}
+	r.values, r.row, r.groupLen = values, 0, int(numRows)
+	return nil
+}
+
+// readColumnChunk returns the values of a column chunk, nil for nulls.
+func (r *Reader) readColumnChunk(c *column, chunk thriftFields) ([]interface{}, error) {
+	meta := chunk.fields(3)
+	if meta == nil {
+		return nil, fmt.Errorf("columns in other files are not supported")
+	}
+	codec := meta.int(4)
+	numValues := meta.int(5)
+	offset := meta.int(9)
+	if dictOffset := meta.int(11); meta.has(11) && dictOffset > 0 && dictOffset < offset {
+		offset = dictOffset
+	}
+	size := meta.int(7)
+	if offset < 0 || size < 0 || size > 1<<31 || numValues < 0 {
+		return nil, fmt.Errorf("invalid column chunk offset %v and size %v", offset, size)
+	}
+	data := make([]byte, size)
+	if _, err := r.file.ReadAt(data, offset); err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, 0, numValues)
+	var dict []interface{}
+	for int64(len(values)) < numValues {
+		if len(data) == 0 {
+			return nil, fmt.Errorf("truncated column chunk, %v of %v values", len(values), numValues)
+		}
+		header, n, err := decodeThriftStruct(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page header: %w", err)
+		}
+		data = data[n:]
+		compressedSize := int(header.int(3))
+		uncompressedSize := int(header.int(2))
+		if compressedSize < 0 || compressedSize > len(data) || uncompressedSize < 0 {
+			return nil, fmt.Errorf("invalid page size %v", compressedSize)
+		}
+		page := data[:compressedSize]
+		data = data[compressedSize:]
+
+		switch header.int(1) {
+		case pageDictionary:
+			page, err = decompress(codec, page, uncompressedSize)
+			if err != nil {
+				return nil, err
+			}
+			dict, err = c.decodePlain(page, int(header.fields(7).int(1)))
+			if err != nil {
+				return nil, fmt.Errorf("dictionary page: %w", err)
+			}
+		case pageData:
+			h := header.fields(5)
+			page, err = decompress(codec, page, uncompressedSize)
+			if err != nil {
+				return nil, err
+			}
+			count := int(h.int(1))
+			var defs []uint32
+			if c.optional {
+				if h.int(3) != encodingRLE {
+					return nil, fmt.Errorf("definition level encoding %v is not supported", encodingName(h.int(3)))
+				}
+				if len(page) < 4 {
+					return nil, fmt.Errorf("truncated definition levels")
+				}
+				n := int(binary.LittleEndian.Uint32(page))
+				if n < 0 || n > len(page)-4 {
+					return nil, fmt.Errorf("truncated definition levels")
+				}
+				defs, err = decodeHybrid(page[4:4+n], 1, count)
+				if err != nil {
+					return nil, fmt.Errorf("definition levels: %w", err)
+				}
+				page = page[4+n:]
+			}
+			values, err = c.appendPage(values, h.int(2), page, count, defs, dict)
+			if err != nil {
+				return nil, err
+			}
+		case pageDataV2:
+			h := header.fields(8)
+			count := int(h.int(1))
+			repLen, defLen := int(h.int(6)), int(h.int(5))
+			if repLen < 0 || defLen < 0 || repLen+defLen > len(page) {
+				return nil, fmt.Errorf("invalid level lengths")
+			}
+			var defs []uint32
+			if c.optional {
+				defs, err = decodeHybrid(page[repLen:repLen+defLen], 1, count)
+				if err != nil {
+					return nil, fmt.Errorf("definition levels: %w", err)
+				}
+			}
+			page = page[repLen+defLen:]
+			if h.bool(7, true) {
+				page, err = decompress(codec, page, uncompressedSize-repLen-defLen)
+				if err != nil {
+					return nil, err
+				}
+			}
+			values, err = c.appendPage(values, h.int(4), page, count, defs, dict)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return values, nil
+}
+
+// appendPage appends the count values of a data page to values. defs are the
+// definition levels of optional columns, 0 for null.
+func (c *column) appendPage(values []interface{}, encoding int64, data []byte, count int, defs []uint32, dict []interface{}) ([]interface{}, error) {
+	present := count
+	if defs != nil {
+		present = 0
+		for _, d := range defs {
+			if d > 0 {
+				present++
+			}
+		}
+	}
+
+	var decoded []interface{}
+	var err error
+	switch encoding {
+	case encodingPlainDictionary, encodingRLEDictionary:
+		if dict == nil {
+			return nil, fmt.Errorf("dictionary encoded page without dictionary")
+		}
+		if len(data) == 0 {
+			if present > 0 {
+				return nil, fmt.Errorf("truncated dictionary indexes")
+			}
+			break
+		}
+		var indexes []uint32
+		indexes, err = decodeHybrid(data[1:], int(data[0]), present)
+		if err != nil {
+			return nil, fmt.Errorf("dictionary indexes: %w", err)
+		}
+		decoded = make([]interface{}, 0, present)
+		for _, i := range indexes {
+			if int(i) >= len(dict) {
+				return nil, fmt.Errorf("dictionary index %v out of range, the dictionary has %v values", i, len(dict))
+			}
+			decoded = append(decoded, dict[i])
+		}
+	default:
+		decoded, err = c.decodeValues(encoding, data, present)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if defs == nil {
+		return append(values, decoded...), nil
+	}
+	next := 0
+	for _, d := range defs {
+		if d == 0 {
+			values = append(values, nil)
+			continue
+		}
+		values = append(values, decoded[next])
+		next++
+	}
+	return values, nil
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+func decompress(codec int64, data []byte, size int) ([]byte, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid uncompressed page size %v", size)
+	}
+	var out []byte
+	var err error
+	switch codec {
+	case codecUncompressed:
+		out = data
+	case codecSnappy:
+		out, err = snappy.Decode(nil, data)
+	case codecGzip:
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+			out, err = ioutil.ReadAll(zr)
+		}
+	case codecZstd:
+		zstdOnce.Do(func() {
+			zstdDecoder, zstdErr = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		})
+		if zstdErr != nil {
+			return nil, zstdErr
+		}
+		out, err = zstdDecoder.DecodeAll(data, nil)
+	case codecLz4Raw:
+		out = make([]byte, size)
+		var n int
+		n, err = lz4.UncompressBlock(data, out)
+		out = out[:n]
+	default:
+		name, ok := codecNames[codec]
+		if !ok {
+			name = fmt.Sprint(codec)
+		}
+		return nil, fmt.Errorf("compression codec %v is not supported", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress page: %w", err)
+	}
+	if len(out) != size {
+		return nil, errors.New("unable to decompress page: size differs from the page header")
+	}
+	return out, nil
+}
@@ -0,0 +1,190 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func openFile(t *testing.T, name string) (*Reader, error) {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	info, err := f.Stat()
+	require.NoError(t, err)
+	return NewReader(f, info.Size())
+}
+
+func readAll(t *testing.T, name string) []string {
+	t.Helper()
+	r, err := openFile(t, name)
+	require.NoError(t, err)
+	var rows []string
+	for {
+		row, err := r.ReadJSON()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		rows = append(rows, string(row))
+	}
+	require.EqualValues(t, r.NumRows(), len(rows))
+	return rows
+}
+
+// expectedRow returns row i of the rows_*.parquet files, see
+// testdata/generate.
+func expectedRow(i int) string {
+	var email, ratio interface{}
+	if i%3 != 0 {
+		email = fmt.Sprintf("u%d@example.com", i)
+	}
+	if i%5 != 0 {
+		ratio = float32(i) / 8
+	}
+	fixed := []byte{byte(i), byte(i + 1), byte(i + 2), byte(i + 3)}
+	values := []struct {
+		name  string
+		value interface{}
+	}{
+		{"id", int64(i)*1000003 - 5000},
+		{"name", fmt.Sprintf("user-%d", i%7)},
+		{"email", email},
+		{"active", i%2 == 0},
+		{"score", float64(i) / 4},
+		{"ratio", ratio},
+		{"amount", big.NewRat(int64(i)*137-999, 100).FloatString(2)},
+		{"created", time.UnixMilli(1700000000000 + int64(i)*1001).UTC().Format(time.RFC3339Nano)},
+		{"day", time.Unix(int64(19000+i)*86400, 0).UTC().Format("2006-01-02")},
+		{"fixed", fixed},
+		{"count", uint32(i) * 30000000},
+		{"meta", json.RawMessage(fmt.Sprintf(`{"i":%d}`, i))},
+		{"status", []string{"OK", "FAILED"}[i%2]},
+		{"blob", []byte{byte(i), byte(i >> 8), 0xff}},
+		{"seq", int64(i*i) - 100},
+		{"code", fmt.Sprintf("code-%03d", i/3)},
+		{"split", float64(i) * -1.5},
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for j, v := range values {
+		if j > 0 {
+			buf.WriteByte(',')
+		}
+		value, _ := json.Marshal(v.value)
+		fmt.Fprintf(&buf, "%q:%s", v.name, value)
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+func TestReadJSON(t *testing.T) {
+	for _, codec := range []string{"snappy", "gzip", "zstd", "lz4_raw"} {
+		t.Run(codec, func(t *testing.T) {
+			rows := readAll(t, "rows_"+codec+".parquet")
+			require.Len(t, rows, 30)
+			for i, row := range rows {
+				require.Equal(t, expectedRow(i), row, "row %v", i)
+			}
+		})
+	}
+}
+
+func TestReadJSONColumns(t *testing.T) {
+	r, err := openFile(t, "rows_snappy.parquet")
+	require.NoError(t, err)
+	require.Equal(t, []string{"id", "name", "email", "active", "score", "ratio", "amount", "created", "day",
+		"fixed", "count", "meta", "status", "blob", "seq", "code", "split"}, r.Columns())
+	require.EqualValues(t, 30, r.NumRows())
+}
+
+// The files of the Apache parquet-testing repository cover version 1 data
+// pages and the converted types of older writers.
+func TestReadJSONParquetTesting(t *testing.T) {
+	tests := []struct {
+		file  string
+		rows  int
+		first string
+		last  string
+	}{
+		{
+			file:  "alltypes_plain.parquet",
+			rows:  8,
+			first: `{"id":4,"bool_col":true,"tinyint_col":0,"smallint_col":0,"int_col":0,"bigint_col":0,"float_col":0,"double_col":0,"date_string_col":"MDMvMDEvMDk=","string_col":"MA==","timestamp_col":"2009-03-01T00:00:00Z"}`,
+		},
+		{
+			file:  "alltypes_plain.snappy.parquet",
+			rows:  2,
+			first: `{"id":6,"bool_col":true,"tinyint_col":0,"smallint_col":0,"int_col":0,"bigint_col":0,"float_col":0,"double_col":0,"date_string_col":"MDQvMDEvMDk=","string_col":"MA==","timestamp_col":"2009-04-01T00:00:00Z"}`,
+			last:  `{"id":7,"bool_col":false,"tinyint_col":1,"smallint_col":1,"int_col":1,"bigint_col":10,"float_col":1.1,"double_col":10.1,"date_string_col":"MDQvMDEvMDk=","string_col":"MQ==","timestamp_col":"2009-04-01T00:01:00Z"}`,
+		},
+		{
+			file:  "alltypes_dictionary.parquet",
+			rows:  2,
+			first: `{"id":0,"bool_col":true,"tinyint_col":0,"smallint_col":0,"int_col":0,"bigint_col":0,"float_col":0,"double_col":0,"date_string_col":"MDEvMDEvMDk=","string_col":"MA==","timestamp_col":"2009-01-01T00:00:00Z"}`,
+			last:  `{"id":1,"bool_col":false,"tinyint_col":1,"smallint_col":1,"int_col":1,"bigint_col":10,"float_col":1.1,"double_col":10.1,"date_string_col":"MDEvMDEvMDk=","string_col":"MQ==","timestamp_col":"2009-01-01T00:01:00Z"}`,
+		},
+		{
+			file:  "rle_boolean_encoding.parquet",
+			rows:  68,
+			first: `{"datatype_boolean":true}`,
+		},
+		{
+			file:  "fixed_length_decimal.parquet",
+			rows:  24,
+			first: `{"value":"1.00"}`,
+			last:  `{"value":"24.00"}`,
+		},
+		{
+			file:  "delta_length_byte_array.parquet",
+			rows:  1000,
+			first: `{"FRUIT":"apple_banana_mango0"}`,
+			last:  `{"FRUIT":"apple_banana_mango998001"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			rows := readAll(t, tt.file)
+			require.Len(t, rows, tt.rows)
+			require.Equal(t, tt.first, rows[0])
+			if tt.last != "" {
+				require.Equal(t, tt.last, rows[len(rows)-1])
+			}
+		})
+	}
+
+	rows := readAll(t, "rle_boolean_encoding.parquet")
+	require.Equal(t, []string{`{"datatype_boolean":true}`, `{"datatype_boolean":false}`, `{"datatype_boolean":null}`}, rows[:3])
+}
+
+func TestNewReaderErrors(t *testing.T) {
+	_, err := openFile(t, "nested.parquet")
+	require.EqualError(t, err, "nested columns are not supported, only flat schemas of primitive columns")
+
+	_, err = NewReader(strings.NewReader("PAR1 not parquet"), 16)
+	require.EqualError(t, err, `not a Parquet file, the magic bytes "PAR1" are missing`)
+
+	_, err = NewReader(strings.NewReader("PAR1"), 4)
+	require.EqualError(t, err, "not a Parquet file, it is too short")
+}
+
+func TestDecodeHybrid(t *testing.T) {
+	// A run of 3 times the value 5, then a bit packed group of 8 values.
+	data := []byte{3 << 1, 5, 1<<1 | 1, 0x88, 0xc6, 0xfa}
+	values, err := decodeHybrid(data, 3, 11)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{5, 5, 5, 0, 1, 2, 3, 4, 5, 6, 7}, values)
+
+	_, err = decodeHybrid(data[:4], 3, 11)
+	require.Error(t, err)
+}
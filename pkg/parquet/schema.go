@@ -0,0 +1,169 @@
+package parquet
+
+import "fmt"
+
+// Physical types.
+const (
+	typeBoolean           = 0
+	typeInt32             = 1
+	typeInt64             = 2
+	typeInt96             = 3
+	typeFloat             = 4
+	typeDouble            = 5
+	typeByteArray         = 6
+	typeFixedLenByteArray = 7
+)
+
+// Repetition types.
+const (
+	repetitionRequired = 0
+	repetitionOptional = 1
+	repetitionRepeated = 2
+)
+
+// kind is what a column value is rendered as in JSON, from the logical or
+// converted type of the column.
+type kind int
+
+const (
+	kindPlain kind = iota
+	kindString
+	kindJSON
+	kindDate
+	kindTime
+	kindTimestamp
+	kindDecimal
+	kindUnsigned
+	kindUUID
+)
+
+// Time units of time and timestamp columns.
+const (
+	unitMillis = iota
+	unitMicros
+	unitNanos
+)
+
+// column is a top level column of a flat schema.
+type column struct {
+	name       string
+	typ        int64
+	typeLength int
+	optional   bool
+	kind       kind
+	unit       int
+	scale      int
+}
+
+// parseSchema returns the columns of a flat schema. The first element is the
+// root, its children must be primitive columns that are not repeated.
+func parseSchema(elements []interface{}) ([]*column, error) {
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("file has no schema")
+	}
+	root, _ := elements[0].(thriftFields)
+	if int(root.int(5)) != len(elements)-1 {
+		return nil, fmt.Errorf("nested columns are not supported, only flat schemas of primitive columns")
+	}
+
+	columns := make([]*column, 0, len(elements)-1)
+	for _, e := range elements[1:] {
+		element, _ := e.(thriftFields)
+		name := element.string(4)
+		if element.int(5) > 0 || !element.has(1) {
+			return nil, fmt.Errorf("column %v: nested columns are not supported, only flat schemas of primitive columns", name)
+		}
+		if element.int(3) == repetitionRepeated {
+			return nil, fmt.Errorf("column %v: repeated columns are not supported", name)
+		}
+		c := &column{
+			name:       name,
+			typ:        element.int(1),
+			typeLength: int(element.int(2)),
+			optional:   element.int(3) == repetitionOptional,
+		}
+		if c.typ < typeBoolean || c.typ > typeFixedLenByteArray {
+			return nil, fmt.Errorf("column %v: invalid type %v", name, c.typ)
+		}
+		if c.typ == typeFixedLenByteArray && c.typeLength <= 0 {
+			return nil, fmt.Errorf("column %v: invalid fixed length %v", name, c.typeLength)
+		}
+		if logical := element.fields(10); logical != nil {
+			c.setLogicalType(logical)
+		} else if element.has(6) {
+			c.setConvertedType(element.int(6), int(element.int(7)))
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+func (c *column) setLogicalType(logical thriftFields) {
+	id := logical.unionField()
+	fields := logical.fields(id)
+	switch id {
+	case 1, 4: // STRING, ENUM
+		c.kind = kindString
+	case 5: // DECIMAL
+		c.kind = kindDecimal
+		c.scale = nonNegative(int(fields.int(1)))
+	case 6: // DATE
+		c.kind = kindDate
+	case 7, 8: // TIME, TIMESTAMP
+		c.kind = kindTime
+		if id == 8 {
+			c.kind = kindTimestamp
+		}
+		switch fields.fields(2).unionField() {
+		case 1:
+			c.unit = unitMillis
+		case 2:
+			c.unit = unitMicros
+		default:
+			c.unit = unitNanos
+		}
+	case 10: // INTEGER
+		if !fields.bool(2, true) {
+			c.kind = kindUnsigned
+		}
+	case 12: // JSON
+		c.kind = kindJSON
+	case 14: // UUID
+		c.kind = kindUUID
+	}
+}
+
+// setConvertedType applies the converted type of files written before
+// logical types existed.
+func (c *column) setConvertedType(converted int64, scale int) {
+	switch converted {
+	case 0, 4: // UTF8, ENUM
+		c.kind = kindString
+	case 5: // DECIMAL
+		c.kind = kindDecimal
+		c.scale = nonNegative(scale)
+	case 6: // DATE
+		c.kind = kindDate
+	case 7, 8: // TIME_MILLIS, TIME_MICROS
+		c.kind, c.unit = kindTime, unitMillis
+		if converted == 8 {
+			c.unit = unitMicros
+		}
+	case 9, 10: // TIMESTAMP_MILLIS, TIMESTAMP_MICROS
+		c.kind, c.unit = kindTimestamp, unitMillis
+		if converted == 10 {
+			c.unit = unitMicros
+		}
+	case 11, 12, 13, 14: // UINT_8 to UINT_64
+		c.kind = kindUnsigned
+	case 19: // JSON
+		c.kind = kindJSON
+	}
+}
+
+func nonNegative(n int) int {
+	if n < 0 {
+		return 0
+	}
+	return n
+}
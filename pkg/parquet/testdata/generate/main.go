@@ -0,0 +1,116 @@
+// Command generate writes the rows_*.parquet and nested.parquet test files
+// with parquet-go, which needs a newer Go than kaf and is not a dependency.
+// Run it from a scratch module:
+//
+//	go mod init generate && go get github.com/parquet-go/parquet-go@v0.20.0
+//	go run -tags purego . && mv *.parquet ..
+//
+// The files use data pages of version 2, parquet-go v0.20.0 writes invalid
+// definition levels into version 1 pages.
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Row is a row of the rows_*.parquet files. TestReadJSON computes the same
+// values.
+type Row struct {
+	ID      int64     `parquet:"id"`
+	Name    string    `parquet:"name,dict"`
+	Email   *string   `parquet:"email,optional"`
+	Active  bool      `parquet:"active"`
+	Score   float64   `parquet:"score"`
+	Ratio   *float32  `parquet:"ratio,optional"`
+	Amount  int64     `parquet:"amount,decimal(2:18)"`
+	Created time.Time `parquet:"created,timestamp(millisecond)"`
+	Day     int32     `parquet:"day,date"`
+	Fixed   [4]byte   `parquet:"fixed"`
+	Count   uint32    `parquet:"count"`
+	Meta    string    `parquet:"meta,json"`
+	Status  string    `parquet:"status,enum,dict"`
+	Blob    []byte    `parquet:"blob"`
+	Seq     int64     `parquet:"seq,delta"`
+	Code    string    `parquet:"code,delta"`
+	Split   float64   `parquet:"split,split"`
+}
+
+func row(i int) Row {
+	r := Row{
+		ID:      int64(i)*1000003 - 5000,
+		Name:    fmt.Sprintf("user-%d", i%7),
+		Active:  i%2 == 0,
+		Score:   float64(i) / 4,
+		Amount:  int64(i)*137 - 999,
+		Created: time.UnixMilli(1700000000000 + int64(i)*1001).UTC(),
+		Day:     19000 + int32(i),
+		Count:   uint32(i) * 30000000,
+		Meta:    fmt.Sprintf(`{"i":%d}`, i),
+		Status:  []string{"OK", "FAILED"}[i%2],
+		Blob:    []byte{byte(i), byte(i >> 8), 0xff},
+		Seq:     int64(i*i) - 100,
+		Code:    fmt.Sprintf("code-%03d", i/3),
+		Split:   float64(i) * -1.5,
+	}
+	for j := range r.Fixed {
+		r.Fixed[j] = byte(i + j)
+	}
+	if i%3 != 0 {
+		email := fmt.Sprintf("u%d@example.com", i)
+		r.Email = &email
+	}
+	if i%5 != 0 {
+		ratio := float32(i) / 8
+		r.Ratio = &ratio
+	}
+	return r
+}
+
+// write writes 30 rows in row groups of 20 rows and small pages, so that
+// columns span several pages and row groups.
+func write(name string, codec parquet.WriterOption) {
+	f, err := os.Create(name)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	w := parquet.NewGenericWriter[Row](f, codec, parquet.DataPageVersion(2), parquet.PageBufferSize(256), parquet.MaxRowsPerRowGroup(20))
+	rows := make([]Row, 30)
+	for i := range rows {
+		rows[i] = row(i)
+	}
+	if _, err := w.Write(rows); err != nil {
+		panic(err)
+	}
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+}
+
+type Nested struct {
+	Tags []string `parquet:"tags,list"`
+}
+
+func main() {
+	write("rows_snappy.parquet", parquet.Compression(&parquet.Snappy))
+	write("rows_gzip.parquet", parquet.Compression(&parquet.Gzip))
+	write("rows_zstd.parquet", parquet.Compression(&parquet.Zstd))
+	write("rows_lz4_raw.parquet", parquet.Compression(&parquet.Lz4Raw))
+
+	f, err := os.Create("nested.parquet")
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	w := parquet.NewGenericWriter[Nested](f)
+	if _, err := w.Write([]Nested{{Tags: []string{"a"}}}); err != nil {
+		panic(err)
+	}
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+}
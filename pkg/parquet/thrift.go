@@ -0,0 +1,269 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Parquet metadata is encoded with the Thrift compact protocol. A generic
+// decoder is enough to read it: structs are decoded into their fields by id,
+// and the few fields the reader needs are picked from them.
+
+const (
+	thriftStop   = 0
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI16    = 4
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftDouble = 7
+	thriftBinary = 8
+	thriftList   = 9
+	thriftSet    = 10
+	thriftMap    = 11
+	thriftStruct = 12
+)
+
+// maxThriftDepth limits the nesting of structs and lists, parquet metadata is
+// no more than a few levels deep.
+const maxThriftDepth = 32
+
+var errThriftTruncated = errors.New("truncated thrift data")
+
+// thriftFields is a decoded struct. Values are int64 for all integers, bool,
+// float64, []byte, thriftFields for structs and []interface{} for lists and
+// sets. Maps are skipped.
+type thriftFields map[int16]interface{}
+
+func (f thriftFields) has(id int16) bool {
+	_, ok := f[id]
+	return ok
+}
+
+func (f thriftFields) int(id int16) int64 {
+	v, _ := f[id].(int64)
+	return v
+}
+
+func (f thriftFields) bool(id int16, def bool) bool {
+	if v, ok := f[id].(bool); ok {
+		return v
+	}
+	return def
+}
+
+func (f thriftFields) string(id int16) string {
+	v, _ := f[id].([]byte)
+	return string(v)
+}
+
+func (f thriftFields) fields(id int16) thriftFields {
+	v, _ := f[id].(thriftFields)
+	return v
+}
+
+func (f thriftFields) list(id int16) []interface{} {
+	v, _ := f[id].([]interface{})
+	return v
+}
+
+// unionField returns the id of the set field of a union, 0 if none is set.
+func (f thriftFields) unionField() int16 {
+	for id := range f {
+		return id
+	}
+	return 0
+}
+
+// thriftDecoder decodes compact protocol values from a byte slice.
+type thriftDecoder struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+// decodeThriftStruct decodes the struct at the start of data and returns it
+// with the number of bytes it takes.
+func decodeThriftStruct(data []byte) (thriftFields, int, error) {
+	d := &thriftDecoder{data: data}
+	fields, err := d.readStruct()
+	if err != nil {
+		return nil, 0, err
+	}
+	return fields, d.pos, nil
+}
+
+func (d *thriftDecoder) readByte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, errThriftTruncated
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *thriftDecoder) readUvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		return 0, errThriftTruncated
+	}
+	d.pos += n
+	return v, nil
+}
+
+func (d *thriftDecoder) readVarint() (int64, error) {
+	v, err := d.readUvarint()
+	if err != nil {
+		return 0, err
+	}
+	// Zigzag encoding.
+	return int64(v>>1) ^ -int64(v&1), nil
+}
+
+func (d *thriftDecoder) readStruct() (thriftFields, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxThriftDepth {
+		return nil, fmt.Errorf("thrift data nested too deeply")
+	}
+
+	fields := make(thriftFields)
+	var id int16
+	for {
+		header, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		typ := header & 0x0f
+		if typ == thriftStop {
+			return fields, nil
+		}
+		if delta := header >> 4; delta != 0 {
+			id += int16(delta)
+		} else {
+			v, err := d.readVarint()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+
+		var value interface{}
+		switch typ {
+		case thriftTrue:
+			value = true
+		case thriftFalse:
+			value = false
+		default:
+			value, err = d.readValue(typ)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if value != nil {
+			fields[id] = value
+		}
+	}
+}
+
+// readValue reads a value of typ other than the booleans of struct fields,
+// which are part of the field header.
+func (d *thriftDecoder) readValue(typ byte) (interface{}, error) {
+	switch typ {
+	case thriftTrue, thriftFalse:
+		// Booleans of lists take a byte each.
+		b, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		return b == thriftTrue, nil
+	case thriftByte:
+		b, err := d.readByte()
+		return int64(int8(b)), err
+	case thriftI16, thriftI32, thriftI64:
+		return d.readVarint()
+	case thriftDouble:
+		if len(d.data)-d.pos < 8 {
+			return nil, errThriftTruncated
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(d.data[d.pos:]))
+		d.pos += 8
+		return v, nil
+	case thriftBinary:
+		n, err := d.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(len(d.data)-d.pos) {
+			return nil, errThriftTruncated
+		}
+		v := d.data[d.pos : d.pos+int(n)]
+		d.pos += int(n)
+		return v, nil
+	case thriftList, thriftSet:
+		return d.readList()
+	case thriftMap:
+		return nil, d.skipMap()
+	case thriftStruct:
+		return d.readStruct()
+	}
+	return nil, fmt.Errorf("invalid thrift type %v", typ)
+}
+
+func (d *thriftDecoder) readList() ([]interface{}, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxThriftDepth {
+		return nil, fmt.Errorf("thrift data nested too deeply")
+	}
+
+	header, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	size := uint64(header >> 4)
+	if size == 15 {
+		if size, err = d.readUvarint(); err != nil {
+			return nil, err
+		}
+	}
+	// Every element takes at least a byte.
+	if size > uint64(len(d.data)-d.pos) {
+		return nil, errThriftTruncated
+	}
+	list := make([]interface{}, 0, size)
+	for i := uint64(0); i < size; i++ {
+		v, err := d.readValue(header & 0x0f)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+func (d *thriftDecoder) skipMap() error {
+	size, err := d.readUvarint()
+	if err != nil || size == 0 {
+		return err
+	}
+	if size > uint64(len(d.data)-d.pos) {
+		return errThriftTruncated
+	}
+	types, err := d.readByte()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < size; i++ {
+		if _, err := d.readValue(types >> 4); err != nil {
+			return err
+		}
+		if _, err := d.readValue(types & 0x0f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
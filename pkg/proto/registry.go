@@ -0,0 +1,237 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+// ErrNotProtobuf is returned by RegistryDecoder.Decode for messages that are
+// not encoded with a Protobuf schema of the registry.
+var ErrNotProtobuf = errors.New("not a registry Protobuf message")
+
+// ErrSchemaNotFound is returned by RegistryDecoder.Decode for schema IDs
+// unknown to the registry.
+var ErrSchemaNotFound = errors.New("schema not found in the registry")
+
+var errNotFound = errors.New("not found")
+
+type schemaReference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+type registrySchema struct {
+	Schema     string            `json:"schema"`
+	SchemaType string            `json:"schemaType"`
+	References []schemaReference `json:"references"`
+}
+
+type cachedDescriptor struct {
+	done chan struct{}
+	// fd is nil for schemas that are not Protobuf.
+	fd  *desc.FileDescriptor
+	err error
+}
+
+// RegistryDecoder decodes Protobuf messages in the Confluent wire format into
+// JSON with field names, using the schemas and their references from a
+// Confluent schema registry.
+type RegistryDecoder struct {
+	url                string
+	encodedCredentials string
+	client             *http.Client
+
+	mu          sync.Mutex
+	descriptors map[int]*cachedDescriptor
+}
+
+// NewRegistryDecoder returns a decoder using the schema registry at url.
+func NewRegistryDecoder(url string, username string, password string) *RegistryDecoder {
+	d := &RegistryDecoder{
+		url:         strings.TrimSuffix(url, "/"),
+		client:      http.DefaultClient,
+		descriptors: make(map[int]*cachedDescriptor),
+	}
+	if username != "" {
+		d.encodedCredentials = base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	}
+	return d
+}
+
+// Decode returns the JSON representation of a Protobuf message in the
+// Confluent wire format: a zero byte, the big endian schema ID, the message
+// indexes of the message type in the schema and the Protobuf payload.
+func (d *RegistryDecoder) Decode(b []byte) ([]byte, error) {
+	if len(b) < 6 || b[0] != 0x00 {
+		return nil, ErrNotProtobuf
+	}
+	schemaID := int(binary.BigEndian.Uint32(b[1:5]))
+	fd, err := d.descriptor(schemaID)
+	if err != nil {
+		return nil, err
+	}
+	if fd == nil {
+		return nil, ErrNotProtobuf
+	}
+
+	indexes, payload, err := readMessageIndexes(b[5:])
+	if err != nil {
+		return nil, err
+	}
+	md, err := messageForIndexes(fd, indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dynamic.NewMessage(md)
+	if err := msg.Unmarshal(payload); err != nil {
+		return nil, err
+	}
+	var m jsonpb.Marshaler
+	var w bytes.Buffer
+	if err := m.Marshal(&w, msg); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// readMessageIndexes reads the zigzag varint encoded message indexes. A
+// single zero stands for the first message of the schema.
+func readMessageIndexes(b []byte) ([]int, []byte, error) {
+	count, n := binary.Varint(b)
+	if n <= 0 || count < 0 {
+		return nil, nil, fmt.Errorf("invalid message indexes")
+	}
+	b = b[n:]
+	if count == 0 {
+		return []int{0}, b, nil
+	}
+	indexes := make([]int, 0, count)
+	for i := int64(0); i < count; i++ {
+		index, n := binary.Varint(b)
+		if n <= 0 || index < 0 {
+			return nil, nil, fmt.Errorf("invalid message indexes")
+		}
+		indexes = append(indexes, int(index))
+		b = b[n:]
+	}
+	return indexes, b, nil
+}
+
+func messageForIndexes(fd *desc.FileDescriptor, indexes []int) (*desc.MessageDescriptor, error) {
+	messages := fd.GetMessageTypes()
+	var md *desc.MessageDescriptor
+	for _, index := range indexes {
+		if index >= len(messages) {
+			return nil, fmt.Errorf("message index %v not found in schema %v", indexes, fd.GetName())
+		}
+		md = messages[index]
+		messages = md.GetNestedMessageTypes()
+	}
+	return md, nil
+}
+
+// descriptor returns the parsed schema with the given ID, or nil if it is not
+// a Protobuf schema. Schemas are fetched once per ID.
+func (d *RegistryDecoder) descriptor(schemaID int) (*desc.FileDescriptor, error) {
+	d.mu.Lock()
+	cd, ok := d.descriptors[schemaID]
+	if !ok {
+		cd = &cachedDescriptor{done: make(chan struct{})}
+		d.descriptors[schemaID] = cd
+	}
+	d.mu.Unlock()
+
+	if ok {
+		<-cd.done
+		return cd.fd, cd.err
+	}
+
+	// Any failure is permanent per schema ID.
+	cd.fd, cd.err = d.fetchDescriptor(schemaID)
+	close(cd.done)
+	return cd.fd, cd.err
+}
+
+func (d *RegistryDecoder) fetchDescriptor(schemaID int) (*desc.FileDescriptor, error) {
+	var schema registrySchema
+	if err := d.get(fmt.Sprintf("/schemas/ids/%d", schemaID), &schema); err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, fmt.Errorf("schema %d: %w", schemaID, ErrSchemaNotFound)
+		}
+		return nil, err
+	}
+	if schema.SchemaType != "PROTOBUF" {
+		return nil, nil
+	}
+
+	files := make(map[string]string)
+	if err := d.resolveReferences(schema.References, files); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("schema-%d.proto", schemaID)
+	files[name] = schema.Schema
+
+	parser := protoparse.Parser{Accessor: protoparse.FileContentsFromMap(files)}
+	fds, err := parser.ParseFiles(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse Protobuf schema %v: %w", schemaID, err)
+	}
+	return fds[0], nil
+}
+
+// resolveReferences fetches the referenced schemas and their references into
+// files, by the import name used in the referencing schema.
+func (d *RegistryDecoder) resolveReferences(references []schemaReference, files map[string]string) error {
+	for _, ref := range references {
+		if _, ok := files[ref.Name]; ok {
+			continue
+		}
+		var schema registrySchema
+		if err := d.get(fmt.Sprintf("/subjects/%s/versions/%d", url.PathEscape(ref.Subject), ref.Version), &schema); err != nil {
+			return fmt.Errorf("unable to resolve reference %v: %w", ref.Name, err)
+		}
+		files[ref.Name] = schema.Schema
+		if err := d.resolveReferences(schema.References, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *RegistryDecoder) get(path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, d.url+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if d.encodedCredentials != "" {
+		req.Header.Set("Authorization", "Basic "+d.encodedCredentials)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("schema registry returned %v for %v: %w", resp.Status, path, errNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("schema registry returned %v for %v", resp.Status, path)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,112 @@
+package proto
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	customerSchema = `syntax = "proto3";
+package shop;
+message Customer {
+  string name = 1;
+}`
+	orderSchema = `syntax = "proto3";
+package shop;
+import "customer.proto";
+message Order {
+  string id = 1;
+  Customer customer = 2;
+  message Line {
+    string sku = 1;
+    int64 quantity = 2;
+  }
+}`
+)
+
+func TestRegistryDecoder(t *testing.T) {
+	var fetches int
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		require.Equal(t, "user", user)
+		require.Equal(t, "secret", pass)
+		fetches++
+		switch r.URL.Path {
+		case "/schemas/ids/7":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"schemaType": "PROTOBUF",
+				"schema":     orderSchema,
+				"references": []map[string]interface{}{{"name": "customer.proto", "subject": "customer", "version": 1}},
+			})
+		case "/subjects/customer/versions/1":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"schemaType": "PROTOBUF", "schema": customerSchema})
+		case "/schemas/ids/8":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"schema": `{"type":"string"}`})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer registry.Close()
+
+	fds, err := (&protoparse.Parser{Accessor: protoparse.FileContentsFromMap(map[string]string{
+		"order.proto":    orderSchema,
+		"customer.proto": customerSchema,
+	})}).ParseFiles("order.proto")
+	require.NoError(t, err)
+	orderType := fds[0].FindMessage("shop.Order")
+
+	customer := dynamic.NewMessage(orderType.FindFieldByName("customer").GetMessageType())
+	customer.SetFieldByName("name", "Ada")
+	order := dynamic.NewMessage(orderType)
+	order.SetFieldByName("id", "o-1")
+	order.SetFieldByName("customer", customer)
+	orderBytes, err := order.Marshal()
+	require.NoError(t, err)
+
+	line := dynamic.NewMessage(orderType.GetNestedMessageTypes()[0])
+	line.SetFieldByName("sku", "apple")
+	line.SetFieldByName("quantity", int64(3))
+	lineBytes, err := line.Marshal()
+	require.NoError(t, err)
+
+	d := NewRegistryDecoder(registry.URL+"/", "user", "secret")
+
+	// The first message of the schema is encoded as a single zero index.
+	decoded, err := d.Decode(wireFormat(7, []int64{0}, orderBytes))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id":"o-1","customer":{"name":"Ada"}}`, string(decoded))
+
+	decoded, err = d.Decode(wireFormat(7, []int64{2, 0, 0}, lineBytes))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"sku":"apple","quantity":"3"}`, string(decoded))
+	require.Equal(t, 2, fetches, "schemas are cached per ID")
+
+	_, err = d.Decode(wireFormat(8, []int64{0}, []byte("x")))
+	require.ErrorIs(t, err, ErrNotProtobuf)
+	_, err = d.Decode([]byte("plain text"))
+	require.ErrorIs(t, err, ErrNotProtobuf)
+	_, err = d.Decode(wireFormat(7, []int64{2, 5, 0}, lineBytes))
+	require.Error(t, err)
+	_, err = d.Decode(wireFormat(9, []int64{0}, []byte("x")))
+	require.ErrorIs(t, err, ErrSchemaNotFound)
+}
+
+// wireFormat encodes payload in the Confluent wire format. indexes starts
+// with the count of message indexes, a single 0 stands for [0].
+func wireFormat(schemaID uint32, indexes []int64, payload []byte) []byte {
+	b := []byte{0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], schemaID)
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, index := range indexes {
+		n := binary.PutVarint(buf, index)
+		b = append(b, buf[:n]...)
+	}
+	return append(b, payload...)
+}